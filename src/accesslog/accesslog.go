@@ -0,0 +1,108 @@
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record describes a single request handled by the HTTP server.
+type Record struct {
+	Time          time.Time
+	Method        string
+	Path          string
+	Protocol      string
+	Status        int
+	BytesIn       int64
+	BytesOut      int64
+	Duration      time.Duration
+	Principal     string
+	TraceId       string
+	RemoteAddress string
+	UserAgent     string
+	Referer       string
+}
+
+// Logger writes one line per record in the configured format. It is
+// independent of the application logger, so it is neither filtered by level
+// nor exported with OpenTelemetry.
+type Logger struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	format formatter
+}
+
+// NewLogger writes the records to stdout if output is "stdout", or else to the
+// file at output, which is rotated once it grows beyond maxSize bytes keeping
+// at most maxBackups rotated files.
+func NewLogger(format string, output string, maxSize int64, maxBackups int) (*Logger, error) {
+	formatter, err := newFormatter(format)
+	if err != nil {
+		return nil, err
+	}
+
+	if output == "stdout" {
+		return &Logger{
+			writer: os.Stdout,
+			format: formatter,
+		}, nil
+	}
+
+	file, err := newRotatingFile(output, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Logger{
+		writer: file,
+		format: formatter,
+	}, nil
+}
+
+// NewWriterLogger writes the records to writer.
+func NewWriterLogger(format string, writer io.Writer) (*Logger, error) {
+	formatter, err := newFormatter(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Logger{
+		writer: writer,
+		format: formatter,
+	}, nil
+}
+
+func (logger *Logger) Log(record *Record) error {
+	line := logger.format(record)
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	_, err := logger.writer.Write(line)
+	return err
+}
+
+// Close closes the file the records are written to, if any.
+func (logger *Logger) Close() error {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if closer, ok := logger.writer.(*rotatingFile); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func newFormatter(format string) (formatter, error) {
+	switch format {
+	case "json":
+		return formatJson, nil
+	case "logfmt":
+		return formatLogfmt, nil
+	case "combined":
+		return formatCombined, nil
+	default:
+		return nil, fmt.Errorf("unsupported access log format: %s", format)
+	}
+}
@@ -0,0 +1,127 @@
+//go:build unit
+
+package accesslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/accesslog"
+)
+
+func newRecord() *accesslog.Record {
+	return &accesslog.Record{
+		Time:          time.Date(2024, time.March, 1, 12, 30, 45, 0, time.UTC),
+		Method:        "GET",
+		Path:          "/images/cat.png",
+		Protocol:      "HTTP/1.1",
+		Status:        200,
+		BytesIn:       0,
+		BytesOut:      1024,
+		Duration:      1500 * time.Microsecond,
+		Principal:     "cdn",
+		TraceId:       "4bf92f3577b34da6a3ce929d0e0e4736",
+		RemoteAddress: "10.0.0.1:51234",
+		UserAgent:     "curl/8.5.0",
+	}
+}
+
+func logRecord(t *testing.T, format string, record *accesslog.Record) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	logger, err := accesslog.NewWriterLogger(format, &buffer)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := logger.Log(record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return buffer.String()
+}
+
+func TestJsonFormatShouldWriteAllFields(t *testing.T) {
+	line := logRecord(t, "json", newRecord())
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := map[string]any{
+		"time":           "2024-03-01T12:30:45Z",
+		"method":         "GET",
+		"path":           "/images/cat.png",
+		"status":         float64(200),
+		"bytes_in":       float64(0),
+		"bytes_out":      float64(1024),
+		"duration_ms":    1.5,
+		"principal":      "cdn",
+		"trace_id":       "4bf92f3577b34da6a3ce929d0e0e4736",
+		"remote_address": "10.0.0.1:51234",
+		"user_agent":     "curl/8.5.0",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Fatalf("Expected %s to be %v, got %v", key, value, fields[key])
+		}
+	}
+}
+
+func TestLogfmtFormatShouldQuoteValues(t *testing.T) {
+	record := newRecord()
+	record.UserAgent = "Mozilla/5.0 (X11)"
+	record.Principal = ""
+
+	line := logRecord(t, "logfmt", record)
+
+	for _, pair := range []string{"method=GET", "status=200", "duration_ms=1.5", `user_agent="Mozilla/5.0 (X11)"`, `principal=""`} {
+		if !strings.Contains(line, pair) {
+			t.Fatalf("Expected %s in %s", pair, line)
+		}
+	}
+}
+
+func TestCombinedFormatShouldWriteApacheCombinedLine(t *testing.T) {
+	line := logRecord(t, "combined", newRecord())
+
+	expected := `10.0.0.1 - cdn [01/Mar/2024:12:30:45 +0000] "GET /images/cat.png HTTP/1.1" 200 1024 "-" "curl/8.5.0"` + "\n"
+	if line != expected {
+		t.Fatalf("Expected %s, got %s", expected, line)
+	}
+}
+
+func TestNewLoggerShouldRejectUnknownFormat(t *testing.T) {
+	if _, err := accesslog.NewWriterLogger("xml", &bytes.Buffer{}); err == nil {
+		t.Fatalf("Expected an error")
+	}
+}
+
+func TestFileLoggerShouldRotateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, err := accesslog.NewLogger("combined", path, 200, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for range 5 {
+		if err := logger.Log(newRecord()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	logger.Close()
+
+	current, _ := os.ReadFile(path)
+	backup, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("Expected a rotated file, got %v", err)
+	}
+	if len(current) > 200 || len(backup) > 200 {
+		t.Fatalf("Expected files of at most 200 bytes, got %d and %d", len(current), len(backup))
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatalf("Expected at most 1 rotated file")
+	}
+}
@@ -0,0 +1,133 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type formatter func(record *Record) []byte
+
+type jsonRecord struct {
+	Time          string  `json:"time"`
+	Method        string  `json:"method"`
+	Path          string  `json:"path"`
+	Protocol      string  `json:"protocol"`
+	Status        int     `json:"status"`
+	BytesIn       int64   `json:"bytes_in"`
+	BytesOut      int64   `json:"bytes_out"`
+	DurationMs    float64 `json:"duration_ms"`
+	Principal     string  `json:"principal,omitempty"`
+	TraceId       string  `json:"trace_id,omitempty"`
+	RemoteAddress string  `json:"remote_address"`
+	UserAgent     string  `json:"user_agent,omitempty"`
+	Referer       string  `json:"referer,omitempty"`
+}
+
+func formatJson(record *Record) []byte {
+	line, _ := json.Marshal(jsonRecord{
+		Time:          record.Time.UTC().Format(time.RFC3339Nano),
+		Method:        record.Method,
+		Path:          record.Path,
+		Protocol:      record.Protocol,
+		Status:        record.Status,
+		BytesIn:       record.BytesIn,
+		BytesOut:      record.BytesOut,
+		DurationMs:    durationMs(record.Duration),
+		Principal:     record.Principal,
+		TraceId:       record.TraceId,
+		RemoteAddress: record.RemoteAddress,
+		UserAgent:     record.UserAgent,
+		Referer:       record.Referer,
+	})
+	return append(line, '\n')
+}
+
+func formatLogfmt(record *Record) []byte {
+	var builder strings.Builder
+	writePair := func(key string, value string) {
+		if builder.Len() > 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(key)
+		builder.WriteByte('=')
+		builder.WriteString(logfmtValue(value))
+	}
+
+	writePair("time", record.Time.UTC().Format(time.RFC3339Nano))
+	writePair("method", record.Method)
+	writePair("path", record.Path)
+	writePair("protocol", record.Protocol)
+	writePair("status", strconv.Itoa(record.Status))
+	writePair("bytes_in", strconv.FormatInt(record.BytesIn, 10))
+	writePair("bytes_out", strconv.FormatInt(record.BytesOut, 10))
+	writePair("duration_ms", strconv.FormatFloat(durationMs(record.Duration), 'f', -1, 64))
+	writePair("principal", record.Principal)
+	writePair("trace_id", record.TraceId)
+	writePair("remote_address", record.RemoteAddress)
+	writePair("user_agent", record.UserAgent)
+	writePair("referer", record.Referer)
+	builder.WriteByte('\n')
+	return []byte(builder.String())
+}
+
+// logfmtValue quotes values that are empty or hold characters that would
+// otherwise end the value.
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\\") || strings.ContainsFunc(value, func(r rune) bool { return r < ' ' }) {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// formatCombined formats the record in the Apache combined log format, the
+// user is the authenticated principal.
+func formatCombined(record *Record) []byte {
+	host, _, err := net.SplitHostPort(record.RemoteAddress)
+	if err != nil {
+		host = record.RemoteAddress
+	}
+	bytesOut := "-"
+	if record.BytesOut > 0 {
+		bytesOut = strconv.FormatInt(record.BytesOut, 10)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(combinedValue(host))
+	builder.WriteString(" - ")
+	builder.WriteString(combinedValue(record.Principal))
+	builder.WriteString(" [")
+	builder.WriteString(record.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	builder.WriteString("] \"")
+	builder.WriteString(combinedQuoted(record.Method + " " + record.Path + " " + record.Protocol))
+	builder.WriteString("\" ")
+	builder.WriteString(strconv.Itoa(record.Status))
+	builder.WriteByte(' ')
+	builder.WriteString(bytesOut)
+	builder.WriteString(" \"")
+	builder.WriteString(combinedQuoted(combinedValue(record.Referer)))
+	builder.WriteString("\" \"")
+	builder.WriteString(combinedQuoted(combinedValue(record.UserAgent)))
+	builder.WriteString("\"\n")
+	return []byte(builder.String())
+}
+
+func combinedValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// combinedQuoted escapes the quotes and control characters of a value written
+// within quotes, the way Apache does.
+func combinedQuoted(value string) string {
+	quoted := strconv.Quote(value)
+	return quoted[1 : len(quoted)-1]
+}
+
+func durationMs(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
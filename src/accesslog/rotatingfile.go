@@ -0,0 +1,95 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// rotatingFile appends to the file at path, and renames it to path.1 once a
+// write would grow it beyond maxSize bytes. Earlier rotations are shifted to
+// path.2 and so on, up to maxBackups. It is not safe for concurrent use.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	rotatingFile := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := rotatingFile.open(); err != nil {
+		return nil, err
+	}
+	return rotatingFile, nil
+}
+
+func (rotatingFile *rotatingFile) open() error {
+	file, err := os.OpenFile(rotatingFile.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rotatingFile.file = file
+	rotatingFile.size = info.Size()
+	return nil
+}
+
+func (rotatingFile *rotatingFile) Write(data []byte) (int, error) {
+	if rotatingFile.maxSize > 0 && rotatingFile.size > 0 && rotatingFile.size+int64(len(data)) > rotatingFile.maxSize {
+		if err := rotatingFile.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := rotatingFile.file.Write(data)
+	rotatingFile.size += int64(written)
+	return written, err
+}
+
+func (rotatingFile *rotatingFile) rotate() error {
+	if err := rotatingFile.file.Close(); err != nil {
+		return err
+	}
+
+	if rotatingFile.maxBackups <= 0 {
+		if err := os.Remove(rotatingFile.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return rotatingFile.open()
+	}
+
+	os.Remove(backupPath(rotatingFile.path, rotatingFile.maxBackups))
+	for backup := rotatingFile.maxBackups - 1; backup >= 1; backup-- {
+		err := os.Rename(backupPath(rotatingFile.path, backup), backupPath(rotatingFile.path, backup+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(rotatingFile.path, backupPath(rotatingFile.path, 1)); err != nil {
+		return err
+	}
+	return rotatingFile.open()
+}
+
+func (rotatingFile *rotatingFile) Close() error {
+	return rotatingFile.file.Close()
+}
+
+func backupPath(path string, backup int) string {
+	return fmt.Sprintf("%s.%d", path, backup)
+}
@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/telemetry"
 )
 
 type Application struct {
 	container     *Container
 	configuration *configuration.Configuration
+	arguments     *arguments
+	reloadWorker  *reloadWorker
+	reloadMutex   sync.Mutex
 }
 
 func NewApplication() *Application {
@@ -18,35 +26,114 @@ func NewApplication() *Application {
 	}
 }
 
-func (app *Application) ConfigureConfigurations(ctx context.Context) *configuration.Configuration {
-	configurations := configuration.NewConfiguration()
-
+// ConfigureConfigurations loads the configuration from the configuration file,
+// the environment variables and the overrides of the arguments. It is not
+// validated, so that an invalid configuration can still be printed.
+func (app *Application) ConfigureConfigurations(ctx context.Context, args *arguments) (*configuration.Configuration, error) {
 	configuration.ConfigureEnvFiles()
-	configuration.ConfigureEnvironmentVariables(configurations)
+
+	app.arguments = args
+	configurations, err := configuration.LoadConfiguration(app.configFile(), args.overrides)
+	if err != nil {
+		return nil, err
+	}
 
 	app.configuration = configurations
-	return configurations
+	return configurations, nil
+}
+
+// configFile returns the configuration file of the arguments, or else of the
+// environment variable.
+func (app *Application) configFile() string {
+	if app.arguments.configFile != "" {
+		return app.arguments.configFile
+	}
+	return os.Getenv(configFileEnvironmentVariable)
 }
 
 func (app *Application) ConfigureContainer(ctx context.Context) {
 	container := NewContainer()
 	container.setupStorage(ctx, app.configuration.STORAGE_PROVIDER)
+	container.setupCache(ctx, app.configuration.CACHE)
 	container.setupEventPublisher(ctx, app.configuration.EVENT_PUBLISHER)
+	container.setupUploads(ctx, app.configuration.UPLOAD)
+	container.setupAuthentication(ctx, app.configuration.AUTHENTICATION)
+	container.setupAuthorization(ctx, app.configuration.AUTHORIZATION)
+	container.setupHealth(ctx, app.configuration.HEALTH)
 	container.setupHandlers()
+	container.setupMetrics(ctx, app.configuration.METRICS, telemetry.MetricsHandler())
+	container.setupAccessLog(ctx, app.configuration.ACCESS_LOG)
 	container.setupHttpServer(app.configuration.HTTP_SERVER)
 	app.container = container
+
+	watchInterval, _ := time.ParseDuration(app.configuration.RELOAD.WATCH_INTERVAL)
+	app.reloadWorker = newReloadWorker(app, watchInterval)
 }
 
 func (app *Application) Run(ctx context.Context) {
+	// Seeded before any request is served, so no change is counted twice.
+	if app.container.seedResources {
+		app.container.seedResourcesOverall(ctx)
+	}
 	app.container.server.Start(ctx)
+	if app.container.metrics != nil {
+		app.container.metrics.Start(ctx)
+	}
 	if app.container.outboxWorker != nil {
 		go app.container.outboxWorker.Start(ctx)
 	}
+	if app.container.uploadWorker != nil {
+		go app.container.uploadWorker.Start(ctx)
+	}
+	if app.container.authzWorker != nil {
+		go app.container.authzWorker.Start(ctx)
+	}
+	if app.container.cachePeers != nil {
+		go app.container.cachePeers.Start(ctx)
+	}
+	go app.reloadWorker.Start(ctx)
 }
 
 func (app *Application) Shutdown(ctx context.Context) {
+	app.drain(ctx)
 	app.container.server.Shutdown(ctx)
+	if app.container.metrics != nil {
+		app.container.metrics.Shutdown(ctx)
+	}
 	if app.container.outboxWorker != nil {
 		app.container.outboxWorker.Stop(ctx)
 	}
+	if app.container.publisher != nil {
+		app.container.publisher.Stop()
+	}
+	if app.container.uploadWorker != nil {
+		app.container.uploadWorker.Stop(ctx)
+		app.container.uploads.Close()
+	}
+	if app.container.authzWorker != nil {
+		app.container.authzWorker.Stop(ctx)
+	}
+	if app.container.cachePeers != nil {
+		app.container.cachePeers.Stop(ctx)
+	}
+	app.reloadWorker.Stop(ctx)
+	if app.container.accessLog != nil {
+		app.container.accessLog.Close()
+	}
+}
+
+// drain fails the readiness probe and waits for the shutdown delay, so that
+// load balancers stop routing requests here before the server stops accepting
+// them.
+func (app *Application) drain(ctx context.Context) {
+	app.container.health.Drain()
+	if app.container.shutdownDelay <= 0 {
+		return
+	}
+
+	logger.Info(ctx, "Draining before shutdown", "delay", app.container.shutdownDelay)
+	select {
+	case <-time.After(app.container.shutdownDelay):
+	case <-ctx.Done():
+	}
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const configFileEnvironmentVariable = "HOWLITE_RESOURCE_CONFIG_FILE"
+
+// arguments are the command line arguments, the command is empty when the
+// application should be run.
+type arguments struct {
+	command    string
+	operands   []string
+	configFile string
+	overrides  map[string]string
+}
+
+// parseArguments parses [command] [-config path] [-set VARIABLE=value ...],
+// the overrides take precedence over the environment variables they name. The
+// command is one of config print, dead-letters list or dead-letters replay
+// followed by the ids of the dead letters to replay, or all.
+func parseArguments(args []string, output io.Writer) (*arguments, error) {
+	parsed := &arguments{
+		overrides: make(map[string]string),
+	}
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		parsed.command = "config print"
+		args = args[2:]
+	} else if len(args) >= 2 && args[0] == "dead-letters" && (args[1] == "list" || args[1] == "replay") {
+		parsed.command = "dead-letters " + args[1]
+		args = args[2:]
+		if parsed.command == "dead-letters replay" {
+			for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				parsed.operands = append(parsed.operands, args[0])
+				args = args[1:]
+			}
+		}
+	}
+
+	flags := flag.NewFlagSet("howlite-resources", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintln(output, "Usage: howlite-resources [config print | dead-letters list | dead-letters replay id... | dead-letters replay all] [-config path] [-set VARIABLE=value ...]")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&parsed.configFile, "config", "", "YAML or TOML configuration file, defaults to $"+configFileEnvironmentVariable)
+	flags.Func("set", "overrides a configuration environment variable, e.g. -set HOWLITE_RESOURCE_HTTP_SERVER_PORT=9090", func(value string) error {
+		name, variableValue, found := strings.Cut(value, "=")
+		if !found || name == "" {
+			return errors.New("expected VARIABLE=value")
+		}
+		parsed.overrides[name] = variableValue
+		return nil
+	})
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return nil, fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+	if parsed.command == "dead-letters replay" && len(parsed.operands) == 0 {
+		flags.Usage()
+		return nil, errors.New("expected the ids of the dead letters to replay, or all")
+	}
+
+	return parsed, nil
+}
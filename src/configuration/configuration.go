@@ -9,14 +9,66 @@ type Configuration struct {
 	HTTP_SERVER      HttpServer
 	STORAGE_PROVIDER StorageProvider
 	OTEL             OtelConfiguration
+	LOGGING          Logging
 	TRACING          Tracing
+	METRICS          Metrics
 	EVENT_PUBLISHER  EventPublisher
+	UPLOAD           Upload
+	AUTHENTICATION   Authentication
+	AUTHORIZATION    Authorization
+	CACHE            Cache
+	ACCESS_LOG       AccessLog
+	RELOAD           Reload
+	HEALTH           Health
+}
+
+// Logging configures the application log, LEVEL is one of Debug, Info, Warn or
+// Error and FORMAT is text or json. Values of attributes whose keys contain one
+// of REDACT_KEYS are redacted, a default set of sensitive keys is used if empty.
+type Logging struct {
+	LEVEL       string   `env:"HOWLITE_RESOURCE_LOGGING_LEVEL" envDefault:"Info"`
+	FORMAT      string   `env:"HOWLITE_RESOURCE_LOGGING_FORMAT" envDefault:"text"`
+	ADD_SOURCE  bool     `env:"HOWLITE_RESOURCE_LOGGING_ADD_SOURCE" envDefault:"false"`
+	REDACT_KEYS []string `env:"HOWLITE_RESOURCE_LOGGING_REDACT_KEYS" envSeparator:","`
 }
 
 type Tracing struct {
 	LEVEL string `env:"HOWLITE_RESOURCE_TRACING_LEVEL" envDefault:"Info"`
 }
 
+// Metrics are served in the Prometheus text format on PROMETHEUS_PATH if
+// PROMETHEUS_ENABLED is set, regardless of whether they are exported with
+// OTLP. They are served by the HTTP server unless PROMETHEUS_ADDRESS is set, in
+// which case they are served on a separate listener.
+//
+// Resource metrics are labeled with the first ROUTE_PATTERNS pattern matching
+// the resource, or else its first ROUTE_SEGMENTS directory segments.
+type Metrics struct {
+	PROMETHEUS_ENABLED     bool     `env:"HOWLITE_RESOURCE_METRICS_PROMETHEUS_ENABLED" envDefault:"false"`
+	PROMETHEUS_ADDRESS     string   `env:"HOWLITE_RESOURCE_METRICS_PROMETHEUS_ADDRESS"`
+	PROMETHEUS_PATH        string   `env:"HOWLITE_RESOURCE_METRICS_PROMETHEUS_PATH" envDefault:"/$sys/metrics"`
+	ROUTE_SEGMENTS         int      `env:"HOWLITE_RESOURCE_METRICS_ROUTE_SEGMENTS" envDefault:"1"`
+	ROUTE_PATTERNS         []string `env:"HOWLITE_RESOURCE_METRICS_ROUTE_PATTERNS" envSeparator:","`
+	SEED_RESOURCES_OVERALL bool     `env:"HOWLITE_RESOURCE_METRICS_SEED_RESOURCES_OVERALL" envDefault:"false"`
+}
+
+// Reload configures reloading of the configuration, which always happens on
+// SIGHUP. If WATCH_INTERVAL is set, the configuration file and the .env files
+// are also checked for changes at that interval.
+type Reload struct {
+	WATCH_INTERVAL string `env:"HOWLITE_RESOURCE_RELOAD_WATCH_INTERVAL"`
+}
+
+// Health configures the readiness probe, each dependency is checked within
+// CHECK_TIMEOUT and the result is reused for CACHE_TTL. On shutdown the probe fails for SHUTDOWN_DELAY before the HTTP
+// server stops accepting connections, so load balancers can stop routing to
+// the instance first.
+type Health struct {
+	CHECK_TIMEOUT  string `env:"HOWLITE_RESOURCE_HEALTH_CHECK_TIMEOUT" envDefault:"5s"`
+	CACHE_TTL      string `env:"HOWLITE_RESOURCE_HEALTH_CACHE_TTL" envDefault:"5s"`
+	SHUTDOWN_DELAY string `env:"HOWLITE_RESOURCE_HEALTH_SHUTDOWN_DELAY"`
+}
+
 type HttpServer struct {
 	HOST          string `env:"HOWLITE_RESOURCE_HTTP_SERVER_HOST" envDefault:"localhost"`
 	PORT          int    `env:"HOWLITE_RESOURCE_HTTP_SERVER_PORT" envDefault:"8080"`
@@ -25,6 +77,72 @@ type HttpServer struct {
 	WRITE_TIMEOUT string `env:"HOWLITE_RESOURCE_HTTP_SERVER_WRITE_TIMEOUT" envDefault:"30s"`
 }
 
+// AccessLog writes a record of every request in FORMAT (json, logfmt or
+// combined) to OUTPUT, which is either stdout or the path of a file. The file is
+// rotated once it grows beyond MAX_SIZE bytes, keeping MAX_BACKUPS rotated
+// files.
+type AccessLog struct {
+	ENABLED     bool   `env:"HOWLITE_RESOURCE_ACCESS_LOG_ENABLED" envDefault:"false"`
+	FORMAT      string `env:"HOWLITE_RESOURCE_ACCESS_LOG_FORMAT" envDefault:"json"`
+	OUTPUT      string `env:"HOWLITE_RESOURCE_ACCESS_LOG_OUTPUT" envDefault:"stdout"`
+	MAX_SIZE    int64  `env:"HOWLITE_RESOURCE_ACCESS_LOG_MAX_SIZE" envDefault:"104857600"`
+	MAX_BACKUPS int    `env:"HOWLITE_RESOURCE_ACCESS_LOG_MAX_BACKUPS" envDefault:"5"`
+}
+
+// STAGING_PATH is a local directory in which resumable uploads are staged until
+// they are complete. Uploads that have not been resumed within EXPIRATION are
+// removed. MAX_SIZE limits the length of an upload, 0 means unlimited.
+//
+// Uploads are staged by the instance that created them, so they are off by
+// default and only supported on a single instance. The staging path is locked
+// and can't be shared, and requests for uploads of another instance are
+// answered with 421 Misdirected Request.
+type Upload struct {
+	ENABLED      bool   `env:"HOWLITE_RESOURCE_UPLOAD_ENABLED" envDefault:"false"`
+	STAGING_PATH string `env:"HOWLITE_RESOURCE_UPLOAD_STAGING_PATH" envDefault:"./tmp/howlite-uploads"`
+	EXPIRATION   string `env:"HOWLITE_RESOURCE_UPLOAD_EXPIRATION" envDefault:"24h"`
+	MAX_SIZE     int64  `env:"HOWLITE_RESOURCE_UPLOAD_MAX_SIZE" envDefault:"0"`
+}
+
+// Authentication is enabled as soon as at least one authenticator is
+// configured, API_KEYS_PATH and HMAC_KEYS_PATH point at JSON files holding the
+// keys and the principals they belong to.
+type Authentication struct {
+	API_KEYS_PATH       string `env:"HOWLITE_RESOURCE_AUTHENTICATION_API_KEYS_PATH"`
+	HMAC_KEYS_PATH      string `env:"HOWLITE_RESOURCE_AUTHENTICATION_HMAC_KEYS_PATH"`
+	HMAC_MAX_CLOCK_SKEW string `env:"HOWLITE_RESOURCE_AUTHENTICATION_HMAC_MAX_CLOCK_SKEW" envDefault:"5m"`
+	JWT                 JwtAuthentication
+}
+
+// Authorization is enabled as soon as POLICY_PATH is set, the policy file is
+// checked for changes every RELOAD_INTERVAL.
+type Authorization struct {
+	POLICY_PATH     string `env:"HOWLITE_RESOURCE_AUTHORIZATION_POLICY_PATH"`
+	RELOAD_INTERVAL string `env:"HOWLITE_RESOURCE_AUTHORIZATION_RELOAD_INTERVAL" envDefault:"30s"`
+}
+
+// Cache is enabled as soon as MEMORY_MAX_SIZE or DISK_PATH is set. Resources up
+// to MAX_RESOURCE_SIZE bytes are cached for TTL, in memory up to MEMORY_MAX_SIZE
+// bytes and in DISK_PATH up to DISK_MAX_SIZE bytes, 0 means unlimited.
+type Cache struct {
+	MEMORY_MAX_SIZE   int64  `env:"HOWLITE_RESOURCE_CACHE_MEMORY_MAX_SIZE" envDefault:"0"`
+	DISK_PATH         string `env:"HOWLITE_RESOURCE_CACHE_DISK_PATH"`
+	DISK_MAX_SIZE     int64  `env:"HOWLITE_RESOURCE_CACHE_DISK_MAX_SIZE" envDefault:"1073741824"`
+	MAX_RESOURCE_SIZE int64  `env:"HOWLITE_RESOURCE_CACHE_MAX_RESOURCE_SIZE" envDefault:"1048576"`
+	TTL               string `env:"HOWLITE_RESOURCE_CACHE_TTL" envDefault:"5m"`
+	PEERS             ZeroMqSubscriberConfiguration
+}
+
+// JWKS_PATH takes precedence over ISSUER for loading the signing keys, the
+// ISSUER is still used to validate the iss claim of tokens.
+type JwtAuthentication struct {
+	JWKS_PATH        string `env:"HOWLITE_RESOURCE_AUTHENTICATION_JWT_JWKS_PATH"`
+	ISSUER           string `env:"HOWLITE_RESOURCE_AUTHENTICATION_JWT_ISSUER"`
+	AUDIENCE         string `env:"HOWLITE_RESOURCE_AUTHENTICATION_JWT_AUDIENCE"`
+	ROLES_CLAIM      string `env:"HOWLITE_RESOURCE_AUTHENTICATION_JWT_ROLES_CLAIM" envDefault:"roles"`
+	REFRESH_INTERVAL string `env:"HOWLITE_RESOURCE_AUTHENTICATION_JWT_REFRESH_INTERVAL" envDefault:"15m"`
+}
+
 type OtelConfiguration struct {
 	OTEL_SERVICE_NAME                   string `env:"OTEL_SERVICE_NAME"`
 	OTEL_EXPORTER_OTLP_PROTOCOL         string `env:"OTEL_EXPORTER_OTLP_PROTOCOL"`
@@ -38,6 +156,9 @@ type StorageProvider struct {
 	STORAGE_PROVIDER_FILESYSTEM FilesystemConfiguration
 	STORAGE_PROVIDER_S3         S3Configuration
 	STORAGE_PROVIDER_AZBLOB     AzureBlobStorageConfiguration
+	STORAGE_PROVIDER_GCS        GcsConfiguration
+	STORAGE_PROVIDER_DAPR       DaprConfiguration
+	STORAGE_PROVIDER_MEMORY     MemoryConfiguration
 }
 
 type FilesystemConfiguration struct {
@@ -46,8 +167,8 @@ type FilesystemConfiguration struct {
 
 type S3Configuration struct {
 	BUCKET               string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_BUCKET"`
-	ACCESS_KEY           string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_ACCESS_KEY"`
-	SECRET_KEY           string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_SECRET_KEY"`
+	ACCESS_KEY           string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_ACCESS_KEY" secret:"true"`
+	SECRET_KEY           string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_SECRET_KEY" secret:"true"`
 	ENDPOINT             string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_ENDPOINT"`
 	REGION               string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_REGION"`
 	PART_UPLOAD_SIZE     int64  `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_PART_UPLOAD_SIZE" envDefault:"5242880"`
@@ -56,15 +177,50 @@ type S3Configuration struct {
 }
 
 type AzureBlobStorageConfiguration struct {
-	CONNECTION_STRING  string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_CONNECTION_STRING"`
+	CONNECTION_STRING  string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_CONNECTION_STRING" secret:"true"`
 	CONTAINER_NAME     string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_CONTAINER_NAME"`
 	BLOCK_SIZE         int64  `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_BLOCK_SIZE" envDefault:"8388608"`
 	UPLOAD_CONCURRENCY int    `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_UPLOAD_CONCURRENCY" envDefault:"5"`
 }
 
+// CREDENTIALS_PATH points at a service account key file, Application Default
+// Credentials are used if it is empty. ENDPOINT is only needed for emulators.
+type GcsConfiguration struct {
+	BUCKET           string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_GCS_BUCKET"`
+	CREDENTIALS_PATH string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_GCS_CREDENTIALS_PATH"`
+	ENDPOINT         string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_GCS_ENDPOINT"`
+	CHUNK_SIZE       int    `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_GCS_CHUNK_SIZE" envDefault:"16777216"`
+}
+
+// ENDPOINT is the HTTP endpoint of the Dapr sidecar and STORE_NAME the name of
+// the state store component resources are stored in. API_TOKEN is only needed
+// if the sidecar requires API token authentication.
+type DaprConfiguration struct {
+	ENDPOINT   string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_DAPR_ENDPOINT" envDefault:"http://localhost:3500"`
+	STORE_NAME string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_DAPR_STORE_NAME" envDefault:"statestore"`
+	API_TOKEN  string `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_DAPR_API_TOKEN" secret:"true"`
+}
+
+// MAX_SIZE limits the total size of all resources and MAX_RESOURCE_SIZE the
+// size of a single resource, in bytes including headers, 0 means unlimited.
+// LRU_EVICTION evicts the least recently used resources instead of rejecting
+// new ones once MAX_SIZE is reached.
+type MemoryConfiguration struct {
+	MAX_SIZE          int64 `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_MEMORY_MAX_SIZE" envDefault:"268435456"`
+	MAX_RESOURCE_SIZE int64 `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_MEMORY_MAX_RESOURCE_SIZE" envDefault:"0"`
+	LRU_EVICTION      bool  `env:"HOWLITE_RESOURCE_STORAGE_PROVIDER_MEMORY_LRU_EVICTION" envDefault:"false"`
+}
+
+// EventPublisher configures the published events. FORMAT is legacy or
+// cloudevents, in which case SOURCE is the CloudEvents source of the events.
 type EventPublisher struct {
-	OUTBOX_SQLITE_PATH   string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_OUTBOX_SQLITE_PATH"`
-	ZEROMQ_CONFIGURATION ZeroMqConfiguration
+	FORMAT                string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_FORMAT" envDefault:"legacy"`
+	SOURCE                string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_SOURCE" envDefault:"/howlite-resources"`
+	OUTBOX_SQLITE_PATH    string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_OUTBOX_SQLITE_PATH"`
+	ZEROMQ_CONFIGURATION  ZeroMqConfiguration
+	NATS_CONFIGURATION    NatsConfiguration
+	KAFKA_CONFIGURATION   KafkaConfiguration
+	WEBHOOK_CONFIGURATION WebhookConfiguration
 }
 
 // SERVER_CERT_PATH must point at a CZMQ secret cert file (the "*_secret"
@@ -85,7 +241,85 @@ type ZeroMqCurveConfiguration struct {
 	ALLOWED_CLIENTS_PATH string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_ZEROMQ_CURVE_ALLOWED_CLIENTS_PATH"`
 }
 
-//TODO: We should validate the configuration values so we can throw any unexpected configuration errors on startup..
+// NatsConfiguration publishes the events to the NATS server at URL. The subject
+// of an event is SUBJECT_TEMPLATE with {type} replaced by the event type and
+// {path} by the segments of the resource path, so subscribers can filter on
+// both with wildcards. With JETSTREAM each event waits at most ACK_TIMEOUT for
+// the acknowledgement of the stream. CONTENT_MODE is structured or binary, in
+// which case the CloudEvent attributes are sent as ce- headers.
+//
+// CREDENTIALS_PATH points at a .creds file and NKEY_SEED_PATH at an NKey seed
+// file, only one of them is used. The TLS paths are all optional, CERT_PATH
+// and KEY_PATH are needed for mutual TLS.
+type NatsConfiguration struct {
+	URL              string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_URL"`
+	SUBJECT_TEMPLATE string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_SUBJECT_TEMPLATE" envDefault:"howlite.resources.{type}.{path}"`
+	JETSTREAM        bool   `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_JETSTREAM" envDefault:"false"`
+	ACK_TIMEOUT      string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_ACK_TIMEOUT" envDefault:"5s"`
+	CONTENT_MODE     string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_CONTENT_MODE" envDefault:"structured"`
+	CREDENTIALS_PATH string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_CREDENTIALS_PATH"`
+	NKEY_SEED_PATH   string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_NKEY_SEED_PATH"`
+	TLS              NatsTlsConfiguration
+}
+
+type NatsTlsConfiguration struct {
+	CA_PATH   string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_TLS_CA_PATH"`
+	CERT_PATH string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_TLS_CERT_PATH"`
+	KEY_PATH  string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_TLS_KEY_PATH"`
+}
+
+// KafkaConfiguration produces the events to TOPIC on the Kafka cluster of
+// BROKERS, keyed by the identifier of their resource. An event has failed to
+// publish if it was not acknowledged within DELIVERY_TIMEOUT. CONTENT_MODE is
+// structured or binary, in which case the CloudEvent attributes are sent as
+// ce_ headers.
+type KafkaConfiguration struct {
+	BROKERS          []string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_BROKERS" envSeparator:","`
+	TOPIC            string   `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_TOPIC" envDefault:"howlite-resources"`
+	DELIVERY_TIMEOUT string   `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_DELIVERY_TIMEOUT" envDefault:"30s"`
+	CONTENT_MODE     string   `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_CONTENT_MODE" envDefault:"structured"`
+	SASL             KafkaSaslConfiguration
+	TLS              KafkaTlsConfiguration
+}
+
+// MECHANISM is plain, scram-sha-256 or scram-sha-512, SASL is disabled if it
+// is empty.
+type KafkaSaslConfiguration struct {
+	MECHANISM string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_SASL_MECHANISM"`
+	USERNAME  string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_SASL_USERNAME"`
+	PASSWORD  string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_SASL_PASSWORD" secret:"true"`
+}
+
+// The brokers are verified with the CAs of CA_PATH, or the system CAs if it is
+// empty. CERT_PATH and KEY_PATH are needed for mutual TLS.
+type KafkaTlsConfiguration struct {
+	ENABLED   bool   `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_TLS_ENABLED" envDefault:"false"`
+	CA_PATH   string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_TLS_CA_PATH"`
+	CERT_PATH string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_TLS_CERT_PATH"`
+	KEY_PATH  string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_TLS_KEY_PATH"`
+}
+
+// WebhookConfiguration POSTs the events to the endpoints listed in the JSON
+// file at ENDPOINTS_PATH. A delivery is attempted at most MAX_ATTEMPTS times,
+// waiting from INITIAL_BACKOFF up to MAX_BACKOFF between the attempts, and is
+// then parked as a dead letter in the outbox, which webhooks require.
+type WebhookConfiguration struct {
+	ENDPOINTS_PATH  string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_ENDPOINTS_PATH"`
+	TIMEOUT         string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_TIMEOUT" envDefault:"10s"`
+	MAX_ATTEMPTS    int    `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_MAX_ATTEMPTS" envDefault:"5"`
+	INITIAL_BACKOFF string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_INITIAL_BACKOFF" envDefault:"1s"`
+	MAX_BACKOFF     string `env:"HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_MAX_BACKOFF" envDefault:"1m"`
+}
+
+// ENDPOINTS are the ZeroMQ publisher endpoints of the other instances, whose
+// events are received. CURVE_CLIENT_CERT_PATH points at a CZMQ secret cert
+// file and CURVE_SERVER_PUBLIC_KEY holds the Z85 encoded public key of the
+// publishers, both are needed if the publishers use CURVE.
+type ZeroMqSubscriberConfiguration struct {
+	ENDPOINTS               []string `env:"HOWLITE_RESOURCE_CACHE_PEERS_ENDPOINTS" envSeparator:","`
+	CURVE_CLIENT_CERT_PATH  string   `env:"HOWLITE_RESOURCE_CACHE_PEERS_CURVE_CLIENT_CERT_PATH"`
+	CURVE_SERVER_PUBLIC_KEY string   `env:"HOWLITE_RESOURCE_CACHE_PEERS_CURVE_SERVER_PUBLIC_KEY"`
+}
 
 func NewConfiguration() *Configuration {
 	return &Configuration{}
@@ -95,6 +329,8 @@ func ConfigureEnvFiles() {
 	godotenv.Overload(".env", ".local.env")
 }
 
-func ConfigureEnvironmentVariables(configuration *Configuration) {
-	env.Parse(configuration)
+// ConfigureEnvironmentVariables parses the environment variables into the
+// configuration, the returned error lists every variable that failed to parse.
+func ConfigureEnvironmentVariables(configuration *Configuration) error {
+	return env.Parse(configuration)
 }
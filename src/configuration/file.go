@@ -0,0 +1,112 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/caarlos0/env/v11"
+	"gopkg.in/yaml.v3"
+)
+
+// noDefaultTagName is a tag no field has, so parsing with it as the default
+// value tag leaves the fields of unset variables as they are.
+const noDefaultTagName = "envNoDefault"
+
+const redacted = "[REDACTED]"
+
+// LoadConfiguration builds the configuration from, in increasing precedence,
+// the defaults, the YAML or TOML file at path if it is set, the environment
+// variables and the overrides, which are keyed by environment variable.
+//
+// The structure of the file mirrors Configuration, with the field names in
+// lower case, e.g. http_server.port.
+func LoadConfiguration(path string, overrides map[string]string) (*Configuration, error) {
+	configuration := NewConfiguration()
+	if err := env.ParseWithOptions(configuration, env.Options{Environment: map[string]string{}}); err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		if err := decodeFile(path, configuration); err != nil {
+			return nil, fmt.Errorf("failed to read configuration file %s: %w", path, err)
+		}
+	}
+
+	if err := env.ParseWithOptions(configuration, env.Options{DefaultValueTagName: noDefaultTagName}); err != nil {
+		return nil, err
+	}
+
+	if len(overrides) > 0 {
+		err := env.ParseWithOptions(configuration, env.Options{Environment: overrides, DefaultValueTagName: noDefaultTagName})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return configuration, nil
+}
+
+// decodeFile decodes the file by its extension, keys that do not match a field
+// are reported rather than ignored.
+func decodeFile(path string, configuration *Configuration) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(configuration); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case ".toml":
+		metadata, err := toml.DecodeFile(path, configuration)
+		if err != nil {
+			return err
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown keys %v", undecoded)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported file extension %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+}
+
+// WriteYaml writes the configuration as YAML, in the structure of a
+// configuration file, with the values of the secret fields redacted.
+func (configuration *Configuration) WriteYaml(writer io.Writer) error {
+	redactedConfiguration := *configuration
+	redactSecrets(reflect.ValueOf(&redactedConfiguration).Elem())
+
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&redactedConfiguration); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// redactSecrets replaces the values of the string fields tagged secret:"true"
+// that are set.
+func redactSecrets(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			redactSecrets(field)
+		case field.Kind() == reflect.String && value.Type().Field(i).Tag.Get("secret") == "true" && field.String() != "":
+			field.SetString(redacted)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+//go:build unit
+
+package configuration_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inx51/howlite-resources/configuration"
+)
+
+func writeConfigurationFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigurationShouldReadYamlFile(t *testing.T) {
+	path := writeConfigurationFile(t, "howlite.yaml", `
+http_server:
+  port: 9090
+storage_provider:
+  name: s3
+  storage_provider_s3:
+    bucket: resources
+`)
+
+	config, err := configuration.LoadConfiguration(path, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if config.HTTP_SERVER.PORT != 9090 || config.STORAGE_PROVIDER.STORAGE_PROVIDER_S3.BUCKET != "resources" {
+		t.Fatalf("Expected port 9090 and bucket resources, got %d and %s", config.HTTP_SERVER.PORT, config.STORAGE_PROVIDER.STORAGE_PROVIDER_S3.BUCKET)
+	}
+	if config.HTTP_SERVER.READ_TIMEOUT != "30s" {
+		t.Fatalf("Expected the default read timeout, got %s", config.HTTP_SERVER.READ_TIMEOUT)
+	}
+}
+
+func TestLoadConfigurationShouldReadTomlFile(t *testing.T) {
+	path := writeConfigurationFile(t, "howlite.toml", `
+[http_server]
+port = 9090
+
+[cache]
+ttl = "1m"
+`)
+
+	config, err := configuration.LoadConfiguration(path, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if config.HTTP_SERVER.PORT != 9090 || config.CACHE.TTL != "1m" {
+		t.Fatalf("Expected port 9090 and ttl 1m, got %d and %s", config.HTTP_SERVER.PORT, config.CACHE.TTL)
+	}
+}
+
+func TestLoadConfigurationShouldPreferEnvironmentOverFileAndOverridesOverEnvironment(t *testing.T) {
+	path := writeConfigurationFile(t, "howlite.yaml", `
+http_server:
+  host: file
+  port: 9090
+  read_timeout: 10s
+`)
+	t.Setenv("HOWLITE_RESOURCE_HTTP_SERVER_PORT", "9091")
+	t.Setenv("HOWLITE_RESOURCE_HTTP_SERVER_HOST", "environment")
+
+	config, err := configuration.LoadConfiguration(path, map[string]string{"HOWLITE_RESOURCE_HTTP_SERVER_HOST": "override"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if config.HTTP_SERVER.READ_TIMEOUT != "10s" || config.HTTP_SERVER.PORT != 9091 || config.HTTP_SERVER.HOST != "override" {
+		t.Fatalf("Expected 10s, 9091 and override, got %s, %d and %s", config.HTTP_SERVER.READ_TIMEOUT, config.HTTP_SERVER.PORT, config.HTTP_SERVER.HOST)
+	}
+}
+
+func TestLoadConfigurationShouldRejectUnknownKeys(t *testing.T) {
+	for name, content := range map[string]string{
+		"howlite.yaml": "http_server:\n  prot: 9090\n",
+		"howlite.toml": "[http_server]\nprot = 9090\n",
+		"howlite.json": "{}",
+	} {
+		path := writeConfigurationFile(t, name, content)
+
+		if _, err := configuration.LoadConfiguration(path, nil); err == nil {
+			t.Fatalf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestWriteYamlShouldRedactSecrets(t *testing.T) {
+	config, err := configuration.LoadConfiguration("", map[string]string{
+		"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_SECRET_KEY":               "s3-secret",
+		"HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_CONNECTION_STRING": "AccountKey=azure-secret",
+		"HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_BUCKET":                   "resources",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := config.WriteYaml(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if strings.Contains(buffer.String(), "s3-secret") || strings.Contains(buffer.String(), "azure-secret") {
+		t.Fatalf("Expected secrets to be redacted, got %s", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), "bucket: resources") || config.STORAGE_PROVIDER.STORAGE_PROVIDER_S3.SECRET_KEY != "s3-secret" {
+		t.Fatalf("Expected only the printed secrets to be redacted")
+	}
+}
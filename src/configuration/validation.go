@@ -0,0 +1,416 @@
+package configuration
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// s3MinPartSize is the smallest part size S3 accepts for multipart uploads.
+const s3MinPartSize = 5 * 1024 * 1024
+
+// ValidationError lists every problem found in the configuration, so they can
+// all be fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(err.Problems, "\n  - ")
+}
+
+// Validate checks the whole configuration, including the settings of the
+// selected storage provider and the files referenced by it, and returns a
+// ValidationError listing all problems if there are any.
+func (configuration *Configuration) Validate() error {
+	validator := &validator{}
+
+	validator.validateHttpServer(&configuration.HTTP_SERVER)
+	validator.validateStorageProvider(&configuration.STORAGE_PROVIDER)
+	validator.validateLogging(&configuration.LOGGING)
+	validator.validateMetrics(&configuration.METRICS, &configuration.CACHE.PEERS)
+	validator.validateEventPublisher(&configuration.EVENT_PUBLISHER)
+	validator.validateUpload(&configuration.UPLOAD, &configuration.CACHE.PEERS)
+	validator.validateAuthentication(&configuration.AUTHENTICATION)
+	validator.validateAuthorization(&configuration.AUTHORIZATION)
+	validator.validateCache(&configuration.CACHE)
+	validator.validateAccessLog(&configuration.ACCESS_LOG)
+	validator.validateReload(&configuration.RELOAD)
+	validator.validateHealth(&configuration.HEALTH)
+
+	if len(validator.problems) > 0 {
+		return &ValidationError{Problems: validator.problems}
+	}
+	return nil
+}
+
+// validator collects the problems of the configuration, they are reported by
+// the environment variables of the fields.
+type validator struct {
+	problems []string
+}
+
+func (validator *validator) validateHttpServer(configuration *HttpServer) {
+	validator.between(configuration, "PORT", int64(configuration.PORT), 1, 65535)
+	validator.duration(configuration, "IDLE_TIMEOUT", configuration.IDLE_TIMEOUT)
+	validator.duration(configuration, "READ_TIMEOUT", configuration.READ_TIMEOUT)
+	validator.duration(configuration, "WRITE_TIMEOUT", configuration.WRITE_TIMEOUT)
+}
+
+func (validator *validator) validateStorageProvider(configuration *StorageProvider) {
+	switch configuration.NAME {
+	case "filesystem":
+		filesystem := &configuration.STORAGE_PROVIDER_FILESYSTEM
+		validator.required(filesystem, "PATH", filesystem.PATH)
+	case "s3":
+		s3 := &configuration.STORAGE_PROVIDER_S3
+		validator.required(s3, "BUCKET", s3.BUCKET)
+		if (s3.ACCESS_KEY == "") != (s3.SECRET_KEY == "") {
+			validator.report(s3, "SECRET_KEY", "must be set together with %s", envName(s3, "ACCESS_KEY"))
+		}
+		validator.atLeast(s3, "PART_UPLOAD_SIZE", s3.PART_UPLOAD_SIZE, s3MinPartSize)
+		validator.atLeast(s3, "UPLOAD_CONCURRENCY", int64(s3.UPLOAD_CONCURRENCY), 1)
+		validator.atLeast(s3, "DOWNLOAD_CONCURRENCY", int64(s3.DOWNLOAD_CONCURRENCY), 1)
+	case "azureblob":
+		azureBlob := &configuration.STORAGE_PROVIDER_AZBLOB
+		validator.required(azureBlob, "CONNECTION_STRING", azureBlob.CONNECTION_STRING)
+		validator.required(azureBlob, "CONTAINER_NAME", azureBlob.CONTAINER_NAME)
+		validator.atLeast(azureBlob, "BLOCK_SIZE", azureBlob.BLOCK_SIZE, 1)
+		validator.atLeast(azureBlob, "UPLOAD_CONCURRENCY", int64(azureBlob.UPLOAD_CONCURRENCY), 1)
+	case "gcs":
+		gcs := &configuration.STORAGE_PROVIDER_GCS
+		validator.required(gcs, "BUCKET", gcs.BUCKET)
+		validator.file(gcs, "CREDENTIALS_PATH", gcs.CREDENTIALS_PATH)
+		validator.atLeast(gcs, "CHUNK_SIZE", int64(gcs.CHUNK_SIZE), 0)
+	case "dapr":
+		dapr := &configuration.STORAGE_PROVIDER_DAPR
+		validator.url(dapr, "ENDPOINT", dapr.ENDPOINT)
+		validator.required(dapr, "STORE_NAME", dapr.STORE_NAME)
+	case "memory":
+		memory := &configuration.STORAGE_PROVIDER_MEMORY
+		validator.atLeast(memory, "MAX_SIZE", memory.MAX_SIZE, 0)
+		validator.atLeast(memory, "MAX_RESOURCE_SIZE", memory.MAX_RESOURCE_SIZE, 0)
+	default:
+		validator.oneOf(configuration, "NAME", configuration.NAME, "filesystem", "s3", "azureblob", "gcs", "dapr", "memory")
+	}
+}
+
+func (validator *validator) validateLogging(configuration *Logging) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(configuration.LEVEL)); err != nil {
+		validator.report(configuration, "LEVEL", "must be one of Debug, Info, Warn or Error, got %q", configuration.LEVEL)
+	}
+	validator.oneOf(configuration, "FORMAT", strings.ToLower(configuration.FORMAT), "text", "json")
+}
+
+// validateMetrics rejects seeding resources_overall when peers are configured,
+// every instance would count all resources and only follow its own changes.
+func (validator *validator) validateMetrics(configuration *Metrics, peers *ZeroMqSubscriberConfiguration) {
+	if configuration.SEED_RESOURCES_OVERALL && len(peers.ENDPOINTS) > 0 {
+		validator.report(configuration, "SEED_RESOURCES_OVERALL", "must be false when running multiple instances with %s, the seeded gauges can't be aggregated", envName(peers, "ENDPOINTS"))
+	}
+	if !strings.HasPrefix(configuration.PROMETHEUS_PATH, "/") {
+		validator.report(configuration, "PROMETHEUS_PATH", "must start with /, got %q", configuration.PROMETHEUS_PATH)
+	}
+	validator.atLeast(configuration, "ROUTE_SEGMENTS", int64(configuration.ROUTE_SEGMENTS), 0)
+	for _, pattern := range configuration.ROUTE_PATTERNS {
+		if !strings.HasPrefix(pattern, "/") {
+			validator.report(configuration, "ROUTE_PATTERNS", "patterns must start with /, got %q", pattern)
+		}
+		if index := strings.Index(pattern, "**"); index >= 0 && index != len(pattern)-2 {
+			validator.report(configuration, "ROUTE_PATTERNS", "patterns may only end with **, got %q", pattern)
+		}
+	}
+}
+
+func (validator *validator) validateEventPublisher(configuration *EventPublisher) {
+	validator.oneOf(configuration, "FORMAT", configuration.FORMAT, "legacy", "cloudevents")
+	if configuration.FORMAT == "cloudevents" {
+		validator.uriReference(configuration, "SOURCE", configuration.SOURCE)
+	}
+
+	if !zeroMqAvailable && configuration.ZEROMQ_CONFIGURATION.ENDPOINT != "" {
+		validator.report(&configuration.ZEROMQ_CONFIGURATION, "ENDPOINT", "can't be set, this build leaves out zero mq (nozmq tag)")
+	}
+	curve := &configuration.ZEROMQ_CONFIGURATION.CURVE
+	validator.file(curve, "SERVER_CERT_PATH", curve.SERVER_CERT_PATH)
+	validator.directory(curve, "ALLOWED_CLIENTS_PATH", curve.ALLOWED_CLIENTS_PATH)
+
+	validator.validateTransports(configuration)
+	validator.validateNats(configuration)
+	validator.validateKafka(configuration)
+	validator.validateWebhook(configuration)
+}
+
+// validateTransports reports every configured transport after the first, as
+// events are published to a single transport.
+func (validator *validator) validateTransports(configuration *EventPublisher) {
+	transports := []struct {
+		structure  any
+		field      string
+		configured bool
+	}{
+		{&configuration.ZEROMQ_CONFIGURATION, "ENDPOINT", configuration.ZEROMQ_CONFIGURATION.ENDPOINT != ""},
+		{&configuration.NATS_CONFIGURATION, "URL", configuration.NATS_CONFIGURATION.URL != ""},
+		{&configuration.KAFKA_CONFIGURATION, "BROKERS", len(configuration.KAFKA_CONFIGURATION.BROKERS) > 0},
+		{&configuration.WEBHOOK_CONFIGURATION, "ENDPOINTS_PATH", configuration.WEBHOOK_CONFIGURATION.ENDPOINTS_PATH != ""},
+	}
+
+	first := ""
+	for _, transport := range transports {
+		if !transport.configured {
+			continue
+		}
+		if first == "" {
+			first = envName(transport.structure, transport.field)
+			continue
+		}
+		validator.report(transport.structure, transport.field, "can't be set along with %s, events are published to a single transport", first)
+	}
+}
+
+func (validator *validator) validateNats(configuration *EventPublisher) {
+	nats := &configuration.NATS_CONFIGURATION
+	if nats.URL != "" {
+		validator.required(nats, "SUBJECT_TEMPLATE", nats.SUBJECT_TEMPLATE)
+		validator.duration(nats, "ACK_TIMEOUT", nats.ACK_TIMEOUT)
+		validator.oneOf(nats, "CONTENT_MODE", nats.CONTENT_MODE, "structured", "binary")
+		if nats.CONTENT_MODE == "binary" && configuration.FORMAT != "cloudevents" {
+			validator.report(nats, "CONTENT_MODE", "binary requires %s to be cloudevents", envName(configuration, "FORMAT"))
+		}
+	}
+
+	validator.file(nats, "CREDENTIALS_PATH", nats.CREDENTIALS_PATH)
+	validator.file(nats, "NKEY_SEED_PATH", nats.NKEY_SEED_PATH)
+	tls := &nats.TLS
+	validator.file(tls, "CA_PATH", tls.CA_PATH)
+	validator.file(tls, "CERT_PATH", tls.CERT_PATH)
+	validator.file(tls, "KEY_PATH", tls.KEY_PATH)
+	if (tls.CERT_PATH == "") != (tls.KEY_PATH == "") {
+		validator.report(tls, "CERT_PATH", "and %s must be set together", envName(tls, "KEY_PATH"))
+	}
+}
+
+func (validator *validator) validateKafka(configuration *EventPublisher) {
+	kafka := &configuration.KAFKA_CONFIGURATION
+	if len(kafka.BROKERS) == 0 {
+		return
+	}
+
+	validator.required(kafka, "TOPIC", kafka.TOPIC)
+	validator.duration(kafka, "DELIVERY_TIMEOUT", kafka.DELIVERY_TIMEOUT)
+	if timeout, err := time.ParseDuration(kafka.DELIVERY_TIMEOUT); err == nil && timeout > 0 && timeout < time.Second {
+		validator.report(kafka, "DELIVERY_TIMEOUT", "must be at least 1s, got %q", kafka.DELIVERY_TIMEOUT)
+	}
+	validator.oneOf(kafka, "CONTENT_MODE", kafka.CONTENT_MODE, "structured", "binary")
+	if kafka.CONTENT_MODE == "binary" && configuration.FORMAT != "cloudevents" {
+		validator.report(kafka, "CONTENT_MODE", "binary requires %s to be cloudevents", envName(configuration, "FORMAT"))
+	}
+
+	saslConfiguration := &kafka.SASL
+	if saslConfiguration.MECHANISM != "" {
+		validator.oneOf(saslConfiguration, "MECHANISM", saslConfiguration.MECHANISM, "plain", "scram-sha-256", "scram-sha-512")
+		validator.required(saslConfiguration, "USERNAME", saslConfiguration.USERNAME)
+		validator.required(saslConfiguration, "PASSWORD", saslConfiguration.PASSWORD)
+	}
+
+	tls := &kafka.TLS
+	validator.file(tls, "CA_PATH", tls.CA_PATH)
+	validator.file(tls, "CERT_PATH", tls.CERT_PATH)
+	validator.file(tls, "KEY_PATH", tls.KEY_PATH)
+	if (tls.CERT_PATH == "") != (tls.KEY_PATH == "") {
+		validator.report(tls, "CERT_PATH", "and %s must be set together", envName(tls, "KEY_PATH"))
+	}
+}
+
+func (validator *validator) validateWebhook(configuration *EventPublisher) {
+	webhook := &configuration.WEBHOOK_CONFIGURATION
+	if webhook.ENDPOINTS_PATH == "" {
+		return
+	}
+
+	if configuration.OUTBOX_SQLITE_PATH == "" {
+		validator.report(webhook, "ENDPOINTS_PATH", "requires %s, which holds the deliveries and dead letters", envName(configuration, "OUTBOX_SQLITE_PATH"))
+	}
+	validator.file(webhook, "ENDPOINTS_PATH", webhook.ENDPOINTS_PATH)
+	validator.duration(webhook, "TIMEOUT", webhook.TIMEOUT)
+	validator.atLeast(webhook, "MAX_ATTEMPTS", int64(webhook.MAX_ATTEMPTS), 1)
+	validator.duration(webhook, "INITIAL_BACKOFF", webhook.INITIAL_BACKOFF)
+	validator.duration(webhook, "MAX_BACKOFF", webhook.MAX_BACKOFF)
+}
+
+// validateUpload rejects uploads when peers are configured, the other
+// instances can't resume the uploads staged by this one.
+func (validator *validator) validateUpload(configuration *Upload, peers *ZeroMqSubscriberConfiguration) {
+	if !configuration.ENABLED {
+		return
+	}
+
+	if len(peers.ENDPOINTS) > 0 {
+		validator.report(configuration, "ENABLED", "must be false when running multiple instances with %s, uploads are staged on the instance that created them", envName(peers, "ENDPOINTS"))
+	}
+	validator.required(configuration, "STAGING_PATH", configuration.STAGING_PATH)
+	validator.duration(configuration, "EXPIRATION", configuration.EXPIRATION)
+	validator.atLeast(configuration, "MAX_SIZE", configuration.MAX_SIZE, 0)
+}
+
+func (validator *validator) validateAuthentication(configuration *Authentication) {
+	validator.file(configuration, "API_KEYS_PATH", configuration.API_KEYS_PATH)
+	validator.file(configuration, "HMAC_KEYS_PATH", configuration.HMAC_KEYS_PATH)
+	validator.duration(configuration, "HMAC_MAX_CLOCK_SKEW", configuration.HMAC_MAX_CLOCK_SKEW)
+
+	jwt := &configuration.JWT
+	validator.file(jwt, "JWKS_PATH", jwt.JWKS_PATH)
+	if jwt.ISSUER != "" {
+		validator.url(jwt, "ISSUER", jwt.ISSUER)
+	}
+	validator.duration(jwt, "REFRESH_INTERVAL", jwt.REFRESH_INTERVAL)
+}
+
+func (validator *validator) validateAuthorization(configuration *Authorization) {
+	validator.file(configuration, "POLICY_PATH", configuration.POLICY_PATH)
+	validator.duration(configuration, "RELOAD_INTERVAL", configuration.RELOAD_INTERVAL)
+}
+
+func (validator *validator) validateCache(configuration *Cache) {
+	validator.atLeast(configuration, "MEMORY_MAX_SIZE", configuration.MEMORY_MAX_SIZE, 0)
+	validator.atLeast(configuration, "DISK_MAX_SIZE", configuration.DISK_MAX_SIZE, 0)
+	validator.atLeast(configuration, "MAX_RESOURCE_SIZE", configuration.MAX_RESOURCE_SIZE, 0)
+	validator.duration(configuration, "TTL", configuration.TTL)
+
+	peers := &configuration.PEERS
+	if !zeroMqAvailable && len(peers.ENDPOINTS) > 0 {
+		validator.report(peers, "ENDPOINTS", "can't be set, this build leaves out zero mq (nozmq tag) which peers are invalidated through")
+	}
+	validator.file(peers, "CURVE_CLIENT_CERT_PATH", peers.CURVE_CLIENT_CERT_PATH)
+	if peers.CURVE_CLIENT_CERT_PATH != "" && len(peers.CURVE_SERVER_PUBLIC_KEY) != 40 {
+		validator.report(peers, "CURVE_SERVER_PUBLIC_KEY", "must be a 40 character Z85 encoded key when %s is set", envName(peers, "CURVE_CLIENT_CERT_PATH"))
+	}
+}
+
+func (validator *validator) validateAccessLog(configuration *AccessLog) {
+	if !configuration.ENABLED {
+		return
+	}
+
+	validator.oneOf(configuration, "FORMAT", configuration.FORMAT, "json", "logfmt", "combined")
+	validator.required(configuration, "OUTPUT", configuration.OUTPUT)
+	validator.atLeast(configuration, "MAX_SIZE", configuration.MAX_SIZE, 0)
+	validator.atLeast(configuration, "MAX_BACKUPS", int64(configuration.MAX_BACKUPS), 0)
+}
+
+func (validator *validator) validateReload(configuration *Reload) {
+	if configuration.WATCH_INTERVAL == "" {
+		return
+	}
+	validator.duration(configuration, "WATCH_INTERVAL", configuration.WATCH_INTERVAL)
+}
+
+func (validator *validator) validateHealth(configuration *Health) {
+	validator.duration(configuration, "CHECK_TIMEOUT", configuration.CHECK_TIMEOUT)
+	validator.duration(configuration, "CACHE_TTL", configuration.CACHE_TTL)
+	if configuration.SHUTDOWN_DELAY != "" {
+		validator.duration(configuration, "SHUTDOWN_DELAY", configuration.SHUTDOWN_DELAY)
+	}
+}
+
+func (validator *validator) report(structure any, field string, format string, args ...any) {
+	validator.problems = append(validator.problems, envName(structure, field)+" "+fmt.Sprintf(format, args...))
+}
+
+func (validator *validator) required(structure any, field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		validator.report(structure, field, "is required")
+	}
+}
+
+func (validator *validator) oneOf(structure any, field string, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		validator.report(structure, field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+}
+
+func (validator *validator) atLeast(structure any, field string, value int64, min int64) {
+	if value < min {
+		validator.report(structure, field, "must be at least %d, got %d", min, value)
+	}
+}
+
+func (validator *validator) between(structure any, field string, value int64, min int64, max int64) {
+	if value < min || value > max {
+		validator.report(structure, field, "must be between %d and %d, got %d", min, max, value)
+	}
+}
+
+func (validator *validator) duration(structure any, field string, value string) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		validator.report(structure, field, "must be a duration such as 30s or 5m, got %q", value)
+		return
+	}
+	if duration <= 0 {
+		validator.report(structure, field, "must be positive, got %q", value)
+	}
+}
+
+func (validator *validator) url(structure any, field string, value string) {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		validator.report(structure, field, "must be an absolute URL, got %q", value)
+	}
+}
+
+// uriReference reports the field if it is not a non-empty URI reference.
+func (validator *validator) uriReference(structure any, field string, value string) {
+	if _, err := url.Parse(value); err != nil || value == "" {
+		validator.report(structure, field, "must be a URI reference such as /howlite-resources, got %q", value)
+	}
+}
+
+// file reports the field if it is set to a path that is not an existing file.
+func (validator *validator) file(structure any, field string, path string) {
+	if path == "" {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		validator.report(structure, field, "must point at an existing file, %v", err)
+		return
+	}
+	if info.IsDir() {
+		validator.report(structure, field, "must point at a file, %s is a directory", path)
+	}
+}
+
+// directory reports the field if it is set to a path that is not an existing
+// directory.
+func (validator *validator) directory(structure any, field string, path string) {
+	if path == "" {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		validator.report(structure, field, "must point at an existing directory, %v", err)
+		return
+	}
+	if !info.IsDir() {
+		validator.report(structure, field, "must point at a directory, %s is a file", path)
+	}
+}
+
+// envName returns the environment variable of a field of the struct pointed
+// at by structure.
+func envName(structure any, field string) string {
+	structField, found := reflect.TypeOf(structure).Elem().FieldByName(field)
+	if !found {
+		return field
+	}
+	return structField.Tag.Get("env")
+}
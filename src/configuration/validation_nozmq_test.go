@@ -0,0 +1,16 @@
+//go:build unit && nozmq
+
+package configuration_test
+
+import "testing"
+
+func TestValidateShouldRejectZeroMqWithoutZeroMq(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.CACHE.PEERS.ENDPOINTS = []string{"tcp://peer:5556"}
+	config.EVENT_PUBLISHER.ZEROMQ_CONFIGURATION.ENDPOINT = "tcp://*:5556"
+
+	problems := validationProblems(t, config)
+
+	assertProblem(t, problems, "HOWLITE_RESOURCE_CACHE_PEERS_ENDPOINTS can't be set, this build leaves out zero mq")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_ZEROMQ_ENDPOINT can't be set, this build leaves out zero mq")
+}
@@ -0,0 +1,193 @@
+//go:build unit
+
+package configuration_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/inx51/howlite-resources/configuration"
+)
+
+func newDefaultConfiguration(t *testing.T) *configuration.Configuration {
+	t.Helper()
+	defaults := configuration.NewConfiguration()
+	if err := configuration.ConfigureEnvironmentVariables(defaults); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return defaults
+}
+
+func validationProblems(t *testing.T, config *configuration.Configuration) []string {
+	t.Helper()
+	err := config.Validate()
+	var validationError *configuration.ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	return validationError.Problems
+}
+
+func assertProblem(t *testing.T, problems []string, expected string) {
+	t.Helper()
+	for _, problem := range problems {
+		if strings.Contains(problem, expected) {
+			return
+		}
+	}
+	t.Fatalf("Expected a problem containing %s, got %v", expected, problems)
+}
+
+func TestValidateShouldAcceptDefaults(t *testing.T) {
+	if err := newDefaultConfiguration(t).Validate(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestValidateShouldReportAllS3Problems(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.STORAGE_PROVIDER.NAME = "s3"
+	config.STORAGE_PROVIDER.STORAGE_PROVIDER_S3.PART_UPLOAD_SIZE = 1024
+	config.STORAGE_PROVIDER.STORAGE_PROVIDER_S3.UPLOAD_CONCURRENCY = 0
+	config.STORAGE_PROVIDER.STORAGE_PROVIDER_S3.ACCESS_KEY = "key"
+
+	problems := validationProblems(t, config)
+
+	if len(problems) != 4 {
+		t.Fatalf("Expected 4 problems, got %v", problems)
+	}
+	assertProblem(t, problems, "HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_BUCKET is required")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_PART_UPLOAD_SIZE must be at least 5242880")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_UPLOAD_CONCURRENCY must be at least 1")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_STORAGE_PROVIDER_S3_SECRET_KEY must be set together with")
+}
+
+func TestValidateShouldReportAzureBlobRequiredFields(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.STORAGE_PROVIDER.NAME = "azureblob"
+
+	problems := validationProblems(t, config)
+
+	assertProblem(t, problems, "HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_CONNECTION_STRING is required")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_STORAGE_PROVIDER_AZUREBLOB_CONTAINER_NAME is required")
+}
+
+func TestValidateShouldReportUnknownStorageProvider(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.STORAGE_PROVIDER.NAME = "ftp"
+
+	assertProblem(t, validationProblems(t, config), "HOWLITE_RESOURCE_STORAGE_PROVIDER_NAME must be one of")
+}
+
+func TestValidateShouldReportInvalidDurationsAndMissingFiles(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.HTTP_SERVER.READ_TIMEOUT = "30"
+	config.CACHE.TTL = "-1m"
+	config.EVENT_PUBLISHER.ZEROMQ_CONFIGURATION.CURVE.SERVER_CERT_PATH = filepath.Join(t.TempDir(), "missing_secret")
+	config.EVENT_PUBLISHER.ZEROMQ_CONFIGURATION.CURVE.ALLOWED_CLIENTS_PATH = t.TempDir()
+
+	problems := validationProblems(t, config)
+
+	if len(problems) != 3 {
+		t.Fatalf("Expected 3 problems, got %v", problems)
+	}
+	assertProblem(t, problems, "HOWLITE_RESOURCE_HTTP_SERVER_READ_TIMEOUT must be a duration")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_CACHE_TTL must be positive")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_ZEROMQ_CURVE_SERVER_CERT_PATH must point at an existing file")
+}
+
+func TestValidateShouldReportInvalidReloadWatchInterval(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.RELOAD.WATCH_INTERVAL = "often"
+
+	problems := validationProblems(t, config)
+
+	if len(problems) != 1 {
+		t.Fatalf("Expected 1 problem, got %v", problems)
+	}
+	assertProblem(t, problems, "HOWLITE_RESOURCE_RELOAD_WATCH_INTERVAL must be a duration")
+}
+
+func TestConfigureEnvironmentVariablesShouldReturnParseErrors(t *testing.T) {
+	t.Setenv("HOWLITE_RESOURCE_HTTP_SERVER_PORT", "http")
+
+	if err := configuration.ConfigureEnvironmentVariables(configuration.NewConfiguration()); err == nil {
+		t.Fatalf("Expected an error")
+	}
+}
+
+func TestValidateShouldReportUnknownEventFormat(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.EVENT_PUBLISHER.FORMAT = "avro"
+
+	problems := validationProblems(t, config)
+
+	if len(problems) != 1 {
+		t.Fatalf("Expected 1 problem, got %v", problems)
+	}
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_FORMAT must be one of")
+}
+
+func TestValidateShouldReportNatsProblems(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.EVENT_PUBLISHER.ZEROMQ_CONFIGURATION.ENDPOINT = "tcp://*:5556"
+	config.EVENT_PUBLISHER.NATS_CONFIGURATION.URL = "nats://localhost:4222"
+	config.EVENT_PUBLISHER.NATS_CONFIGURATION.CONTENT_MODE = "binary"
+	config.EVENT_PUBLISHER.NATS_CONFIGURATION.TLS.CERT_PATH = filepath.Join(t.TempDir(), "missing.crt")
+
+	problems := validationProblems(t, config)
+
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_URL can't be set along with HOWLITE_RESOURCE_EVENT_PUBLISHER_ZEROMQ_ENDPOINT")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_CONTENT_MODE binary requires HOWLITE_RESOURCE_EVENT_PUBLISHER_FORMAT to be cloudevents")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_TLS_CERT_PATH must point at an existing file")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_TLS_CERT_PATH and HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_TLS_KEY_PATH must be set together")
+}
+
+func TestValidateShouldReportKafkaProblems(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.EVENT_PUBLISHER.NATS_CONFIGURATION.URL = "nats://localhost:4222"
+	config.EVENT_PUBLISHER.KAFKA_CONFIGURATION.BROKERS = []string{"localhost:9092"}
+	config.EVENT_PUBLISHER.KAFKA_CONFIGURATION.DELIVERY_TIMEOUT = "soon"
+	config.EVENT_PUBLISHER.KAFKA_CONFIGURATION.SASL.MECHANISM = "gssapi"
+
+	problems := validationProblems(t, config)
+
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_BROKERS can't be set along with HOWLITE_RESOURCE_EVENT_PUBLISHER_NATS_URL")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_DELIVERY_TIMEOUT must be a duration")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_SASL_MECHANISM must be one of")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_KAFKA_SASL_USERNAME is required")
+}
+
+func TestValidateShouldRequireOutboxForWebhooks(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.EVENT_PUBLISHER.WEBHOOK_CONFIGURATION.ENDPOINTS_PATH = filepath.Join(t.TempDir(), "missing.json")
+	config.EVENT_PUBLISHER.WEBHOOK_CONFIGURATION.MAX_ATTEMPTS = 0
+
+	problems := validationProblems(t, config)
+
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_ENDPOINTS_PATH requires HOWLITE_RESOURCE_EVENT_PUBLISHER_OUTBOX_SQLITE_PATH")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_ENDPOINTS_PATH must point at an existing file")
+	assertProblem(t, problems, "HOWLITE_RESOURCE_EVENT_PUBLISHER_WEBHOOK_MAX_ATTEMPTS must be at least 1")
+}
+
+func TestValidateShouldRejectSeedingResourcesOverallWithPeers(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.CACHE.PEERS.ENDPOINTS = []string{"tcp://peer:5556"}
+	config.METRICS.SEED_RESOURCES_OVERALL = true
+
+	problems := validationProblems(t, config)
+
+	assertProblem(t, problems, "HOWLITE_RESOURCE_METRICS_SEED_RESOURCES_OVERALL must be false when running multiple instances with HOWLITE_RESOURCE_CACHE_PEERS_ENDPOINTS")
+}
+
+func TestValidateShouldRejectUploadsWithPeers(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.CACHE.PEERS.ENDPOINTS = []string{"tcp://peer:5556"}
+	config.UPLOAD.ENABLED = true
+
+	problems := validationProblems(t, config)
+
+	assertProblem(t, problems, "HOWLITE_RESOURCE_UPLOAD_ENABLED must be false when running multiple instances with HOWLITE_RESOURCE_CACHE_PEERS_ENDPOINTS")
+}
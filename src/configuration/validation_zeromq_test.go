@@ -0,0 +1,14 @@
+//go:build unit && !nozmq
+
+package configuration_test
+
+import "testing"
+
+func TestValidateShouldAcceptPeersWhenUploadsAreDisabled(t *testing.T) {
+	config := newDefaultConfiguration(t)
+	config.CACHE.PEERS.ENDPOINTS = []string{"tcp://peer:5556"}
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !nozmq
+
+package configuration
+
+// zeroMqAvailable reports whether the binary includes the cgo zero mq
+// bindings, which the nozmq tag leaves out.
+const zeroMqAvailable = true
@@ -0,0 +1,5 @@
+//go:build nozmq
+
+package configuration
+
+const zeroMqAvailable = false
@@ -2,25 +2,52 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"time"
 
+	"github.com/inx51/howlite-resources/accesslog"
 	"github.com/inx51/howlite-resources/configuration"
 	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/health"
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/http/authz"
 	"github.com/inx51/howlite-resources/http/handlers"
 	"github.com/inx51/howlite-resources/http/server"
 	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/meter"
 	"github.com/inx51/howlite-resources/storage"
 	"github.com/inx51/howlite-resources/storage/azureblob"
+	"github.com/inx51/howlite-resources/storage/cache"
+	"github.com/inx51/howlite-resources/storage/dapr"
 	"github.com/inx51/howlite-resources/storage/filesystem"
+	"github.com/inx51/howlite-resources/storage/gcs"
+	"github.com/inx51/howlite-resources/storage/memory"
 	"github.com/inx51/howlite-resources/storage/s3"
+	"github.com/inx51/howlite-resources/upload"
 )
 
 type Container struct {
-	storage      storage.Storage
-	handlers     *[]handlers.Handler
-	server       *server.Server
-	bus          *event.Bus
-	outboxWorker *event.OutboxWorker
+	storage       storage.Storage
+	handlers      *[]handlers.Handler
+	server        *server.Server
+	bus           *event.Bus
+	outboxWorker  *event.OutboxWorker
+	uploads       *upload.Store
+	uploadWorker  *upload.ExpirationWorker
+	uploadLimit   int64
+	auth          *auth.Chain
+	authz         *authz.Authorizer
+	authzWorker   *authz.ReloadWorker
+	cachePeers    *event.Subscriber
+	metrics       *server.Server
+	metricsOption server.Option
+	accessLog     *accesslog.Logger
+	publisher     event.Publisher
+	outbox        *event.Outbox
+	health        *health.Checker
+	shutdownDelay time.Duration
+	seedResources bool
 }
 
 func NewContainer() *Container {
@@ -36,12 +63,55 @@ func (container *Container) setupStorage(ctx context.Context, configuration conf
 		container.storage = azureblob.NewStorage(&configuration.STORAGE_PROVIDER_AZBLOB)
 	case "s3":
 		container.storage = s3.NewStorage(ctx, &configuration.STORAGE_PROVIDER_S3)
+	case "gcs":
+		container.storage = gcs.NewStorage(ctx, &configuration.STORAGE_PROVIDER_GCS)
+	case "dapr":
+		container.storage = dapr.NewStorage(&configuration.STORAGE_PROVIDER_DAPR)
+	case "memory":
+		container.storage = memory.NewStorage(&configuration.STORAGE_PROVIDER_MEMORY)
 	default:
 		panic("Unsupported storage provider: " + storageProviderName)
 	}
+	container.storage = storage.NewMeteredStorage(container.storage)
 	logger.Info(ctx, "Storage provider loaded", "provider", container.storage.GetName())
 }
 
+func (container *Container) setupCache(ctx context.Context, configuration configuration.Cache) {
+	if configuration.MEMORY_MAX_SIZE <= 0 && configuration.DISK_PATH == "" {
+		logger.Info(ctx, "No cache configured, resources will be read from the storage provider")
+		return
+	}
+
+	ttl, err := time.ParseDuration(configuration.TTL)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheStorage, err := cache.NewStorage(ctx, container.storage, cache.Options{
+		MemoryMaxSize:   configuration.MEMORY_MAX_SIZE,
+		DiskPath:        configuration.DISK_PATH,
+		DiskMaxSize:     configuration.DISK_MAX_SIZE,
+		MaxResourceSize: configuration.MAX_RESOURCE_SIZE,
+		Ttl:             ttl,
+	})
+	if err != nil {
+		panic(err)
+	}
+	container.storage = cacheStorage
+	logger.Info(ctx, "Cache enabled", "memoryMaxSize", configuration.MEMORY_MAX_SIZE, "diskPath", configuration.DISK_PATH, "ttl", ttl)
+
+	if len(configuration.PEERS.ENDPOINTS) == 0 {
+		logger.Info(ctx, "No cache peers specified, only changes made by this instance will invalidate the cache")
+		return
+	}
+
+	subscriber := event.NewSubscriber(ctx, configuration.PEERS, cacheStorage.HandleEvent)
+	if !subscriber.IsAvailable() {
+		panic("Failed to subscribe to the events of the cache peers")
+	}
+	container.cachePeers = &subscriber
+}
+
 func (container *Container) setupHandlers() {
 	container.handlers = &[]handlers.Handler{
 		handlers.NewGetHandler(&container.storage),
@@ -51,40 +121,269 @@ func (container *Container) setupHandlers() {
 		handlers.NewExistsHandler(&container.storage),
 		handlers.NewSysProbeHandler(),
 	}
+	if container.uploads != nil {
+		*container.handlers = append(*container.handlers,
+			handlers.NewUploadOptionsHandler(container.uploadLimit),
+			handlers.NewUploadCreateHandler(&container.storage, container.bus, container.uploads, container.uploadLimit),
+			handlers.NewUploadOffsetHandler(container.uploads),
+			handlers.NewUploadPatchHandler(&container.storage, container.bus, container.uploads),
+			handlers.NewUploadTerminateHandler(container.uploads),
+		)
+	}
 }
 
-func (container *Container) setupEventPublisher(ctx context.Context, configuration configuration.EventPublisher) {
+func (container *Container) setupUploads(ctx context.Context, configuration configuration.Upload) {
+	if !configuration.ENABLED {
+		logger.Info(ctx, "Resumable uploads disabled")
+		return
+	}
 
-	var publisherPtr *event.Publisher
-	var outboxPtr *event.Outbox
+	expiration, err := time.ParseDuration(configuration.EXPIRATION)
+	if err != nil {
+		panic(err)
+	}
 
-	if configuration.ZEROMQ_CONFIGURATION.ENDPOINT != "" {
-		if configuration.ZEROMQ_CONFIGURATION.CURVE.SERVER_CERT_PATH == "" {
-			logger.Info(ctx, "No CURVE server cert path specified, zero mq connection will not be encrypted")
+	uploads, err := upload.NewStore(configuration.STAGING_PATH, expiration)
+	if err != nil {
+		panic(err)
+	}
+	container.uploads = uploads
+	uploadWorker := upload.NewExpirationWorker(ctx, container.uploads, time.Minute)
+	container.uploadWorker = &uploadWorker
+	container.uploadLimit = configuration.MAX_SIZE
+	logger.Info(ctx, "Resumable uploads enabled", "stagingPath", configuration.STAGING_PATH, "expiration", expiration)
+}
+
+func (container *Container) setupAuthentication(ctx context.Context, configuration configuration.Authentication) {
+	authenticators, err := buildAuthenticators(ctx, configuration)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(authenticators) == 0 {
+		logger.Info(ctx, "No authenticators configured, requests will not be authenticated")
+		return
+	}
+
+	container.auth = auth.NewChain(authenticators...)
+	logger.Info(ctx, "Authentication enabled", "authenticators", len(authenticators))
+}
+
+// buildAuthenticators loads the keys of every configured authenticator, it is
+// also used to replace the authenticators when the configuration is reloaded.
+func buildAuthenticators(ctx context.Context, configuration configuration.Authentication) ([]auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+
+	if configuration.API_KEYS_PATH != "" {
+		apiKeyAuthenticator, err := auth.NewApiKeyAuthenticator(configuration.API_KEYS_PATH)
+		if err != nil {
+			return nil, err
 		}
+		authenticators = append(authenticators, apiKeyAuthenticator)
+	}
 
-		publisher := event.NewPublisher(ctx, configuration.ZEROMQ_CONFIGURATION)
-		if !publisher.IsAvailable() {
-			logger.Error(ctx, "Event publisher configured but unavailable in this build")
-			return
+	if configuration.JWT.JWKS_PATH != "" || configuration.JWT.ISSUER != "" {
+		refreshInterval, err := time.ParseDuration(configuration.JWT.REFRESH_INTERVAL)
+		if err != nil {
+			return nil, err
 		}
-		publisherPtr = &publisher
-	} else {
-		logger.Info(ctx, "No event publisher endpoint specified, events will not be published")
+		jwtAuthenticator, err := auth.NewJwtAuthenticator(ctx, auth.JwtOptions{
+			JwksPath:        configuration.JWT.JWKS_PATH,
+			Issuer:          configuration.JWT.ISSUER,
+			Audience:        configuration.JWT.AUDIENCE,
+			RolesClaim:      configuration.JWT.ROLES_CLAIM,
+			RefreshInterval: refreshInterval,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+
+	if configuration.HMAC_KEYS_PATH != "" {
+		maxClockSkew, err := time.ParseDuration(configuration.HMAC_MAX_CLOCK_SKEW)
+		if err != nil {
+			return nil, err
+		}
+		hmacAuthenticator, err := auth.NewHmacAuthenticator(configuration.HMAC_KEYS_PATH, maxClockSkew)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, hmacAuthenticator)
+	}
+
+	return authenticators, nil
+}
+
+func (container *Container) setupAuthorization(ctx context.Context, configuration configuration.Authorization) {
+	if configuration.POLICY_PATH == "" {
+		logger.Info(ctx, "No authorization policy configured, requests will not be authorized")
 		return
 	}
 
+	reloadInterval, err := time.ParseDuration(configuration.RELOAD_INTERVAL)
+	if err != nil {
+		panic(err)
+	}
+
+	authorizer, err := authz.NewAuthorizer(configuration.POLICY_PATH)
+	if err != nil {
+		panic(err)
+	}
+	container.authz = authorizer
+	authzWorker := authz.NewReloadWorker(ctx, authorizer, reloadInterval)
+	container.authzWorker = &authzWorker
+	logger.Info(ctx, "Authorization enabled", "policyPath", configuration.POLICY_PATH, "reloadInterval", reloadInterval)
+}
+
+func (container *Container) setupEventPublisher(ctx context.Context, configuration configuration.EventPublisher) {
+
+	var outboxPtr *event.Outbox
 	if configuration.OUTBOX_SQLITE_PATH != "" {
 		outbox := event.NewOutbox(ctx, configuration.OUTBOX_SQLITE_PATH)
 		outboxPtr = &outbox
+	}
 
-		outboxWorker := event.NewOutboxWorker(ctx, outboxPtr, publisherPtr)
+	publisher := newEventPublisher(ctx, configuration)
+	if publisher == nil {
+		logger.Info(ctx, "No event publisher endpoint specified, events will not be published")
+		outboxPtr.Close(ctx)
+		return
+	}
+	container.publisher = publisher
+	if !publisher.IsAvailable() {
+		logger.Error(ctx, "Event publisher configured but unavailable")
+		outboxPtr.Close(ctx)
+		return
+	}
+
+	if outboxPtr != nil {
+		container.outbox = outboxPtr
+
+		outboxWorker := event.NewOutboxWorker(ctx, outboxPtr, publisher)
 		container.outboxWorker = &outboxWorker
 	} else {
 		logger.Info(ctx, "No outbox path specified, published events will not be persisted")
 	}
 
-	container.bus = event.NewBus(publisherPtr, outboxPtr) // One or both can be nil
+	encoder := event.NewEncoder(configuration.FORMAT, configuration.SOURCE)
+	container.bus = event.NewBus(publisher, outboxPtr, event.WithEncoder(encoder)) // The outbox can be nil
+	logger.Info(ctx, "Events will be published", "format", configuration.FORMAT)
+}
+
+// newEventPublisher creates the publisher of the configured transport, or
+// returns nil if no transport is configured.
+func newEventPublisher(ctx context.Context, configuration configuration.EventPublisher) event.Publisher {
+	if configuration.WEBHOOK_CONFIGURATION.ENDPOINTS_PATH != "" {
+		publisher := event.NewWebhookPublisher(ctx, configuration.WEBHOOK_CONFIGURATION, configuration.FORMAT)
+		return &publisher
+	}
+
+	if len(configuration.KAFKA_CONFIGURATION.BROKERS) > 0 {
+		publisher := event.NewKafkaPublisher(ctx, configuration.KAFKA_CONFIGURATION)
+		return &publisher
+	}
+
+	if configuration.NATS_CONFIGURATION.URL != "" {
+		publisher := event.NewNatsPublisher(ctx, configuration.NATS_CONFIGURATION)
+		return &publisher
+	}
+
+	if configuration.ZEROMQ_CONFIGURATION.ENDPOINT != "" {
+		if configuration.ZEROMQ_CONFIGURATION.CURVE.SERVER_CERT_PATH == "" {
+			logger.Info(ctx, "No CURVE server cert path specified, zero mq connection will not be encrypted")
+		}
+		publisher := event.NewZeroMqPublisher(ctx, configuration.ZEROMQ_CONFIGURATION)
+		return &publisher
+	}
+
+	return nil
+}
+
+// setupHealth registers the readiness checks of the storage provider and, if
+// configured, the event publisher and its outbox.
+func (container *Container) setupHealth(ctx context.Context, configuration configuration.Health) {
+	checkTimeout, err := time.ParseDuration(configuration.CHECK_TIMEOUT)
+	if err != nil {
+		panic(err)
+	}
+	cacheTTL, err := time.ParseDuration(configuration.CACHE_TTL)
+	if err != nil {
+		panic(err)
+	}
+	if configuration.SHUTDOWN_DELAY != "" {
+		container.shutdownDelay, err = time.ParseDuration(configuration.SHUTDOWN_DELAY)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	container.health = health.NewChecker(checkTimeout, cacheTTL)
+	container.health.Register("storage", container.storage.CheckHealth)
+	if checker, ok := container.publisher.(event.HealthChecker); ok {
+		container.health.Register("event_publisher", checker.CheckHealth)
+	} else if container.publisher != nil {
+		publisher := container.publisher
+		container.health.Register("event_publisher", func(ctx context.Context) error {
+			if !publisher.IsAvailable() {
+				return errors.New("event publisher is not available")
+			}
+			return nil
+		})
+	}
+	if container.outbox != nil {
+		container.health.Register("event_outbox", container.outbox.CheckHealth)
+	}
+	logger.Info(ctx, "Readiness checks enabled", "checkTimeout", checkTimeout, "shutdownDelay", container.shutdownDelay)
+}
+
+func (container *Container) setupMetrics(ctx context.Context, configuration configuration.Metrics, handler http.Handler) {
+	err := meter.SetupRoutes(configuration.ROUTE_SEGMENTS, configuration.ROUTE_PATTERNS)
+	if err != nil {
+		panic(err)
+	}
+
+	container.seedResources = configuration.SEED_RESOURCES_OVERALL
+
+	if handler == nil {
+		return
+	}
+
+	if configuration.PROMETHEUS_ADDRESS != "" {
+		container.metrics = server.NewMetricsServer(configuration.PROMETHEUS_ADDRESS, configuration.PROMETHEUS_PATH, handler)
+		logger.Info(ctx, "Prometheus metrics enabled", "address", configuration.PROMETHEUS_ADDRESS, "path", configuration.PROMETHEUS_PATH)
+		return
+	}
+
+	container.metricsOption = server.WithMetrics(configuration.PROMETHEUS_PATH, handler)
+	logger.Info(ctx, "Prometheus metrics enabled", "path", configuration.PROMETHEUS_PATH)
+}
+
+// seedResourcesOverall counts the stored resources so resources_overall starts
+// from the actual number rather than zero. It runs before the server accepts
+// requests and records the count as the value of the gauge.
+func (container *Container) seedResourcesOverall(ctx context.Context) {
+	start := time.Now()
+	count, err := storage.CountResources(ctx, container.storage)
+	if err != nil {
+		logger.Error(ctx, "Failed to seed resources_overall from storage, it only follows the changes of this instance", "duration", time.Since(start), "error", err)
+		return
+	}
+	meter.RecordInt64Gauge(ctx, handlers.ResourcesOverall, count)
+	logger.Info(ctx, "Seeded resources_overall from storage", "count", count, "duration", time.Since(start))
+}
+
+func (container *Container) setupAccessLog(ctx context.Context, configuration configuration.AccessLog) {
+	if !configuration.ENABLED {
+		return
+	}
+
+	accessLog, err := accesslog.NewLogger(configuration.FORMAT, configuration.OUTPUT, configuration.MAX_SIZE, configuration.MAX_BACKUPS)
+	if err != nil {
+		panic(err)
+	}
+	container.accessLog = accessLog
+	logger.Info(ctx, "Access log enabled", "format", configuration.FORMAT, "output", configuration.OUTPUT)
 }
 
 func (container *Container) setupHttpServer(configuration configuration.HttpServer) {
@@ -102,11 +401,26 @@ func (container *Container) setupHttpServer(configuration configuration.HttpServ
 		panic(err)
 	}
 
+	opts := []server.Option{server.WithStorageProvider(container.storage.GetName()), server.WithHealth(container.health)}
+	if container.auth != nil {
+		opts = append(opts, server.WithAuthenticator(container.auth))
+	}
+	if container.authz != nil {
+		opts = append(opts, server.WithAuthorizer(container.authz))
+	}
+	if container.metricsOption != nil {
+		opts = append(opts, container.metricsOption)
+	}
+	if container.accessLog != nil {
+		opts = append(opts, server.WithAccessLog(container.accessLog))
+	}
+
 	container.server = server.NewServer(
 		configuration.HOST,
 		configuration.PORT,
 		container.handlers,
 		readTimeout,
 		writeTimeout,
-		idleTimeout)
+		idleTimeout,
+		opts...)
 }
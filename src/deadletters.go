@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/event"
+)
+
+// runDeadLettersCommand lists or replays the dead letters of the webhook
+// publisher, which are kept in the outbox database.
+func runDeadLettersCommand(ctx context.Context, args *arguments, configurations *configuration.Configuration, output io.Writer) error {
+	sqlitePath := configurations.EVENT_PUBLISHER.OUTBOX_SQLITE_PATH
+	if sqlitePath == "" {
+		return errors.New("dead letters are kept in the outbox, HOWLITE_RESOURCE_EVENT_PUBLISHER_OUTBOX_SQLITE_PATH is not set")
+	}
+
+	outbox := event.NewOutbox(ctx, sqlitePath)
+	defer outbox.Close(ctx)
+
+	if args.command == "dead-letters list" {
+		deadLetters, err := outbox.DeadLetters(ctx)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(deadLetters)
+	}
+
+	ids, err := deadLetterIds(ctx, &outbox, args.operands)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := outbox.Replay(ctx, id); err != nil {
+			return fmt.Errorf("failed to replay dead letter %d: %w", id, err)
+		}
+		fmt.Fprintf(output, "Replayed dead letter %d\n", id)
+	}
+	return nil
+}
+
+// deadLetterIds parses the ids to replay, all replays every dead letter.
+func deadLetterIds(ctx context.Context, outbox *event.Outbox, operands []string) ([]int64, error) {
+	if len(operands) == 1 && operands[0] == "all" {
+		deadLetters, err := outbox.DeadLetters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]int64, 0, len(deadLetters))
+		for _, deadLetter := range deadLetters {
+			ids = append(ids, deadLetter.Id)
+		}
+		return ids, nil
+	}
+
+	ids := make([]int64, 0, len(operands))
+	for _, operand := range operands {
+		id, err := strconv.ParseInt(operand, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dead letter id %q", operand)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -2,43 +2,72 @@ package event
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/inx51/howlite-resources/logger"
 )
 
 type Bus struct {
 	outbox    *Outbox
-	publisher *Publisher
+	publisher Publisher
+	encoder   Encoder
 }
 
-func NewBus(publisher *Publisher, outbox *Outbox) *Bus {
-	return &Bus{
+type BusOption func(*Bus)
+
+// WithEncoder encodes the published envelopes with encoder instead of in the
+// legacy format.
+func WithEncoder(encoder Encoder) BusOption {
+	return func(bus *Bus) {
+		bus.encoder = encoder
+	}
+}
+
+// NewBus creates a bus that enqueues the events in outbox, or publishes them
+// directly with publisher when there is no outbox. Both can be nil.
+func NewBus(publisher Publisher, outbox *Outbox, opts ...BusOption) *Bus {
+	bus := &Bus{
 		publisher: publisher,
 		outbox:    outbox,
+		encoder:   NewEncoder(FormatLegacy, ""),
 	}
+	for _, opt := range opts {
+		opt(bus)
+	}
+	return bus
 }
 
-func (bus *Bus) Publish(ctx context.Context, eventType string, eventData any) {
-	envelope, err := NewEnvelope(eventType, eventData)
+// Publish publishes an event about subject, the identifier of the resource the
+// event is about. It does nothing on a nil bus, which is used when no event
+// publisher is configured.
+func (bus *Bus) Publish(ctx context.Context, eventType string, subject string, eventData any) {
+	if bus == nil {
+		return
+	}
+
+	envelope, err := NewEnvelope(ctx, eventType, subject, eventData)
 	if err != nil {
 		logger.Error(ctx, "failed to build envelope", "error", err)
 		return
 	}
 
-	msg, err := json.Marshal(envelope)
+	payload, err := bus.encoder.Encode(envelope)
 	if err != nil {
 		logger.Error(ctx, "failed to marshall event", "error", err)
 		return
 	}
 
-	logger.Debug(ctx, "Sending event", "event", string(msg))
+	message := &Message{Type: eventType, Subject: subject, Payload: payload}
+	logger.Debug(ctx, "Sending event", "event", string(payload))
 	if bus.outbox != nil {
-		bus.outbox.Enqueue(ctx, msg)
+		bus.outbox.Enqueue(ctx, message)
 		return
 	}
 
 	if bus.publisher != nil {
-		bus.publisher.Publish(ctx, msg)
+		if err := bus.publisher.Publish(ctx, message); err != nil {
+			logger.Error(ctx, "failed to publish event", "error", err)
+			return
+		}
+		logger.Info(ctx, "Event published")
 	}
 }
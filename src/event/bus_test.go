@@ -0,0 +1,16 @@
+//go:build unit
+
+package event_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inx51/howlite-resources/event"
+)
+
+func TestPublishShouldIgnoreNilBus(t *testing.T) {
+	var bus *event.Bus
+
+	bus.Publish(context.Background(), "ResourceCreated", "/a.txt", struct{}{})
+}
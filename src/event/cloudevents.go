@@ -0,0 +1,161 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// FormatLegacy encodes envelopes as {data, type, traceContext}.
+	FormatLegacy = "legacy"
+	// FormatCloudEvents encodes envelopes as CloudEvents 1.0 in structured mode.
+	FormatCloudEvents = "cloudevents"
+
+	CloudEventsContentType = "application/cloudevents+json"
+	cloudEventsSpecVersion = "1.0"
+	dataContentType        = "application/json"
+)
+
+// cloudEvent is the structured mode JSON representation of an envelope. Its
+// trace context is carried by the distributed tracing extension attributes.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	Traceparent     string          `json:"traceparent,omitempty"`
+	Tracestate      string          `json:"tracestate,omitempty"`
+	Baggage         string          `json:"baggage,omitempty"`
+}
+
+// Encoder marshals envelopes in the configured format, source is the
+// CloudEvents source of the events of this instance.
+type Encoder struct {
+	format string
+	source string
+}
+
+func NewEncoder(format string, source string) Encoder {
+	return Encoder{format: format, source: source}
+}
+
+func (encoder Encoder) Encode(envelope *Envelope) ([]byte, error) {
+	if encoder.format != FormatCloudEvents {
+		return json.Marshal(envelope)
+	}
+
+	return json.Marshal(&cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Id:              envelope.Id,
+		Source:          encoder.source,
+		Type:            envelope.Type,
+		Subject:         envelope.Subject,
+		Time:            envelope.Time,
+		DataContentType: dataContentType,
+		Data:            envelope.Data,
+		Traceparent:     envelope.TraceContext["traceparent"],
+		Tracestate:      envelope.TraceContext["tracestate"],
+		Baggage:         envelope.TraceContext["baggage"],
+	})
+}
+
+// DecodeEnvelope unmarshals an envelope encoded in either format, so instances
+// publishing different formats can still be peers.
+func DecodeEnvelope(payload []byte) (*Envelope, error) {
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, err
+	}
+
+	if probe.SpecVersion == "" {
+		var envelope Envelope
+		if err := json.Unmarshal(payload, &envelope); err != nil {
+			return nil, err
+		}
+		return &envelope, nil
+	}
+
+	var event cloudEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	if event.SpecVersion != cloudEventsSpecVersion {
+		return nil, fmt.Errorf("unsupported CloudEvents spec version %q", event.SpecVersion)
+	}
+
+	traceContext := map[string]string{}
+	for key, value := range map[string]string{"traceparent": event.Traceparent, "tracestate": event.Tracestate, "baggage": event.Baggage} {
+		if value != "" {
+			traceContext[key] = value
+		}
+	}
+	if len(traceContext) == 0 {
+		traceContext = nil
+	}
+
+	return &Envelope{
+		Id:           event.Id,
+		Type:         event.Type,
+		Subject:      event.Subject,
+		Time:         event.Time,
+		Data:         event.Data,
+		TraceContext: traceContext,
+	}, nil
+}
+
+// BinaryMessage is a CloudEvent in binary content mode, for transports with
+// headers. Its attributes are carried by the headers and its data by the body.
+type BinaryMessage struct {
+	Attributes  map[string]string
+	ContentType string
+	Data        []byte
+}
+
+// ToBinary converts a CloudEvent encoded in structured mode to binary mode.
+func ToBinary(payload []byte) (*BinaryMessage, error) {
+	var event cloudEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	if event.SpecVersion == "" {
+		return nil, errors.New("payload is not a CloudEvent")
+	}
+
+	attributes := map[string]string{
+		"specversion": event.SpecVersion,
+		"id":          event.Id,
+		"source":      event.Source,
+		"type":        event.Type,
+		"time":        event.Time.Format(time.RFC3339Nano),
+	}
+	for key, value := range map[string]string{"subject": event.Subject, "traceparent": event.Traceparent, "tracestate": event.Tracestate, "baggage": event.Baggage} {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+
+	return &BinaryMessage{
+		Attributes:  attributes,
+		ContentType: event.DataContentType,
+		Data:        event.Data,
+	}, nil
+}
+
+// Headers returns the attributes as headers named with prefix, which is "ce-"
+// for HTTP and NATS and "ce_" for Kafka, along with the content type.
+func (message *BinaryMessage) Headers(prefix string) map[string]string {
+	headers := make(map[string]string, len(message.Attributes)+1)
+	for key, value := range message.Attributes {
+		headers[prefix+key] = value
+	}
+	headers["content-type"] = message.ContentType
+	return headers
+}
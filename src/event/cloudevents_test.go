@@ -0,0 +1,143 @@
+//go:build unit
+
+package event_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/inx51/howlite-resources/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracedContext(t *testing.T) context.Context {
+	t.Helper()
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceId, SpanID: spanId, TraceFlags: trace.FlagsSampled})
+	return trace.ContextWithSpanContext(context.Background(), spanContext)
+}
+
+func newEnvelope(t *testing.T, ctx context.Context) *event.Envelope {
+	t.Helper()
+	envelope, err := event.NewEnvelope(ctx, "ResourceCreated", "/images/cat.png", map[string]string{"ResourceIdentity": "/images/cat.png"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return envelope
+}
+
+func TestEncodeShouldKeepLegacyFormat(t *testing.T) {
+	payload, err := event.NewEncoder(event.FormatLegacy, "").Encode(newEnvelope(t, context.Background()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(fields) != 2 || fields["type"] == nil || fields["data"] == nil {
+		t.Fatalf("Expected only type and data, got %s", payload)
+	}
+}
+
+func TestEncodeShouldWriteStructuredCloudEvent(t *testing.T) {
+	envelope := newEnvelope(t, context.Background())
+
+	payload, err := event.NewEncoder(event.FormatCloudEvents, "/howlite-resources").Encode(envelope)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := map[string]string{
+		"specversion":     "1.0",
+		"id":              envelope.Id,
+		"source":          "/howlite-resources",
+		"type":            "ResourceCreated",
+		"subject":         "/images/cat.png",
+		"datacontenttype": "application/json",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Fatalf("Expected %s to be %q, got %v", key, value, fields[key])
+		}
+	}
+	if envelope.Id == "" {
+		t.Fatalf("Expected an event id")
+	}
+}
+
+func TestDecodeEnvelopeShouldReadBothFormats(t *testing.T) {
+	ctx := propagationContext(t)
+	envelope := newEnvelope(t, ctx)
+
+	for _, format := range []string{event.FormatLegacy, event.FormatCloudEvents} {
+		payload, err := event.NewEncoder(format, "/howlite-resources").Encode(envelope)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		decoded, err := event.DecodeEnvelope(payload)
+
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", format, err)
+		}
+		if decoded.Type != envelope.Type || string(decoded.Data) != string(envelope.Data) {
+			t.Fatalf("Expected %s envelope %v, got %v", format, envelope, decoded)
+		}
+		if decoded.TraceContext["traceparent"] != envelope.TraceContext["traceparent"] {
+			t.Fatalf("Expected %s traceparent %s, got %v", format, envelope.TraceContext["traceparent"], decoded.TraceContext)
+		}
+	}
+}
+
+func TestToBinaryShouldMoveAttributesToHeaders(t *testing.T) {
+	envelope := newEnvelope(t, propagationContext(t))
+	payload, err := event.NewEncoder(event.FormatCloudEvents, "/howlite-resources").Encode(envelope)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	message, err := event.ToBinary(payload)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	headers := message.Headers("ce-")
+	if headers["ce-id"] != envelope.Id || headers["ce-subject"] != "/images/cat.png" || headers["content-type"] != "application/json" {
+		t.Fatalf("Expected the attributes as headers, got %v", headers)
+	}
+	if headers["ce-traceparent"] == "" {
+		t.Fatalf("Expected a traceparent header, got %v", headers)
+	}
+	if string(message.Data) != string(envelope.Data) {
+		t.Fatalf("Expected data %s, got %s", envelope.Data, message.Data)
+	}
+}
+
+func TestToBinaryShouldRejectLegacyPayload(t *testing.T) {
+	payload, err := event.NewEncoder(event.FormatLegacy, "").Encode(newEnvelope(t, context.Background()))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := event.ToBinary(payload); err == nil {
+		t.Fatalf("Expected an error for a legacy payload")
+	}
+}
+
+// propagationContext returns a traced context, with the W3C propagator set so
+// that NewEnvelope injects it.
+func propagationContext(t *testing.T) context.Context {
+	t.Helper()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return newTracedContext(t)
+}
@@ -0,0 +1,109 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is a message that could not be delivered to a webhook endpoint,
+// it is kept in the outbox database until it is replayed.
+type DeadLetter struct {
+	Id        int64     `json:"id"`
+	Endpoint  string    `json:"endpoint"`
+	Type      string    `json:"type"`
+	Subject   string    `json:"subject"`
+	Payload   string    `json:"payload"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	FailedUtc time.Time `json:"failedUtc"`
+}
+
+// Park moves a message returned by Next, that failed to be delivered to its
+// endpoint, from the outbox to the dead letters.
+func (outbox *Outbox) Park(ctx context.Context, message *Message, attempts int, lastError error) error {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	tx, err := outbox.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO dead_letters(endpoint, event_type, subject, payload, attempts, last_error, failed_utc)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, message.Endpoint, message.Type, message.Subject, string(message.Payload), attempts, lastError.Error(), time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, message.outboxId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeadLetters returns all dead letters, oldest first.
+func (outbox *Outbox) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	rows, err := outbox.db.QueryContext(ctx, `
+		SELECT id, endpoint, event_type, subject, payload, attempts, last_error, failed_utc
+		FROM dead_letters
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deadLetters := []DeadLetter{}
+	for rows.Next() {
+		var deadLetter DeadLetter
+		var failedUtc string
+		if err := rows.Scan(&deadLetter.Id, &deadLetter.Endpoint, &deadLetter.Type, &deadLetter.Subject, &deadLetter.Payload, &deadLetter.Attempts, &deadLetter.LastError, &failedUtc); err != nil {
+			return nil, err
+		}
+		deadLetter.FailedUtc, err = time.Parse(time.RFC3339Nano, failedUtc)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	return deadLetters, rows.Err()
+}
+
+// Replay moves a dead letter back to the outbox, from where it is delivered to
+// its endpoint again.
+func (outbox *Outbox) Replay(ctx context.Context, id int64) error {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	tx, err := outbox.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var payload string
+	message := &Message{}
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM dead_letters WHERE id = ?
+		RETURNING endpoint, event_type, subject, payload
+	`, id).Scan(&message.Endpoint, &message.Type, &message.Subject, &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return err
+	}
+	message.Payload = []byte(payload)
+
+	if err := appendMessageToDb(ctx, tx, message, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
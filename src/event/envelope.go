@@ -1,20 +1,72 @@
 package event
 
-import "encoding/json"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
 
 type Envelope struct {
 	Data json.RawMessage `json:"data"`
 	Type string          `json:"type"`
+	// TraceContext holds the W3C trace context and baggage of the request that
+	// caused the event, so consumers can continue its trace.
+	TraceContext map[string]string `json:"traceContext,omitempty"`
+	// Id, Subject and Time are only encoded in the CloudEvents format, which
+	// keeps the legacy format unchanged. Id stays the same when the event is
+	// retried from the outbox, so consumers can deduplicate it.
+	Id      string    `json:"-"`
+	Subject string    `json:"-"`
+	Time    time.Time `json:"-"`
 }
 
-func NewEnvelope(eventType string, eventData any) (*Envelope, error) {
+// NewEnvelope creates the envelope of an event about subject, which is the
+// identifier of the resource the event is about.
+func NewEnvelope(ctx context.Context, eventType string, subject string, eventData any) (*Envelope, error) {
 	raw, err := json.Marshal(eventData)
 	if err != nil {
 		return nil, err
 	}
 
+	id, err := newEventId()
+	if err != nil {
+		return nil, err
+	}
+
+	traceContext := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, traceContext)
+	if len(traceContext) == 0 {
+		traceContext = nil
+	}
+
 	return &Envelope{
-		Type: eventType,
-		Data: raw,
+		Type:         eventType,
+		Data:         raw,
+		TraceContext: traceContext,
+		Id:           id,
+		Subject:      subject,
+		Time:         time.Now().UTC(),
 	}, nil
 }
+
+// Context returns ctx with the trace context of the envelope, if any.
+func (envelope *Envelope) Context(ctx context.Context) context.Context {
+	if len(envelope.TraceContext) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(envelope.TraceContext))
+}
+
+func newEventId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,212 @@
+package event
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+const (
+	kafkaClientId          = "howlite-resources"
+	kafkaContentModeBinary = "binary"
+)
+
+// KafkaPublisher produces the messages to a Kafka topic, keyed by the
+// identifier of their resource so the events of a resource keep their order
+// within a partition, partitioned like the Java client does. The producer is
+// idempotent and waits for all in-sync replicas, so its retries neither
+// duplicate nor reorder the messages.
+type KafkaPublisher struct {
+	client *kgo.Client
+	topic  string
+	binary bool
+}
+
+func NewKafkaPublisher(ctx context.Context, config configuration.KafkaConfiguration) KafkaPublisher {
+	ctx, span := tracer.StartInfoSpan(ctx, "kafka.publisher.init")
+	defer tracer.SafeEndSpan(span)
+
+	brokers := strings.Join(config.BROKERS, ",")
+	logger.Debug(ctx, "Establishing connection to kafka", "brokers", brokers)
+
+	options, err := kafkaOptions(config)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to configure kafka publisher", "error", err)
+		return KafkaPublisher{}
+	}
+
+	client, err := kgo.NewClient(options...)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to create kafka client", "error", err)
+		return KafkaPublisher{}
+	}
+
+	// The client connects lazily, pinging makes unreachable brokers and
+	// rejected credentials fail at startup instead of on the first event.
+	if err := client.Ping(ctx); err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to establish connection to kafka", "brokers", brokers, "error", err)
+		client.Close()
+		return KafkaPublisher{}
+	}
+
+	logger.Info(ctx, "Kafka publisher initialized", "brokers", brokers, "topic", config.TOPIC)
+	return KafkaPublisher{
+		client: client,
+		topic:  config.TOPIC,
+		binary: config.CONTENT_MODE == kafkaContentModeBinary,
+	}
+}
+
+func kafkaOptions(config configuration.KafkaConfiguration) ([]kgo.Opt, error) {
+	deliveryTimeout, err := time.ParseDuration(config.DELIVERY_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []kgo.Opt{
+		kgo.SeedBrokers(config.BROKERS...),
+		kgo.ClientID(kafkaClientId),
+		kgo.DefaultProduceTopic(config.TOPIC),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+		kgo.RecordDeliveryTimeout(deliveryTimeout),
+	}
+
+	if config.SASL.MECHANISM != "" {
+		mechanism, err := kafkaSaslMechanism(config.SASL)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, kgo.SASL(mechanism))
+	}
+
+	if config.TLS.ENABLED {
+		tlsConfig, err := kafkaTlsConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	return options, nil
+}
+
+func kafkaSaslMechanism(config configuration.KafkaSaslConfiguration) (sasl.Mechanism, error) {
+	switch config.MECHANISM {
+	case "plain":
+		return plain.Auth{User: config.USERNAME, Pass: config.PASSWORD}.AsMechanism(), nil
+	case "scram-sha-256":
+		return scram.Auth{User: config.USERNAME, Pass: config.PASSWORD}.AsSha256Mechanism(), nil
+	case "scram-sha-512":
+		return scram.Auth{User: config.USERNAME, Pass: config.PASSWORD}.AsSha512Mechanism(), nil
+	}
+	return nil, fmt.Errorf("unsupported sasl mechanism %q", config.MECHANISM)
+}
+
+// kafkaTlsConfig verifies the brokers with the CAs of CA_PATH, or the system
+// CAs if it is empty, and presents the client certificate if one is set.
+func kafkaTlsConfig(config configuration.KafkaTlsConfiguration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.CA_PATH != "" {
+		pem, err := os.ReadFile(config.CA_PATH)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CA_PATH)
+		}
+	}
+
+	if config.CERT_PATH != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CERT_PATH, config.KEY_PATH)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+func (publisher *KafkaPublisher) IsAvailable() bool {
+	return publisher.client != nil
+}
+
+// CheckHealth checks that the brokers can be reached.
+func (publisher *KafkaPublisher) CheckHealth(ctx context.Context) error {
+	if publisher.client == nil {
+		return errors.New("kafka publisher is not available")
+	}
+	return publisher.client.Ping(ctx)
+}
+
+// Publish produces the message and waits until the brokers acknowledged it, or
+// the delivery timeout expired.
+func (publisher *KafkaPublisher) Publish(ctx context.Context, message *Message) error {
+	if publisher == nil || publisher.client == nil {
+		return errors.New("kafka publisher is not available")
+	}
+
+	ctx, span := tracer.StartDebugSpan(ctx, "kafka.produce")
+	defer tracer.SafeEndSpan(span)
+
+	record, err := publisher.newRecord(message)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+
+	logger.Debug(ctx, "Producing event to kafka", "topic", publisher.topic, "key", message.Subject)
+	if err := publisher.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (publisher *KafkaPublisher) newRecord(message *Message) (*kgo.Record, error) {
+	record := &kgo.Record{Topic: publisher.topic}
+	if message.Subject != "" {
+		record.Key = []byte(message.Subject)
+	}
+	if !publisher.binary {
+		record.Value = message.Payload
+		return record, nil
+	}
+
+	binary, err := ToBinary(message.Payload)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range binary.Headers("ce_") {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+	}
+	record.Value = binary.Data
+	return record, nil
+}
+
+func (publisher *KafkaPublisher) Stop() {
+	if publisher == nil || publisher.client == nil {
+		return
+	}
+
+	publisher.client.Close()
+}
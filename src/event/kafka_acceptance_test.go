@@ -0,0 +1,139 @@
+package event_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/event"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const kafkaTopic = "howlite-resources"
+
+func startKafkaCluster(t *testing.T, opts ...kfake.Opt) *kfake.Cluster {
+	t.Helper()
+	cluster, err := kfake.NewCluster(append([]kfake.Opt{kfake.NumBrokers(1), kfake.SeedTopics(3, kafkaTopic)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(cluster.Close)
+	return cluster
+}
+
+func newKafkaConfiguration(cluster *kfake.Cluster) configuration.KafkaConfiguration {
+	return configuration.KafkaConfiguration{
+		BROKERS:          cluster.ListenAddrs(),
+		TOPIC:            kafkaTopic,
+		DELIVERY_TIMEOUT: "5s",
+		CONTENT_MODE:     "structured",
+	}
+}
+
+func newKafkaPublisher(t *testing.T, config configuration.KafkaConfiguration) *event.KafkaPublisher {
+	t.Helper()
+	publisher := event.NewKafkaPublisher(context.Background(), config)
+	require.True(t, publisher.IsAvailable(), "kafka publisher is not available")
+	t.Cleanup(publisher.Stop)
+	return &publisher
+}
+
+func consumeRecords(t *testing.T, cluster *kfake.Cluster, count int) []*kgo.Record {
+	t.Helper()
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumeTopics(kafkaTopic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var records []*kgo.Record
+	for len(records) < count {
+		fetches := client.PollFetches(ctx)
+		require.NoError(t, ctx.Err(), "received %d of %d records", len(records), count)
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func TestAcceptance_Kafka_KeysRecordsByResourceIdentifier(t *testing.T) {
+	cluster := startKafkaCluster(t)
+	publisher := newKafkaPublisher(t, newKafkaConfiguration(cluster))
+	bus := event.NewBus(publisher, nil)
+
+	for _, eventType := range []string{"ResourceCreated", "ResourceReplaced", "ResourceRemoved"} {
+		bus.Publish(context.Background(), eventType, "/a.txt", struct{}{})
+		bus.Publish(context.Background(), eventType, "/images/b.png", struct{}{})
+	}
+
+	records := consumeRecords(t, cluster, 6)
+
+	partitions := map[string]int32{}
+	types := map[string][]string{}
+	for _, record := range records {
+		key := string(record.Key)
+		if partition, found := partitions[key]; found {
+			require.Equal(t, partition, record.Partition, "records of %s are in different partitions", key)
+		}
+		partitions[key] = record.Partition
+
+		envelope, err := event.DecodeEnvelope(record.Value)
+		require.NoError(t, err)
+		types[key] = append(types[key], envelope.Type)
+	}
+	require.Len(t, partitions, 2)
+	require.Equal(t, []string{"ResourceCreated", "ResourceReplaced", "ResourceRemoved"}, types["/a.txt"])
+	require.Equal(t, []string{"ResourceCreated", "ResourceReplaced", "ResourceRemoved"}, types["/images/b.png"])
+}
+
+func TestAcceptance_Kafka_PublishesCloudEventAttributesAsHeadersInBinaryMode(t *testing.T) {
+	cluster := startKafkaCluster(t)
+	config := newKafkaConfiguration(cluster)
+	config.CONTENT_MODE = "binary"
+	publisher := newKafkaPublisher(t, config)
+	bus := event.NewBus(publisher, nil, event.WithEncoder(event.NewEncoder(event.FormatCloudEvents, "/test")))
+
+	bus.Publish(context.Background(), "ResourceCreated", "/a.txt", map[string]string{"name": "a"})
+
+	record := consumeRecords(t, cluster, 1)[0]
+	headers := map[string]string{}
+	for _, header := range record.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	require.Equal(t, "1.0", headers["ce_specversion"])
+	require.Equal(t, "ResourceCreated", headers["ce_type"])
+	require.Equal(t, "/test", headers["ce_source"])
+	require.Equal(t, "/a.txt", headers["ce_subject"])
+	require.Equal(t, "application/json", headers["content-type"])
+	require.JSONEq(t, `{"name":"a"}`, string(record.Value))
+}
+
+func TestAcceptance_Kafka_AuthenticatesWithSasl(t *testing.T) {
+	cluster := startKafkaCluster(t, kfake.EnableSASL(), kfake.Superuser("SCRAM-SHA-512", "howlite", "secret"))
+
+	config := newKafkaConfiguration(cluster)
+	config.SASL = configuration.KafkaSaslConfiguration{MECHANISM: "scram-sha-512", USERNAME: "howlite", PASSWORD: "wrong"}
+	rejected := event.NewKafkaPublisher(context.Background(), config)
+	require.False(t, rejected.IsAvailable())
+
+	config.SASL.PASSWORD = "secret"
+	publisher := newKafkaPublisher(t, config)
+	require.NoError(t, publisher.Publish(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)}))
+}
+
+func TestAcceptance_Kafka_FailsWhenBrokersDoNotAcknowledge(t *testing.T) {
+	cluster := startKafkaCluster(t)
+	config := newKafkaConfiguration(cluster)
+	config.DELIVERY_TIMEOUT = "1s"
+	publisher := newKafkaPublisher(t, config)
+	cluster.Close()
+
+	err := publisher.Publish(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)})
+	require.Error(t, err)
+	require.Error(t, publisher.CheckHealth(context.Background()))
+}
@@ -0,0 +1,197 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsConnectionName    = "howlite-resources"
+	natsContentModeBinary = "binary"
+)
+
+// NatsPublisher publishes the messages to NATS, or to a JetStream stream in
+// which case every message waits for the acknowledgement of the stream.
+type NatsPublisher struct {
+	connection      *nats.Conn
+	jetStream       jetstream.JetStream
+	subjectTemplate string
+	ackTimeout      time.Duration
+	binary          bool
+}
+
+func NewNatsPublisher(ctx context.Context, config configuration.NatsConfiguration) NatsPublisher {
+	ctx, span := tracer.StartInfoSpan(ctx, "nats.publisher.init")
+	defer tracer.SafeEndSpan(span)
+
+	logger.Debug(ctx, "Establishing connection to nats", "url", config.URL)
+
+	ackTimeout, err := time.ParseDuration(config.ACK_TIMEOUT)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Invalid ack timeout for nats publisher", "error", err)
+		return NatsPublisher{}
+	}
+
+	options, err := natsOptions(config)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to configure authentication for nats publisher", "error", err)
+		return NatsPublisher{}
+	}
+
+	connection, err := nats.Connect(config.URL, options...)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to establish connection to nats", "url", config.URL, "error", err)
+		return NatsPublisher{}
+	}
+
+	publisher := NatsPublisher{
+		connection:      connection,
+		subjectTemplate: config.SUBJECT_TEMPLATE,
+		ackTimeout:      ackTimeout,
+		binary:          config.CONTENT_MODE == natsContentModeBinary,
+	}
+	if config.JETSTREAM {
+		publisher.jetStream, err = jetstream.New(connection)
+		if err != nil {
+			tracer.SafeRecordError(span, err)
+			logger.Error(ctx, "Failed to create jetstream context", "error", err)
+			connection.Close()
+			return NatsPublisher{}
+		}
+	}
+
+	logger.Info(ctx, "Nats publisher initialized", "url", connection.ConnectedUrlRedacted(), "jetStream", config.JETSTREAM)
+	return publisher
+}
+
+// natsOptions returns the options for the authentication and TLS settings of
+// config. Reconnects are retried forever, messages published meanwhile are
+// buffered by the client.
+func natsOptions(config configuration.NatsConfiguration) ([]nats.Option, error) {
+	options := []nats.Option{
+		nats.Name(natsConnectionName),
+		nats.MaxReconnects(-1),
+	}
+
+	if config.CREDENTIALS_PATH != "" {
+		options = append(options, nats.UserCredentials(config.CREDENTIALS_PATH))
+	} else if config.NKEY_SEED_PATH != "" {
+		option, err := nats.NkeyOptionFromSeed(config.NKEY_SEED_PATH)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+
+	if config.TLS.CA_PATH != "" {
+		options = append(options, nats.RootCAs(config.TLS.CA_PATH))
+	}
+	if config.TLS.CERT_PATH != "" {
+		options = append(options, nats.ClientCert(config.TLS.CERT_PATH, config.TLS.KEY_PATH))
+	}
+
+	return options, nil
+}
+
+func (publisher *NatsPublisher) IsAvailable() bool {
+	return publisher.connection != nil && publisher.connection.IsConnected()
+}
+
+func (publisher *NatsPublisher) Publish(ctx context.Context, message *Message) error {
+	if publisher == nil || publisher.connection == nil {
+		return errors.New("nats publisher is not available")
+	}
+
+	ctx, span := tracer.StartDebugSpan(ctx, "nats.publish")
+	defer tracer.SafeEndSpan(span)
+
+	msg, err := publisher.newMsg(message)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+
+	logger.Debug(ctx, "Sending event via nats", "subject", msg.Subject)
+	if publisher.jetStream == nil {
+		err = publisher.connection.PublishMsg(msg)
+	} else {
+		ackCtx, cancel := context.WithTimeout(ctx, publisher.ackTimeout)
+		defer cancel()
+		_, err = publisher.jetStream.PublishMsg(ackCtx, msg)
+	}
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (publisher *NatsPublisher) newMsg(message *Message) (*nats.Msg, error) {
+	msg := nats.NewMsg(natsSubject(publisher.subjectTemplate, message.Type, message.Subject))
+	if !publisher.binary {
+		msg.Data = message.Payload
+		return msg, nil
+	}
+
+	binary, err := ToBinary(message.Payload)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range binary.Headers("ce-") {
+		msg.Header.Set(key, value)
+	}
+	msg.Data = binary.Data
+	return msg, nil
+}
+
+// Stop flushes the messages still buffered by the client and closes the
+// connection.
+func (publisher *NatsPublisher) Stop() {
+	if publisher == nil || publisher.connection == nil {
+		return
+	}
+
+	_ = publisher.connection.FlushTimeout(publisher.ackTimeout)
+	publisher.connection.Close()
+}
+
+// natsSubject expands template with the event type and the segments of path,
+// the identifier of the resource. The characters that are not allowed in
+// tokens are replaced, and empty tokens are left out so an empty path does not
+// leave a trailing dot.
+func natsSubject(template string, eventType string, path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = natsToken(segment)
+	}
+
+	subject := strings.NewReplacer(
+		"{type}", natsToken(eventType),
+		"{path}", strings.Join(segments, "."),
+	).Replace(template)
+
+	tokens := strings.Split(subject, ".")
+	return strings.Join(slices.DeleteFunc(tokens, func(token string) bool { return token == "" }), ".")
+}
+
+func natsToken(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '*' || r == '>' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
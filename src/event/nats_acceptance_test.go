@@ -0,0 +1,155 @@
+package event_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/event"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nkeys"
+	"github.com/stretchr/testify/require"
+)
+
+func startNatsServer(t *testing.T, options *server.Options) *server.Server {
+	t.Helper()
+	options.Host = "127.0.0.1"
+	options.Port = -1
+	options.NoLog = true
+	options.NoSigs = true
+
+	natsServer, err := server.NewServer(options)
+	require.NoError(t, err)
+	go natsServer.Start()
+	t.Cleanup(natsServer.Shutdown)
+	require.True(t, natsServer.ReadyForConnections(10*time.Second), "nats server did not start")
+	return natsServer
+}
+
+func newNatsConfiguration(natsServer *server.Server) configuration.NatsConfiguration {
+	return configuration.NatsConfiguration{
+		URL:              natsServer.ClientURL(),
+		SUBJECT_TEMPLATE: "howlite.resources.{type}.{path}",
+		ACK_TIMEOUT:      "5s",
+		CONTENT_MODE:     "structured",
+	}
+}
+
+func newNatsPublisher(t *testing.T, config configuration.NatsConfiguration) *event.NatsPublisher {
+	t.Helper()
+	publisher := event.NewNatsPublisher(context.Background(), config)
+	require.True(t, publisher.IsAvailable(), "nats publisher is not available")
+	t.Cleanup(publisher.Stop)
+	return &publisher
+}
+
+func subscribe(t *testing.T, natsServer *server.Server, subject string) *nats.Subscription {
+	t.Helper()
+	connection, err := nats.Connect(natsServer.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(connection.Close)
+
+	subscription, err := connection.SubscribeSync(subject)
+	require.NoError(t, err)
+	require.NoError(t, connection.Flush())
+	return subscription
+}
+
+func TestAcceptance_Nats_PublishesToSubjectOfTypeAndPath(t *testing.T) {
+	natsServer := startNatsServer(t, &server.Options{})
+	subscription := subscribe(t, natsServer, "howlite.resources.ResourceCreated.images.>")
+	publisher := newNatsPublisher(t, newNatsConfiguration(natsServer))
+	bus := event.NewBus(publisher, nil)
+
+	bus.Publish(context.Background(), "ResourceCreated", "/images/cat 1.png", map[string]string{"name": "cat"})
+
+	msg, err := subscription.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "howlite.resources.ResourceCreated.images.cat_1_png", msg.Subject)
+
+	envelope, err := event.DecodeEnvelope(msg.Data)
+	require.NoError(t, err)
+	require.Equal(t, "ResourceCreated", envelope.Type)
+	require.JSONEq(t, `{"name":"cat"}`, string(envelope.Data))
+}
+
+func TestAcceptance_Nats_PublishesCloudEventAttributesAsHeadersInBinaryMode(t *testing.T) {
+	natsServer := startNatsServer(t, &server.Options{})
+	subscription := subscribe(t, natsServer, "howlite.resources.>")
+	config := newNatsConfiguration(natsServer)
+	config.CONTENT_MODE = "binary"
+	publisher := newNatsPublisher(t, config)
+	bus := event.NewBus(publisher, nil, event.WithEncoder(event.NewEncoder(event.FormatCloudEvents, "/test")))
+
+	bus.Publish(context.Background(), "ResourceRemoved", "/a.txt", map[string]string{"name": "a"})
+
+	msg, err := subscription.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "1.0", msg.Header.Get("ce-specversion"))
+	require.Equal(t, "ResourceRemoved", msg.Header.Get("ce-type"))
+	require.Equal(t, "/test", msg.Header.Get("ce-source"))
+	require.Equal(t, "/a.txt", msg.Header.Get("ce-subject"))
+	require.Equal(t, "application/json", msg.Header.Get("content-type"))
+	require.JSONEq(t, `{"name":"a"}`, string(msg.Data))
+}
+
+func TestAcceptance_Nats_JetStreamAcknowledgesPublishedMessage(t *testing.T) {
+	natsServer := startNatsServer(t, &server.Options{JetStream: true, StoreDir: t.TempDir()})
+	connection, err := nats.Connect(natsServer.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(connection.Close)
+	js, err := jetstream.New(connection)
+	require.NoError(t, err)
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{
+		Name:     "RESOURCES",
+		Subjects: []string{"howlite.resources.>"},
+	})
+	require.NoError(t, err)
+
+	config := newNatsConfiguration(natsServer)
+	config.JETSTREAM = true
+	publisher := newNatsPublisher(t, config)
+
+	err = publisher.Publish(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)})
+	require.NoError(t, err)
+
+	msg, err := stream.GetLastMsgForSubject(context.Background(), "howlite.resources.ResourceCreated.a_txt")
+	require.NoError(t, err)
+	require.Equal(t, `{}`, string(msg.Data))
+}
+
+func TestAcceptance_Nats_JetStreamFailsWithoutStream(t *testing.T) {
+	natsServer := startNatsServer(t, &server.Options{JetStream: true, StoreDir: t.TempDir()})
+	config := newNatsConfiguration(natsServer)
+	config.JETSTREAM = true
+	config.ACK_TIMEOUT = "500ms"
+	publisher := newNatsPublisher(t, config)
+
+	err := publisher.Publish(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)})
+	require.Error(t, err)
+}
+
+func TestAcceptance_Nats_AuthenticatesWithNkeySeed(t *testing.T) {
+	user, err := nkeys.CreateUser()
+	require.NoError(t, err)
+	publicKey, err := user.PublicKey()
+	require.NoError(t, err)
+	seed, err := user.Seed()
+	require.NoError(t, err)
+	seedPath := filepath.Join(t.TempDir(), "user.nk")
+	require.NoError(t, os.WriteFile(seedPath, seed, 0o600))
+
+	natsServer := startNatsServer(t, &server.Options{Nkeys: []*server.NkeyUser{{Nkey: publicKey}}})
+
+	unauthenticated := event.NewNatsPublisher(context.Background(), newNatsConfiguration(natsServer))
+	require.False(t, unauthenticated.IsAvailable())
+
+	config := newNatsConfiguration(natsServer)
+	config.NKEY_SEED_PATH = seedPath
+	newNatsPublisher(t, config)
+}
@@ -3,13 +3,14 @@ package event
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/inx51/howlite-resources/logger"
-	_ "github.com/mattn/go-sqlite3"
+	_ "modernc.org/sqlite"
 )
 
 type Outbox struct {
@@ -19,7 +20,9 @@ type Outbox struct {
 
 func NewOutbox(ctx context.Context, sqlitePath string) Outbox {
 	setupSqliteFile(sqlitePath)
-	db, err := sql.Open("sqlite3", sqlitePath)
+	// The busy timeout lets the dead-letters command use the database while the
+	// application is running.
+	db, err := sql.Open("sqlite", sqlitePath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		panic(err)
 	}
@@ -38,6 +41,54 @@ func runMigrations(ctx context.Context, db *sql.DB) {
 		_ = db.Close()
 		panic(err)
 	}
+
+	// The type, subject and endpoint of the events, and the delivery state of
+	// webhook endpoints, were added later. Rows enqueued before have them empty.
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"event_type", "TEXT NOT NULL DEFAULT ''"},
+		{"subject", "TEXT NOT NULL DEFAULT ''"},
+		{"endpoint", "TEXT NOT NULL DEFAULT ''"},
+		{"attempts", "INTEGER NOT NULL DEFAULT 0"},
+		{"next_attempt_utc", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, column := range columns {
+		if err := addColumnIfMissing(ctx, db, column.name, column.definition); err != nil {
+			_ = db.Close()
+			panic(err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS dead_letters (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			endpoint TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			last_error TEXT NOT NULL,
+			failed_utc TEXT NOT NULL
+		)
+	`); err != nil {
+		_ = db.Close()
+		panic(err)
+	}
+}
+
+func addColumnIfMissing(ctx context.Context, db *sql.DB, column string, definition string) error {
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM pragma_table_info('outbox') WHERE name = ?
+	`, column).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `ALTER TABLE outbox ADD COLUMN `+column+` `+definition)
+	return err
 }
 
 func setupSqliteFile(sqlitePath string) {
@@ -46,53 +97,137 @@ func setupSqliteFile(sqlitePath string) {
 	}
 }
 
-func (outbox *Outbox) Enqueue(ctx context.Context, event []byte) {
-	err := appendMessageToDb(ctx, outbox.db, event, time.Now().UTC())
+func (outbox *Outbox) Enqueue(ctx context.Context, message *Message) {
+	err := appendMessageToDb(ctx, outbox.db, message, time.Now().UTC())
 	if err != nil {
 		logger.Error(ctx, "failed to save to outbox", "error", err)
 	}
 }
 
-func appendMessageToDb(ctx context.Context, db *sql.DB, event []byte, enqueuedUtc time.Time) error {
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func appendMessageToDb(ctx context.Context, db execer, message *Message, enqueuedUtc time.Time) error {
 	_, err := db.ExecContext(ctx, `
-		INSERT INTO outbox(payload, enqueued_utc) VALUES (?, ?)
-	`, string(event), enqueuedUtc.Format(time.RFC3339Nano))
+		INSERT INTO outbox(payload, enqueued_utc, event_type, subject, endpoint) VALUES (?, ?, ?, ?, ?)
+	`, string(message.Payload), enqueuedUtc.Format(time.RFC3339Nano), message.Type, message.Subject, message.Endpoint)
 	return err
 }
 
-func (outbox *Outbox) Dequeue(ctx context.Context) []byte {
+// Next returns the oldest message of the outbox that is due without removing
+// it, or nil if there is none. Messages that were never attempted come before
+// the retries that are due. The message is removed by Acknowledge once it
+// has been delivered, so it is retried until then.
+func (outbox *Outbox) Next(ctx context.Context) *Message {
 	outbox.mutex.Lock()
 	defer outbox.mutex.Unlock()
 
-	payload, err := getLatestMessageFromDb(ctx, outbox.db)
+	message, err := getNextMessageFromDb(ctx, outbox.db, time.Now().UTC())
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil
 		}
-		logger.Error(ctx, "failed to dequeue event", "error", err)
+		logger.Error(ctx, "failed to read event from outbox", "error", err)
 		return nil
 	}
 
-	return payload
+	return message
+}
+
+// Acknowledge removes a message returned by Next from the outbox.
+func (outbox *Outbox) Acknowledge(ctx context.Context, message *Message) {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	if err := removeMessageFromDb(ctx, outbox.db, message.outboxId); err != nil {
+		logger.Error(ctx, "failed to remove event from outbox", "error", err)
+	}
+}
+
+// Expand replaces a message returned by Next with a message for each of the
+// endpoints, which are then delivered, retried and parked on their own.
+func (outbox *Outbox) Expand(ctx context.Context, message *Message, endpoints []string) error {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	tx, err := outbox.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, message.outboxId); err != nil {
+		return err
+	}
+	enqueuedUtc := time.Now().UTC()
+	for _, endpoint := range endpoints {
+		endpointMessage := *message
+		endpointMessage.Endpoint = endpoint
+		if err := appendMessageToDb(ctx, tx, &endpointMessage, enqueuedUtc); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func getLatestMessageFromDb(ctx context.Context, db *sql.DB) ([]byte, error) {
+// Reschedule counts a failed attempt to deliver a message returned by Next,
+// which is not returned again until nextAttemptUtc.
+func (outbox *Outbox) Reschedule(ctx context.Context, message *Message, nextAttemptUtc time.Time) error {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	_, err := outbox.db.ExecContext(ctx, `
+		UPDATE outbox SET attempts = attempts + 1, next_attempt_utc = ? WHERE id = ?
+	`, nextAttemptUtc.UTC().Format(nextAttemptLayout), message.outboxId)
+	return err
+}
+
+// nextAttemptLayout has a fixed width, so that the next attempts can be
+// compared as text.
+const nextAttemptLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+func getNextMessageFromDb(ctx context.Context, db *sql.DB, nowUtc time.Time) (*Message, error) {
 	var payload string
+	message := &Message{}
 	err := db.QueryRowContext(ctx, `
-		DELETE FROM outbox
-		WHERE id = (
-			SELECT id
-			FROM outbox
-			ORDER BY id
-			LIMIT 1
-		)
-		RETURNING payload
-	`).Scan(&payload)
+		SELECT id, payload, event_type, subject, endpoint, attempts
+		FROM outbox
+		WHERE next_attempt_utc <= ?
+		ORDER BY next_attempt_utc, id
+		LIMIT 1
+	`, nowUtc.Format(nextAttemptLayout)).Scan(&message.outboxId, &payload, &message.Type, &message.Subject, &message.Endpoint, &message.Attempts)
 	if err != nil {
 		return nil, err
 	}
+	message.Payload = []byte(payload)
+
+	// Rows enqueued before the type was stored only have it in the payload.
+	if message.Type == "" {
+		if envelope, err := DecodeEnvelope(message.Payload); err == nil {
+			message.Type = envelope.Type
+			message.Subject = envelope.Subject
+		}
+	}
+
+	return message, nil
+}
+
+func removeMessageFromDb(ctx context.Context, db *sql.DB, id int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, id)
+	return err
+}
 
-	return []byte(payload), nil
+// CheckHealth checks that the outbox database can be queried.
+func (outbox *Outbox) CheckHealth(ctx context.Context) error {
+	var id int64
+	err := outbox.db.QueryRowContext(ctx, `SELECT id FROM outbox LIMIT 1`).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
 }
 
 func (outbox *Outbox) Close(ctx context.Context) {
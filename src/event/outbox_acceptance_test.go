@@ -0,0 +1,94 @@
+package event_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/event"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptance_Outbox_ReadsTypeOfRowsEnqueuedBeforeMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE outbox (id INTEGER PRIMARY KEY AUTOINCREMENT, payload TEXT NOT NULL, enqueued_utc TEXT NOT NULL)`)
+	require.NoError(t, err)
+	payload, err := json.Marshal(&event.Envelope{Type: "ResourceCreated", Data: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO outbox(payload, enqueued_utc) VALUES (?, ?)`, string(payload), time.Now().UTC().Format(time.RFC3339Nano))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	outbox := event.NewOutbox(context.Background(), path)
+	t.Cleanup(func() { outbox.Close(context.Background()) })
+
+	message := outbox.Next(context.Background())
+	require.NotNil(t, message)
+	require.Equal(t, "ResourceCreated", message.Type)
+	require.Equal(t, string(payload), string(message.Payload))
+	outbox.Acknowledge(context.Background(), message)
+	require.Nil(t, outbox.Next(context.Background()))
+}
+
+// flakyPublisher fails the first publishes, like a broker that does not
+// acknowledge them.
+type flakyPublisher struct {
+	mutex     sync.Mutex
+	failures  int
+	published []*event.Message
+}
+
+func (publisher *flakyPublisher) Publish(ctx context.Context, message *event.Message) error {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	if publisher.failures > 0 {
+		publisher.failures--
+		return errors.New("not acknowledged")
+	}
+	publisher.published = append(publisher.published, message)
+	return nil
+}
+
+func (publisher *flakyPublisher) IsAvailable() bool { return true }
+
+func (publisher *flakyPublisher) Stop() {}
+
+func (publisher *flakyPublisher) publishedTypes() []string {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	var types []string
+	for _, message := range publisher.published {
+		types = append(types, message.Type)
+	}
+	return types
+}
+
+func TestAcceptance_OutboxWorker_KeepsMessagesUntilPublished(t *testing.T) {
+	outbox := event.NewOutbox(context.Background(), filepath.Join(t.TempDir(), "outbox.db"))
+	publisher := &flakyPublisher{failures: 1}
+	worker := event.NewOutboxWorker(context.Background(), &outbox, publisher)
+	bus := event.NewBus(publisher, &outbox)
+
+	bus.Publish(context.Background(), "ResourceCreated", "/a.txt", struct{}{})
+	bus.Publish(context.Background(), "ResourceRemoved", "/a.txt", struct{}{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go worker.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		worker.Stop(context.Background())
+	})
+
+	require.Eventually(t, func() bool {
+		return len(publisher.publishedTypes()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"ResourceCreated", "ResourceRemoved"}, publisher.publishedTypes())
+	require.Nil(t, outbox.Next(context.Background()))
+}
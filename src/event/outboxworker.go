@@ -7,17 +7,28 @@ import (
 	"github.com/inx51/howlite-resources/logger"
 )
 
+const (
+	outboxPollInterval = 10 * time.Millisecond
+	// outboxRetryDelay is how long the worker waits after a message failed to
+	// publish, before it is retried.
+	outboxRetryDelay = time.Second
+)
+
+// OutboxWorker publishes the messages of the outbox in order. A message is only
+// removed from the outbox once the publisher has accepted it, a message that
+// fails to publish is retried and holds back the messages enqueued after it.
+// Publishers with several endpoints are the exception, see deliver.
 type OutboxWorker struct {
 	outbox    *Outbox
-	publisher *Publisher
+	publisher Publisher
 	ticker    *time.Ticker
 }
 
-func NewOutboxWorker(ctx context.Context, outbox *Outbox, publisher *Publisher) OutboxWorker {
+func NewOutboxWorker(ctx context.Context, outbox *Outbox, publisher Publisher) OutboxWorker {
 	return OutboxWorker{
 		outbox:    outbox,
 		publisher: publisher,
-		ticker:    time.NewTicker(10 * time.Millisecond),
+		ticker:    time.NewTicker(outboxPollInterval),
 	}
 }
 
@@ -33,18 +44,59 @@ func (worker *OutboxWorker) Start(ctx context.Context) {
 			logger.Info(ctx, "Outbox worker stopped")
 			return
 		case <-worker.ticker.C:
-			message := worker.outbox.Dequeue(ctx)
+			message := worker.outbox.Next(ctx)
 			if message == nil {
 				logger.Debug(ctx, "No new messages in outbox")
 				continue
 			}
 
-			worker.publisher.Publish(ctx, message)
+			if publisher, ok := worker.publisher.(EndpointPublisher); ok {
+				if err := worker.deliver(ctx, publisher, message); err != nil {
+					logger.Error(ctx, "failed to update event in outbox, it will be retried", "error", err, "retryDelay", outboxRetryDelay)
+					worker.ticker.Reset(outboxRetryDelay)
+					continue
+				}
+				worker.ticker.Reset(outboxPollInterval)
+				continue
+			}
+
+			if err := worker.publisher.Publish(ctx, message); err != nil {
+				logger.Error(ctx, "failed to publish event from outbox, it will be retried", "error", err, "retryDelay", outboxRetryDelay)
+				worker.ticker.Reset(outboxRetryDelay)
+				continue
+			}
+			worker.outbox.Acknowledge(ctx, message)
+			worker.ticker.Reset(outboxPollInterval)
 			logger.Info(ctx, "Published event from outbox")
 		}
 	}
 }
 
+// deliver makes a single attempt to deliver a message to its endpoint, a
+// message without one is first expanded into a message per endpoint. Failed
+// deliveries are rescheduled in the outbox instead of waited for, so that they
+// hold back neither the other endpoints nor the later messages.
+func (worker *OutboxWorker) deliver(ctx context.Context, publisher EndpointPublisher, message *Message) error {
+	if message.Endpoint == "" {
+		return worker.outbox.Expand(ctx, message, publisher.Endpoints(message))
+	}
+
+	retryAtUtc, err := publisher.Deliver(ctx, message)
+	if ctx.Err() != nil {
+		// The application is stopping, the message stays in the outbox.
+		return nil
+	}
+	if err == nil {
+		worker.outbox.Acknowledge(ctx, message)
+		logger.Info(ctx, "Published event from outbox", "endpoint", message.Endpoint)
+		return nil
+	}
+	if !retryAtUtc.IsZero() {
+		return worker.outbox.Reschedule(ctx, message, retryAtUtc)
+	}
+	return worker.outbox.Park(ctx, message, message.Attempts+1, err)
+}
+
 func (worker *OutboxWorker) Stop(ctx context.Context) {
 	worker.outbox.Close(ctx)
 	worker.ticker.Stop()
@@ -1,114 +1,52 @@
-package event
-
-import (
-	"context"
-
-	"github.com/inx51/howlite-resources/configuration"
-	"github.com/inx51/howlite-resources/logger"
-	"github.com/inx51/howlite-resources/tracer"
-	"github.com/zeromq/goczmq"
-)
-
-type Publisher struct {
-	socket *goczmq.Sock
-	auth   *goczmq.Auth
-}
-
-func (publisher Publisher) IsAvailable() bool {
-	return publisher.socket != nil
-}
-
-func NewPublisher(ctx context.Context, config configuration.ZeroMqConfiguration) Publisher {
-	ctx, span := tracer.StartInfoSpan(ctx, "zeromq.publisher.init")
-	defer tracer.SafeEndSpan(span)
-
-	logger.Debug(ctx, "Establishing connection to zero mq publisher", "endpoint", config.ENDPOINT)
-
-	sock := goczmq.NewSock(goczmq.Pub)
-
-	var auth *goczmq.Auth
-	if config.CURVE.SERVER_CERT_PATH != "" {
-		var err error
-		auth, err = setupCurve(sock, config.CURVE)
-		if err != nil {
-			tracer.SafeRecordError(span, err)
-			logger.Error(ctx, "Failed to configure CURVE for zero mq publisher", "error", err)
-			sock.Destroy()
-			return Publisher{}
-		}
-	}
-
-	if err := sock.Attach(config.ENDPOINT, true); err != nil {
-		tracer.SafeRecordError(span, err)
-		logger.Error(ctx, "Failed to establish connection to zero mq publisher", "endpoint", config.ENDPOINT, "error", err)
-		sock.Destroy()
-		if auth != nil {
-			auth.Destroy()
-		}
-		return Publisher{}
-	}
-
-	logger.Info(ctx, "Zero mq publisher initialized", "endpoint", config.ENDPOINT)
-	return Publisher{
-		socket: sock,
-		auth:   auth,
-	}
-}
-
-// setupCurve loads the publisher's CURVE cert onto sock, marks it as a CURVE
-// server, and starts an auth actor enforcing the configured client allowlist
-// (or CURVE_ALLOW_ANY if none was configured). Must be called before the
-// socket is bound.
-func setupCurve(sock *goczmq.Sock, curve configuration.ZeroMqCurveConfiguration) (*goczmq.Auth, error) {
-	cert, err := goczmq.NewCertFromFile(curve.SERVER_CERT_PATH)
-	if err != nil {
-		return nil, err
-	}
-
-	sock.SetZapDomain("global")
-	cert.Apply(sock)
-	sock.SetCurveServer(1)
-
-	allowed := goczmq.CurveAllowAny
-	if curve.ALLOWED_CLIENTS_PATH != "" {
-		allowed = curve.ALLOWED_CLIENTS_PATH
-	}
-
-	auth := goczmq.NewAuth()
-	if err := auth.Curve(allowed); err != nil {
-		auth.Destroy()
-		return nil, err
-	}
-
-	return auth, nil
-}
-
-func (publisher *Publisher) Publish(ctx context.Context, event []byte) {
-	if publisher == nil || publisher.socket == nil {
-		logger.Error(ctx, "Zero mq publisher is not available")
-		return
-	}
-
-	ctx, span := tracer.StartDebugSpan(ctx, "zeromq.sendframe")
-	defer tracer.SafeEndSpan(span)
-
-	logger.Debug(ctx, "Sending event frame via zero mq", "payload", string(event))
-	err := publisher.socket.SendFrame(event, goczmq.FlagNone)
-	if err != nil {
-		tracer.SafeRecordError(span, err)
-		logger.Error(ctx, "Failed to send event frame via zero mq", "error", err)
-		return
-	}
-	logger.Info(ctx, "Event published")
-}
-
-func (publisher *Publisher) Stop() {
-	if publisher == nil || publisher.socket == nil {
-		return
-	}
-
-	publisher.socket.Destroy()
-	if publisher.auth != nil {
-		publisher.auth.Destroy()
-	}
-}
+package event
+
+import (
+	"context"
+	"time"
+)
+
+// Message is an encoded event along with its type and subject, which
+// transports use to route it.
+type Message struct {
+	Type    string
+	Subject string
+	Payload []byte
+	// Endpoint restricts the delivery to the webhook endpoint of that name, it
+	// is set when the outbox worker expands a message per endpoint and when a
+	// dead letter is replayed.
+	Endpoint string
+	// Attempts is the number of failed attempts to deliver the message to its
+	// endpoint.
+	Attempts int
+	// outboxId identifies the row of a message read from the outbox.
+	outboxId int64
+}
+
+// Publisher sends messages to a transport. Publish returns once the transport
+// has accepted the message, which for transports with acknowledgements means
+// the broker has acknowledged it.
+type Publisher interface {
+	Publish(ctx context.Context, message *Message) error
+	IsAvailable() bool
+	Stop()
+}
+
+// EndpointPublisher is implemented by publishers that deliver a message to
+// several endpoints. The outbox worker keeps a message per endpoint, so that
+// an endpoint that fails neither holds back the others nor makes them receive
+// the message again.
+type EndpointPublisher interface {
+	Publisher
+	// Endpoints returns the names of the endpoints a message is delivered to.
+	Endpoints(message *Message) []string
+	// Deliver makes a single attempt to deliver a message to its endpoint. When
+	// it fails, retryAtUtc is when to attempt it next, or zero if the message is
+	// to be parked as a dead letter.
+	Deliver(ctx context.Context, message *Message) (retryAtUtc time.Time, err error)
+}
+
+// HealthChecker is implemented by the publishers that can check whether the
+// transport is reachable, beyond whether they are available.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
@@ -0,0 +1,107 @@
+//go:build !nozmq
+
+package event
+
+import (
+	"context"
+	"strings"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/zeromq/goczmq"
+)
+
+// subscriberReceiveTimeout bounds how long a receive blocks, so that the
+// subscriber notices when it is stopped.
+const subscriberReceiveTimeout = 1000
+
+// Subscriber receives the events published by the zero mq publishers of other
+// instances and passes them to a handler.
+type Subscriber struct {
+	socket  *goczmq.Sock
+	handler func(ctx context.Context, envelope *Envelope)
+	done    chan struct{}
+}
+
+func (subscriber Subscriber) IsAvailable() bool {
+	return subscriber.socket != nil
+}
+
+func NewSubscriber(ctx context.Context, config configuration.ZeroMqSubscriberConfiguration, handler func(ctx context.Context, envelope *Envelope)) Subscriber {
+	ctx, span := tracer.StartInfoSpan(ctx, "zeromq.subscriber.init")
+	defer tracer.SafeEndSpan(span)
+
+	endpoints := strings.Join(config.ENDPOINTS, ",")
+	logger.Debug(ctx, "Connecting zero mq subscriber", "endpoints", endpoints)
+
+	sock := goczmq.NewSock(goczmq.Sub)
+	if config.CURVE_CLIENT_CERT_PATH != "" {
+		cert, err := goczmq.NewCertFromFile(config.CURVE_CLIENT_CERT_PATH)
+		if err != nil {
+			tracer.SafeRecordError(span, err)
+			logger.Error(ctx, "Failed to configure CURVE for zero mq subscriber", "error", err)
+			sock.Destroy()
+			return Subscriber{}
+		}
+		cert.Apply(sock)
+		sock.SetCurveServerkey(config.CURVE_SERVER_PUBLIC_KEY)
+	}
+	sock.SetSubscribe("")
+	sock.SetRcvtimeo(subscriberReceiveTimeout)
+
+	if err := sock.Attach(endpoints, false); err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to connect zero mq subscriber", "endpoints", endpoints, "error", err)
+		sock.Destroy()
+		return Subscriber{}
+	}
+
+	logger.Info(ctx, "Zero mq subscriber initialized", "endpoints", endpoints)
+	return Subscriber{
+		socket:  sock,
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start receives events until ctx is done. The socket is only used, and
+// destroyed, by the goroutine running Start.
+func (subscriber *Subscriber) Start(ctx context.Context) {
+	defer close(subscriber.done)
+	defer subscriber.socket.Destroy()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "Zero mq subscriber stopped")
+			return
+		default:
+		}
+
+		frame, _, err := subscriber.socket.RecvFrame()
+		if err != nil {
+			// The receive timed out, or the socket was interrupted.
+			continue
+		}
+
+		envelope, err := DecodeEnvelope(frame)
+		if err != nil {
+			logger.Warn(ctx, "Failed to unmarshal received event", "error", err)
+			continue
+		}
+
+		logger.Debug(ctx, "Received event", "type", envelope.Type)
+		subscriber.handler(envelope.Context(ctx), envelope)
+	}
+}
+
+// Stop waits for Start to return, which happens once the context passed to it
+// is done.
+func (subscriber *Subscriber) Stop(ctx context.Context) {
+	select {
+	case <-subscriber.done:
+	case <-ctx.Done():
+		logger.Warn(ctx, "Zero mq subscriber did not stop in time")
+	}
+}
@@ -0,0 +1,28 @@
+package types
+
+// StoredResource describes a resource as it was stored. Headers are the
+// response headers persisted with the resource.
+type StoredResource struct {
+	ContentLength int64
+	ContentType   string
+	ContentHash   string
+	ETag          string
+	Headers       map[string][]string
+}
+
+// PreviousResource describes the version of a resource that was replaced or
+// removed, its ContentHash is empty for resources stored before content hashes
+// were recorded.
+type PreviousResource struct {
+	ContentLength int64
+	ContentHash   string
+}
+
+// Caller identifies the request that caused an event. Principal and Scheme are
+// empty for anonymous requests, RequestId is the X-Request-Id of the request.
+type Caller struct {
+	Principal string `json:",omitempty"`
+	Scheme    string `json:",omitempty"`
+	RequestId string `json:",omitempty"`
+	TraceId   string `json:",omitempty"`
+}
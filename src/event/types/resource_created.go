@@ -7,4 +7,6 @@ var ResourceCreatedEventType = "ResourceCreated"
 type ResourceCreated struct {
 	CreatedUtc       time.Time
 	ResourceIdentity string
+	Resource         StoredResource
+	Caller           Caller
 }
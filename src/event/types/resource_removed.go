@@ -7,4 +7,6 @@ var ResourceRemoavedEventType = "ResourceRemoved"
 type ResourceRemoved struct {
 	RemovedUtc       time.Time
 	ResourceIdentity string
+	Previous         *PreviousResource `json:",omitempty"`
+	Caller           Caller
 }
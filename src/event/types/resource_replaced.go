@@ -7,4 +7,7 @@ var ResourceRepalcedEventType = "ResourceReplaced"
 type ResourceReplaced struct {
 	ReplacedUtc      time.Time
 	ResourceIdentity string
+	Resource         StoredResource
+	Previous         *PreviousResource `json:",omitempty"`
+	Caller           Caller
 }
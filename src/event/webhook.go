@@ -0,0 +1,309 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+)
+
+const (
+	WebhookEventTypeHeader = "X-Howlite-Event-Type"
+	WebhookTimestampHeader = "X-Howlite-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Howlite-Webhook-Signature"
+
+	webhookUserAgent = "howlite-resources"
+	// webhookMaxResponseSize is how much of a response body is read, so the
+	// connection can be reused.
+	webhookMaxResponseSize = 64 * 1024
+)
+
+// WebhookEndpoint is an entry of the webhook endpoints file. An endpoint
+// receives the events of the listed types about resources whose identifier
+// starts with one of the path prefixes, an empty list matches everything.
+type WebhookEndpoint struct {
+	Name         string   `json:"name"`
+	Url          string   `json:"url"`
+	Secret       string   `json:"secret"`
+	Types        []string `json:"types"`
+	PathPrefixes []string `json:"pathPrefixes"`
+}
+
+func (endpoint *WebhookEndpoint) matches(message *Message) bool {
+	if message.Endpoint != "" {
+		return message.Endpoint == endpoint.Name
+	}
+	if len(endpoint.Types) > 0 && !slices.Contains(endpoint.Types, message.Type) {
+		return false
+	}
+	if len(endpoint.PathPrefixes) > 0 && !slices.ContainsFunc(endpoint.PathPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(message.Subject, prefix)
+	}) {
+		return false
+	}
+	return true
+}
+
+// WebhookPublisher POSTs the messages to the matching webhook endpoints, each
+// signed with the secret of the endpoint. Through the outbox worker, failed
+// deliveries are retried with an exponential, jittered backoff, and parked as
+// dead letters once they failed MAX_ATTEMPTS times or were rejected with a
+// client error.
+type WebhookPublisher struct {
+	client         *http.Client
+	endpoints      []WebhookEndpoint
+	contentType    string
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// NewWebhookPublisher creates a publisher for the endpoints of the file at
+// ENDPOINTS_PATH, format is the format of the published events.
+func NewWebhookPublisher(ctx context.Context, config configuration.WebhookConfiguration, format string) WebhookPublisher {
+	ctx, span := tracer.StartInfoSpan(ctx, "webhook.publisher.init")
+	defer tracer.SafeEndSpan(span)
+
+	publisher, err := newWebhookPublisher(config, format)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to configure webhook publisher", "endpointsPath", config.ENDPOINTS_PATH, "error", err)
+		return WebhookPublisher{}
+	}
+
+	logger.Info(ctx, "Webhook publisher initialized", "endpoints", len(publisher.endpoints))
+	return publisher
+}
+
+func newWebhookPublisher(config configuration.WebhookConfiguration, format string) (WebhookPublisher, error) {
+	endpoints, err := loadWebhookEndpoints(config.ENDPOINTS_PATH)
+	if err != nil {
+		return WebhookPublisher{}, err
+	}
+
+	timeout, err := time.ParseDuration(config.TIMEOUT)
+	if err != nil {
+		return WebhookPublisher{}, err
+	}
+	initialBackoff, err := time.ParseDuration(config.INITIAL_BACKOFF)
+	if err != nil {
+		return WebhookPublisher{}, err
+	}
+	maxBackoff, err := time.ParseDuration(config.MAX_BACKOFF)
+	if err != nil {
+		return WebhookPublisher{}, err
+	}
+
+	contentType := "application/json"
+	if format == FormatCloudEvents {
+		contentType = CloudEventsContentType
+	}
+
+	return WebhookPublisher{
+		client:         &http.Client{Timeout: timeout},
+		endpoints:      endpoints,
+		contentType:    contentType,
+		maxAttempts:    max(config.MAX_ATTEMPTS, 1),
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+	}, nil
+}
+
+func loadWebhookEndpoints(path string) ([]WebhookEndpoint, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []WebhookEndpoint
+	if err := json.Unmarshal(file, &endpoints); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.Name == "" || endpoint.Url == "" || endpoint.Secret == "" {
+			return nil, errors.New("webhook endpoints must have a name, url and secret")
+		}
+		if names[endpoint.Name] {
+			return nil, fmt.Errorf("webhook endpoint %q is listed more than once", endpoint.Name)
+		}
+		names[endpoint.Name] = true
+
+		parsed, err := url.Parse(endpoint.Url)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("webhook endpoint %q must have an absolute http or https url", endpoint.Name)
+		}
+	}
+
+	return endpoints, nil
+}
+
+func (publisher *WebhookPublisher) IsAvailable() bool {
+	return publisher.client != nil
+}
+
+// Publish makes a single attempt to deliver the message to each matching
+// endpoint, concurrently. The outbox worker uses Endpoints and Deliver instead,
+// so that every endpoint is retried on its own.
+func (publisher *WebhookPublisher) Publish(ctx context.Context, message *Message) error {
+	if publisher == nil || publisher.client == nil {
+		return errors.New("webhook publisher is not available")
+	}
+
+	var wait sync.WaitGroup
+	errs := make([]error, len(publisher.endpoints))
+	for index := range publisher.endpoints {
+		endpoint := &publisher.endpoints[index]
+		if !endpoint.matches(message) {
+			continue
+		}
+		wait.Go(func() {
+			errs[index] = publisher.post(ctx, endpoint, message)
+		})
+	}
+	wait.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Endpoints returns the names of the endpoints the message matches.
+func (publisher *WebhookPublisher) Endpoints(message *Message) []string {
+	var names []string
+	for index := range publisher.endpoints {
+		if publisher.endpoints[index].matches(message) {
+			names = append(names, publisher.endpoints[index].Name)
+		}
+	}
+	return names
+}
+
+// Deliver makes a single attempt to deliver the message to its endpoint. A
+// failed attempt is retried after a backoff, unless the endpoint rejected the
+// message or it has been attempted MAX_ATTEMPTS times.
+func (publisher *WebhookPublisher) Deliver(ctx context.Context, message *Message) (time.Time, error) {
+	ctx, span := tracer.StartDebugSpan(ctx, "webhook.deliver")
+	defer tracer.SafeEndSpan(span)
+
+	index := slices.IndexFunc(publisher.endpoints, func(endpoint WebhookEndpoint) bool {
+		return endpoint.Name == message.Endpoint
+	})
+	if index < 0 {
+		logger.Warn(ctx, "Dropping event, its webhook endpoint is no longer configured", "endpoint", message.Endpoint)
+		return time.Time{}, nil
+	}
+	endpoint := &publisher.endpoints[index]
+
+	attempt := message.Attempts + 1
+	err := publisher.post(ctx, endpoint, message)
+	if err == nil {
+		logger.Debug(ctx, "Delivered event to webhook", "endpoint", endpoint.Name, "attempt", attempt)
+		return time.Time{}, nil
+	}
+	tracer.SafeRecordError(span, err)
+
+	if attempt >= publisher.maxAttempts || !isRetryable(err) {
+		logger.Error(ctx, "Failed to deliver event to webhook, parking it as a dead letter", "endpoint", endpoint.Name, "attempts", attempt, "error", err)
+		return time.Time{}, err
+	}
+
+	backoff := publisher.backoff(attempt)
+	logger.Warn(ctx, "Failed to deliver event to webhook, retrying", "endpoint", endpoint.Name, "attempt", attempt, "backoff", backoff, "error", err)
+	return time.Now().UTC().Add(backoff), err
+}
+
+func (publisher *WebhookPublisher) post(ctx context.Context, endpoint *WebhookEndpoint, message *Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(message.Payload))
+	if err != nil {
+		return &webhookError{err: err}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", publisher.contentType)
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookEventTypeHeader, message.Type)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+WebhookSignature([]byte(endpoint.Secret), timestamp, message.Payload))
+
+	resp, err := publisher.client.Do(req)
+	if err != nil {
+		return &webhookError{err: err, retryable: true}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookMaxResponseSize))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return &webhookError{
+		err:       fmt.Errorf("webhook responded with %s", resp.Status),
+		retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests,
+	}
+}
+
+// backoff returns the delay before the next attempt, which doubles with every
+// attempt up to the max backoff. Half of it is random, so that endpoints
+// recovering from an outage are not hit by all retries at once.
+func (publisher *WebhookPublisher) backoff(attempt int) time.Duration {
+	delay := publisher.maxBackoff
+	if shift := attempt - 1; shift < 32 && publisher.initialBackoff<<shift < publisher.maxBackoff {
+		delay = publisher.initialBackoff << shift
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (publisher *WebhookPublisher) Stop() {
+	if publisher == nil || publisher.client == nil {
+		return
+	}
+
+	publisher.client.CloseIdleConnections()
+}
+
+// WebhookSignature returns the hex encoded HMAC-SHA256 of the timestamp and
+// the payload, joined by a dot, as sent in the signature header.
+func WebhookSignature(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookError is a failed delivery, retryable unless the endpoint rejected
+// the event with a client error.
+type webhookError struct {
+	err       error
+	retryable bool
+}
+
+func (err *webhookError) Error() string {
+	return err.err.Error()
+}
+
+func (err *webhookError) Unwrap() error {
+	return err.err
+}
+
+func isRetryable(err error) bool {
+	var deliveryError *webhookError
+	return errors.As(err, &deliveryError) && deliveryError.retryable
+}
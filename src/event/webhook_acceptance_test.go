@@ -0,0 +1,222 @@
+package event_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/event"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records the requests it receives and responds with the
+// queued status codes, then with 204.
+type webhookReceiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func startWebhookReceiver(t *testing.T, statuses ...int) (*webhookReceiver, string) {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mutex.Lock()
+		defer receiver.mutex.Unlock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		status := http.StatusNoContent
+		if len(receiver.statuses) > 0 {
+			status = receiver.statuses[0]
+			receiver.statuses = receiver.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server.URL
+}
+
+func (receiver *webhookReceiver) received() int {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return len(receiver.requests)
+}
+
+func newWebhookPublisher(t *testing.T, initialBackoff string, endpoints ...event.WebhookEndpoint) *event.WebhookPublisher {
+	t.Helper()
+	file, err := json.Marshal(endpoints)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	require.NoError(t, os.WriteFile(path, file, 0o600))
+
+	publisher := event.NewWebhookPublisher(context.Background(), configuration.WebhookConfiguration{
+		ENDPOINTS_PATH:  path,
+		TIMEOUT:         "5s",
+		MAX_ATTEMPTS:    3,
+		INITIAL_BACKOFF: initialBackoff,
+		MAX_BACKOFF:     initialBackoff,
+	}, event.FormatLegacy)
+	require.True(t, publisher.IsAvailable(), "webhook publisher is not available")
+	t.Cleanup(publisher.Stop)
+	return &publisher
+}
+
+func newTestOutbox(t *testing.T) *event.Outbox {
+	t.Helper()
+	outbox := event.NewOutbox(context.Background(), filepath.Join(t.TempDir(), "outbox.db"))
+	t.Cleanup(func() { outbox.Close(context.Background()) })
+	return &outbox
+}
+
+// startOutboxWorker delivers the messages of the outbox until the test ends.
+func startOutboxWorker(t *testing.T, outbox *event.Outbox, publisher event.Publisher) {
+	t.Helper()
+	worker := event.NewOutboxWorker(context.Background(), outbox, publisher)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func deadLetters(t *testing.T, outbox *event.Outbox) []event.DeadLetter {
+	t.Helper()
+	deadLetters, err := outbox.DeadLetters(context.Background())
+	require.NoError(t, err)
+	return deadLetters
+}
+
+func TestAcceptance_Webhook_SignsDeliveredEvents(t *testing.T) {
+	receiver, url := startWebhookReceiver(t)
+	publisher := newWebhookPublisher(t, "10ms", event.WebhookEndpoint{Name: "a", Url: url, Secret: "secret"})
+
+	message := &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{"name":"a"}`)}
+	require.NoError(t, publisher.Publish(context.Background(), message))
+
+	require.Equal(t, 1, receiver.received())
+	request := receiver.requests[0]
+	require.Equal(t, `{"name":"a"}`, string(receiver.bodies[0]))
+	require.Equal(t, "ResourceCreated", request.Header.Get(event.WebhookEventTypeHeader))
+	timestamp := request.Header.Get(event.WebhookTimestampHeader)
+	require.NotEmpty(t, timestamp)
+	require.Equal(t, "sha256="+event.WebhookSignature([]byte("secret"), timestamp, message.Payload), request.Header.Get(event.WebhookSignatureHeader))
+}
+
+func TestAcceptance_Webhook_DeliversOnlyToMatchingEndpoints(t *testing.T) {
+	images, imagesUrl := startWebhookReceiver(t)
+	removals, removalsUrl := startWebhookReceiver(t)
+	outbox := newTestOutbox(t)
+	publisher := newWebhookPublisher(t, "10ms",
+		event.WebhookEndpoint{Name: "images", Url: imagesUrl, Secret: "secret", PathPrefixes: []string{"/images/"}},
+		event.WebhookEndpoint{Name: "removals", Url: removalsUrl, Secret: "secret", Types: []string{"ResourceRemoved"}},
+	)
+
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/images/a.png", Payload: []byte(`{}`)})
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceRemoved", Subject: "/a.txt", Payload: []byte(`{}`)})
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)})
+	startOutboxWorker(t, outbox, publisher)
+
+	require.Eventually(t, func() bool {
+		return images.received() == 1 && removals.received() == 1 && outbox.Next(context.Background()) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "ResourceRemoved", removals.requests[0].Header.Get(event.WebhookEventTypeHeader))
+}
+
+func TestAcceptance_Webhook_RetriesServerErrors(t *testing.T) {
+	receiver, url := startWebhookReceiver(t, http.StatusInternalServerError, http.StatusTooManyRequests)
+	outbox := newTestOutbox(t)
+	publisher := newWebhookPublisher(t, "10ms", event.WebhookEndpoint{Name: "a", Url: url, Secret: "secret"})
+
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)})
+	startOutboxWorker(t, outbox, publisher)
+
+	require.Eventually(t, func() bool {
+		return receiver.received() == 3 && outbox.Next(context.Background()) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, deadLetters(t, outbox))
+}
+
+func TestAcceptance_Webhook_ParksRejectedEventsWithoutRetrying(t *testing.T) {
+	receiver, url := startWebhookReceiver(t, http.StatusBadRequest)
+	outbox := newTestOutbox(t)
+	publisher := newWebhookPublisher(t, "10ms", event.WebhookEndpoint{Name: "a", Url: url, Secret: "secret"})
+
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)})
+	startOutboxWorker(t, outbox, publisher)
+
+	require.Eventually(t, func() bool {
+		return len(deadLetters(t, outbox)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, receiver.received())
+	parked := deadLetters(t, outbox)[0]
+	require.Equal(t, "a", parked.Endpoint)
+	require.Equal(t, 1, parked.Attempts)
+	require.Contains(t, parked.LastError, "400")
+	require.Nil(t, outbox.Next(context.Background()))
+}
+
+func TestAcceptance_Webhook_RetriesFailingEndpointWithoutHoldingBackOthers(t *testing.T) {
+	failing, failingUrl := startWebhookReceiver(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	healthy, healthyUrl := startWebhookReceiver(t)
+	outbox := newTestOutbox(t)
+	publisher := newWebhookPublisher(t, "1h",
+		event.WebhookEndpoint{Name: "failing", Url: failingUrl, Secret: "secret"},
+		event.WebhookEndpoint{Name: "healthy", Url: healthyUrl, Secret: "secret"},
+	)
+
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{}`)})
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/b.txt", Payload: []byte(`{}`)})
+	startOutboxWorker(t, outbox, publisher)
+
+	// The failed deliveries are scheduled an hour later, rather than waited for.
+	require.Eventually(t, func() bool {
+		return failing.received() == 2 && healthy.received() == 2 && outbox.Next(context.Background()) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, deadLetters(t, outbox))
+}
+
+func TestAcceptance_Webhook_ReplaysDeadLettersToTheirEndpoint(t *testing.T) {
+	failing, failingUrl := startWebhookReceiver(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	healthy, healthyUrl := startWebhookReceiver(t)
+	outbox := newTestOutbox(t)
+	publisher := newWebhookPublisher(t, "10ms",
+		event.WebhookEndpoint{Name: "failing", Url: failingUrl, Secret: "secret"},
+		event.WebhookEndpoint{Name: "healthy", Url: healthyUrl, Secret: "secret"},
+	)
+
+	outbox.Enqueue(context.Background(), &event.Message{Type: "ResourceCreated", Subject: "/a.txt", Payload: []byte(`{"name":"a"}`)})
+	startOutboxWorker(t, outbox, publisher)
+
+	require.Eventually(t, func() bool {
+		return len(deadLetters(t, outbox)) == 1 && healthy.received() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, failing.received())
+	parked := deadLetters(t, outbox)[0]
+	require.Equal(t, "failing", parked.Endpoint)
+	require.Equal(t, 3, parked.Attempts)
+
+	require.NoError(t, outbox.Replay(context.Background(), parked.Id))
+	require.ErrorIs(t, outbox.Replay(context.Background(), parked.Id), event.ErrDeadLetterNotFound)
+
+	require.Eventually(t, func() bool {
+		return failing.received() == 4 && outbox.Next(context.Background()) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, healthy.received())
+	require.Empty(t, deadLetters(t, outbox))
+	require.Equal(t, `{"name":"a"}`, string(failing.bodies[3]))
+}
@@ -0,0 +1,117 @@
+//go:build !nozmq
+
+package event
+
+import (
+	"context"
+	"errors"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/zeromq/goczmq"
+)
+
+// ZeroMqPublisher publishes the messages on a zero mq PUB socket, which the
+// subscribers connect to.
+type ZeroMqPublisher struct {
+	socket *goczmq.Sock
+	auth   *goczmq.Auth
+}
+
+func (publisher *ZeroMqPublisher) IsAvailable() bool {
+	return publisher.socket != nil
+}
+
+func NewZeroMqPublisher(ctx context.Context, config configuration.ZeroMqConfiguration) ZeroMqPublisher {
+	ctx, span := tracer.StartInfoSpan(ctx, "zeromq.publisher.init")
+	defer tracer.SafeEndSpan(span)
+
+	logger.Debug(ctx, "Establishing connection to zero mq publisher", "endpoint", config.ENDPOINT)
+
+	sock := goczmq.NewSock(goczmq.Pub)
+
+	var auth *goczmq.Auth
+	if config.CURVE.SERVER_CERT_PATH != "" {
+		var err error
+		auth, err = setupCurve(sock, config.CURVE)
+		if err != nil {
+			tracer.SafeRecordError(span, err)
+			logger.Error(ctx, "Failed to configure CURVE for zero mq publisher", "error", err)
+			sock.Destroy()
+			return ZeroMqPublisher{}
+		}
+	}
+
+	if err := sock.Attach(config.ENDPOINT, true); err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "Failed to establish connection to zero mq publisher", "endpoint", config.ENDPOINT, "error", err)
+		sock.Destroy()
+		if auth != nil {
+			auth.Destroy()
+		}
+		return ZeroMqPublisher{}
+	}
+
+	logger.Info(ctx, "Zero mq publisher initialized", "endpoint", config.ENDPOINT)
+	return ZeroMqPublisher{
+		socket: sock,
+		auth:   auth,
+	}
+}
+
+// setupCurve loads the publisher's CURVE cert onto sock, marks it as a CURVE
+// server, and starts an auth actor enforcing the configured client allowlist
+// (or CURVE_ALLOW_ANY if none was configured). Must be called before the
+// socket is bound.
+func setupCurve(sock *goczmq.Sock, curve configuration.ZeroMqCurveConfiguration) (*goczmq.Auth, error) {
+	cert, err := goczmq.NewCertFromFile(curve.SERVER_CERT_PATH)
+	if err != nil {
+		return nil, err
+	}
+
+	sock.SetZapDomain("global")
+	cert.Apply(sock)
+	sock.SetCurveServer(1)
+
+	allowed := goczmq.CurveAllowAny
+	if curve.ALLOWED_CLIENTS_PATH != "" {
+		allowed = curve.ALLOWED_CLIENTS_PATH
+	}
+
+	auth := goczmq.NewAuth()
+	if err := auth.Curve(allowed); err != nil {
+		auth.Destroy()
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+func (publisher *ZeroMqPublisher) Publish(ctx context.Context, message *Message) error {
+	if publisher == nil || publisher.socket == nil {
+		return errors.New("zero mq publisher is not available")
+	}
+
+	ctx, span := tracer.StartDebugSpan(ctx, "zeromq.sendframe")
+	defer tracer.SafeEndSpan(span)
+
+	logger.Debug(ctx, "Sending event frame via zero mq", "payload", string(message.Payload))
+	err := publisher.socket.SendFrame(message.Payload, goczmq.FlagNone)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (publisher *ZeroMqPublisher) Stop() {
+	if publisher == nil || publisher.socket == nil {
+		return
+	}
+
+	publisher.socket.Destroy()
+	if publisher.auth != nil {
+		publisher.auth.Destroy()
+	}
+}
@@ -0,0 +1,47 @@
+//go:build nozmq
+
+package event
+
+import (
+	"context"
+	"errors"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+)
+
+// errZeroMqUnavailable is returned when the binary is built with the nozmq
+// tag, which leaves out the cgo zero mq bindings.
+var errZeroMqUnavailable = errors.New("zero mq is not available in this build")
+
+type ZeroMqPublisher struct{}
+
+func (publisher *ZeroMqPublisher) IsAvailable() bool {
+	return false
+}
+
+func NewZeroMqPublisher(ctx context.Context, config configuration.ZeroMqConfiguration) ZeroMqPublisher {
+	logger.Error(ctx, "Zero mq publisher is not available in this build", "endpoint", config.ENDPOINT)
+	return ZeroMqPublisher{}
+}
+
+func (publisher *ZeroMqPublisher) Publish(ctx context.Context, message *Message) error {
+	return errZeroMqUnavailable
+}
+
+func (publisher *ZeroMqPublisher) Stop() {}
+
+type Subscriber struct{}
+
+func (subscriber Subscriber) IsAvailable() bool {
+	return false
+}
+
+func NewSubscriber(ctx context.Context, config configuration.ZeroMqSubscriberConfiguration, handler func(ctx context.Context, envelope *Envelope)) Subscriber {
+	logger.Error(ctx, "Zero mq subscriber is not available in this build")
+	return Subscriber{}
+}
+
+func (subscriber *Subscriber) Start(ctx context.Context) {}
+
+func (subscriber *Subscriber) Stop(ctx context.Context) {}
@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// Check returns an error if the component it checks cannot serve requests.
+type Check func(ctx context.Context) error
+
+// Checker runs the checks of the registered components for readiness. Once
+// drained, during graceful shutdown, it reports not ready without running them.
+// The report of a run is reused for cacheTTL, so frequent probes don't run the
+// checks against the dependencies every time.
+type Checker struct {
+	components []component
+	timeout    time.Duration
+	cacheTTL   time.Duration
+	draining   atomic.Bool
+
+	mutex     sync.Mutex
+	last      *Report
+	checkedAt time.Time
+}
+
+type component struct {
+	name  string
+	check Check
+}
+
+// Report is the result of a readiness check, Components holds the result of
+// each registered component.
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentReport `json:"components,omitempty"`
+}
+
+type ComponentReport struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// NewChecker creates a checker whose checks are cancelled after timeout and
+// whose reports are reused for cacheTTL.
+func NewChecker(timeout time.Duration, cacheTTL time.Duration) *Checker {
+	return &Checker{timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Register adds a component to the checks, it must be called before the
+// checker is used.
+func (checker *Checker) Register(name string, check Check) {
+	checker.components = append(checker.components, component{name: name, check: check})
+}
+
+// Drain makes every following check report not ready.
+func (checker *Checker) Drain() {
+	checker.draining.Store(true)
+}
+
+// Check runs the checks of all components concurrently, the report is up only
+// if all of them are. Concurrent calls wait for a single run, and its report is
+// returned until it is older than the cache TTL.
+func (checker *Checker) Check(ctx context.Context) *Report {
+	if checker.draining.Load() {
+		return &Report{Status: StatusDraining}
+	}
+
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	if checker.last != nil && time.Since(checker.checkedAt) < checker.cacheTTL {
+		return checker.last
+	}
+
+	checker.last = checker.run(ctx)
+	checker.checkedAt = time.Now()
+	return checker.last
+}
+
+func (checker *Checker) run(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, checker.timeout)
+	defer cancel()
+
+	reports := make([]ComponentReport, len(checker.components))
+	var wait sync.WaitGroup
+	for i, component := range checker.components {
+		wait.Go(func() {
+			reports[i] = runCheck(ctx, component.check)
+		})
+	}
+	wait.Wait()
+
+	report := &Report{Status: StatusUp, Components: make(map[string]ComponentReport, len(reports))}
+	for i, component := range checker.components {
+		if reports[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+		report.Components[component.name] = reports[i]
+	}
+	return report
+}
+
+// WithoutErrors returns a copy of the report without the errors of the
+// components, which may reveal details of the dependencies.
+func (report *Report) WithoutErrors() *Report {
+	redacted := &Report{Status: report.Status}
+	if report.Components != nil {
+		redacted.Components = make(map[string]ComponentReport, len(report.Components))
+		for name, component := range report.Components {
+			component.Error = ""
+			redacted.Components[name] = component
+		}
+	}
+	return redacted
+}
+
+// Ready returns whether the report is up.
+func (report *Report) Ready() bool {
+	return report.Status == StatusUp
+}
+
+func runCheck(ctx context.Context, check Check) ComponentReport {
+	start := time.Now()
+	err := check(ctx)
+	report := ComponentReport{Status: StatusUp, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		report.Status = StatusDown
+		report.Error = err.Error()
+	}
+	return report
+}
@@ -0,0 +1,123 @@
+//go:build unit
+
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/health"
+)
+
+func up(ctx context.Context) error {
+	return nil
+}
+
+func TestCheckShouldReportUpWhenAllComponentsAreUp(t *testing.T) {
+	checker := health.NewChecker(time.Second, 0)
+	checker.Register("storage", up)
+	checker.Register("event_outbox", up)
+
+	report := checker.Check(context.Background())
+
+	if !report.Ready() {
+		t.Fatalf("Expected ready, got %s", report.Status)
+	}
+	if len(report.Components) != 2 {
+		t.Fatalf("Expected 2 components, got %v", report.Components)
+	}
+}
+
+func TestCheckShouldReportDownWhenAnyComponentIsDown(t *testing.T) {
+	checker := health.NewChecker(time.Second, 0)
+	checker.Register("storage", up)
+	checker.Register("event_publisher", func(ctx context.Context) error {
+		return errors.New("not bound")
+	})
+
+	report := checker.Check(context.Background())
+
+	if report.Status != health.StatusDown {
+		t.Fatalf("Expected status %s, got %s", health.StatusDown, report.Status)
+	}
+	if report.Components["storage"].Status != health.StatusUp {
+		t.Fatalf("Expected storage to be up, got %v", report.Components["storage"])
+	}
+	if publisher := report.Components["event_publisher"]; publisher.Status != health.StatusDown || publisher.Error != "not bound" {
+		t.Fatalf("Expected event_publisher to be down with its error, got %v", publisher)
+	}
+}
+
+func TestCheckShouldCancelChecksAfterTimeout(t *testing.T) {
+	checker := health.NewChecker(10*time.Millisecond, 0)
+	checker.Register("storage", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := checker.Check(context.Background())
+
+	if report.Components["storage"].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("Expected %v, got %v", context.DeadlineExceeded, report.Components["storage"])
+	}
+}
+
+func TestCheckShouldReportDrainingWithoutRunningChecks(t *testing.T) {
+	checker := health.NewChecker(time.Second, 0)
+	checked := false
+	checker.Register("storage", func(ctx context.Context) error {
+		checked = true
+		return nil
+	})
+
+	checker.Drain()
+	report := checker.Check(context.Background())
+
+	if report.Status != health.StatusDraining || report.Ready() {
+		t.Fatalf("Expected status %s, got %s", health.StatusDraining, report.Status)
+	}
+	if checked {
+		t.Fatalf("Expected the checks not to run while draining")
+	}
+}
+
+func TestCheckShouldReuseReportWithinCacheTTL(t *testing.T) {
+	checker := health.NewChecker(time.Second, time.Hour)
+	checks := 0
+	checker.Register("storage", func(ctx context.Context) error {
+		checks++
+		return nil
+	})
+
+	checker.Check(context.Background())
+	report := checker.Check(context.Background())
+
+	if !report.Ready() {
+		t.Fatalf("Expected ready, got %s", report.Status)
+	}
+	if checks != 1 {
+		t.Fatalf("Expected the checks to run once, got %d", checks)
+	}
+}
+
+func TestWithoutErrorsShouldKeepOnlyStatuses(t *testing.T) {
+	checker := health.NewChecker(time.Second, 0)
+	checker.Register("storage", func(ctx context.Context) error {
+		return errors.New("bucket howlite is not reachable")
+	})
+	report := checker.Check(context.Background())
+
+	redacted := report.WithoutErrors()
+
+	if redacted.Status != health.StatusDown || redacted.Components["storage"].Status != health.StatusDown {
+		t.Fatalf("Expected the statuses to be kept, got %v", redacted)
+	}
+	if redacted.Components["storage"].Error != "" {
+		t.Fatalf("Expected no error, got %s", redacted.Components["storage"].Error)
+	}
+	if report.Components["storage"].Error == "" {
+		t.Fatalf("Expected the error of the original report to be kept")
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const apiKeyScheme = "ApiKey"
+
+// ApiKeyAuthenticator authenticates requests carrying a static API key, either
+// as "Authorization: ApiKey <key>" or in the X-Api-Key header.
+type ApiKeyAuthenticator struct {
+	principals map[string]*Principal
+}
+
+// apiKeyEntry is an entry of the API keys file. Either the key itself or the
+// hex encoded sha256 of the key can be stored.
+type apiKeyEntry struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Sha256 string   `json:"sha256"`
+	Roles  []string `json:"roles"`
+}
+
+func NewApiKeyAuthenticator(path string) (*ApiKeyAuthenticator, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []apiKeyEntry
+	if err := json.Unmarshal(file, &entries); err != nil {
+		return nil, err
+	}
+
+	principals := make(map[string]*Principal, len(entries))
+	for _, entry := range entries {
+		hash := strings.ToLower(entry.Sha256)
+		if entry.Key != "" {
+			hash = hashApiKey(entry.Key)
+		}
+		if entry.Name == "" || hash == "" {
+			return nil, errors.New("api key entries must have a name and a key or sha256")
+		}
+
+		principals[hash] = &Principal{
+			Name:   entry.Name,
+			Roles:  entry.Roles,
+			Scheme: apiKeyScheme,
+		}
+	}
+
+	return &ApiKeyAuthenticator{
+		principals: principals,
+	}, nil
+}
+
+func (authenticator *ApiKeyAuthenticator) Authenticate(req *http.Request) (*Principal, error) {
+	key := req.Header.Get("X-Api-Key")
+	if key == "" {
+		scheme, credentials, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, apiKeyScheme) {
+			return nil, ErrNoCredentials
+		}
+		key = strings.TrimSpace(credentials)
+	}
+
+	principal, exists := authenticator.principals[hashApiKey(key)]
+	if !exists {
+		return nil, ErrInvalidCredentials
+	}
+
+	return principal, nil
+}
+
+func (authenticator *ApiKeyAuthenticator) Challenge() string {
+	return apiKeyScheme + ` realm="howlite-resources"`
+}
+
+func hashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,105 @@
+//go:build unit
+
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/inx51/howlite-resources/http/auth"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func newApiKeyAuthenticator(t *testing.T) *auth.ApiKeyAuthenticator {
+	t.Helper()
+	// sha256 of "hashed-secret"
+	path := writeFile(t, "apikeys.json", `[
+		{"name": "cdn", "key": "plain-secret", "roles": ["reader"]},
+		{"name": "ci", "sha256": "4e598f5daafc2fda61641ddbb5956deb23fde6616366dc9dd5a7c9f47da4d787"}
+	]`)
+	authenticator, err := auth.NewApiKeyAuthenticator(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return authenticator
+}
+
+func TestApiKeyAuthenticateShouldAcceptApiKeyHeader(t *testing.T) {
+	authenticator := newApiKeyAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("X-Api-Key", "plain-secret")
+
+	principal, err := authenticator.Authenticate(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "cdn" || len(principal.Roles) != 1 || principal.Roles[0] != "reader" {
+		t.Fatalf("Expected principal 'cdn' with role 'reader', got %+v", principal)
+	}
+}
+
+func TestApiKeyAuthenticateShouldAcceptAuthorizationHeader(t *testing.T) {
+	authenticator := newApiKeyAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("Authorization", "ApiKey plain-secret")
+
+	principal, err := authenticator.Authenticate(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "cdn" {
+		t.Fatalf("Expected principal 'cdn', got %s", principal.Name)
+	}
+}
+
+func TestApiKeyAuthenticateShouldAcceptHashedKey(t *testing.T) {
+	authenticator := newApiKeyAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("X-Api-Key", "hashed-secret")
+
+	principal, err := authenticator.Authenticate(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "ci" {
+		t.Fatalf("Expected principal 'ci', got %s", principal.Name)
+	}
+}
+
+func TestApiKeyAuthenticateShouldRejectUnknownKey(t *testing.T) {
+	authenticator := newApiKeyAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("X-Api-Key", "unknown")
+
+	_, err := authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestApiKeyAuthenticateShouldReturnNoCredentialsForOtherSchemes(t *testing.T) {
+	authenticator := newApiKeyAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	_, err := authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrNoCredentials) {
+		t.Fatalf("Expected ErrNoCredentials, got %v", err)
+	}
+}
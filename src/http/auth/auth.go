@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request holds
+	// no credentials for its scheme, so that the next one in a Chain is tried.
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Principal struct {
+	Name   string
+	Roles  []string
+	Scheme string
+}
+
+type Authenticator interface {
+	Authenticate(req *http.Request) (*Principal, error)
+	Challenge() string
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal of the request, or
+// nil if authentication is disabled or the handler allows anonymous access.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Chain authenticates a request with the first authenticator for which the
+// request holds credentials. The authenticators can be replaced by Replace
+// while requests are authenticated.
+type Chain struct {
+	authenticators atomic.Pointer[[]Authenticator]
+}
+
+func NewChain(authenticators ...Authenticator) *Chain {
+	chain := &Chain{}
+	chain.Replace(authenticators...)
+	return chain
+}
+
+// Replace swaps the authenticators of the chain, requests being authenticated
+// finish with the previous ones.
+func (chain *Chain) Replace(authenticators ...Authenticator) {
+	chain.authenticators.Store(&authenticators)
+}
+
+func (chain *Chain) Authenticate(req *http.Request) (*Principal, error) {
+	for _, authenticator := range *chain.authenticators.Load() {
+		principal, err := authenticator.Authenticate(req)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+
+		return principal, err
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// Challenges returns the WWW-Authenticate challenges of all authenticators.
+func (chain *Chain) Challenges() []string {
+	authenticators := *chain.authenticators.Load()
+	challenges := make([]string, 0, len(authenticators))
+	for _, authenticator := range authenticators {
+		challenges = append(challenges, authenticator.Challenge())
+	}
+
+	return challenges
+}
+
+func (chain *Chain) IsEmpty() bool {
+	return len(*chain.authenticators.Load()) == 0
+}
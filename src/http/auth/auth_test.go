@@ -0,0 +1,60 @@
+//go:build unit
+
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/http/auth"
+)
+
+func TestChainShouldUseAuthenticatorMatchingCredentials(t *testing.T) {
+	chain := auth.NewChain(newApiKeyAuthenticator(t), newHmacAuthenticator(t))
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/resource", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+
+	principal, err := chain.Authenticate(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "janitor" {
+		t.Fatalf("Expected principal 'janitor', got %s", principal.Name)
+	}
+}
+
+func TestChainShouldReturnNoCredentialsWithoutCredentials(t *testing.T) {
+	chain := auth.NewChain(newApiKeyAuthenticator(t), newHmacAuthenticator(t))
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/resource", nil)
+
+	_, err := chain.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrNoCredentials) {
+		t.Fatalf("Expected ErrNoCredentials, got %v", err)
+	}
+	if len(chain.Challenges()) != 2 {
+		t.Fatalf("Expected 2 challenges, got %v", chain.Challenges())
+	}
+}
+
+func TestChainShouldUseReplacedAuthenticators(t *testing.T) {
+	chain := auth.NewChain(newApiKeyAuthenticator(t))
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/resource", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+
+	chain.Replace(newHmacAuthenticator(t))
+	principal, err := chain.Authenticate(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "janitor" {
+		t.Fatalf("Expected principal 'janitor', got %s", principal.Name)
+	}
+	if len(chain.Challenges()) != 1 {
+		t.Fatalf("Expected 1 challenge, got %v", chain.Challenges())
+	}
+}
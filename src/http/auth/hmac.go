@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inx51/howlite-resources/resource"
+)
+
+const (
+	hmacScheme            = "HMAC-SHA256"
+	HmacDateHeader        = "X-Howlite-Date"
+	HmacContentHashHeader = "X-Content-SHA256"
+)
+
+// ErrBodyNotVerified is returned when reading the body of a signed request
+// that doesn't match its signed hash, or that could not be read to the end
+// and therefore not be verified. The bytes read before must be discarded.
+var ErrBodyNotVerified = errors.New("request body does not match its signed hash")
+
+// HmacAuthenticator authenticates service to service requests signed with a
+// shared secret, see SignRequest for how a request is signed. Signatures are
+// only accepted once within the clock skew, which is tracked per instance, so
+// another instance accepts a replayed signature until it expires.
+type HmacAuthenticator struct {
+	keys         map[string]hmacKey
+	maxClockSkew time.Duration
+	mutex        sync.Mutex
+	seen         map[string]struct{}
+	expiries     seenSignatures
+}
+
+// seenSignatures is a min-heap of the seen signatures by the time they expire,
+// so expired signatures are pruned without walking all of them.
+type seenSignatures []seenSignature
+
+type seenSignature struct {
+	signature string
+	expires   time.Time
+}
+
+func (signatures seenSignatures) Len() int {
+	return len(signatures)
+}
+
+func (signatures seenSignatures) Less(i int, j int) bool {
+	return signatures[i].expires.Before(signatures[j].expires)
+}
+
+func (signatures seenSignatures) Swap(i int, j int) {
+	signatures[i], signatures[j] = signatures[j], signatures[i]
+}
+
+func (signatures *seenSignatures) Push(value any) {
+	*signatures = append(*signatures, value.(seenSignature))
+}
+
+func (signatures *seenSignatures) Pop() any {
+	old := *signatures
+	last := old[len(old)-1]
+	*signatures = old[:len(old)-1]
+	return last
+}
+
+type hmacKey struct {
+	secret    []byte
+	principal *Principal
+}
+
+type hmacKeyEntry struct {
+	Id     string   `json:"id"`
+	Secret string   `json:"secret"`
+	Roles  []string `json:"roles"`
+}
+
+func NewHmacAuthenticator(path string, maxClockSkew time.Duration) (*HmacAuthenticator, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []hmacKeyEntry
+	if err := json.Unmarshal(file, &entries); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]hmacKey, len(entries))
+	for _, entry := range entries {
+		if entry.Id == "" || entry.Secret == "" {
+			return nil, errors.New("hmac key entries must have an id and a secret")
+		}
+
+		keys[entry.Id] = hmacKey{
+			secret: []byte(entry.Secret),
+			principal: &Principal{
+				Name:   entry.Id,
+				Roles:  entry.Roles,
+				Scheme: hmacScheme,
+			},
+		}
+	}
+
+	return &HmacAuthenticator{
+		keys:         keys,
+		maxClockSkew: maxClockSkew,
+		seen:         make(map[string]struct{}),
+	}, nil
+}
+
+func (authenticator *HmacAuthenticator) Authenticate(req *http.Request) (*Principal, error) {
+	scheme, credentials, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+	if scheme != hmacScheme {
+		return nil, ErrNoCredentials
+	}
+
+	keyId, signature := parseHmacCredentials(credentials)
+	key, exists := authenticator.keys[keyId]
+	if !exists || signature == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	date, err := time.Parse(time.RFC3339, req.Header.Get(HmacDateHeader))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	skew := time.Since(date)
+	if skew > authenticator.maxClockSkew || skew < -authenticator.maxClockSkew {
+		return nil, ErrInvalidCredentials
+	}
+
+	contentHash, err := hex.DecodeString(req.Header.Get(HmacContentHashHeader))
+	if err != nil || len(contentHash) != sha256.Size {
+		return nil, ErrInvalidCredentials
+	}
+
+	expected := hmacSignature(key.secret, req)
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return nil, ErrInvalidCredentials
+	}
+	if !authenticator.markSeen(keyId+":"+signature, date) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := verifyBody(req, contentHash); err != nil {
+		return nil, err
+	}
+	return key.principal, nil
+}
+
+// markSeen records the signature until it's outside of the clock skew and
+// reports whether it was seen before. Expired signatures are pruned as new
+// ones are recorded, in the order they expire.
+func (authenticator *HmacAuthenticator) markSeen(signature string, date time.Time) bool {
+	authenticator.mutex.Lock()
+	defer authenticator.mutex.Unlock()
+
+	now := time.Now()
+	for authenticator.expiries.Len() > 0 && now.After(authenticator.expiries[0].expires) {
+		expired := heap.Pop(&authenticator.expiries).(seenSignature)
+		delete(authenticator.seen, expired.signature)
+	}
+	if _, seen := authenticator.seen[signature]; seen {
+		return false
+	}
+	authenticator.seen[signature] = struct{}{}
+	heap.Push(&authenticator.expiries, seenSignature{signature: signature, expires: date.Add(authenticator.maxClockSkew)})
+	return true
+}
+
+// verifyBody checks requests without a body right away, the body of other
+// requests is checked as it's read, see signedBody.
+func verifyBody(req *http.Request, contentHash []byte) error {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		empty := sha256.Sum256(nil)
+		if !hmac.Equal(empty[:], contentHash) {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	req.Body = &signedBody{
+		body:      req.Body,
+		hash:      sha256.New(),
+		expected:  contentHash,
+		remaining: req.ContentLength,
+	}
+	return nil
+}
+
+// signedBody hashes the body as it's read and fails with ErrBodyNotVerified
+// once the whole body has been read, by its Content-Length or at its end, if
+// it doesn't match the signed hash.
+type signedBody struct {
+	body      io.ReadCloser
+	hash      hash.Hash
+	expected  []byte
+	remaining int64
+	verified  bool
+	err       error
+}
+
+func (body *signedBody) Read(p []byte) (int, error) {
+	if body.err != nil {
+		return 0, body.err
+	}
+
+	n, err := body.body.Read(p)
+	body.hash.Write(p[:n])
+	if body.remaining >= 0 {
+		body.remaining -= int64(n)
+	}
+
+	if !body.verified && (err == io.EOF || body.remaining == 0) {
+		body.verified = true
+		if !hmac.Equal(body.hash.Sum(nil), body.expected) {
+			body.err = ErrBodyNotVerified
+			return n, body.err
+		}
+	}
+	if err != nil && err != io.EOF && !body.verified {
+		body.err = errors.Join(ErrBodyNotVerified, err)
+		return n, body.err
+	}
+	return n, err
+}
+
+func (body *signedBody) Close() error {
+	return body.body.Close()
+}
+
+func (authenticator *HmacAuthenticator) Challenge() string {
+	return hmacScheme + ` realm="howlite-resources"`
+}
+
+// SignRequest signs the request with the given key, by setting the
+// X-Howlite-Date, X-Content-SHA256 and Authorization headers. The signature
+// covers the method, path, query, date, the SHA-256 hash of the body, which
+// is read to compute it and replaced by a reader over the same bytes, and the
+// headers that are stored with the resource. Headers must not be changed once
+// the request is signed.
+func SignRequest(req *http.Request, keyId string, secret string, now time.Time) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	contentHash := sha256.Sum256(body)
+
+	req.Header.Set(HmacDateHeader, now.UTC().Format(time.RFC3339Nano))
+	req.Header.Set(HmacContentHashHeader, hex.EncodeToString(contentHash[:]))
+	signature := hex.EncodeToString(hmacSignature([]byte(secret), req))
+	req.Header.Set("Authorization", hmacScheme+" Credential="+keyId+", Signature="+signature)
+	return nil
+}
+
+func hmacSignature(secret []byte, req *http.Request) []byte {
+	stringToSign := strings.Join([]string{
+		hmacScheme,
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		req.Header.Get(HmacDateHeader),
+		strings.ToLower(req.Header.Get(HmacContentHashHeader)),
+		storedHeaders(req.Header),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(stringToSign))
+	return mac.Sum(nil)
+}
+
+// storedHeaders canonicalizes the headers that are stored with the resource,
+// such as Content-Type and custom headers, as a line of lowercase name and
+// comma separated values per header, sorted by name.
+func storedHeaders(header http.Header) string {
+	lines := make([]string, 0, len(header))
+	for name, values := range header {
+		if !resource.IsStoredHeader(name) {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.TrimSpace(value)
+		}
+		lines = append(lines, strings.ToLower(name)+":"+strings.Join(trimmed, ","))
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
+
+func parseHmacCredentials(credentials string) (string, string) {
+	var keyId, signature string
+	for _, part := range strings.Split(credentials, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "Credential":
+			keyId = value
+		case "Signature":
+			signature = value
+		}
+	}
+
+	return keyId, signature
+}
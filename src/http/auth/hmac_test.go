@@ -0,0 +1,204 @@
+//go:build unit
+
+package auth_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/http/auth"
+)
+
+func newHmacAuthenticator(t *testing.T) *auth.HmacAuthenticator {
+	t.Helper()
+	path := writeFile(t, "hmackeys.json", `[{"id": "janitor", "secret": "s3cr3t", "roles": ["cleanup"]}]`)
+	authenticator, err := auth.NewHmacAuthenticator(path, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return authenticator
+}
+
+func TestHmacAuthenticateShouldAcceptSignedRequest(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodDelete, "http://localhost/tmp/a.txt?force=true", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+
+	principal, err := authenticator.Authenticate(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "janitor" || principal.Roles[0] != "cleanup" {
+		t.Fatalf("Expected principal 'janitor' with role 'cleanup', got %+v", principal)
+	}
+}
+
+func TestHmacAuthenticateShouldRejectTamperedRequest(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+	req.Method = http.MethodDelete
+
+	_, err := authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestHmacAuthenticateShouldRejectWrongSecret(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(req, "janitor", "wrong", time.Now())
+
+	_, err := authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestHmacAuthenticateShouldRejectRequestOutsideClockSkew(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now().Add(-10*time.Minute))
+
+	_, err := authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestHmacAuthenticateShouldRejectReplayedSignature(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodDelete, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+	authenticator.Authenticate(req)
+
+	_, err := authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestHmacAuthenticateShouldRejectReplayedSignatureAfterExpiredOnesArePruned(t *testing.T) {
+	path := writeFile(t, "hmackeys.json", `[{"id": "janitor", "secret": "s3cr3t"}]`)
+	authenticator, err := auth.NewHmacAuthenticator(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expired, _ := http.NewRequest(http.MethodDelete, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(expired, "janitor", "s3cr3t", time.Now())
+	authenticator.Authenticate(expired)
+	time.Sleep(100 * time.Millisecond)
+	req, _ := http.NewRequest(http.MethodDelete, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+
+	_, err = authenticator.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, err = authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestHmacAuthenticateShouldRejectTamperedStoredHeader(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(header http.Header)
+	}{
+		{"changed content type", func(header http.Header) { header.Set("Content-Type", "text/html") }},
+		{"added custom header", func(header http.Header) { header.Set("X-Owner", "mallory") }},
+		{"removed custom header", func(header http.Header) { header.Del("X-Team") }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			authenticator := newHmacAuthenticator(t)
+			req, _ := http.NewRequest(http.MethodPut, "http://localhost/tmp/a.txt", strings.NewReader("hello world"))
+			req.Header.Set("Content-Type", "text/plain")
+			req.Header.Set("X-Team", "platform")
+			auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+			tc.tamper(req.Header)
+
+			_, err := authenticator.Authenticate(req)
+
+			if !errors.Is(err, auth.ErrInvalidCredentials) {
+				t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHmacAuthenticateShouldIgnoreHeadersThatAreNotStored(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+	req.Header.Set("User-Agent", "proxy/1.0")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	_, err := authenticator.Authenticate(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestHmacAuthenticateShouldRejectRequestWithoutContentHash(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/tmp/a.txt", nil)
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+	req.Header.Del(auth.HmacContentHashHeader)
+
+	_, err := authenticator.Authenticate(req)
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestHmacAuthenticateShouldVerifySignedBody(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodPut, "http://localhost/tmp/a.txt", strings.NewReader("hello world"))
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+
+	_, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	body, err := io.ReadAll(req.Body)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(body) != "hello world" {
+		t.Fatalf("Expected 'hello world', got %q", body)
+	}
+}
+
+func TestHmacAuthenticateShouldFailReadingTamperedBody(t *testing.T) {
+	authenticator := newHmacAuthenticator(t)
+	req, _ := http.NewRequest(http.MethodPut, "http://localhost/tmp/a.txt", strings.NewReader("hello world"))
+	auth.SignRequest(req, "janitor", "s3cr3t", time.Now())
+	req.Body = io.NopCloser(strings.NewReader("hello there"))
+
+	_, err := authenticator.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, err = io.ReadAll(req.Body)
+
+	if !errors.Is(err, auth.ErrBodyNotVerified) {
+		t.Fatalf("Expected ErrBodyNotVerified, got %v", err)
+	}
+}
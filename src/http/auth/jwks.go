@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inx51/howlite-resources/logger"
+)
+
+// minKeySetRefreshInterval limits how often an unknown key id or a stale key
+// set can trigger a refresh of the key set, whether the last one failed or not.
+const minKeySetRefreshInterval = 30 * time.Second
+
+var errKeyNotFound = errors.New("signing key not found")
+
+// keySet holds the public keys of a JWKS document, which is loaded from a
+// local file or from the jwks_uri of an OpenID Connect issuer.
+type keySet struct {
+	mutex           sync.RWMutex
+	keys            map[string]any
+	refreshedUtc    time.Time
+	attemptedUtc    time.Time
+	refreshInterval time.Duration
+	fetch           func(ctx context.Context) ([]byte, error)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newFileKeySet(path string) *keySet {
+	return &keySet{
+		fetch: func(ctx context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		},
+	}
+}
+
+func newIssuerKeySet(issuer string, refreshInterval time.Duration, client *http.Client) *keySet {
+	return &keySet{
+		refreshInterval: refreshInterval,
+		fetch: func(ctx context.Context) ([]byte, error) {
+			discovery, err := httpGet(ctx, client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+			if err != nil {
+				return nil, err
+			}
+
+			var configuration struct {
+				JwksUri string `json:"jwks_uri"`
+			}
+			if err := json.Unmarshal(discovery, &configuration); err != nil {
+				return nil, err
+			}
+			if configuration.JwksUri == "" {
+				return nil, errors.New("openid configuration has no jwks_uri")
+			}
+
+			return httpGet(ctx, client, configuration.JwksUri)
+		},
+	}
+}
+
+// key returns the key with the given id, refreshing the key set if it's stale
+// or doesn't hold the key. The cached key is used while the key set can't be
+// refreshed, so that an unavailable issuer doesn't reject every token.
+func (set *keySet) key(ctx context.Context, kid string) (any, error) {
+	set.mutex.RLock()
+	key, found := set.lookup(kid)
+	sinceRefresh := time.Since(set.refreshedUtc)
+	sinceAttempt := time.Since(set.attemptedUtc)
+	set.mutex.RUnlock()
+
+	isStale := set.refreshInterval > 0 && sinceRefresh > set.refreshInterval
+	if found && !isStale {
+		return key, nil
+	}
+	if sinceAttempt < minKeySetRefreshInterval {
+		if found {
+			return key, nil
+		}
+		return nil, errKeyNotFound
+	}
+
+	if err := set.refresh(ctx); err != nil {
+		if found {
+			logger.Warn(ctx, "failed to refresh stale jwt signing keys, using the cached key", "jwt.kid", kid, "error", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+	key, found = set.lookup(kid)
+	if !found {
+		return nil, errKeyNotFound
+	}
+
+	return key, nil
+}
+
+// lookup finds the key with the given id, a token without a key id can only be
+// verified if the set holds a single key.
+func (set *keySet) lookup(kid string) (any, bool) {
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key, true
+		}
+	}
+
+	key, found := set.keys[kid]
+	return key, found
+}
+
+func (set *keySet) refresh(ctx context.Context) error {
+	set.mutex.Lock()
+	set.attemptedUtc = time.Now()
+	set.mutex.Unlock()
+
+	document, err := set.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJwks(document)
+	if err != nil {
+		return err
+	}
+
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	set.keys = keys
+	set.refreshedUtc = time.Now()
+	return nil
+}
+
+func parseJwks(document []byte) (map[string]any, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(document, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (jwk *jsonWebKey) publicKey() (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBase64Url(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64Url(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		curve, err := ellipticCurve(jwk.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeBase64Url(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64Url(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid ec coordinates")
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBase64Url(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func ellipticCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+}
+
+func decodeBase64Url(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func httpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
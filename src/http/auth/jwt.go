@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const bearerScheme = "Bearer"
+
+// JwtAuthenticator authenticates requests carrying a bearer JWT signed by one
+// of the keys of a JWKS. The subject of the token becomes the principal name
+// and the roles are read from a configurable claim.
+type JwtAuthenticator struct {
+	keys       *keySet
+	parser     *jwt.Parser
+	rolesClaim string
+}
+
+type JwtOptions struct {
+	JwksPath        string
+	Issuer          string
+	Audience        string
+	RolesClaim      string
+	RefreshInterval time.Duration
+	Client          *http.Client
+}
+
+// NewJwtAuthenticator loads the keys from JwksPath if set, otherwise from the
+// jwks_uri advertised by the Issuer's OpenID configuration, which is then
+// refreshed every RefreshInterval.
+func NewJwtAuthenticator(ctx context.Context, options JwtOptions) (*JwtAuthenticator, error) {
+	var keys *keySet
+	switch {
+	case options.JwksPath != "":
+		keys = newFileKeySet(options.JwksPath)
+	case options.Issuer != "":
+		client := options.Client
+		if client == nil {
+			client = &http.Client{Timeout: 10 * time.Second}
+		}
+		keys = newIssuerKeySet(options.Issuer, options.RefreshInterval, client)
+	default:
+		return nil, errors.New("either a jwks path or an issuer is required")
+	}
+
+	if err := keys.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+
+	rolesClaim := options.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+
+	return &JwtAuthenticator{
+		keys:       keys,
+		parser:     jwt.NewParser(parserOptions...),
+		rolesClaim: rolesClaim,
+	}, nil
+}
+
+func (authenticator *JwtAuthenticator) Authenticate(req *http.Request) (*Principal, error) {
+	scheme, token, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, bearerScheme) {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := authenticator.parser.ParseWithClaims(strings.TrimSpace(token), claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return authenticator.keys.key(req.Context(), kid)
+	})
+	if err != nil {
+		return nil, errors.Join(ErrInvalidCredentials, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{
+		Name:   subject,
+		Roles:  rolesFromClaim(claims[authenticator.rolesClaim]),
+		Scheme: bearerScheme,
+	}, nil
+}
+
+func (authenticator *JwtAuthenticator) Challenge() string {
+	return bearerScheme + ` realm="howlite-resources"`
+}
+
+// rolesFromClaim accepts both an array of roles and a space separated string,
+// as used by the OAuth "scope" claim.
+func rolesFromClaim(claim any) []string {
+	switch value := claim.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		roles := make([]string, 0, len(value))
+		for _, role := range value {
+			if role, ok := role.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
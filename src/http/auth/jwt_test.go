@@ -0,0 +1,216 @@
+//go:build unit
+
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/inx51/howlite-resources/http/auth"
+)
+
+func newSigningKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+func jwksDocument(t *testing.T, kid string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	publicKey, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to encode public key: %v", err)
+	}
+	coordinates := publicKey[1:]
+	document, _ := json.Marshal(map[string]any{
+		"keys": []map[string]string{{
+			"kid": kid,
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(coordinates[:32]),
+			"y":   base64.RawURLEncoding.EncodeToString(coordinates[32:]),
+		}},
+	})
+	return string(document)
+}
+
+func signToken(t *testing.T, kid string, key *ecdsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func bearerRequest(token string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJwtAuthenticateShouldAcceptTokenSignedByJwksFileKey(t *testing.T) {
+	key := newSigningKey(t)
+	path := writeFile(t, "jwks.json", jwksDocument(t, "k1", key))
+	authenticator, err := auth.NewJwtAuthenticator(context.Background(), auth.JwtOptions{JwksPath: path, Audience: "howlite"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	token := signToken(t, "k1", key, jwt.MapClaims{
+		"sub":   "team-a",
+		"aud":   "howlite",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"writer", "reader"},
+	})
+
+	principal, err := authenticator.Authenticate(bearerRequest(token))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "team-a" || len(principal.Roles) != 2 || principal.Roles[0] != "writer" {
+		t.Fatalf("Expected principal 'team-a' with roles, got %+v", principal)
+	}
+}
+
+func TestJwtAuthenticateShouldRejectExpiredToken(t *testing.T) {
+	key := newSigningKey(t)
+	path := writeFile(t, "jwks.json", jwksDocument(t, "k1", key))
+	authenticator, _ := auth.NewJwtAuthenticator(context.Background(), auth.JwtOptions{JwksPath: path})
+	token := signToken(t, "k1", key, jwt.MapClaims{"sub": "team-a", "exp": time.Now().Add(-time.Hour).Unix()})
+
+	_, err := authenticator.Authenticate(bearerRequest(token))
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestJwtAuthenticateShouldRejectTokenSignedByUnknownKey(t *testing.T) {
+	key := newSigningKey(t)
+	path := writeFile(t, "jwks.json", jwksDocument(t, "k1", key))
+	authenticator, _ := auth.NewJwtAuthenticator(context.Background(), auth.JwtOptions{JwksPath: path})
+	token := signToken(t, "k1", newSigningKey(t), jwt.MapClaims{"sub": "team-a", "exp": time.Now().Add(time.Hour).Unix()})
+
+	_, err := authenticator.Authenticate(bearerRequest(token))
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestJwtAuthenticateShouldRejectWrongAudience(t *testing.T) {
+	key := newSigningKey(t)
+	path := writeFile(t, "jwks.json", jwksDocument(t, "k1", key))
+	authenticator, _ := auth.NewJwtAuthenticator(context.Background(), auth.JwtOptions{JwksPath: path, Audience: "howlite"})
+	token := signToken(t, "k1", key, jwt.MapClaims{"sub": "team-a", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()})
+
+	_, err := authenticator.Authenticate(bearerRequest(token))
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestJwtAuthenticateShouldLoadKeysFromIssuer(t *testing.T) {
+	key := newSigningKey(t)
+	mux := http.NewServeMux()
+	issuer := httptest.NewServer(mux)
+	defer issuer.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.URL, "jwks_uri": issuer.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jwksDocument(t, "k1", key)))
+	})
+	authenticator, err := auth.NewJwtAuthenticator(context.Background(), auth.JwtOptions{Issuer: issuer.URL, RolesClaim: "scope"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	token := signToken(t, "k1", key, jwt.MapClaims{
+		"sub":   "cdn",
+		"iss":   issuer.URL,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "read list",
+	})
+
+	principal, err := authenticator.Authenticate(bearerRequest(token))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if principal.Name != "cdn" || len(principal.Roles) != 2 || principal.Roles[1] != "list" {
+		t.Fatalf("Expected principal 'cdn' with scope roles, got %+v", principal)
+	}
+}
+
+func TestJwtAuthenticateShouldRejectWrongIssuer(t *testing.T) {
+	key := newSigningKey(t)
+	mux := http.NewServeMux()
+	issuer := httptest.NewServer(mux)
+	defer issuer.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jwksDocument(t, "k1", key)))
+	})
+	authenticator, _ := auth.NewJwtAuthenticator(context.Background(), auth.JwtOptions{Issuer: issuer.URL})
+	token := signToken(t, "k1", key, jwt.MapClaims{"sub": "cdn", "iss": "https://evil.example", "exp": time.Now().Add(time.Hour).Unix()})
+
+	_, err := authenticator.Authenticate(bearerRequest(token))
+
+	if !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestJwtAuthenticateShouldUseCachedKeyWhenIssuerIsUnavailable(t *testing.T) {
+	key := newSigningKey(t)
+	available := true
+	requests := 0
+	mux := http.NewServeMux()
+	issuer := httptest.NewServer(mux)
+	defer issuer.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jwksDocument(t, "k1", key)))
+	})
+	authenticator, err := auth.NewJwtAuthenticator(context.Background(), auth.JwtOptions{Issuer: issuer.URL, RefreshInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	available = false
+	token := signToken(t, "k1", key, jwt.MapClaims{"sub": "cdn", "iss": issuer.URL, "exp": time.Now().Add(time.Hour).Unix()})
+
+	for range 3 {
+		if _, err := authenticator.Authenticate(bearerRequest(token)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("Expected the stale key set not to be refreshed again within the minimum interval, got %d requests", requests)
+	}
+}
@@ -0,0 +1,162 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/logger"
+)
+
+// Authorizer evaluates requests against the policy loaded from a file, which
+// is reloaded by Reload whenever the file has changed.
+type Authorizer struct {
+	path        string
+	policy      atomic.Pointer[Policy]
+	mutex       sync.Mutex
+	modifiedUtc time.Time
+	size        int64
+}
+
+func NewAuthorizer(path string) (*Authorizer, error) {
+	authorizer := &Authorizer{
+		path: path,
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizer.load(info); err != nil {
+		return nil, err
+	}
+
+	return authorizer, nil
+}
+
+func (authorizer *Authorizer) Authorize(principal *auth.Principal, method string, path string) Decision {
+	return authorizer.policy.Load().Evaluate(principal, method, path)
+}
+
+type authorizerKey struct{}
+
+// WithAuthorizer returns a context carrying the authorizer, so that handlers
+// returning more than the requested resource, like listings, can authorize
+// each of them.
+func WithAuthorizer(ctx context.Context, authorizer *Authorizer) context.Context {
+	return context.WithValue(ctx, authorizerKey{}, authorizer)
+}
+
+// Allowed reports whether the principal of the request may access the path
+// with the method. Everything is allowed when authorization is disabled.
+func Allowed(ctx context.Context, method string, path string) bool {
+	authorizer, _ := ctx.Value(authorizerKey{}).(*Authorizer)
+	if authorizer == nil {
+		return true
+	}
+
+	return authorizer.Authorize(auth.PrincipalFromContext(ctx), method, path).Allowed
+}
+
+// Reload loads the policy file again if it has changed since it was last
+// loaded. An invalid policy is rejected and the current one is kept until the
+// file changes again.
+func (authorizer *Authorizer) Reload(ctx context.Context) (bool, error) {
+	authorizer.mutex.Lock()
+	defer authorizer.mutex.Unlock()
+
+	info, err := os.Stat(authorizer.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(authorizer.modifiedUtc) && info.Size() == authorizer.size {
+		return false, nil
+	}
+
+	if err := authorizer.load(info); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Path returns the path of the policy file.
+func (authorizer *Authorizer) Path() string {
+	authorizer.mutex.Lock()
+	defer authorizer.mutex.Unlock()
+
+	return authorizer.path
+}
+
+// Replace swaps the policy, and the file it is reloaded from, for the ones of
+// the replacement, e.g. when the policy path has been reconfigured.
+func (authorizer *Authorizer) Replace(replacement *Authorizer) {
+	authorizer.mutex.Lock()
+	defer authorizer.mutex.Unlock()
+
+	authorizer.path = replacement.path
+	authorizer.modifiedUtc = replacement.modifiedUtc
+	authorizer.size = replacement.size
+	authorizer.policy.Store(replacement.policy.Load())
+}
+
+func (authorizer *Authorizer) load(info os.FileInfo) error {
+	authorizer.modifiedUtc = info.ModTime()
+	authorizer.size = info.Size()
+
+	document, err := os.ReadFile(authorizer.path)
+	if err != nil {
+		return err
+	}
+
+	policy, err := ParsePolicy(document)
+	if err != nil {
+		return err
+	}
+
+	authorizer.policy.Store(policy)
+	return nil
+}
+
+type ReloadWorker struct {
+	authorizer *Authorizer
+	ticker     *time.Ticker
+}
+
+func NewReloadWorker(ctx context.Context, authorizer *Authorizer, interval time.Duration) ReloadWorker {
+	return ReloadWorker{
+		authorizer: authorizer,
+		ticker:     time.NewTicker(interval),
+	}
+}
+
+func (worker *ReloadWorker) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "Authorization policy reload worker stopped")
+			return
+		case <-worker.ticker.C:
+			reloaded, err := worker.authorizer.Reload(ctx)
+			if err != nil {
+				logger.Error(ctx, "Failed to reload authorization policy, keeping the current policy", "path", worker.authorizer.Path(), "error", err)
+				continue
+			}
+			if reloaded {
+				logger.Info(ctx, "Authorization policy reloaded", "path", worker.authorizer.Path())
+			}
+		}
+	}
+}
+
+// Reset changes the interval at which the policy file is checked for changes.
+func (worker *ReloadWorker) Reset(interval time.Duration) {
+	worker.ticker.Reset(interval)
+}
+
+func (worker *ReloadWorker) Stop(ctx context.Context) {
+	worker.ticker.Stop()
+}
@@ -0,0 +1,139 @@
+//go:build unit
+
+package authz_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/http/authz"
+)
+
+func writePolicy(t *testing.T, path string, document string, modified time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(document), 0o600); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestAuthorizerShouldReloadChangedPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["/**"]}]}`, time.Now().Add(-time.Hour))
+	authorizer, err := authz.NewAuthorizer(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cdn := &auth.Principal{Name: "cdn"}
+
+	writePolicy(t, path, `{"rules": [{"principals": ["cdn"], "methods": ["HEAD"], "paths": ["/**"]}]}`, time.Now())
+	reloaded, err := authorizer.Reload(context.Background())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reloaded {
+		t.Fatalf("Expected the policy to be reloaded")
+	}
+	if authorizer.Authorize(cdn, "GET", "/a.txt").Allowed {
+		t.Fatalf("Expected GET to be denied after reload")
+	}
+	if !authorizer.Authorize(cdn, "HEAD", "/a.txt").Allowed {
+		t.Fatalf("Expected HEAD to be allowed after reload")
+	}
+}
+
+func TestAuthorizerShouldNotReloadUnchangedPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{"rules": []}`, time.Now().Add(-time.Hour))
+	authorizer, err := authz.NewAuthorizer(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	reloaded, err := authorizer.Reload(context.Background())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if reloaded {
+		t.Fatalf("Expected the policy not to be reloaded")
+	}
+}
+
+func TestAuthorizerShouldKeepPolicyWhenReloadFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["/**"]}]}`, time.Now().Add(-time.Hour))
+	authorizer, err := authz.NewAuthorizer(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	writePolicy(t, path, `{"rules": [`, time.Now())
+	_, err = authorizer.Reload(context.Background())
+
+	if err == nil {
+		t.Fatalf("Expected an error for an invalid policy")
+	}
+	if !authorizer.Authorize(&auth.Principal{Name: "cdn"}, "GET", "/a.txt").Allowed {
+		t.Fatalf("Expected the previous policy to be kept")
+	}
+}
+
+func TestAuthorizerShouldUseReplacedPolicy(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "policy.json")
+	writePolicy(t, path, `{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["/**"]}]}`, time.Now())
+	authorizer, err := authz.NewAuthorizer(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	replacementPath := filepath.Join(directory, "replacement.json")
+	writePolicy(t, replacementPath, `{"rules": [{"principals": ["cdn"], "methods": ["HEAD"], "paths": ["/**"]}]}`, time.Now())
+	replacement, err := authz.NewAuthorizer(replacementPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cdn := &auth.Principal{Name: "cdn"}
+
+	authorizer.Replace(replacement)
+
+	if authorizer.Path() != replacementPath {
+		t.Fatalf("Expected path %s, got %s", replacementPath, authorizer.Path())
+	}
+	if authorizer.Authorize(cdn, "GET", "/a.txt").Allowed {
+		t.Fatalf("Expected GET to be denied after replace")
+	}
+	if !authorizer.Authorize(cdn, "HEAD", "/a.txt").Allowed {
+		t.Fatalf("Expected HEAD to be allowed after replace")
+	}
+}
+
+func TestAllowedShouldAuthorizePrincipalOfContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, `{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["/team-a/**"]}]}`, time.Now())
+	authorizer, err := authz.NewAuthorizer(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx := authz.WithAuthorizer(auth.WithPrincipal(context.Background(), &auth.Principal{Name: "cdn"}), authorizer)
+
+	if !authz.Allowed(ctx, "GET", "/team-a/x") {
+		t.Fatalf("Expected /team-a/x to be allowed")
+	}
+	if authz.Allowed(ctx, "GET", "/team-a-secret/x") {
+		t.Fatalf("Expected /team-a-secret/x to be denied")
+	}
+}
+
+func TestAllowedShouldAllowEverythingWithoutAuthorizer(t *testing.T) {
+	if !authz.Allowed(context.Background(), "GET", "/team-a-secret/x") {
+		t.Fatalf("Expected everything to be allowed without an authorizer")
+	}
+}
@@ -0,0 +1,53 @@
+package authz
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the request path matches the pattern. Patterns are
+// matched segment by segment, "*" matches within a single segment and a "**"
+// segment matches any number of segments, including none.
+func matchGlob(pattern string, requestPath string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(requestPath, "/"))
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(segments); skip++ {
+				if matchSegments(pattern[1:], segments[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		matched, err := path.Match(pattern[0], segments[0])
+		if err != nil || !matched {
+			return false
+		}
+
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
+
+func validGlob(pattern string) bool {
+	if !strings.HasPrefix(pattern, "/") {
+		return false
+	}
+
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
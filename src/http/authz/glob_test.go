@@ -0,0 +1,33 @@
+//go:build unit
+
+package authz
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"/team-a/**", "/team-a/docs/a.txt", true},
+		{"/team-a/**", "/team-a/", true},
+		{"/team-a/**", "/team-a", true},
+		{"/team-a/**", "/team-b/a.txt", false},
+		{"/team-a/**", "/team-ab/a.txt", false},
+		{"/**", "/anything/at/all", true},
+		{"/tmp/*", "/tmp/a.txt", true},
+		{"/tmp/*", "/tmp/a/b.txt", false},
+		{"/tmp/*.log", "/tmp/a.log", true},
+		{"/**/*.log", "/var/tmp/a.log", true},
+		{"/**/*.log", "/var/tmp/a.txt", false},
+		{"/exact.txt", "/exact.txt", true},
+		{"/exact.txt", "/exact.txt/more", false},
+	}
+
+	for _, c := range cases {
+		if matchGlob(c.pattern, c.path) != c.matches {
+			t.Errorf("Expected matchGlob(%q, %q) to be %t", c.pattern, c.path, c.matches)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/inx51/howlite-resources/http/auth"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// permissions maps the permission names that can be used in place of HTTP
+// methods to the methods they grant.
+var permissions = map[string][]string{
+	"read":   {http.MethodGet, http.MethodHead},
+	"write":  {http.MethodPost, http.MethodPut},
+	"delete": {http.MethodDelete},
+}
+
+// Policy is an ordered set of rules. A request is allowed if at least one
+// allow rule and no deny rule matches it, requests no rule matches are denied.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule matches requests by the principal making them, their method and path.
+// Principals and roles are alternatives, "*" in Principals matches any caller,
+// including anonymous ones. Methods holds HTTP methods or the "read", "write"
+// and "delete" permissions, Paths holds glob patterns.
+type Rule struct {
+	Name       string   `json:"name"`
+	Effect     string   `json:"effect"`
+	Principals []string `json:"principals"`
+	Roles      []string `json:"roles"`
+	Methods    []string `json:"methods"`
+	Paths      []string `json:"paths"`
+}
+
+type Decision struct {
+	Allowed bool
+	Rule    string
+}
+
+func ParsePolicy(document []byte) (*Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal(document, &policy); err != nil {
+		return nil, err
+	}
+
+	for index := range policy.Rules {
+		rule := &policy.Rules[index]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rules[%d]", index)
+		}
+		if err := rule.normalize(); err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rule.Name, err)
+		}
+	}
+
+	return &policy, nil
+}
+
+func (policy *Policy) Evaluate(principal *auth.Principal, method string, path string) Decision {
+	decision := Decision{}
+	for index := range policy.Rules {
+		rule := &policy.Rules[index]
+		if !rule.matches(principal, method, path) {
+			continue
+		}
+
+		if rule.Effect == EffectDeny {
+			return Decision{Allowed: false, Rule: rule.Name}
+		}
+		if !decision.Allowed {
+			decision = Decision{Allowed: true, Rule: rule.Name}
+		}
+	}
+
+	return decision
+}
+
+func (rule *Rule) normalize() error {
+	switch rule.Effect {
+	case "":
+		rule.Effect = EffectAllow
+	case EffectAllow, EffectDeny:
+	default:
+		return fmt.Errorf("unknown effect %q", rule.Effect)
+	}
+
+	if len(rule.Principals) == 0 && len(rule.Roles) == 0 {
+		return fmt.Errorf("at least one principal or role is required")
+	}
+	if len(rule.Methods) == 0 || len(rule.Paths) == 0 {
+		return fmt.Errorf("at least one method and path is required")
+	}
+
+	methods := make([]string, 0, len(rule.Methods))
+	for _, method := range rule.Methods {
+		if granted, ok := permissions[strings.ToLower(method)]; ok {
+			methods = append(methods, granted...)
+			continue
+		}
+		methods = append(methods, strings.ToUpper(method))
+	}
+	rule.Methods = methods
+
+	for _, pattern := range rule.Paths {
+		if !validGlob(pattern) {
+			return fmt.Errorf("invalid path pattern %q", pattern)
+		}
+	}
+
+	return nil
+}
+
+func (rule *Rule) matches(principal *auth.Principal, method string, path string) bool {
+	if !rule.matchesPrincipal(principal) {
+		return false
+	}
+	if !slices.Contains(rule.Methods, "*") && !slices.Contains(rule.Methods, method) {
+		return false
+	}
+
+	return slices.ContainsFunc(rule.Paths, func(pattern string) bool {
+		return matchGlob(pattern, path)
+	})
+}
+
+func (rule *Rule) matchesPrincipal(principal *auth.Principal) bool {
+	if slices.Contains(rule.Principals, "*") {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+	if slices.Contains(rule.Principals, principal.Name) {
+		return true
+	}
+
+	return slices.ContainsFunc(principal.Roles, func(role string) bool {
+		return slices.Contains(rule.Roles, role)
+	})
+}
@@ -0,0 +1,87 @@
+//go:build unit
+
+package authz_test
+
+import (
+	"testing"
+
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/http/authz"
+)
+
+const testPolicy = `{
+	"rules": [
+		{ "name": "team-a", "roles": ["team-a"], "methods": ["read", "write", "delete"], "paths": ["/team-a/**"] },
+		{ "name": "cdn", "principals": ["cdn"], "methods": ["GET"], "paths": ["/**"] },
+		{ "name": "janitor", "principals": ["janitor"], "methods": ["delete"], "paths": ["/tmp/**"] },
+		{ "name": "protected", "effect": "deny", "principals": ["*"], "methods": ["*"], "paths": ["/team-a/protected/**"] },
+		{ "name": "public", "principals": ["*"], "methods": ["HEAD"], "paths": ["/public/**"] }
+	]
+}`
+
+func TestEvaluate(t *testing.T) {
+	policy, err := authz.ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	teamA := &auth.Principal{Name: "alice", Roles: []string{"team-a"}}
+	cdn := &auth.Principal{Name: "cdn"}
+	janitor := &auth.Principal{Name: "janitor"}
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		method    string
+		path      string
+		allowed   bool
+		rule      string
+	}{
+		{"role may write under its prefix", teamA, "PUT", "/team-a/a.txt", true, "team-a"},
+		{"role may not write elsewhere", teamA, "PUT", "/team-b/a.txt", false, ""},
+		{"cdn may get", cdn, "GET", "/team-a/a.txt", true, "cdn"},
+		{"cdn may not head", cdn, "HEAD", "/team-a/a.txt", false, ""},
+		{"cdn may not delete", cdn, "DELETE", "/team-a/a.txt", false, ""},
+		{"janitor may delete under tmp", janitor, "DELETE", "/tmp/a/b.txt", true, "janitor"},
+		{"janitor may not delete elsewhere", janitor, "DELETE", "/team-a/a.txt", false, ""},
+		{"deny overrides allow", teamA, "GET", "/team-a/protected/a.txt", false, "protected"},
+		{"wildcard matches anonymous callers", nil, "HEAD", "/public/a.txt", true, "public"},
+		{"anonymous callers match no principal", nil, "GET", "/team-a/a.txt", false, ""},
+	}
+
+	for _, c := range cases {
+		decision := policy.Evaluate(c.principal, c.method, c.path)
+		if decision.Allowed != c.allowed || decision.Rule != c.rule {
+			t.Errorf("%s: expected allowed %t by %q, got %t by %q", c.name, c.allowed, c.rule, decision.Allowed, decision.Rule)
+		}
+	}
+}
+
+func TestParsePolicyShouldNameUnnamedRules(t *testing.T) {
+	policy, err := authz.ParsePolicy([]byte(`{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["/**"]}]}`))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if policy.Rules[0].Name != "rules[0]" {
+		t.Fatalf("Expected rule name 'rules[0]', got %q", policy.Rules[0].Name)
+	}
+}
+
+func TestParsePolicyShouldRejectInvalidRules(t *testing.T) {
+	documents := []string{
+		`{"rules": [{"methods": ["GET"], "paths": ["/**"]}]}`,
+		`{"rules": [{"principals": ["cdn"], "paths": ["/**"]}]}`,
+		`{"rules": [{"principals": ["cdn"], "methods": ["GET"]}]}`,
+		`{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["relative/**"]}]}`,
+		`{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["/[a"]}]}`,
+		`{"rules": [{"effect": "maybe", "principals": ["cdn"], "methods": ["GET"], "paths": ["/**"]}]}`,
+		`not json`,
+	}
+
+	for _, document := range documents {
+		if _, err := authz.ParsePolicy([]byte(document)); err == nil {
+			t.Errorf("Expected an error for %s", document)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package byterange
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MaxRanges limits how many ranges are served for a single request once they
+// are coalesced, if there are more the full resource is returned instead.
+const MaxRanges = 16
+
+var (
+	// ErrInvalid is returned for Range headers that can't be parsed, such
+	// headers must be ignored and the full resource returned.
+	ErrInvalid = errors.New("invalid range")
+	// ErrUnsatisfiable is returned when none of the requested ranges overlap
+	// the resource.
+	ErrUnsatisfiable = errors.New("range not satisfiable")
+)
+
+type Range struct {
+	Start  int64
+	Length int64
+}
+
+// ContentRange formats the range as the value of a Content-Range header for
+// a resource of the given size.
+func (r Range) ContentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(r.Start, 10) + "-" + strconv.FormatInt(r.Start+r.Length-1, 10) + "/" + strconv.FormatInt(size, 10)
+}
+
+// UnsatisfiedContentRange formats the Content-Range header value sent along
+// with a 416 Range Not Satisfiable response.
+func UnsatisfiedContentRange(size int64) string {
+	return "bytes */" + strconv.FormatInt(size, 10)
+}
+
+// Parse parses a Range header as defined in RFC 9110 section 14.2 for a
+// resource of the given size. Ranges that don't overlap the resource are
+// dropped, if none remain ErrUnsatisfiable is returned.
+func Parse(header string, size int64) ([]Range, error) {
+	const unit = "bytes="
+	if !strings.HasPrefix(header, unit) {
+		return nil, ErrInvalid
+	}
+
+	var ranges []Range
+	unsatisfiable := false
+	for _, spec := range strings.Split(header[len(unit):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		first, last, found := strings.Cut(spec, "-")
+		if !found {
+			return nil, ErrInvalid
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		if first == "" {
+			suffixLength, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || suffixLength < 0 {
+				return nil, ErrInvalid
+			}
+			if suffixLength == 0 || size == 0 {
+				unsatisfiable = true
+				continue
+			}
+			if suffixLength > size {
+				suffixLength = size
+			}
+			ranges = append(ranges, Range{Start: size - suffixLength, Length: suffixLength})
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, ErrInvalid
+		}
+
+		end := size - 1
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return nil, ErrInvalid
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+
+		if start >= size {
+			unsatisfiable = true
+			continue
+		}
+		ranges = append(ranges, Range{Start: start, Length: end - start + 1})
+	}
+
+	if len(ranges) == 0 {
+		if unsatisfiable {
+			return nil, ErrUnsatisfiable
+		}
+		return nil, ErrInvalid
+	}
+
+	return ranges, nil
+}
+
+// Coalesce sorts the ranges and merges those that overlap or are adjacent, as
+// allowed by RFC 9110 section 14.2, so that no byte is sent more than once.
+func Coalesce(ranges []Range) []Range {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a Range, b Range) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	coalesced := sorted[:0]
+	for _, r := range sorted {
+		if len(coalesced) > 0 {
+			last := &coalesced[len(coalesced)-1]
+			lastEnd := last.Start + last.Length
+			if r.Start <= lastEnd {
+				last.Length = max(lastEnd, r.Start+r.Length) - last.Start
+				continue
+			}
+		}
+		coalesced = append(coalesced, r)
+	}
+	return coalesced
+}
@@ -0,0 +1,111 @@
+//go:build unit
+
+package byterange_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/inx51/howlite-resources/http/byterange"
+)
+
+func TestParseShouldReturnExpectedRanges(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		size     int64
+		expected []byterange.Range
+	}{
+		{"single range", "bytes=0-4", 10, []byterange.Range{{Start: 0, Length: 5}}},
+		{"open ended range", "bytes=6-", 10, []byterange.Range{{Start: 6, Length: 4}}},
+		{"suffix range", "bytes=-3", 10, []byterange.Range{{Start: 7, Length: 3}}},
+		{"suffix range larger than size", "bytes=-20", 10, []byterange.Range{{Start: 0, Length: 10}}},
+		{"end beyond size", "bytes=5-100", 10, []byterange.Range{{Start: 5, Length: 5}}},
+		{"multiple ranges", "bytes=0-1, 4-5", 10, []byterange.Range{{Start: 0, Length: 2}, {Start: 4, Length: 2}}},
+		{"unsatisfiable range dropped", "bytes=0-1, 20-30", 10, []byterange.Range{{Start: 0, Length: 2}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges, err := byterange.Parse(tc.header, tc.size)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(ranges) != len(tc.expected) {
+				t.Fatalf("Expected %d ranges, got %d", len(tc.expected), len(ranges))
+			}
+			for i := range ranges {
+				if ranges[i] != tc.expected[i] {
+					t.Fatalf("Expected range %v at index %d, got %v", tc.expected[i], i, ranges[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseShouldReturnExpectedErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   string
+		size     int64
+		expected error
+	}{
+		{"unknown unit", "items=0-1", 10, byterange.ErrInvalid},
+		{"missing dash", "bytes=5", 10, byterange.ErrInvalid},
+		{"end before start", "bytes=5-1", 10, byterange.ErrInvalid},
+		{"not a number", "bytes=a-b", 10, byterange.ErrInvalid},
+		{"empty set", "bytes=", 10, byterange.ErrInvalid},
+		{"start beyond size", "bytes=10-20", 10, byterange.ErrUnsatisfiable},
+		{"empty suffix", "bytes=-0", 10, byterange.ErrUnsatisfiable},
+		{"empty resource", "bytes=0-1", 0, byterange.ErrUnsatisfiable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := byterange.Parse(tc.header, tc.size)
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestContentRangeShouldFormatRange(t *testing.T) {
+	r := byterange.Range{Start: 5, Length: 5}
+
+	if r.ContentRange(10) != "bytes 5-9/10" {
+		t.Fatalf("Expected 'bytes 5-9/10', got %s", r.ContentRange(10))
+	}
+}
+
+func TestCoalesceShouldMergeOverlappingAndAdjacentRanges(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ranges   []byterange.Range
+		expected []byterange.Range
+	}{
+		{"separate ranges", []byterange.Range{{Start: 0, Length: 10}, {Start: 11, Length: 10}}, []byterange.Range{{Start: 0, Length: 10}, {Start: 11, Length: 10}}},
+		{"overlapping ranges", []byterange.Range{{Start: 0, Length: 10}, {Start: 5, Length: 10}}, []byterange.Range{{Start: 0, Length: 15}}},
+		{"contained range", []byterange.Range{{Start: 0, Length: 100}, {Start: 10, Length: 10}}, []byterange.Range{{Start: 0, Length: 100}}},
+		{"adjacent ranges", []byterange.Range{{Start: 0, Length: 10}, {Start: 10, Length: 10}}, []byterange.Range{{Start: 0, Length: 20}}},
+		{"unordered ranges", []byterange.Range{{Start: 1000, Length: 10}, {Start: 0, Length: 10}}, []byterange.Range{{Start: 0, Length: 10}, {Start: 1000, Length: 10}}},
+		{"repeated ranges", []byterange.Range{{Start: 0, Length: 1}, {Start: 0, Length: 1}, {Start: 0, Length: 1}}, []byterange.Range{{Start: 0, Length: 1}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges := byterange.Coalesce(tc.ranges)
+
+			if len(ranges) != len(tc.expected) {
+				t.Fatalf("Expected %d ranges, got %d", len(tc.expected), len(ranges))
+			}
+			for i := range ranges {
+				if ranges[i] != tc.expected[i] {
+					t.Fatalf("Expected range %d to be %+v, got %+v", i, tc.expected[i], ranges[i])
+				}
+			}
+		})
+	}
+}
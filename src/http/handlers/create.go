@@ -7,6 +7,7 @@ import (
 
 	"github.com/inx51/howlite-resources/event"
 	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/http/response"
 	"github.com/inx51/howlite-resources/http/uri"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/meter"
@@ -14,7 +15,6 @@ import (
 	"github.com/inx51/howlite-resources/storage"
 	"github.com/inx51/howlite-resources/tracer"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/metric"
 )
 
 type CreateHandler struct {
@@ -70,27 +70,31 @@ func (handler *CreateHandler) Handle(
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
-	err = storage.SaveResource(srCtx, resource)
+	err = storage.SaveResource(srCtx, resource, createPrecondition())
 	tracer.SafeEndSpan(span)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
+		statusCode, err = createErrorStatusCode(err)
 		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
 
-	meter.ArithmeticInt64Counter(ctx, "resources_created_total", 1, metric.WithAttributes(attribute.String("resource_identifier", resourceIdentifier.Identifier())))
-	meter.ArithmeticInt64Counter(ctx, "resources_overall", 1)
+	meter.ArithmeticInt64Counter(ctx, "resources_created_total", 1, routeAttributes(resourceIdentifier))
+	meter.ArithmeticInt64Gauge(ctx, ResourcesOverall, 1)
 
 	handler.bus.Publish(
 		ctx,
 		types.ResourceCreatedEventType,
+		resourceIdentifier.Identifier(),
 		types.ResourceCreated{
 			CreatedUtc:       time.Now(),
 			ResourceIdentity: resourceIdentifier.Identifier(),
+			Resource:         storedResource(resource),
+			Caller:           eventCaller(ctx, req),
 		})
 
 	location := uri.AbsoluteUri(req)
 	resp.Header().Add("Location", location)
+	response.WriteProperties(resource.Properties, resp)
 	resp.WriteHeader(statusCode)
 	logger.Info(ctx, "Resource created", "resourceIdentifier", resourceIdentifier.Identifier())
 	return statusCode, nil
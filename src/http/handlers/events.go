@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"maps"
+	"net/http"
+
+	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// storedResource describes the saved resource in events, so consumers don't
+// need to get it to learn its size, type or headers.
+func storedResource(saved *resource.Resource) types.StoredResource {
+	return types.StoredResource{
+		ContentLength: saved.Properties.ContentLength,
+		ContentType:   saved.Properties.ContentType,
+		ContentHash:   saved.Properties.ContentHash,
+		ETag:          saved.Properties.ETag(),
+		Headers:       maps.Clone(*saved.Headers.Headers()),
+	}
+}
+
+// previousResource describes the replaced or removed version of a resource,
+// nil if its properties are unknown.
+func previousResource(properties *resource.ResourceProperties) *types.PreviousResource {
+	if properties == nil {
+		return nil
+	}
+
+	return &types.PreviousResource{
+		ContentLength: properties.ContentLength,
+		ContentHash:   properties.ContentHash,
+	}
+}
+
+// eventCaller identifies the principal, request and trace of req in events.
+func eventCaller(ctx context.Context, req *http.Request) types.Caller {
+	caller := types.Caller{RequestId: req.Header.Get("X-Request-Id")}
+	if principal := auth.PrincipalFromContext(ctx); principal != nil {
+		caller.Principal = principal.Name
+		caller.Scheme = principal.Scheme
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		caller.TraceId = spanContext.TraceID().String()
+	}
+	return caller
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/inx51/howlite-resources/http/precondition"
 	"github.com/inx51/howlite-resources/http/response"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/resource"
@@ -32,41 +33,46 @@ func (handler *ExistsHandler) Handle(
 
 	storage := *handler.storage
 	statusCode := http.StatusNoContent
-	reCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".resource_exists")
+	// The stored headers are returned as well, so the resource is read along
+	// with its properties and its body is left unread.
+	grCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".get_resource")
 	tracer.SetInfoAttributes(
-		reCtx,
+		grCtx,
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
-	exists, err := storage.ResourceExists(reCtx, resourceIdentifier)
+	resource, err := storage.GetResource(grCtx, resourceIdentifier)
 	tracer.SafeEndSpan(span)
+	if isResourceNotFound(err) {
+		logger.Debug(ctx, "Failed to find resource", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode = http.StatusNotFound
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
 	if err != nil {
 		statusCode = http.StatusInternalServerError
 		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
+	defer (*resource.Body).Close()
 
-	if exists {
-
-		grCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".get_resource")
-		tracer.SetInfoAttributes(
-			grCtx,
-			span,
-			attribute.String("resource_identifier", resourceIdentifier.Identifier()),
-		)
-		resource, _ := storage.GetResource(grCtx, resourceIdentifier)
-		tracer.SafeEndSpan(span)
-		defer (*resource.Body).Close()
-
-		response.WriteHeaders(resource.Headers.Headers(), resp)
-
-		logger.Debug(ctx, "Resource found", "resourceIdentifier", resourceIdentifier.Identifier())
-		resp.WriteHeader(statusCode)
-	} else {
-		logger.Debug(ctx, "Failed to find resource", "resourceIdentifier", resourceIdentifier.Identifier())
-		statusCode = http.StatusNotFound
+	properties := resource.Properties
+	if preconditionStatusCode := precondition.Evaluate(req, properties); preconditionStatusCode != 0 {
+		logger.Debug(ctx, "Precondition evaluated to not return resource", "resourceIdentifier", resourceIdentifier.Identifier(), "status", preconditionStatusCode)
+		statusCode = preconditionStatusCode
+		response.WriteProperties(properties, resp)
 		resp.WriteHeader(statusCode)
+		return statusCode, nil
 	}
+
+	response.WriteHeaders(resource.Headers.Headers(), resp)
+	response.WriteProperties(properties, resp)
+	if properties.ContentHash != "" {
+		resp.Header().Set("Accept-Ranges", "bytes")
+	}
+
+	logger.Debug(ctx, "Resource found", "resourceIdentifier", resourceIdentifier.Identifier())
+	resp.WriteHeader(statusCode)
 	return statusCode, nil
 }
 
@@ -2,8 +2,15 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
 
+	"github.com/inx51/howlite-resources/http/byterange"
+	"github.com/inx51/howlite-resources/http/precondition"
 	"github.com/inx51/howlite-resources/http/response"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/meter"
@@ -11,7 +18,6 @@ import (
 	"github.com/inx51/howlite-resources/storage"
 	"github.com/inx51/howlite-resources/tracer"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/metric"
 )
 
 type GetHandler struct {
@@ -31,49 +37,119 @@ func (handler *GetHandler) Handle(
 	ctx context.Context,
 	req *http.Request,
 	resp http.ResponseWriter) (int, error) {
-	resourceIdentifier := resource.NewResourceIdentifier(req.URL.Path)
+	if req.URL.Query().Has("list") {
+		return handler.handleList(ctx, req, resp)
+	}
 
+	resourceIdentifier := resource.NewResourceIdentifier(req.URL.Path)
 	storage := *handler.storage
-	statusCode := 200
-	reCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".resource_exists")
+
+	// Without a range the resource is read along with its properties at once,
+	// a range can only be resolved once the properties are known.
+	rangeHeader := req.Header.Get("Range")
+	if rangeHeader == "" {
+		return handler.handleResource(ctx, req, resp, storage, resourceIdentifier)
+	}
+
+	gpCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".get_resource_properties")
 	tracer.SetInfoAttributes(
-		reCtx,
+		gpCtx,
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
-	resourceExists, err := storage.ResourceExists(reCtx, resourceIdentifier)
+	properties, err := storage.GetResourceProperties(gpCtx, resourceIdentifier)
 	tracer.SafeEndSpan(span)
+	if isResourceNotFound(err) {
+		logger.Debug(ctx, "Failed to get resource since it does not exist", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode := http.StatusNotFound
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
 	if err != nil {
-		statusCode = 500
+		statusCode := http.StatusInternalServerError
 		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
 
-	if !resourceExists {
-		logger.Debug(ctx, "Failed to get resource since it does not exist", "resourceIdentifier", resourceIdentifier.Identifier())
-		statusCode = 404
-		resp.WriteHeader(statusCode)
-		return statusCode, nil
+	if preconditionStatusCode := precondition.Evaluate(req, properties); preconditionStatusCode != 0 {
+		logger.Debug(ctx, "Precondition evaluated to not return resource", "resourceIdentifier", resourceIdentifier.Identifier(), "status", preconditionStatusCode)
+		response.WriteProperties(properties, resp)
+		resp.WriteHeader(preconditionStatusCode)
+		return preconditionStatusCode, nil
 	}
 
+	// Resources stored before their properties were recorded have no known
+	// length, so range requests can't be served for them.
+	if properties.ContentHash != "" && precondition.IfRangeMatches(req, properties) {
+		ranges, err := byterange.Parse(rangeHeader, properties.ContentLength)
+		if errors.Is(err, byterange.ErrUnsatisfiable) {
+			logger.Debug(ctx, "Requested range is not satisfiable", "resourceIdentifier", resourceIdentifier.Identifier(), "range", rangeHeader)
+			statusCode := http.StatusRequestedRangeNotSatisfiable
+			resp.Header().Set("Content-Range", byterange.UnsatisfiedContentRange(properties.ContentLength))
+			resp.WriteHeader(statusCode)
+			return statusCode, nil
+		}
+
+		// Invalid ranges, or more ranges than are served once coalesced, are
+		// ignored and the whole resource is returned instead.
+		if err == nil {
+			ranges = byterange.Coalesce(ranges)
+		}
+		if err == nil && len(ranges) <= byterange.MaxRanges {
+			return handler.handleRanges(ctx, req, resp, storage, resourceIdentifier, properties, ranges)
+		}
+	}
+
+	return handler.handleResource(ctx, req, resp, storage, resourceIdentifier)
+}
+
+// handleResource returns the whole resource, the preconditions are evaluated
+// against the properties read along with it.
+func (handler *GetHandler) handleResource(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter,
+	storage storage.Storage,
+	resourceIdentifier *resource.ResourceIdentifier) (int, error) {
+
+	statusCode := http.StatusOK
 	grCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".get_resource")
-	resource, err := storage.GetResource(grCtx, resourceIdentifier)
 	tracer.SetInfoAttributes(
 		grCtx,
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
+	resource, err := storage.GetResource(grCtx, resourceIdentifier)
 	tracer.SafeEndSpan(span)
-
+	if isResourceNotFound(err) {
+		logger.Debug(ctx, "Failed to get resource since it does not exist", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode = http.StatusNotFound
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
 	if err != nil {
-		statusCode = 500
+		statusCode = http.StatusInternalServerError
 		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
+	defer (*resource.Body).Close()
+
+	properties := resource.Properties
+	if preconditionStatusCode := precondition.Evaluate(req, properties); preconditionStatusCode != 0 {
+		logger.Debug(ctx, "Precondition evaluated to not return resource", "resourceIdentifier", resourceIdentifier.Identifier(), "status", preconditionStatusCode)
+		statusCode = preconditionStatusCode
+		response.WriteProperties(properties, resp)
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
 
 	response.WriteHeaders(resource.Headers.Headers(), resp)
+	response.WriteProperties(properties, resp)
+	if properties.ContentHash != "" {
+		resp.Header().Set("Accept-Ranges", "bytes")
+	}
 
-	meter.ArithmeticInt64Counter(ctx, "resources_fetched_total", 1, metric.WithAttributes(attribute.String("resource_identifier", resourceIdentifier.Identifier())))
+	meter.ArithmeticInt64Counter(ctx, "resources_fetched_total", 1, routeAttributes(resourceIdentifier))
 
 	resp.WriteHeader(statusCode)
 
@@ -82,6 +158,108 @@ func (handler *GetHandler) Handle(
 	return statusCode, nil
 }
 
+// handleRanges returns the ranges of the resource, all of them read from the
+// revision the preconditions were evaluated against. If the resource was
+// replaced before the first range was read, the whole resource is returned
+// instead.
+func (handler *GetHandler) handleRanges(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter,
+	storage storage.Storage,
+	resourceIdentifier *resource.ResourceIdentifier,
+	properties *resource.ResourceProperties,
+	ranges []byterange.Range) (int, error) {
+
+	statusCode := http.StatusPartialContent
+	resource, err := handler.getResourceRange(ctx, storage, resourceIdentifier, properties, ranges[0])
+	if isPreconditionFailed(err) {
+		logger.Debug(ctx, "Resource changed since its properties were read", "resourceIdentifier", resourceIdentifier.Identifier())
+		return handler.handleResource(ctx, req, resp, storage, resourceIdentifier)
+	}
+	if isResourceNotFound(err) {
+		logger.Debug(ctx, "Failed to get resource since it does not exist", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode = http.StatusNotFound
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+	if err != nil {
+		statusCode = http.StatusInternalServerError
+		resp.WriteHeader(statusCode)
+		return statusCode, err
+	}
+
+	response.WriteHeaders(resource.Headers.Headers(), resp)
+	response.WriteProperties(properties, resp)
+	resp.Header().Set("Accept-Ranges", "bytes")
+
+	meter.ArithmeticInt64Counter(ctx, "resources_fetched_total", 1, routeAttributes(resourceIdentifier))
+
+	if len(ranges) == 1 {
+		resp.Header().Set("Content-Range", ranges[0].ContentRange(properties.ContentLength))
+		resp.Header().Set("Content-Length", strconv.FormatInt(ranges[0].Length, 10))
+		resp.WriteHeader(statusCode)
+		response.WriteBody(*resource.Body, resp)
+		logger.Debug(ctx, "Resource range returned", "resourceIdentifier", resourceIdentifier.Identifier())
+		return statusCode, nil
+	}
+
+	contentType := resp.Header().Get("Content-Type")
+	multipartWriter := multipart.NewWriter(resp)
+	resp.Header().Set("Content-Type", "multipart/byteranges; boundary="+multipartWriter.Boundary())
+	resp.WriteHeader(statusCode)
+
+	for i, r := range ranges {
+		if i > 0 {
+			resource, err = handler.getResourceRange(ctx, storage, resourceIdentifier, properties, r)
+			if err != nil {
+				return statusCode, err
+			}
+		}
+
+		partHeader := textproto.MIMEHeader{}
+		if contentType != "" {
+			partHeader.Set("Content-Type", contentType)
+		}
+		partHeader.Set("Content-Range", r.ContentRange(properties.ContentLength))
+		part, err := multipartWriter.CreatePart(partHeader)
+		if err != nil {
+			(*resource.Body).Close()
+			return statusCode, err
+		}
+
+		_, err = io.Copy(part, *resource.Body)
+		(*resource.Body).Close()
+		if err != nil {
+			return statusCode, err
+		}
+	}
+
+	err = multipartWriter.Close()
+	logger.Debug(ctx, "Resource ranges returned", "resourceIdentifier", resourceIdentifier.Identifier(), "ranges", len(ranges))
+	return statusCode, err
+}
+
+func (handler *GetHandler) getResourceRange(
+	ctx context.Context,
+	storage storage.Storage,
+	resourceIdentifier *resource.ResourceIdentifier,
+	properties *resource.ResourceProperties,
+	byteRange byterange.Range) (*resource.Resource, error) {
+
+	grCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".get_resource_range")
+	tracer.SetInfoAttributes(
+		grCtx,
+		span,
+		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
+		attribute.Int64("range_start", byteRange.Start),
+		attribute.Int64("range_length", byteRange.Length),
+	)
+	resource, err := storage.GetResourceRange(grCtx, resourceIdentifier, byteRange.Start, byteRange.Length, readPrecondition(properties))
+	tracer.SafeEndSpan(span)
+	return resource, err
+}
+
 func NewGetHandler(storage *storage.Storage) Handler {
 	return &GetHandler{
 		storage: storage,
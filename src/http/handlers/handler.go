@@ -2,7 +2,13 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/http/precondition"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
 )
 
 type Handler interface {
@@ -10,3 +16,90 @@ type Handler interface {
 	Path() string
 	Handle(ctx context.Context, request *http.Request, response http.ResponseWriter) (int, error)
 }
+
+// AnonymousHandler is implemented by handlers that must be reachable without
+// authentication, such as probes.
+type AnonymousHandler interface {
+	AllowAnonymous() bool
+}
+
+// AuthorizationTargetHandler is implemented by handlers whose request path is
+// not the identifier of the resource they act on, such as uploads. It returns
+// the method and path the request is authorized as.
+type AuthorizationTargetHandler interface {
+	AuthorizationTarget(ctx context.Context, request *http.Request) (string, string)
+}
+
+// isResourceNotFound reports whether reading a resource failed because it
+// doesn't exist.
+func isResourceNotFound(err error) bool {
+	return errors.Is(err, storage.ErrResourceNotFound)
+}
+
+// isPreconditionFailed reports whether a read, save or removal failed because
+// of its precondition.
+func isPreconditionFailed(err error) bool {
+	return errors.Is(err, storage.ErrPreconditionFailed)
+}
+
+// requestPrecondition makes the save or removal of a conditional request only
+// succeed if the stored resource is still the one described by properties,
+// against which its preconditions were evaluated.
+func requestPrecondition(request *http.Request, properties *resource.ResourceProperties) *storage.Precondition {
+	if !precondition.HasConditions(request) {
+		return nil
+	}
+
+	return storage.PreconditionFor(properties)
+}
+
+// readPrecondition makes reading a part of a resource only succeed if the
+// stored resource is still the one described by properties, so that all the
+// parts of a response come from the same revision.
+func readPrecondition(properties *resource.ResourceProperties) *storage.Precondition {
+	return storage.PreconditionFor(properties)
+}
+
+// createPrecondition makes the save of a created resource fail if another
+// request created it in the meantime.
+func createPrecondition() *storage.Precondition {
+	return storage.PreconditionFor(nil)
+}
+
+// createErrorStatusCode maps the errors of storage.SaveResource with the
+// createPrecondition to a status code, a resource created in the meantime is
+// a conflict.
+func createErrorStatusCode(err error) (int, error) {
+	if isPreconditionFailed(err) {
+		return http.StatusConflict, nil
+	}
+
+	return saveErrorStatusCode(err)
+}
+
+// removeErrorStatusCode maps the errors of storage.RemoveResource to a status
+// code, only unexpected errors are returned to be logged.
+func removeErrorStatusCode(err error) (int, error) {
+	if isPreconditionFailed(err) {
+		return http.StatusPreconditionFailed, nil
+	}
+
+	return http.StatusInternalServerError, err
+}
+
+// saveErrorStatusCode maps the errors of storage.SaveResource to a status code,
+// only unexpected errors are returned to be logged.
+func saveErrorStatusCode(err error) (int, error) {
+	switch {
+	case errors.Is(err, storage.ErrResourceTooLarge):
+		return http.StatusRequestEntityTooLarge, nil
+	case errors.Is(err, storage.ErrStorageFull):
+		return http.StatusInsufficientStorage, err
+	case isPreconditionFailed(err):
+		return http.StatusPreconditionFailed, nil
+	case errors.Is(err, auth.ErrBodyNotVerified):
+		return http.StatusBadRequest, nil
+	default:
+		return http.StatusInternalServerError, err
+	}
+}
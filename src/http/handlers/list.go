@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/inx51/howlite-resources/http/authz"
+	"github.com/inx51/howlite-resources/http/response"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type listResponse struct {
+	Resources  []listedResource `json:"resources"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+type listedResource struct {
+	Identifier   string    `json:"identifier"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"contentType,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+	ETag         string    `json:"etag,omitempty"`
+}
+
+// handleList lists the resources whose identifiers start with the request
+// path, e.g. GET /docs/?list&limit=10&cursor=... The prefix is matched by
+// characters, so GET /docs?list also lists /docs-private/..., and every listed
+// resource is therefore authorized as a GET of its own identifier.
+func (handler *GetHandler) handleList(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter) (int, error) {
+	query := req.URL.Query()
+	prefix := req.URL.Path
+	cursor := query.Get("cursor")
+
+	limit, err := parseListLimit(query.Get("limit"))
+	if err != nil {
+		logger.Debug(ctx, "Invalid list limit", "limit", query.Get("limit"))
+		statusCode := http.StatusBadRequest
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	storage := *handler.storage
+	lrCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".list_resources")
+	tracer.SetInfoAttributes(
+		lrCtx,
+		span,
+		attribute.String("prefix", prefix),
+		attribute.Int("limit", limit),
+	)
+	list, err := storage.ListResources(lrCtx, prefix, cursor, limit)
+	tracer.SafeEndSpan(span)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		resp.WriteHeader(statusCode)
+		return statusCode, err
+	}
+
+	body := listResponse{
+		Resources:  make([]listedResource, 0, len(list.Resources)),
+		NextCursor: list.NextCursor,
+	}
+	for _, properties := range list.Resources {
+		if !authz.Allowed(ctx, http.MethodGet, properties.Identifier) {
+			continue
+		}
+		body.Resources = append(body.Resources, listedResource{
+			Identifier:   properties.Identifier,
+			Size:         properties.ContentLength,
+			ContentType:  properties.ContentType,
+			LastModified: properties.LastModified,
+			ETag:         properties.ETag(),
+		})
+	}
+
+	logger.Debug(ctx, "Resources listed", "prefix", prefix, "count", len(body.Resources))
+	statusCode := http.StatusOK
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(statusCode)
+	return statusCode, response.WriteJson(body, resp)
+}
+
+func parseListLimit(value string) (int, error) {
+	if value == "" {
+		return defaultListLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if limit < 1 || limit > maxListLimit {
+		return 0, errors.New("list limit out of range")
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"github.com/inx51/howlite-resources/meter"
+	"github.com/inx51/howlite-resources/resource"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ResourcesOverall is the number of stored resources. It only reflects the
+// changes made through this instance, on top of the count it was seeded with,
+// so it is only accurate when running a single instance.
+var ResourcesOverall = meter.Instrument{
+	Name:        "resources_overall",
+	Unit:        "{resource}",
+	Description: "Number of stored resources.",
+}
+
+// routeAttributes labels resource metrics with the route template of the
+// resource instead of its identifier, to keep the cardinality bounded.
+func routeAttributes(resourceIdentifier *resource.ResourceIdentifier) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("resource_route", meter.Route(resourceIdentifier.Identifier())))
+}
@@ -7,13 +7,14 @@ import (
 
 	"github.com/inx51/howlite-resources/event"
 	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/http/precondition"
+	"github.com/inx51/howlite-resources/http/response"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/meter"
 	"github.com/inx51/howlite-resources/resource"
 	"github.com/inx51/howlite-resources/storage"
 	"github.com/inx51/howlite-resources/tracer"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/metric"
 )
 
 type RemoveHandler struct {
@@ -37,23 +38,32 @@ func (handler *RemoveHandler) Handle(
 
 	storage := *handler.storage
 	statusCode := http.StatusNoContent
-	reCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".resource_exists")
+	// The properties are needed to evaluate the preconditions, and to describe
+	// the removed version in the event.
+	gpCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".get_resource_properties")
 	tracer.SetInfoAttributes(
-		reCtx,
+		gpCtx,
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
-	resourceExists, err := storage.ResourceExists(reCtx, resourceIdentifier)
+	properties, err := storage.GetResourceProperties(gpCtx, resourceIdentifier)
 	tracer.SafeEndSpan(span)
+	if isResourceNotFound(err) {
+		logger.Debug(ctx, "Failed to get resource since it does not exist", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode = http.StatusNotFound
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
 	if err != nil {
 		statusCode = http.StatusInternalServerError
 		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
 
-	if !resourceExists {
-		logger.Debug(ctx, "Failed to get resource since it does not exist", "resourceIdentifier", resourceIdentifier.Identifier())
-		statusCode = http.StatusNotFound
+	if preconditionStatusCode := precondition.Evaluate(req, properties); preconditionStatusCode != 0 {
+		logger.Debug(ctx, "Precondition failed, resource will not be removed", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode = preconditionStatusCode
+		response.WriteProperties(properties, resp)
 		resp.WriteHeader(statusCode)
 		return statusCode, nil
 	}
@@ -64,22 +74,29 @@ func (handler *RemoveHandler) Handle(
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
-	err = storage.RemoveResource(rrCtx, resourceIdentifier)
+	err = storage.RemoveResource(rrCtx, resourceIdentifier, requestPrecondition(req, properties))
 	tracer.SafeEndSpan(span)
+	if isPreconditionFailed(err) {
+		logger.Debug(ctx, "Resource changed since its preconditions were evaluated, it will not be removed", "resourceIdentifier", resourceIdentifier.Identifier())
+	}
 	if err != nil {
-		statusCode = http.StatusInternalServerError
+		statusCode, err = removeErrorStatusCode(err)
+		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
 
-	meter.ArithmeticInt64Counter(ctx, "resources_removed_total", 1, metric.WithAttributes(attribute.String("resource_identifier", resourceIdentifier.Identifier())))
-	meter.ArithmeticInt64Counter(ctx, "resources_overall", -1)
+	meter.ArithmeticInt64Counter(ctx, "resources_removed_total", 1, routeAttributes(resourceIdentifier))
+	meter.ArithmeticInt64Gauge(ctx, ResourcesOverall, -1)
 
 	handler.bus.Publish(
 		ctx,
 		types.ResourceRemoavedEventType,
+		resourceIdentifier.Identifier(),
 		types.ResourceRemoved{
 			RemovedUtc:       time.Now(),
 			ResourceIdentity: resourceIdentifier.Identifier(),
+			Previous:         previousResource(properties),
+			Caller:           eventCaller(ctx, req),
 		})
 
 	resp.WriteHeader(statusCode)
@@ -7,6 +7,8 @@ import (
 
 	"github.com/inx51/howlite-resources/event"
 	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/http/precondition"
+	"github.com/inx51/howlite-resources/http/response"
 	"github.com/inx51/howlite-resources/http/uri"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/meter"
@@ -14,7 +16,6 @@ import (
 	"github.com/inx51/howlite-resources/storage"
 	"github.com/inx51/howlite-resources/tracer"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/metric"
 )
 
 type ReplaceHandler struct {
@@ -38,20 +39,33 @@ func (handler *ReplaceHandler) Handle(
 
 	storage := *handler.storage
 	statusCode := http.StatusNoContent
-	reCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".resource_exists")
+	// The properties of an existing resource are needed to evaluate the
+	// preconditions, and to describe the replaced version in the event.
+	gpCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".get_resource_properties")
 	tracer.SetInfoAttributes(
-		reCtx,
+		gpCtx,
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
-	resourceExists, err := storage.ResourceExists(reCtx, resourceIdentifier)
+	properties, err := storage.GetResourceProperties(gpCtx, resourceIdentifier)
 	tracer.SafeEndSpan(span)
-	if err != nil {
+	resourceExists := !isResourceNotFound(err)
+	if !resourceExists {
+		properties = nil
+	} else if err != nil {
 		statusCode = http.StatusInternalServerError
 		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
 
+	if preconditionStatusCode := precondition.Evaluate(req, properties); preconditionStatusCode != 0 {
+		logger.Debug(ctx, "Precondition failed, resource will not be replaced", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode = preconditionStatusCode
+		response.WriteProperties(properties, resp)
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
 	headers := make(map[string][]string)
 	for k, v := range req.Header {
 		headers[k] = v
@@ -68,26 +82,30 @@ func (handler *ReplaceHandler) Handle(
 		span,
 		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
 	)
-	err = storage.SaveResource(srCtx, resource)
+	err = storage.SaveResource(srCtx, resource, requestPrecondition(req, properties))
 	tracer.SafeEndSpan(span)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
+		statusCode, err = saveErrorStatusCode(err)
 		resp.WriteHeader(statusCode)
 		return statusCode, err
 	}
 
 	location := uri.AbsoluteUri(req)
 	resp.Header().Add("Location", location)
+	response.WriteProperties(resource.Properties, resp)
 	if !resourceExists {
 		handler.bus.Publish(
 			ctx,
-			types.ResourceRemoavedEventType,
+			types.ResourceCreatedEventType,
+			resourceIdentifier.Identifier(),
 			types.ResourceCreated{
 				CreatedUtc:       time.Now(),
 				ResourceIdentity: resourceIdentifier.Identifier(),
+				Resource:         storedResource(resource),
+				Caller:           eventCaller(ctx, req),
 			})
-		meter.ArithmeticInt64Counter(ctx, "resources_created_total", 1, metric.WithAttributes(attribute.String("resource_identifier", resourceIdentifier.Identifier())))
-		meter.ArithmeticInt64Counter(ctx, "resources_overall", 1)
+		meter.ArithmeticInt64Counter(ctx, "resources_created_total", 1, routeAttributes(resourceIdentifier))
+		meter.ArithmeticInt64Gauge(ctx, ResourcesOverall, 1)
 		logger.Info(ctx, "Resource created", "resourceIdentifier", resourceIdentifier.Identifier())
 		statusCode = http.StatusCreated
 		resp.WriteHeader(statusCode)
@@ -95,11 +113,15 @@ func (handler *ReplaceHandler) Handle(
 		handler.bus.Publish(
 			ctx,
 			types.ResourceRepalcedEventType,
+			resourceIdentifier.Identifier(),
 			types.ResourceReplaced{
 				ReplacedUtc:      time.Now(),
 				ResourceIdentity: resourceIdentifier.Identifier(),
+				Resource:         storedResource(resource),
+				Previous:         previousResource(properties),
+				Caller:           eventCaller(ctx, req),
 			})
-		meter.ArithmeticInt64Counter(ctx, "resources_replaced_total", 1, metric.WithAttributes(attribute.String("resource_identifier", resourceIdentifier.Identifier())))
+		meter.ArithmeticInt64Counter(ctx, "resources_replaced_total", 1, routeAttributes(resourceIdentifier))
 		logger.Info(ctx, "Existing resource replaced", "resourceIdentifier", resourceIdentifier.Identifier())
 		resp.WriteHeader(statusCode)
 	}
@@ -18,6 +18,10 @@ func (handler *SysProbeHandler) Path() string {
 	return "/$sys/probe"
 }
 
+func (handler *SysProbeHandler) AllowAnonymous() bool {
+	return true
+}
+
 func (handler *SysProbeHandler) Handle(
 	ctx context.Context,
 	req *http.Request,
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/http/response"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/meter"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/inx51/howlite-resources/upload"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+	uploadsPath   = "/$sys/uploads"
+)
+
+// checkTusResumable writes the tus headers every response carries and
+// rejects requests for an unsupported protocol version.
+func checkTusResumable(req *http.Request, resp http.ResponseWriter) (int, bool) {
+	resp.Header().Set("Tus-Resumable", tusVersion)
+	if req.Header.Get("Tus-Resumable") != tusVersion {
+		resp.Header().Set("Tus-Version", tusVersion)
+		return http.StatusPreconditionFailed, false
+	}
+
+	return 0, true
+}
+
+// uploadAuthorizationTarget authorizes every request of an upload as a POST of
+// the resource it creates. Requests for unknown uploads are authorized by
+// their own path.
+func uploadAuthorizationTarget(ctx context.Context, uploads *upload.Store, req *http.Request) (string, string) {
+	currentUpload, err := uploads.Get(ctx, req.PathValue("id"))
+	if err != nil {
+		return req.Method, req.URL.Path
+	}
+
+	return http.MethodPost, currentUpload.Identifier
+}
+
+func writeUploadHeaders(upload *upload.Upload, resp http.ResponseWriter) {
+	resp.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	resp.Header().Set("Upload-Expires", upload.ExpiresUtc.Format(http.TimeFormat))
+	resp.Header().Set("Cache-Control", "no-store")
+}
+
+func uploadErrorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, upload.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, upload.ErrUploadExpired):
+		return http.StatusGone
+	case errors.Is(err, upload.ErrUploadLocked):
+		return http.StatusLocked
+	case errors.Is(err, upload.ErrOffsetMismatch):
+		return http.StatusConflict
+	case errors.Is(err, upload.ErrUploadMisdirected):
+		return http.StatusMisdirectedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// finalizeUpload saves a completed upload to the storage provider, publishes
+// the ResourceCreated event and removes the staged upload.
+func finalizeUpload(
+	ctx context.Context,
+	storage storage.Storage,
+	bus *event.Bus,
+	uploads *upload.Store,
+	completedUpload *upload.Upload,
+	req *http.Request,
+	resp http.ResponseWriter) (int, error) {
+	resourceIdentifier := resource.NewResourceIdentifier(completedUpload.Identifier)
+
+	reCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".resource_exists")
+	tracer.SetInfoAttributes(
+		reCtx,
+		span,
+		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
+	)
+	resourceExists, err := storage.ResourceExists(reCtx, resourceIdentifier)
+	tracer.SafeEndSpan(span)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if resourceExists {
+		logger.Debug(ctx, "Can't finalize upload because the resource already exists", "resourceIdentifier", resourceIdentifier.Identifier(), "uploadId", completedUpload.ID)
+		return http.StatusConflict, uploads.Remove(ctx, completedUpload.ID)
+	}
+
+	body, err := uploads.Open(ctx, completedUpload)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	resource := resource.NewResource(resourceIdentifier, &body)
+	defer (*resource.Body).Close()
+	if contentType := uploadContentType(completedUpload); contentType != "" {
+		resource.Headers.Add(ctx, "Content-Type", []string{contentType})
+	}
+
+	srCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".save_resource")
+	tracer.SetInfoAttributes(
+		srCtx,
+		span,
+		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
+	)
+	err = storage.SaveResource(srCtx, resource, createPrecondition())
+	tracer.SafeEndSpan(span)
+	if isPreconditionFailed(err) {
+		logger.Debug(ctx, "Can't finalize upload because the resource was created meanwhile", "resourceIdentifier", resourceIdentifier.Identifier(), "uploadId", completedUpload.ID)
+		return http.StatusConflict, uploads.Remove(ctx, completedUpload.ID)
+	}
+	if err != nil {
+		return saveErrorStatusCode(err)
+	}
+
+	meter.ArithmeticInt64Counter(ctx, "resources_created_total", 1, routeAttributes(resourceIdentifier))
+	meter.ArithmeticInt64Gauge(ctx, ResourcesOverall, 1)
+
+	bus.Publish(
+		ctx,
+		types.ResourceCreatedEventType,
+		resourceIdentifier.Identifier(),
+		types.ResourceCreated{
+			CreatedUtc:       time.Now(),
+			ResourceIdentity: resourceIdentifier.Identifier(),
+			Resource:         storedResource(resource),
+			Caller:           eventCaller(ctx, req),
+		})
+
+	err = uploads.Remove(ctx, completedUpload.ID)
+	if err != nil {
+		logger.Error(ctx, "Failed to remove finalized upload", "uploadId", completedUpload.ID, "error", err)
+	}
+
+	response.WriteProperties(resource.Properties, resp)
+	logger.Info(ctx, "Resource created from upload", "resourceIdentifier", resourceIdentifier.Identifier(), "uploadId", completedUpload.ID)
+	return http.StatusNoContent, nil
+}
+
+func uploadContentType(completedUpload *upload.Upload) string {
+	if contentType := completedUpload.Metadata["contentType"]; contentType != "" {
+		return contentType
+	}
+
+	return completedUpload.Metadata["filetype"]
+}
@@ -0,0 +1,222 @@
+package handlers_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/http/handlers"
+	httpserver "github.com/inx51/howlite-resources/http/server"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/storage/memory"
+	"github.com/inx51/howlite-resources/upload"
+	"github.com/stretchr/testify/require"
+)
+
+func newUploadTestServer(t *testing.T) (*httptest.Server, *http.Client) {
+	t.Helper()
+
+	store := memory.NewStorage(&configuration.MemoryConfiguration{})
+	return newUploadTestServerWithStorage(t, &store)
+}
+
+func newUploadTestServerWithStorage(t *testing.T, store *storage.Storage) (*httptest.Server, *http.Client) {
+	t.Helper()
+
+	bus := event.NewBus(nil, nil)
+	uploads, err := upload.NewStore(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+	t.Cleanup(func() { uploads.Close() })
+	hs := &[]handlers.Handler{
+		handlers.NewGetHandler(store),
+		handlers.NewCreateHandler(store, bus),
+		handlers.NewUploadCreateHandler(store, bus, uploads, 0),
+		handlers.NewUploadOffsetHandler(uploads),
+		handlers.NewUploadPatchHandler(store, bus, uploads),
+		handlers.NewUploadTerminateHandler(uploads),
+	}
+
+	ts := httptest.NewServer(httpserver.NewServeMux(hs))
+	t.Cleanup(ts.Close)
+	return ts, ts.Client()
+}
+
+func newUploadRequest(t *testing.T, method string, url string, body io.Reader) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, url, body)
+	require.NoError(t, err)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	return req
+}
+
+func createUpload(t *testing.T, ts *httptest.Server, client *http.Client, identifier string, length int) string {
+	t.Helper()
+	req := newUploadRequest(t, http.MethodPost, ts.URL+"/$sys/uploads", nil)
+	req.Header.Set("Upload-Length", strconv.Itoa(length))
+	req.Header.Set("Upload-Metadata", "identifier "+base64.StdEncoding.EncodeToString([]byte(identifier))+",contentType "+base64.StdEncoding.EncodeToString([]byte("text/plain")))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get("Upload-Expires"))
+	return ts.URL + resp.Header.Get("Location")
+}
+
+func patchUpload(t *testing.T, client *http.Client, location string, offset int, body string) *http.Response {
+	t.Helper()
+	req := newUploadRequest(t, http.MethodPatch, location, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func TestAcceptance_Upload_CreatesResourceWhenUploadIsComplete(t *testing.T) {
+	ts, client := newUploadTestServer(t)
+	location := createUpload(t, ts, client, "/docs/upload.txt", 11)
+
+	patchResp := patchUpload(t, client, location, 0, "hello ")
+	require.Equal(t, http.StatusNoContent, patchResp.StatusCode)
+	require.Equal(t, "6", patchResp.Header.Get("Upload-Offset"))
+
+	headResp, err := client.Do(newUploadRequest(t, http.MethodHead, location, nil))
+	require.NoError(t, err)
+	headResp.Body.Close()
+	require.Equal(t, http.StatusOK, headResp.StatusCode)
+	require.Equal(t, "6", headResp.Header.Get("Upload-Offset"))
+	require.Equal(t, "11", headResp.Header.Get("Upload-Length"))
+
+	getResp, err := client.Get(ts.URL + "/docs/upload.txt")
+	require.NoError(t, err)
+	getResp.Body.Close()
+	require.Equal(t, http.StatusNotFound, getResp.StatusCode)
+
+	patchResp = patchUpload(t, client, location, 6, "world")
+	require.Equal(t, http.StatusNoContent, patchResp.StatusCode)
+	require.Equal(t, "11", patchResp.Header.Get("Upload-Offset"))
+	require.NotEmpty(t, patchResp.Header.Get("ETag"))
+
+	getResp, err = client.Get(ts.URL + "/docs/upload.txt")
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+	require.Equal(t, http.StatusOK, getResp.StatusCode)
+	require.Equal(t, "text/plain", getResp.Header.Get("Content-Type"))
+	got, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+
+	headResp, err = client.Do(newUploadRequest(t, http.MethodHead, location, nil))
+	require.NoError(t, err)
+	headResp.Body.Close()
+	require.Equal(t, http.StatusNotFound, headResp.StatusCode)
+}
+
+func TestAcceptance_Upload_ReturnsConflictWhenOffsetDoesNotMatch(t *testing.T) {
+	ts, client := newUploadTestServer(t)
+	location := createUpload(t, ts, client, "/docs/upload.txt", 11)
+
+	patchResp := patchUpload(t, client, location, 3, "hello")
+	require.Equal(t, http.StatusConflict, patchResp.StatusCode)
+}
+
+func TestAcceptance_Upload_KeepsBytesReceivedBeforeClientDisconnects(t *testing.T) {
+	ts, client := newUploadTestServer(t)
+	location := createUpload(t, ts, client, "/docs/upload.txt", 11)
+
+	req := newUploadRequest(t, http.MethodPatch, location, io.MultiReader(strings.NewReader("hello"), &disconnectingReader{}))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	_, err := client.Do(req)
+	require.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		headResp, err := client.Do(newUploadRequest(t, http.MethodHead, location, nil))
+		require.NoError(t, err)
+		headResp.Body.Close()
+		return headResp.Header.Get("Upload-Offset") == "5"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	patchResp := patchUpload(t, client, location, 5, " world")
+	require.Equal(t, http.StatusNoContent, patchResp.StatusCode)
+	getResp, err := client.Get(ts.URL + "/docs/upload.txt")
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+	got, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+type disconnectingReader struct{}
+
+func (reader *disconnectingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("client disconnected")
+}
+
+func TestAcceptance_Upload_ReturnsConflictWhenResourceAlreadyExists(t *testing.T) {
+	ts, client := newUploadTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/docs/upload.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req := newUploadRequest(t, http.MethodPost, ts.URL+"/$sys/uploads", nil)
+	req.Header.Set("Upload-Length", "11")
+	req.Header.Set("Upload-Metadata", "identifier "+base64.StdEncoding.EncodeToString([]byte("/docs/upload.txt")))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestAcceptance_Upload_TerminatesUpload(t *testing.T) {
+	ts, client := newUploadTestServer(t)
+	location := createUpload(t, ts, client, "/docs/upload.txt", 11)
+
+	deleteResp, err := client.Do(newUploadRequest(t, http.MethodDelete, location, nil))
+	require.NoError(t, err)
+	deleteResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, deleteResp.StatusCode)
+
+	headResp, err := client.Do(newUploadRequest(t, http.MethodHead, location, nil))
+	require.NoError(t, err)
+	headResp.Body.Close()
+	require.Equal(t, http.StatusNotFound, headResp.StatusCode)
+}
+
+func TestAcceptance_Upload_ReturnsMisdirectedRequestOnAnotherInstance(t *testing.T) {
+	store := memory.NewStorage(&configuration.MemoryConfiguration{})
+	first, client := newUploadTestServerWithStorage(t, &store)
+	second, _ := newUploadTestServerWithStorage(t, &store)
+	location := createUpload(t, first, client, "/docs/upload.txt", 11)
+	location = second.URL + strings.TrimPrefix(location, first.URL)
+
+	headResp, err := client.Do(newUploadRequest(t, http.MethodHead, location, nil))
+	require.NoError(t, err)
+	headResp.Body.Close()
+	require.Equal(t, http.StatusMisdirectedRequest, headResp.StatusCode)
+
+	patchResp := patchUpload(t, client, location, 0, "hello world")
+	require.Equal(t, http.StatusMisdirectedRequest, patchResp.StatusCode)
+}
+
+func TestAcceptance_Upload_ReturnsPreconditionFailedWithoutTusResumable(t *testing.T) {
+	ts, client := newUploadTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/$sys/uploads", nil)
+	req.Header.Set("Upload-Length", "11")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	require.Equal(t, "1.0.0", resp.Header.Get("Tus-Version"))
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/inx51/howlite-resources/upload"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// UploadCreateHandler implements the tus creation extension. The identifier
+// of the resource to create is passed as the "identifier" upload metadata.
+type UploadCreateHandler struct {
+	storage *storage.Storage
+	bus     *event.Bus
+	uploads *upload.Store
+	maxSize int64
+}
+
+func (handler *UploadCreateHandler) Method() string {
+	return "POST"
+}
+
+func (handler *UploadCreateHandler) Path() string {
+	return uploadsPath
+}
+
+// AuthorizationTarget authorizes the creation of an upload as a POST of the
+// resource it creates.
+func (handler *UploadCreateHandler) AuthorizationTarget(ctx context.Context, req *http.Request) (string, string) {
+	metadata, err := upload.ParseMetadata(req.Header.Get("Upload-Metadata"))
+	if err != nil || !strings.HasPrefix(metadata["identifier"], "/") {
+		return req.Method, req.URL.Path
+	}
+
+	return http.MethodPost, metadata["identifier"]
+}
+
+func (handler *UploadCreateHandler) Handle(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter) (int, error) {
+	if statusCode, ok := checkTusResumable(req, resp); !ok {
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	length, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		logger.Debug(ctx, "Invalid or missing Upload-Length", "uploadLength", req.Header.Get("Upload-Length"))
+		statusCode := http.StatusBadRequest
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	if handler.maxSize > 0 && length > handler.maxSize {
+		logger.Debug(ctx, "Upload exceeds max size", "uploadLength", length, "maxSize", handler.maxSize)
+		statusCode := http.StatusRequestEntityTooLarge
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	metadata, err := upload.ParseMetadata(req.Header.Get("Upload-Metadata"))
+	if err != nil || !strings.HasPrefix(metadata["identifier"], "/") {
+		logger.Debug(ctx, "Invalid Upload-Metadata or missing identifier", "uploadMetadata", req.Header.Get("Upload-Metadata"))
+		statusCode := http.StatusBadRequest
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+	resourceIdentifier := resource.NewResourceIdentifier(metadata["identifier"])
+
+	storage := *handler.storage
+	reCtx, span := tracer.StartInfoSpan(ctx, "storage."+storage.GetName()+".resource_exists")
+	tracer.SetInfoAttributes(
+		reCtx,
+		span,
+		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
+	)
+	resourceExists, err := storage.ResourceExists(reCtx, resourceIdentifier)
+	tracer.SafeEndSpan(span)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		resp.WriteHeader(statusCode)
+		return statusCode, err
+	}
+
+	if resourceExists {
+		logger.Debug(ctx, "Can't create upload for a resource that already exists", "resourceIdentifier", resourceIdentifier.Identifier())
+		statusCode := http.StatusConflict
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	cuCtx, span := tracer.StartInfoSpan(ctx, "upload.create")
+	tracer.SetInfoAttributes(
+		cuCtx,
+		span,
+		attribute.String("resource_identifier", resourceIdentifier.Identifier()),
+		attribute.Int64("upload_length", length),
+	)
+	createdUpload, err := handler.uploads.Create(cuCtx, resourceIdentifier.Identifier(), length, metadata)
+	tracer.SafeEndSpan(span)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		resp.WriteHeader(statusCode)
+		return statusCode, err
+	}
+
+	resp.Header().Set("Location", uploadsPath+"/"+createdUpload.ID)
+	writeUploadHeaders(createdUpload, resp)
+
+	// An empty upload is complete as soon as it has been created.
+	if createdUpload.IsComplete() {
+		statusCode, err := finalizeUpload(ctx, storage, handler.bus, handler.uploads, createdUpload, req, resp)
+		if statusCode != http.StatusNoContent {
+			resp.WriteHeader(statusCode)
+			return statusCode, err
+		}
+	}
+
+	logger.Info(ctx, "Upload created", "resourceIdentifier", resourceIdentifier.Identifier(), "uploadId", createdUpload.ID, "uploadLength", length)
+	statusCode := http.StatusCreated
+	resp.WriteHeader(statusCode)
+	return statusCode, nil
+}
+
+func NewUploadCreateHandler(storage *storage.Storage, bus *event.Bus, uploads *upload.Store, maxSize int64) Handler {
+	return &UploadCreateHandler{
+		storage: storage,
+		bus:     bus,
+		uploads: uploads,
+		maxSize: maxSize,
+	}
+}
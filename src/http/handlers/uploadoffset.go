@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/inx51/howlite-resources/upload"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type UploadOffsetHandler struct {
+	uploads *upload.Store
+}
+
+func (handler *UploadOffsetHandler) Method() string {
+	return "HEAD"
+}
+
+func (handler *UploadOffsetHandler) Path() string {
+	return uploadsPath + "/{id}"
+}
+
+func (handler *UploadOffsetHandler) AuthorizationTarget(ctx context.Context, req *http.Request) (string, string) {
+	return uploadAuthorizationTarget(ctx, handler.uploads, req)
+}
+
+func (handler *UploadOffsetHandler) Handle(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter) (int, error) {
+	if statusCode, ok := checkTusResumable(req, resp); !ok {
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	uploadId := req.PathValue("id")
+	guCtx, span := tracer.StartInfoSpan(ctx, "upload.get")
+	tracer.SetInfoAttributes(
+		guCtx,
+		span,
+		attribute.String("upload_id", uploadId),
+	)
+	currentUpload, err := handler.uploads.Get(guCtx, uploadId)
+	tracer.SafeEndSpan(span)
+	if err != nil {
+		statusCode := uploadErrorStatusCode(err)
+		resp.Header().Set("Cache-Control", "no-store")
+		resp.WriteHeader(statusCode)
+		if statusCode == http.StatusInternalServerError {
+			return statusCode, err
+		}
+		return statusCode, nil
+	}
+
+	writeUploadHeaders(currentUpload, resp)
+	resp.Header().Set("Upload-Length", strconv.FormatInt(currentUpload.Length, 10))
+	if len(currentUpload.Metadata) > 0 {
+		resp.Header().Set("Upload-Metadata", upload.FormatMetadata(currentUpload.Metadata))
+	}
+
+	logger.Debug(ctx, "Upload offset retrieved", "uploadId", uploadId, "uploadOffset", currentUpload.Offset)
+	statusCode := http.StatusOK
+	resp.WriteHeader(statusCode)
+	return statusCode, nil
+}
+
+func NewUploadOffsetHandler(uploads *upload.Store) Handler {
+	return &UploadOffsetHandler{
+		uploads: uploads,
+	}
+}
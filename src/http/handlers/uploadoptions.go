@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+type UploadOptionsHandler struct {
+	maxSize int64
+}
+
+func (handler *UploadOptionsHandler) Method() string {
+	return "OPTIONS"
+}
+
+func (handler *UploadOptionsHandler) Path() string {
+	return uploadsPath
+}
+
+func (handler *UploadOptionsHandler) AllowAnonymous() bool {
+	return true
+}
+
+func (handler *UploadOptionsHandler) Handle(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter) (int, error) {
+	resp.Header().Set("Tus-Resumable", tusVersion)
+	resp.Header().Set("Tus-Version", tusVersion)
+	resp.Header().Set("Tus-Extension", tusExtensions)
+	if handler.maxSize > 0 {
+		resp.Header().Set("Tus-Max-Size", strconv.FormatInt(handler.maxSize, 10))
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+	return http.StatusNoContent, nil
+}
+
+func NewUploadOptionsHandler(maxSize int64) Handler {
+	return &UploadOptionsHandler{
+		maxSize: maxSize,
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/inx51/howlite-resources/upload"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type UploadPatchHandler struct {
+	storage *storage.Storage
+	bus     *event.Bus
+	uploads *upload.Store
+}
+
+func (handler *UploadPatchHandler) Method() string {
+	return "PATCH"
+}
+
+func (handler *UploadPatchHandler) Path() string {
+	return uploadsPath + "/{id}"
+}
+
+func (handler *UploadPatchHandler) AuthorizationTarget(ctx context.Context, req *http.Request) (string, string) {
+	return uploadAuthorizationTarget(ctx, handler.uploads, req)
+}
+
+func (handler *UploadPatchHandler) Handle(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter) (int, error) {
+	if statusCode, ok := checkTusResumable(req, resp); !ok {
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	if req.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		logger.Debug(ctx, "Invalid Content-Type for upload", "contentType", req.Header.Get("Content-Type"))
+		statusCode := http.StatusUnsupportedMediaType
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		logger.Debug(ctx, "Invalid or missing Upload-Offset", "uploadOffset", req.Header.Get("Upload-Offset"))
+		statusCode := http.StatusBadRequest
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	uploadId := req.PathValue("id")
+	unlock, err := handler.uploads.Lock(uploadId)
+	if err != nil {
+		statusCode := uploadErrorStatusCode(err)
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+	defer unlock()
+
+	auCtx, span := tracer.StartInfoSpan(ctx, "upload.append")
+	tracer.SetInfoAttributes(
+		auCtx,
+		span,
+		attribute.String("upload_id", uploadId),
+		attribute.Int64("upload_offset", offset),
+	)
+	currentUpload, err := handler.uploads.Append(auCtx, uploadId, offset, req.Body)
+	if err == nil {
+		// The body of a signed request is only verified once it's read to the
+		// end, which the upload stops short of if the chunk completes it.
+		if _, drainErr := io.Copy(io.Discard, req.Body); errors.Is(drainErr, auth.ErrBodyNotVerified) {
+			err = drainErr
+		}
+	}
+	tracer.SafeEndSpan(span)
+	if errors.Is(err, auth.ErrBodyNotVerified) {
+		logger.Debug(ctx, "Upload chunk does not match its signed hash", "uploadId", uploadId, "uploadOffset", offset)
+		statusCode := http.StatusBadRequest
+		if _, err := handler.uploads.Rewind(ctx, uploadId, offset); err != nil {
+			statusCode = http.StatusInternalServerError
+			resp.WriteHeader(statusCode)
+			return statusCode, err
+		}
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+	if errors.Is(err, upload.ErrUploadInterrupted) {
+		// The bytes received are kept, the client resumes from the offset it
+		// gets from a HEAD request if it's gone.
+		logger.Debug(ctx, "Upload interrupted", "uploadId", uploadId, "uploadOffset", currentUpload.Offset, "error", err)
+		writeUploadHeaders(currentUpload, resp)
+		statusCode := http.StatusNoContent
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+	if err != nil {
+		statusCode := uploadErrorStatusCode(err)
+		resp.WriteHeader(statusCode)
+		if statusCode == http.StatusInternalServerError {
+			return statusCode, err
+		}
+		return statusCode, nil
+	}
+
+	writeUploadHeaders(currentUpload, resp)
+	if currentUpload.IsComplete() {
+		statusCode, err := finalizeUpload(ctx, *handler.storage, handler.bus, handler.uploads, currentUpload, req, resp)
+		resp.WriteHeader(statusCode)
+		return statusCode, err
+	}
+
+	logger.Debug(ctx, "Upload appended", "uploadId", uploadId, "uploadOffset", currentUpload.Offset, "uploadLength", currentUpload.Length)
+	statusCode := http.StatusNoContent
+	resp.WriteHeader(statusCode)
+	return statusCode, nil
+}
+
+func NewUploadPatchHandler(storage *storage.Storage, bus *event.Bus, uploads *upload.Store) Handler {
+	return &UploadPatchHandler{
+		storage: storage,
+		bus:     bus,
+		uploads: uploads,
+	}
+}
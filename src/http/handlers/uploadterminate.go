@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/tracer"
+	"github.com/inx51/howlite-resources/upload"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type UploadTerminateHandler struct {
+	uploads *upload.Store
+}
+
+func (handler *UploadTerminateHandler) Method() string {
+	return "DELETE"
+}
+
+func (handler *UploadTerminateHandler) Path() string {
+	return uploadsPath + "/{id}"
+}
+
+func (handler *UploadTerminateHandler) AuthorizationTarget(ctx context.Context, req *http.Request) (string, string) {
+	return uploadAuthorizationTarget(ctx, handler.uploads, req)
+}
+
+func (handler *UploadTerminateHandler) Handle(
+	ctx context.Context,
+	req *http.Request,
+	resp http.ResponseWriter) (int, error) {
+	if statusCode, ok := checkTusResumable(req, resp); !ok {
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+
+	uploadId := req.PathValue("id")
+	unlock, err := handler.uploads.Lock(uploadId)
+	if err != nil {
+		statusCode := uploadErrorStatusCode(err)
+		resp.WriteHeader(statusCode)
+		return statusCode, nil
+	}
+	defer unlock()
+
+	ruCtx, span := tracer.StartInfoSpan(ctx, "upload.remove")
+	tracer.SetInfoAttributes(
+		ruCtx,
+		span,
+		attribute.String("upload_id", uploadId),
+	)
+	err = handler.uploads.Remove(ruCtx, uploadId)
+	tracer.SafeEndSpan(span)
+	if err != nil {
+		statusCode := uploadErrorStatusCode(err)
+		resp.WriteHeader(statusCode)
+		if statusCode == http.StatusInternalServerError {
+			return statusCode, err
+		}
+		return statusCode, nil
+	}
+
+	logger.Info(ctx, "Upload terminated", "uploadId", uploadId)
+	statusCode := http.StatusNoContent
+	resp.WriteHeader(statusCode)
+	return statusCode, nil
+}
+
+func NewUploadTerminateHandler(uploads *upload.Store) Handler {
+	return &UploadTerminateHandler{
+		uploads: uploads,
+	}
+}
@@ -0,0 +1,138 @@
+package precondition
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/inx51/howlite-resources/resource"
+)
+
+var conditionalHeaders = []string{
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+	"If-Unmodified-Since",
+}
+
+// HasConditions reports whether the request carries any conditional header
+// that needs the current resource properties to be evaluated.
+func HasConditions(request *http.Request) bool {
+	for _, header := range conditionalHeaders {
+		if request.Header.Get(header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate checks the conditional headers of the request against the
+// properties of the targeted resource, in the order defined by RFC 9110
+// section 13.2.2. properties must be nil if the resource does not exist.
+//
+// It returns 0 if the request should be processed, otherwise the status code
+// (304 Not Modified or 412 Precondition Failed) to respond with.
+func Evaluate(request *http.Request, properties *resource.ResourceProperties) int {
+	isRead := request.Method == http.MethodGet || request.Method == http.MethodHead
+
+	if ifMatch := headerList(request, "If-Match"); ifMatch != "" {
+		if !matches(ifMatch, properties, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ifUnmodifiedSince := request.Header.Get("If-Unmodified-Since"); ifUnmodifiedSince != "" {
+		if modified, ok := isModifiedSince(ifUnmodifiedSince, properties); ok && modified {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := headerList(request, "If-None-Match"); ifNoneMatch != "" {
+		if matches(ifNoneMatch, properties, true) {
+			if isRead {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ifModifiedSince := request.Header.Get("If-Modified-Since"); ifModifiedSince != "" && isRead {
+		if modified, ok := isModifiedSince(ifModifiedSince, properties); ok && !modified {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// IfRangeMatches reports whether a Range header should be honored given the
+// If-Range header of the request (RFC 9110 section 13.1.5). Requests without
+// If-Range always match. An entity tag must match strongly and a date must be
+// exactly the last modification date of the resource.
+func IfRangeMatches(request *http.Request, properties *resource.ResourceProperties) bool {
+	ifRange := strings.TrimSpace(request.Header.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, "\"") || strings.HasPrefix(ifRange, "W/") {
+		return matches(ifRange, properties, false)
+	}
+
+	since, err := http.ParseTime(ifRange)
+	if err != nil || properties == nil || properties.LastModified.IsZero() {
+		return false
+	}
+	return properties.LastModified.Truncate(time.Second).Equal(since)
+}
+
+func headerList(request *http.Request, name string) string {
+	return strings.Join(request.Header.Values(name), ",")
+}
+
+// matches compares the entity tags listed in a If-Match or If-None-Match
+// header against the resource ETag, using weak comparison if weak is true
+// and strong comparison otherwise.
+func matches(header string, properties *resource.ResourceProperties, weak bool) bool {
+	if properties == nil {
+		return false
+	}
+
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	etag := properties.ETag()
+	if etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isModifiedSince reports whether the resource was modified after the HTTP
+// date in value. ok is false if the date can't be parsed or the resource has
+// no known modification time, in which case the condition must be ignored.
+func isModifiedSince(value string, properties *resource.ResourceProperties) (modified bool, ok bool) {
+	if properties == nil || properties.LastModified.IsZero() {
+		return false, false
+	}
+
+	since, err := http.ParseTime(value)
+	if err != nil {
+		return false, false
+	}
+
+	// HTTP dates only have second precision.
+	return properties.LastModified.Truncate(time.Second).After(since), true
+}
@@ -0,0 +1,119 @@
+//go:build unit
+
+package precondition_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/http/precondition"
+	"github.com/inx51/howlite-resources/resource"
+)
+
+func newProperties() *resource.ResourceProperties {
+	properties := resource.NewResourceProperties(resource.NewResourceIdentifier("/test"))
+	properties.ContentHash = "abc123"
+	properties.LastModified = time.Date(2025, 12, 9, 10, 0, 0, 0, time.UTC)
+	return properties
+}
+
+func newRequest(method string, headers map[string]string) *http.Request {
+	request, _ := http.NewRequest(method, "http://localhost/test", nil)
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+	return request
+}
+
+func TestEvaluateShouldReturnExpectedStatusCode(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		exists     bool
+		statusCode int
+	}{
+		{"no conditions", http.MethodGet, nil, true, 0},
+		{"if-match matching etag", http.MethodPut, map[string]string{"If-Match": "\"abc123\""}, true, 0},
+		{"if-match in list", http.MethodPut, map[string]string{"If-Match": "\"x\", \"abc123\""}, true, 0},
+		{"if-match other etag", http.MethodPut, map[string]string{"If-Match": "\"other\""}, true, http.StatusPreconditionFailed},
+		{"if-match weak etag", http.MethodPut, map[string]string{"If-Match": "W/\"abc123\""}, true, http.StatusPreconditionFailed},
+		{"if-match wildcard", http.MethodDelete, map[string]string{"If-Match": "*"}, true, 0},
+		{"if-match wildcard missing resource", http.MethodPut, map[string]string{"If-Match": "*"}, false, http.StatusPreconditionFailed},
+		{"if-none-match matching etag on get", http.MethodGet, map[string]string{"If-None-Match": "\"abc123\""}, true, http.StatusNotModified},
+		{"if-none-match weak etag on head", http.MethodHead, map[string]string{"If-None-Match": "W/\"abc123\""}, true, http.StatusNotModified},
+		{"if-none-match other etag", http.MethodGet, map[string]string{"If-None-Match": "\"other\""}, true, 0},
+		{"if-none-match matching etag on put", http.MethodPut, map[string]string{"If-None-Match": "\"abc123\""}, true, http.StatusPreconditionFailed},
+		{"if-none-match wildcard existing resource", http.MethodPut, map[string]string{"If-None-Match": "*"}, true, http.StatusPreconditionFailed},
+		{"if-none-match wildcard missing resource", http.MethodPut, map[string]string{"If-None-Match": "*"}, false, 0},
+		{"if-modified-since not modified", http.MethodGet, map[string]string{"If-Modified-Since": "Tue, 09 Dec 2025 10:00:00 GMT"}, true, http.StatusNotModified},
+		{"if-modified-since modified", http.MethodGet, map[string]string{"If-Modified-Since": "Tue, 09 Dec 2025 09:00:00 GMT"}, true, 0},
+		{"if-modified-since ignored on put", http.MethodPut, map[string]string{"If-Modified-Since": "Tue, 09 Dec 2025 10:00:00 GMT"}, true, 0},
+		{"if-modified-since ignored with if-none-match", http.MethodGet, map[string]string{"If-None-Match": "\"other\"", "If-Modified-Since": "Tue, 09 Dec 2025 10:00:00 GMT"}, true, 0},
+		{"if-modified-since invalid date", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, true, 0},
+		{"if-unmodified-since modified", http.MethodDelete, map[string]string{"If-Unmodified-Since": "Tue, 09 Dec 2025 09:00:00 GMT"}, true, http.StatusPreconditionFailed},
+		{"if-unmodified-since not modified", http.MethodDelete, map[string]string{"If-Unmodified-Since": "Tue, 09 Dec 2025 10:00:00 GMT"}, true, 0},
+		{"if-unmodified-since ignored with if-match", http.MethodDelete, map[string]string{"If-Match": "\"abc123\"", "If-Unmodified-Since": "Tue, 09 Dec 2025 09:00:00 GMT"}, true, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var properties *resource.ResourceProperties
+			if tc.exists {
+				properties = newProperties()
+			}
+
+			statusCode := precondition.Evaluate(newRequest(tc.method, tc.headers), properties)
+
+			if statusCode != tc.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tc.statusCode, statusCode)
+			}
+		})
+	}
+}
+
+func TestEvaluateShouldIgnoreETagConditionsWithoutContentHash(t *testing.T) {
+	properties := resource.NewResourceProperties(resource.NewResourceIdentifier("/test"))
+
+	statusCode := precondition.Evaluate(newRequest(http.MethodGet, map[string]string{"If-None-Match": "\"\""}), properties)
+
+	if statusCode != 0 {
+		t.Fatalf("Expected status code 0, got %d", statusCode)
+	}
+}
+
+func TestHasConditionsShouldDetectConditionalHeaders(t *testing.T) {
+	if precondition.HasConditions(newRequest(http.MethodPut, nil)) {
+		t.Fatal("Expected request without conditional headers to have no conditions")
+	}
+	if !precondition.HasConditions(newRequest(http.MethodPut, map[string]string{"If-Match": "*"})) {
+		t.Fatal("Expected request with If-Match to have conditions")
+	}
+}
+
+func TestIfRangeMatchesShouldReturnExpectedResult(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ifRange  string
+		expected bool
+	}{
+		{"no if-range", "", true},
+		{"matching etag", "\"abc123\"", true},
+		{"other etag", "\"other\"", false},
+		{"weak etag", "W/\"abc123\"", false},
+		{"matching date", "Tue, 09 Dec 2025 10:00:00 GMT", true},
+		{"other date", "Tue, 09 Dec 2025 11:00:00 GMT", false},
+		{"invalid date", "yesterday", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := newRequest(http.MethodGet, map[string]string{"If-Range": tc.ifRange})
+
+			if precondition.IfRangeMatches(request, newProperties()) != tc.expected {
+				t.Fatalf("Expected %v for If-Range '%s'", tc.expected, tc.ifRange)
+			}
+		})
+	}
+}
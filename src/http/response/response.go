@@ -1,9 +1,12 @@
 package response
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/inx51/howlite-resources/resource"
 )
 
 func WriteHeaders(headers *map[string][]string, resp http.ResponseWriter) {
@@ -17,6 +20,24 @@ func WriteHeaders(headers *map[string][]string, resp http.ResponseWriter) {
 	}
 }
 
+func WriteProperties(properties *resource.ResourceProperties, resp http.ResponseWriter) {
+	if properties == nil {
+		return
+	}
+
+	if etag := properties.ETag(); etag != "" {
+		resp.Header().Set("ETag", etag)
+	}
+
+	if !properties.LastModified.IsZero() {
+		resp.Header().Set("Last-Modified", properties.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+func WriteJson(value any, resp http.ResponseWriter) error {
+	return json.NewEncoder(resp).Encode(value)
+}
+
 func WriteBody(body io.ReadCloser, resp http.ResponseWriter) error {
 	if body == nil {
 		return nil
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/inx51/howlite-resources/health"
+	"github.com/inx51/howlite-resources/http/response"
+	"github.com/inx51/howlite-resources/logger"
+)
+
+const (
+	livePath  = "/$sys/live"
+	readyPath = "/$sys/ready"
+)
+
+// WithHealth serves GET and HEAD requests for the liveness and readiness
+// probes, without authentication or authorization. Liveness does not check any
+// dependencies, so a failing dependency doesn't get the process restarted,
+// readiness reports the status of the checks of the checker. Their errors are
+// only logged, since the probes are served anonymously.
+func WithHealth(checker *health.Checker) Option {
+	return func(options *options) {
+		options.health = checker
+	}
+}
+
+func serveLive(response http.ResponseWriter, request *http.Request) {
+	writeReport(response, request, http.StatusOK, &health.Report{Status: health.StatusUp})
+}
+
+func serveReady(checker *health.Checker) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		report := checker.Check(request.Context())
+
+		statusCode := http.StatusOK
+		if !report.Ready() {
+			logger.Warn(request.Context(), "Readiness check failed", "status", report.Status, "components", report.Components)
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeReport(response, request, statusCode, report.WithoutErrors())
+	}
+}
+
+func writeReport(resp http.ResponseWriter, request *http.Request, statusCode int, report *health.Report) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Cache-Control", "no-store")
+	resp.WriteHeader(statusCode)
+	if err := response.WriteJson(report, resp); err != nil {
+		logger.Debug(request.Context(), "Failed to write health report", "error", err)
+	}
+}
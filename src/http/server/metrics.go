@@ -0,0 +1,83 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/inx51/howlite-resources/http/handlers"
+	"github.com/inx51/howlite-resources/meter"
+)
+
+// The HTTP server instruments follow the OpenTelemetry semantic conventions.
+var (
+	requestDuration = meter.Instrument{
+		Name:        "http.server.request.duration",
+		Unit:        "s",
+		Description: "Duration of HTTP server requests.",
+		Buckets:     []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10},
+	}
+	requestBodySize = meter.Instrument{
+		Name:        "http.server.request.body.size",
+		Unit:        "By",
+		Description: "Size of HTTP server request bodies.",
+		Buckets:     bodySizeBuckets,
+	}
+	responseBodySize = meter.Instrument{
+		Name:        "http.server.response.body.size",
+		Unit:        "By",
+		Description: "Size of HTTP server response bodies.",
+		Buckets:     bodySizeBuckets,
+	}
+	activeRequests = meter.Instrument{
+		Name:        "http.server.active_requests",
+		Unit:        "{request}",
+		Description: "Number of active HTTP server requests.",
+	}
+	bodySizeBuckets = []float64{0, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864, 268435456, 1073741824}
+)
+
+// WithStorageProvider labels the HTTP server metrics with the name of the
+// storage provider.
+func WithStorageProvider(name string) Option {
+	return func(options *options) {
+		options.storageProvider = name
+	}
+}
+
+// httpRoute returns the route of the handler, or the route template of the
+// resource for the handlers serving every path.
+func httpRoute(handler handlers.Handler, request *http.Request) string {
+	if handler.Path() != "/" {
+		return handler.Path()
+	}
+	return meter.Route(request.URL.Path)
+}
+
+// meteredResponseWriter counts the bytes written to the response body.
+type meteredResponseWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (writer *meteredResponseWriter) Write(data []byte) (int, error) {
+	written, err := writer.ResponseWriter.Write(data)
+	writer.written += int64(written)
+	return written, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (writer *meteredResponseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+// countingReadCloser counts the bytes read from the request body.
+type countingReadCloser struct {
+	io.ReadCloser
+	read int64
+}
+
+func (reader *countingReadCloser) Read(data []byte) (int, error) {
+	read, err := reader.ReadCloser.Read(data)
+	reader.read += int64(read)
+	return read, err
+}
@@ -7,10 +7,19 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/inx51/howlite-resources/accesslog"
+	"github.com/inx51/howlite-resources/health"
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/http/authz"
 	"github.com/inx51/howlite-resources/http/handlers"
 	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/meter"
 	"github.com/inx51/howlite-resources/tracer"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Server struct {
@@ -18,6 +27,50 @@ type Server struct {
 	httpServer *http.Server
 }
 
+type Option func(*options)
+
+type options struct {
+	authenticator   *auth.Chain
+	authorizer      *authz.Authorizer
+	metricsPath     string
+	metrics         http.Handler
+	storageProvider string
+	accessLog       *accesslog.Logger
+	health          *health.Checker
+}
+
+// WithAuthenticator requires every request, except for handlers that allow
+// anonymous access, to be authenticated by the chain.
+func WithAuthenticator(authenticator *auth.Chain) Option {
+	return func(options *options) {
+		options.authenticator = authenticator
+	}
+}
+
+// WithAuthorizer evaluates every request, except for handlers that allow
+// anonymous access, against the policy of the authorizer before it is handled.
+func WithAuthorizer(authorizer *authz.Authorizer) Option {
+	return func(options *options) {
+		options.authorizer = authorizer
+	}
+}
+
+// WithMetrics serves GET and HEAD requests for path with the metrics handler,
+// without authentication or authorization.
+func WithMetrics(path string, handler http.Handler) Option {
+	return func(options *options) {
+		options.metricsPath = path
+		options.metrics = handler
+	}
+}
+
+// WithAccessLog writes a record of every handled request to the access log.
+func WithAccessLog(accessLog *accesslog.Logger) Option {
+	return func(options *options) {
+		options.accessLog = accessLog
+	}
+}
+
 type TimeoutConfigurations struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
@@ -27,10 +80,15 @@ type TimeoutConfigurations struct {
 // NewServeMux builds and returns an http.ServeMux with all supplied handlers
 // registered. It is exposed so that acceptance tests can create an
 // httptest.Server without needing a real listener address or port.
-func NewServeMux(hs *[]handlers.Handler) *http.ServeMux {
+func NewServeMux(hs *[]handlers.Handler, opts ...Option) *http.ServeMux {
+	serverOptions := &options{}
+	for _, opt := range opts {
+		opt(serverOptions)
+	}
+
 	mux := http.NewServeMux()
 	for _, h := range *hs {
-		registerHandler(mux, h)
+		registerHandler(mux, h, serverOptions)
 	}
 	return mux
 }
@@ -41,15 +99,17 @@ func NewServer(
 	handlers *[]handlers.Handler,
 	writeTimout time.Duration,
 	readTimeout time.Duration,
-	idleTimeout time.Duration) *Server {
+	idleTimeout time.Duration,
+	opts ...Option) *Server {
 
-	mux := NewServeMux(handlers)
+	mux := NewServeMux(handlers, opts...)
+	handler := NewHandler(mux, opts...)
 
 	addr := host + ":" + strconv.Itoa(port)
 
 	httpServer := &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimout,
 		IdleTimeout:  idleTimeout,
@@ -63,25 +123,202 @@ func NewServer(
 	return server
 }
 
-func registerHandler(mux *http.ServeMux, handler handlers.Handler) {
+// NewMetricsServer serves only the metrics handler on path, for exposing the
+// metrics on another address than the resources.
+func NewMetricsServer(address string, path string, handler http.Handler) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+path, handler)
+
+	return &Server{
+		mux: mux,
+		httpServer: &http.Server{
+			Addr:              address,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// NewHandler wraps the mux with the routes that are served ahead of it, the
+// metrics and health probes are since a GET pattern for their paths would
+// conflict with the HEAD pattern of the exists handler.
+func NewHandler(mux *http.ServeMux, opts ...Option) http.Handler {
+	serverOptions := &options{}
+	for _, opt := range opts {
+		opt(serverOptions)
+	}
+
+	routes := make(map[string]http.Handler)
+	if serverOptions.metrics != nil {
+		routes[serverOptions.metricsPath] = serverOptions.metrics
+	}
+	if serverOptions.health != nil {
+		routes[livePath] = http.HandlerFunc(serveLive)
+		routes[readyPath] = serveReady(serverOptions.health)
+	}
+	if len(routes) == 0 {
+		return mux
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if handler, found := routes[request.URL.Path]; found && (request.Method == http.MethodGet || request.Method == http.MethodHead) {
+			handler.ServeHTTP(response, request)
+			return
+		}
+
+		mux.ServeHTTP(response, request)
+	})
+}
+
+func registerHandler(mux *http.ServeMux, handler handlers.Handler, options *options) {
 	path := handler.Method() + " " + handler.Path()
 	mux.HandleFunc(path, func(response http.ResponseWriter, request *http.Request) {
-		ctx := request.Context()
-		ctx, span := tracer.StartInfoSpan(ctx, handler.Method()+" "+request.URL.Path)
+		// Continue the trace of the caller, if it sent one.
+		ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		ctx, span := tracer.StartInfoSpan(ctx, handler.Method()+" "+request.URL.Path, trace.WithSpanKind(trace.SpanKindServer))
 		defer tracer.SafeEndSpan(span)
-		logger.Debug(ctx, path, "method", request.Method, "path", request.URL.Path)
-		statusCode, err := handler.Handle(ctx, request, response)
+
+		meteredResponse := &meteredResponseWriter{ResponseWriter: response}
+		requestBody := &countingReadCloser{ReadCloser: request.Body}
+		request.Body = requestBody
+		route := httpRoute(handler, request)
+		requestAttributes := metric.WithAttributes(
+			attribute.String("http.request.method", request.Method),
+			attribute.String("http.route", route),
+			attribute.String("storage.provider", options.storageProvider),
+		)
+		meter.ArithmeticInt64UpDownCounter(ctx, activeRequests, 1, requestAttributes)
+		start := time.Now()
+
+		statusCode, principal := serveHandler(ctx, span, path, handler, options, meteredResponse, request)
+		duration := time.Since(start)
+
+		meter.ArithmeticInt64UpDownCounter(ctx, activeRequests, -1, requestAttributes)
+		responseAttributes := metric.WithAttributes(
+			attribute.String("http.request.method", request.Method),
+			attribute.Int("http.response.status_code", statusCode),
+			attribute.String("http.route", route),
+			attribute.String("storage.provider", options.storageProvider),
+		)
+		meter.RecordFloat64Histogram(ctx, requestDuration, duration.Seconds(), responseAttributes)
+		meter.RecordInt64Histogram(ctx, requestBodySize, requestBody.read, responseAttributes)
+		meter.RecordInt64Histogram(ctx, responseBodySize, meteredResponse.written, responseAttributes)
+
+		if options.accessLog != nil {
+			record := &accesslog.Record{
+				Time:          start,
+				Method:        request.Method,
+				Path:          request.URL.Path,
+				Protocol:      request.Proto,
+				Status:        statusCode,
+				BytesIn:       requestBody.read,
+				BytesOut:      meteredResponse.written,
+				Duration:      duration,
+				RemoteAddress: request.RemoteAddr,
+				UserAgent:     request.UserAgent(),
+				Referer:       request.Referer(),
+			}
+			if principal != nil {
+				record.Principal = principal.Name
+			}
+			if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+				record.TraceId = spanContext.TraceID().String()
+			}
+			if err := options.accessLog.Log(record); err != nil {
+				logger.Error(ctx, "Failed to write access log record", "error", err)
+			}
+		}
+	})
+}
+
+// serveHandler authenticates and authorizes the request before it is handled,
+// and returns the status code of the response along with the authenticated
+// principal, if any.
+func serveHandler(
+	ctx context.Context,
+	span trace.Span,
+	path string,
+	handler handlers.Handler,
+	options *options,
+	response http.ResponseWriter,
+	request *http.Request) (int, *auth.Principal) {
+	if options.authenticator != nil && !allowsAnonymous(handler) {
+		principal, err := options.authenticator.Authenticate(request)
 		if err != nil {
-			logger.Error(ctx, "handler returned error", "method", request.Method, "path", request.URL.Path, "status", statusCode, "error", err)
+			logger.Debug(ctx, "Request not authenticated", "method", request.Method, "path", request.URL.Path, "error", err)
+			for _, challenge := range options.authenticator.Challenges() {
+				response.Header().Add("WWW-Authenticate", challenge)
+			}
+			response.WriteHeader(http.StatusUnauthorized)
+			tracer.SetInfoAttributes(ctx,
+				span,
+				attribute.String("method", request.Method),
+				attribute.String("path", request.URL.Path),
+				attribute.Int("status", http.StatusUnauthorized),
+			)
+			return http.StatusUnauthorized, nil
 		}
 
+		ctx = auth.WithPrincipal(ctx, principal)
+		ctx = logger.WithArgs(ctx, "principal", principal.Name)
 		tracer.SetInfoAttributes(ctx,
 			span,
-			attribute.String("method", request.Method),
-			attribute.String("path", request.URL.Path),
-			attribute.Int("status", statusCode),
+			attribute.String("enduser.id", principal.Name),
+			attribute.StringSlice("enduser.roles", principal.Roles),
+			attribute.String("enduser.auth_scheme", principal.Scheme),
 		)
-	})
+	}
+
+	if options.authorizer != nil && !allowsAnonymous(handler) {
+		method, targetPath := authorizationTarget(ctx, handler, request)
+		decision := options.authorizer.Authorize(auth.PrincipalFromContext(ctx), method, targetPath)
+		tracer.SetInfoAttributes(ctx,
+			span,
+			attribute.Bool("authz.allowed", decision.Allowed),
+			attribute.String("authz.rule", decision.Rule),
+			attribute.String("authz.method", method),
+			attribute.String("authz.path", targetPath),
+		)
+		if !decision.Allowed {
+			logger.Debug(ctx, "Request not authorized", "method", method, "path", targetPath, "rule", decision.Rule)
+			response.WriteHeader(http.StatusForbidden)
+			tracer.SetInfoAttributes(ctx,
+				span,
+				attribute.String("method", request.Method),
+				attribute.String("path", request.URL.Path),
+				attribute.Int("status", http.StatusForbidden),
+			)
+			return http.StatusForbidden, auth.PrincipalFromContext(ctx)
+		}
+		ctx = authz.WithAuthorizer(ctx, options.authorizer)
+	}
+
+	logger.Debug(ctx, path, "method", request.Method, "path", request.URL.Path)
+	statusCode, err := handler.Handle(ctx, request, response)
+	if err != nil {
+		logger.Error(ctx, "handler returned error", "method", request.Method, "path", request.URL.Path, "status", statusCode, "error", err)
+	}
+
+	tracer.SetInfoAttributes(ctx,
+		span,
+		attribute.String("method", request.Method),
+		attribute.String("path", request.URL.Path),
+		attribute.Int("status", statusCode),
+	)
+	return statusCode, auth.PrincipalFromContext(ctx)
+}
+
+func allowsAnonymous(handler handlers.Handler) bool {
+	anonymousHandler, ok := handler.(handlers.AnonymousHandler)
+	return ok && anonymousHandler.AllowAnonymous()
+}
+
+func authorizationTarget(ctx context.Context, handler handlers.Handler, request *http.Request) (string, string) {
+	if targetHandler, ok := handler.(handlers.AuthorizationTargetHandler); ok {
+		return targetHandler.AuthorizationTarget(ctx, request)
+	}
+
+	return request.Method, request.URL.Path
 }
 
 func (server *Server) Start(ctx context.Context) {
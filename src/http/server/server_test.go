@@ -0,0 +1,307 @@
+//go:build unit
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/inx51/howlite-resources/accesslog"
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/http/auth"
+	"github.com/inx51/howlite-resources/http/authz"
+	"github.com/inx51/howlite-resources/http/handlers"
+	"github.com/inx51/howlite-resources/http/server"
+	"github.com/inx51/howlite-resources/meter"
+	"github.com/inx51/howlite-resources/tracer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type principalHandler struct{}
+
+func (handler *principalHandler) Method() string {
+	return "GET"
+}
+
+func (handler *principalHandler) Path() string {
+	return "/"
+}
+
+func (handler *principalHandler) Handle(ctx context.Context, req *http.Request, resp http.ResponseWriter) (int, error) {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal != nil {
+		resp.Header().Set("X-Principal", principal.Name)
+	}
+	resp.WriteHeader(http.StatusOK)
+	return http.StatusOK, nil
+}
+
+func newAuthenticatedServer(t *testing.T, opts ...server.Option) *httptest.Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	os.WriteFile(path, []byte(`[{"name": "cdn", "key": "secret"}]`), 0o600)
+	authenticator, err := auth.NewApiKeyAuthenticator(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	hs := &[]handlers.Handler{
+		&principalHandler{},
+		handlers.NewSysProbeHandler(),
+	}
+	opts = append(opts, server.WithAuthenticator(auth.NewChain(authenticator)))
+	ts := httptest.NewServer(server.NewServeMux(hs, opts...))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newAuthorizer(t *testing.T, policy string) *authz.Authorizer {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	os.WriteFile(path, []byte(policy), 0o600)
+	authorizer, err := authz.NewAuthorizer(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return authorizer
+}
+
+func TestServeMuxShouldRejectUnauthenticatedRequest(t *testing.T) {
+	ts := newAuthenticatedServer(t)
+
+	resp, err := http.Get(ts.URL + "/resource")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("WWW-Authenticate") != `ApiKey realm="howlite-resources"` {
+		t.Fatalf("Expected ApiKey challenge, got %q", resp.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestServeMuxShouldPassPrincipalToHandler(t *testing.T) {
+	ts := newAuthenticatedServer(t)
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/resource", nil)
+	req.Header.Set("X-Api-Key", "secret")
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Principal") != "cdn" {
+		t.Fatalf("Expected principal 'cdn', got %q", resp.Header.Get("X-Principal"))
+	}
+}
+
+func TestServeMuxShouldAllowAnonymousHandlers(t *testing.T) {
+	ts := newAuthenticatedServer(t)
+	req, _ := http.NewRequest(http.MethodHead, ts.URL+"/$sys/probe", nil)
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeMuxShouldForbidUnauthorizedRequest(t *testing.T) {
+	authorizer := newAuthorizer(t, `{"rules": [{"principals": ["cdn"], "methods": ["GET"], "paths": ["/public/**"]}]}`)
+	ts := newAuthenticatedServer(t, server.WithAuthorizer(authorizer))
+
+	statusCodes := map[string]int{}
+	for _, path := range []string{"/public/a.txt", "/private/a.txt"} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("X-Api-Key", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		resp.Body.Close()
+		statusCodes[path] = resp.StatusCode
+	}
+
+	if statusCodes["/public/a.txt"] != http.StatusOK {
+		t.Fatalf("Expected status 200 for an allowed path, got %d", statusCodes["/public/a.txt"])
+	}
+	if statusCodes["/private/a.txt"] != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for a denied path, got %d", statusCodes["/private/a.txt"])
+	}
+}
+
+func TestServeMuxShouldNotAuthorizeAnonymousHandlers(t *testing.T) {
+	authorizer := newAuthorizer(t, `{"rules": []}`)
+	ts := newAuthenticatedServer(t, server.WithAuthorizer(authorizer))
+	req, _ := http.NewRequest(http.MethodHead, ts.URL+"/$sys/probe", nil)
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlerShouldServeMetricsWithoutAuthentication(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	os.WriteFile(path, []byte(`[{"name": "cdn", "key": "secret"}]`), 0o600)
+	authenticator, err := auth.NewApiKeyAuthenticator(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	metrics := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Write([]byte("resources_created_total 1\n"))
+	})
+	opts := []server.Option{
+		server.WithAuthenticator(auth.NewChain(authenticator)),
+		server.WithMetrics("/$sys/metrics", metrics),
+	}
+	hs := &[]handlers.Handler{&principalHandler{}}
+	ts := httptest.NewServer(server.NewHandler(server.NewServeMux(hs, opts...), opts...))
+	t.Cleanup(ts.Close)
+
+	metricsResp, err := http.Get(ts.URL + "/$sys/metrics")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	metricsResp.Body.Close()
+	resourceResp, err := http.Get(ts.URL + "/resource")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resourceResp.Body.Close()
+
+	if metricsResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for metrics, got %d", metricsResp.StatusCode)
+	}
+	if resourceResp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 for resources, got %d", resourceResp.StatusCode)
+	}
+}
+
+func TestServeMuxShouldRecordRequestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	meter.SetupMeter(true)
+	t.Cleanup(func() { meter.SetupMeter(false) })
+	ts := newAuthenticatedServer(t, server.WithStorageProvider("memory"))
+
+	resp, err := http.Get(ts.URL + "/resource")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	var collected metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &collected); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	instruments := map[string]metricdata.Aggregation{}
+	for _, scope := range collected.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			instruments[metric.Name] = metric.Data
+		}
+	}
+	for _, name := range []string{"http.server.request.duration", "http.server.request.body.size", "http.server.response.body.size", "http.server.active_requests"} {
+		if _, found := instruments[name]; !found {
+			t.Fatalf("Expected %s to be recorded", name)
+		}
+	}
+	duration := instruments["http.server.request.duration"].(metricdata.Histogram[float64]).DataPoints[0]
+	status, _ := duration.Attributes.Value(attribute.Key("http.response.status_code"))
+	provider, _ := duration.Attributes.Value(attribute.Key("storage.provider"))
+	if status.AsInt64() != http.StatusUnauthorized || provider.AsString() != "memory" {
+		t.Fatalf("Expected status 401 and provider memory, got %v and %v", status.AsInt64(), provider.AsString())
+	}
+	if duration.Count != 1 {
+		t.Fatalf("Expected 1 request, got %d", duration.Count)
+	}
+	route, _ := duration.Attributes.Value(attribute.Key("http.route"))
+	if route.AsString() != "/**" {
+		t.Fatalf("Expected route /**, got %s", route.AsString())
+	}
+}
+
+func TestServeMuxShouldContinueTraceOfCaller(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tracer.SetupTracer(&configuration.Tracing{LEVEL: "info"}, true)
+	t.Cleanup(func() { tracer.SetupTracer(&configuration.Tracing{}, false) })
+	ts := newAuthenticatedServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/resource", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if traceID := spans[0].SpanContext.TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected trace 4bf92f3577b34da6a3ce929d0e0e4736, got %s", traceID)
+	}
+	if parentID := spans[0].Parent.SpanID().String(); parentID != "00f067aa0ba902b7" {
+		t.Fatalf("Expected parent span 00f067aa0ba902b7, got %s", parentID)
+	}
+}
+
+func TestServeMuxShouldWriteAccessLogRecord(t *testing.T) {
+	var buffer bytes.Buffer
+	accessLog, err := accesslog.NewWriterLogger("json", &buffer)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ts := newAuthenticatedServer(t, server.WithAccessLog(accessLog))
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/resource", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("User-Agent", "howlite-test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	var record map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a single JSON record, got %v", err)
+	}
+	if record["method"] != "GET" || record["path"] != "/resource" || record["status"] != float64(200) {
+		t.Fatalf("Expected GET /resource 200, got %v %v %v", record["method"], record["path"], record["status"])
+	}
+	if record["principal"] != "cdn" || record["user_agent"] != "howlite-test" {
+		t.Fatalf("Expected principal cdn and user agent howlite-test, got %v and %v", record["principal"], record["user_agent"])
+	}
+}
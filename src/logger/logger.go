@@ -3,62 +3,149 @@ package logger
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/inx51/howlite-resources/configuration"
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 )
 
-type Logger interface {
-	DebugContext(ctx context.Context, msg string, args ...any)
-	InfoContext(ctx context.Context, msg string, args ...any)
-	ErrorContext(ctx context.Context, msg string, args ...any)
-	WarnContext(ctx context.Context, msg string, args ...any)
-}
-
 var (
-	loggers = make([]Logger, 0)
+	handlers = make([]slog.Handler, 0)
+	level    = new(slog.LevelVar)
 )
 
+type contextArgsKey struct{}
+
 func init() {
 	if !isTestRun() {
-		registerSlog()
-		registerOtel()
+		handlers = append(handlers,
+			newRedactingHandler(slog.NewTextHandler(os.Stdout, nil), level, nil),
+			newRedactingHandler(otelslog.NewHandler("howlite-resources"), level, nil))
 	}
 }
 
-func registerSlog() {
-	loggers = append(loggers, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+func isTestRun() bool {
+	return flag.Lookup("test.v") != nil
 }
 
-func registerOtel() {
-	loggers = append(loggers, otelslog.NewLogger("howlite-resources"))
+// SetupLogger replaces the loggers registered on startup, which log at Info
+// level in text, with ones configured by the configuration. The level, output
+// format and redaction are applied to both stdout and OpenTelemetry.
+func SetupLogger(configuration *configuration.Logging) error {
+	if err := SetLevel(configuration.LEVEL); err != nil {
+		return err
+	}
+
+	handler, err := NewHandler(os.Stdout, configuration)
+	if err != nil {
+		return err
+	}
+
+	if isTestRun() {
+		return nil
+	}
+	handlers = []slog.Handler{
+		handler,
+		newRedactingHandler(
+			otelslog.NewHandler("howlite-resources", otelslog.WithSource(configuration.ADD_SOURCE)),
+			level,
+			configuration.REDACT_KEYS),
+	}
+	return nil
 }
 
-func isTestRun() bool {
-	return flag.Lookup("test.v") != nil
+// SetLevel changes the minimum level of the registered loggers, e.g. Debug,
+// Info, Warn or Error.
+func SetLevel(name string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("unsupported log level: %s", name)
+	}
+
+	level.Set(parsed)
+	return nil
 }
 
-func Debug(ctx context.Context, msg string, args ...interface{}) {
-	for _, logger := range loggers {
-		logger.DebugContext(ctx, msg, args...)
+// NewHandler returns a handler writing to writer in the format of the
+// configuration, whose records are redacted and filtered by the level set
+// through SetLevel.
+func NewHandler(writer io.Writer, configuration *configuration.Logging) (slog.Handler, error) {
+	options := &slog.HandlerOptions{
+		AddSource: configuration.ADD_SOURCE,
+		Level:     slog.LevelDebug,
+	}
+
+	switch strings.ToLower(configuration.FORMAT) {
+	case "text":
+		return newRedactingHandler(slog.NewTextHandler(writer, options), level, configuration.REDACT_KEYS), nil
+	case "json":
+		return newRedactingHandler(slog.NewJSONHandler(writer, options), level, configuration.REDACT_KEYS), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format: %s", configuration.FORMAT)
 	}
 }
 
-func Info(ctx context.Context, msg string, args ...interface{}) {
-	for _, logger := range loggers {
-		logger.InfoContext(ctx, msg, args...)
+// WithArgs returns a context whose args are added to every message logged
+// with it, e.g. the authenticated principal of a request.
+func WithArgs(ctx context.Context, args ...interface{}) context.Context {
+	contextArgs := append(argsFromContext(ctx), args...)
+	return context.WithValue(ctx, contextArgsKey{}, contextArgs)
+}
+
+func argsFromContext(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
 	}
+
+	contextArgs, _ := ctx.Value(contextArgsKey{}).([]interface{})
+	return contextArgs[:len(contextArgs):len(contextArgs)]
 }
 
-func Error(ctx context.Context, msg string, args ...interface{}) {
-	for _, logger := range loggers {
-		logger.ErrorContext(ctx, msg, args...)
+func withContextArgs(ctx context.Context, args []interface{}) []interface{} {
+	contextArgs := argsFromContext(ctx)
+	if len(contextArgs) == 0 {
+		return args
 	}
+
+	return append(contextArgs, args...)
+}
+
+func Debug(ctx context.Context, msg string, args ...interface{}) {
+	log(ctx, slog.LevelDebug, msg, args)
+}
+
+func Info(ctx context.Context, msg string, args ...interface{}) {
+	log(ctx, slog.LevelInfo, msg, args)
+}
+
+func Error(ctx context.Context, msg string, args ...interface{}) {
+	log(ctx, slog.LevelError, msg, args)
 }
 
 func Warn(ctx context.Context, msg string, args ...interface{}) {
-	for _, logger := range loggers {
-		logger.WarnContext(ctx, msg, args...)
+	log(ctx, slog.LevelWarn, msg, args)
+}
+
+// log hands the record to every registered handler, with the caller of Debug,
+// Info, Warn or Error as its source.
+func log(ctx context.Context, recordLevel slog.Level, msg string, args []interface{}) {
+	if recordLevel < level.Level() || len(handlers) == 0 {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	record := slog.NewRecord(time.Now(), recordLevel, msg, pcs[0])
+	record.Add(withContextArgs(ctx, args)...)
+	for _, handler := range handlers {
+		if handler.Enabled(ctx, recordLevel) {
+			handler.Handle(ctx, record.Clone())
+		}
 	}
 }
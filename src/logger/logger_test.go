@@ -0,0 +1,91 @@
+//go:build unit
+
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+)
+
+func newLogger(t *testing.T, configuration *configuration.Logging) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+	var buffer bytes.Buffer
+	handler, err := logger.NewHandler(&buffer, configuration)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return slog.New(handler), &buffer
+}
+
+func TestHandlerShouldFilterByLevel(t *testing.T) {
+	if err := logger.SetLevel("warn"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { logger.SetLevel("info") })
+	log, buffer := newLogger(t, &configuration.Logging{FORMAT: "text"})
+
+	log.Info("hidden")
+	log.Warn("shown")
+
+	if strings.Contains(buffer.String(), "hidden") || !strings.Contains(buffer.String(), "shown") {
+		t.Fatalf("Expected only the warning to be logged, got %s", buffer.String())
+	}
+}
+
+func TestHandlerShouldWriteJsonWithSource(t *testing.T) {
+	log, buffer := newLogger(t, &configuration.Logging{FORMAT: "json", ADD_SOURCE: true})
+
+	log.InfoContext(context.Background(), "message", "key", "value")
+
+	var record map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %v", err)
+	}
+	if record["msg"] != "message" || record["key"] != "value" {
+		t.Fatalf("Expected message and key, got %v", record)
+	}
+	source, _ := record["source"].(map[string]any)
+	if !strings.HasSuffix(source["file"].(string), "logger_test.go") {
+		t.Fatalf("Expected source in logger_test.go, got %v", record["source"])
+	}
+}
+
+func TestHandlerShouldRedactSensitiveAttributes(t *testing.T) {
+	log, buffer := newLogger(t, &configuration.Logging{FORMAT: "json"})
+	header := http.Header{"Authorization": {"Bearer abc"}, "Accept": {"*/*"}}
+
+	log.Info("message", "connection_string", "AccountKey=abc", "headers", header, slog.Group("s3", "secretKey", "abc", "bucket", "b"))
+
+	if strings.Contains(buffer.String(), "abc") {
+		t.Fatalf("Expected secrets to be redacted, got %s", buffer.String())
+	}
+	for _, expected := range []string{`"connection_string":"[REDACTED]"`, `"Authorization":["[REDACTED]"]`, `"Accept":["*/*"]`, `"bucket":"b"`} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Fatalf("Expected %s in %s", expected, buffer.String())
+		}
+	}
+}
+
+func TestHandlerShouldRedactConfiguredKeys(t *testing.T) {
+	log, buffer := newLogger(t, &configuration.Logging{FORMAT: "text", REDACT_KEYS: []string{"tenant"}})
+
+	log.Info("message", "tenant.id", "acme", "password", "visible")
+
+	if strings.Contains(buffer.String(), "acme") || !strings.Contains(buffer.String(), "visible") {
+		t.Fatalf("Expected only the configured keys to be redacted, got %s", buffer.String())
+	}
+}
+
+func TestNewHandlerShouldRejectUnknownFormat(t *testing.T) {
+	if _, err := logger.NewHandler(&bytes.Buffer{}, &configuration.Logging{FORMAT: "xml"}); err == nil {
+		t.Fatalf("Expected an error")
+	}
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+const redacted = "[REDACTED]"
+
+// defaultRedactKeys are redacted when no keys are configured.
+var defaultRedactKeys = []string{"authorization", "apikey", "connectionstring", "password", "secret", "token", "signature", "cookie"}
+
+// redactingHandler drops records below level and replaces the values of
+// attributes whose keys contain one of the sensitive keys, e.g. "Authorization"
+// or "connectionString". The keys of http.Header values are redacted as well.
+type redactingHandler struct {
+	handler slog.Handler
+	level   slog.Leveler
+	keys    []string
+}
+
+func newRedactingHandler(handler slog.Handler, level slog.Leveler, keys []string) *redactingHandler {
+	if len(keys) == 0 {
+		keys = defaultRedactKeys
+	}
+
+	normalized := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key := normalizeKey(key); key != "" {
+			normalized = append(normalized, key)
+		}
+	}
+
+	return &redactingHandler{
+		handler: handler,
+		level:   level,
+		keys:    normalized,
+	}
+}
+
+func (handler *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= handler.level.Level() && handler.handler.Enabled(ctx, level)
+}
+
+func (handler *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redactedRecord := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redactedRecord.AddAttrs(handler.redact(attr))
+		return true
+	})
+	return handler.handler.Handle(ctx, redactedRecord)
+}
+
+func (handler *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redactedAttrs = append(redactedAttrs, handler.redact(attr))
+	}
+
+	return &redactingHandler{
+		handler: handler.handler.WithAttrs(redactedAttrs),
+		level:   handler.level,
+		keys:    handler.keys,
+	}
+}
+
+func (handler *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{
+		handler: handler.handler.WithGroup(name),
+		level:   handler.level,
+		keys:    handler.keys,
+	}
+}
+
+func (handler *redactingHandler) redact(attr slog.Attr) slog.Attr {
+	if handler.sensitive(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		groupAttrs := value.Group()
+		redactedAttrs := make([]any, 0, len(groupAttrs))
+		for _, groupAttr := range groupAttrs {
+			redactedAttrs = append(redactedAttrs, handler.redact(groupAttr))
+		}
+		return slog.Group(attr.Key, redactedAttrs...)
+	case slog.KindAny:
+		if header, ok := value.Any().(http.Header); ok {
+			return slog.Any(attr.Key, handler.redactHeader(header))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+func (handler *redactingHandler) redactHeader(header http.Header) http.Header {
+	redactedHeader := make(http.Header, len(header))
+	for key, values := range header {
+		if handler.sensitive(key) {
+			redactedHeader[key] = []string{redacted}
+			continue
+		}
+		redactedHeader[key] = values
+	}
+	return redactedHeader
+}
+
+func (handler *redactingHandler) sensitive(key string) bool {
+	key = normalizeKey(key)
+	for _, sensitiveKey := range handler.keys {
+		if strings.Contains(key, sensitiveKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeKey lower cases the key and drops everything but letters and
+// digits, so "Connection-String" and "connection_string" are the same key.
+func normalizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, key)
+}
@@ -4,10 +4,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/inx51/howlite-resources/configuration"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/telemetry"
 )
@@ -16,16 +21,48 @@ func main() {
 	ctx := context.Background()
 	application := NewApplication()
 
+	args, err := parseArguments(os.Args[1:], os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	//Configurations
-	configurations := application.ConfigureConfigurations(ctx)
+	configurations, err := application.ConfigureConfigurations(ctx, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if args.command == "config print" {
+		printConfiguration(configurations)
+		return
+	}
+	if err := configurations.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := logger.SetupLogger(&configurations.LOGGING); err != nil {
+		panic(err)
+	}
+	if args.command == "dead-letters list" || args.command == "dead-letters replay" {
+		if err := runDeadLettersCommand(ctx, args, configurations, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	//Telemetry
+	telemetry.SetupPropagetor()
 	otelEnabled := telemetry.IsEnabled()
 	if otelEnabled {
 		telemetry.SetupLogging(ctx)
-		telemetry.SetupMetric(ctx)
 		telemetry.SetupTracing(ctx, &configurations.TRACING)
 	}
+	telemetry.SetupMetric(ctx, otelEnabled, &configurations.METRICS)
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 	application.ConfigureContainer(ctx)
@@ -37,9 +74,22 @@ func main() {
 	defer shutdownCancel()
 	application.Shutdown(shutdownContext)
 	logger.Info(shutdownContext, "Application shutdown gracefully")
+	telemetry.ShutdownMetrics(shutdownContext)
 	if otelEnabled {
-		telemetry.ShutdownMetrics(shutdownContext)
 		telemetry.ShutdownTracing(shutdownContext)
 		telemetry.ShutdownLogging(shutdownContext)
 	}
 }
+
+// printConfiguration writes the effective configuration to stdout with its
+// secrets redacted, followed by the problems found in it, if any.
+func printConfiguration(configurations *configuration.Configuration) {
+	if err := configurations.WriteYaml(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := configurations.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
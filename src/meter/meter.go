@@ -2,13 +2,32 @@ package meter
 
 import (
 	"context"
+	"sync"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
 )
 
+// Instrument describes an instrument, the unit, description and buckets are
+// only used when the instrument is created on its first use.
+type Instrument struct {
+	Name        string
+	Unit        string
+	Description string
+	// Buckets are the explicit bucket boundaries of a histogram, the default
+	// boundaries of the SDK are used if empty.
+	Buckets []float64
+}
+
 var meter metric.Meter
+var mutex sync.Mutex
 var int64Counters map[string]metric.Int64Counter = make(map[string]metric.Int64Counter)
+var int64UpDownCounters map[string]metric.Int64UpDownCounter = make(map[string]metric.Int64UpDownCounter)
+var int64Histograms map[string]metric.Int64Histogram = make(map[string]metric.Int64Histogram)
+var float64Histograms map[string]metric.Float64Histogram = make(map[string]metric.Float64Histogram)
+var int64Gauges map[string]metric.Int64Gauge = make(map[string]metric.Int64Gauge)
+var gaugeMutex sync.Mutex
+var int64GaugeValues map[string]int64 = make(map[string]int64)
 var enabled bool = false
 
 func SetupMeter(enable bool) {
@@ -21,13 +40,118 @@ func SetupMeter(enable bool) {
 	meter = otel.Meter("howlite.resources")
 }
 
+// instrument returns the instrument with the given name, creating it on first
+// use. Instruments that fail to be created are replaced by the ones of the
+// noop meter the SDK returns alongside the error.
+func instrument[T any](instruments map[string]T, name string, create func() (T, error)) T {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	existing, found := instruments[name]
+	if !found {
+		existing, _ = create()
+		instruments[name] = existing
+	}
+	return existing
+}
+
 func ArithmeticInt64Counter(ctx context.Context, counterName string, change int64, options ...metric.AddOption) {
 	if !enabled {
 		return
 	}
 
-	if int64Counters[counterName] == nil {
-		int64Counters[counterName], _ = meter.Int64Counter(counterName)
+	counter := instrument(int64Counters, counterName, func() (metric.Int64Counter, error) {
+		return meter.Int64Counter(counterName)
+	})
+	counter.Add(ctx, change, options...)
+}
+
+func ArithmeticInt64UpDownCounter(ctx context.Context, instrumentDescription Instrument, change int64, options ...metric.AddOption) {
+	if !enabled {
+		return
+	}
+
+	counter := instrument(int64UpDownCounters, instrumentDescription.Name, func() (metric.Int64UpDownCounter, error) {
+		return meter.Int64UpDownCounter(
+			instrumentDescription.Name,
+			metric.WithUnit(instrumentDescription.Unit),
+			metric.WithDescription(instrumentDescription.Description))
+	})
+	counter.Add(ctx, change, options...)
+}
+
+func RecordInt64Histogram(ctx context.Context, instrumentDescription Instrument, value int64, options ...metric.RecordOption) {
+	if !enabled {
+		return
 	}
-	int64Counters[counterName].Add(ctx, change, options...)
+
+	histogram := instrument(int64Histograms, instrumentDescription.Name, func() (metric.Int64Histogram, error) {
+		histogramOptions := []metric.Int64HistogramOption{
+			metric.WithUnit(instrumentDescription.Unit),
+			metric.WithDescription(instrumentDescription.Description),
+		}
+		if len(instrumentDescription.Buckets) > 0 {
+			histogramOptions = append(histogramOptions, metric.WithExplicitBucketBoundaries(instrumentDescription.Buckets...))
+		}
+		return meter.Int64Histogram(instrumentDescription.Name, histogramOptions...)
+	})
+	histogram.Record(ctx, value, options...)
+}
+
+func RecordFloat64Histogram(ctx context.Context, instrumentDescription Instrument, value float64, options ...metric.RecordOption) {
+	if !enabled {
+		return
+	}
+
+	histogram := instrument(float64Histograms, instrumentDescription.Name, func() (metric.Float64Histogram, error) {
+		histogramOptions := []metric.Float64HistogramOption{
+			metric.WithUnit(instrumentDescription.Unit),
+			metric.WithDescription(instrumentDescription.Description),
+		}
+		if len(instrumentDescription.Buckets) > 0 {
+			histogramOptions = append(histogramOptions, metric.WithExplicitBucketBoundaries(instrumentDescription.Buckets...))
+		}
+		return meter.Float64Histogram(instrumentDescription.Name, histogramOptions...)
+	})
+	histogram.Record(ctx, value, options...)
+}
+
+// RecordInt64Gauge records the value of a gauge, the value is also the one
+// later changes made through ArithmeticInt64Gauge are applied to.
+func RecordInt64Gauge(ctx context.Context, instrumentDescription Instrument, value int64, options ...metric.RecordOption) {
+	if !enabled {
+		return
+	}
+
+	gauge := int64Gauge(instrumentDescription)
+
+	gaugeMutex.Lock()
+	defer gaugeMutex.Unlock()
+	int64GaugeValues[instrumentDescription.Name] = value
+	gauge.Record(ctx, value, options...)
+}
+
+// ArithmeticInt64Gauge changes the value of a gauge by the given amount and
+// records the new value. The value starts at zero unless it has been recorded
+// through RecordInt64Gauge.
+func ArithmeticInt64Gauge(ctx context.Context, instrumentDescription Instrument, change int64) {
+	if !enabled {
+		return
+	}
+
+	gauge := int64Gauge(instrumentDescription)
+
+	gaugeMutex.Lock()
+	defer gaugeMutex.Unlock()
+	int64GaugeValues[instrumentDescription.Name] += change
+	gauge.Record(ctx, int64GaugeValues[instrumentDescription.Name])
+}
+
+func int64Gauge(instrumentDescription Instrument) metric.Int64Gauge {
+	return instrument(int64Gauges, instrumentDescription.Name, func() (metric.Int64Gauge, error) {
+		return meter.Int64Gauge(
+			instrumentDescription.Name,
+			metric.WithUnit(instrumentDescription.Unit),
+			metric.WithDescription(instrumentDescription.Description))
+	})
 }
@@ -0,0 +1,35 @@
+//go:build unit
+
+package meter_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inx51/howlite-resources/meter"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestArithmeticInt64GaugeShouldChangeRecordedValue(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	meter.SetupMeter(true)
+	t.Cleanup(func() { meter.SetupMeter(false) })
+	ctx := context.Background()
+	gauge := meter.Instrument{Name: "test_gauge_value"}
+
+	meter.RecordInt64Gauge(ctx, gauge, 10)
+	meter.ArithmeticInt64Gauge(ctx, gauge, 2)
+	meter.ArithmeticInt64Gauge(ctx, gauge, -5)
+
+	var collected metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &collected); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	value := collected.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0].Value
+	if value != 7 {
+		t.Fatalf("Expected 7, got %d", value)
+	}
+}
@@ -0,0 +1,81 @@
+package meter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// routes maps resource identifiers to the bounded set of route templates that
+// metrics are labeled with, instead of the identifiers themselves.
+var routes = struct {
+	mutex    sync.RWMutex
+	segments int
+	patterns [][]string
+}{segments: 1}
+
+// SetupRoutes configures the route templates. An identifier matching one of the
+// patterns is labeled with that pattern, in a pattern "*" matches a single path
+// segment and a trailing "**" matches any number of segments. Other identifiers
+// are labeled with their first segments, up to the given number of directory
+// segments, followed by "/**".
+func SetupRoutes(segments int, patterns []string) error {
+	if segments < 0 {
+		return fmt.Errorf("route segments must not be negative, got %d", segments)
+	}
+
+	parsed := make([][]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("route pattern %q must start with /", pattern)
+		}
+		patternSegments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+		for i, segment := range patternSegments {
+			if segment == "**" && i != len(patternSegments)-1 {
+				return fmt.Errorf("route pattern %q may only end with **", pattern)
+			}
+		}
+		parsed = append(parsed, patternSegments)
+	}
+
+	routes.mutex.Lock()
+	defer routes.mutex.Unlock()
+	routes.segments = segments
+	routes.patterns = parsed
+	return nil
+}
+
+// Route returns the route template of a resource identifier.
+func Route(identifier string) string {
+	routes.mutex.RLock()
+	defer routes.mutex.RUnlock()
+
+	segments := strings.Split(strings.TrimPrefix(identifier, "/"), "/")
+	for _, pattern := range routes.patterns {
+		if matchRoute(pattern, segments) {
+			return "/" + strings.Join(pattern, "/")
+		}
+	}
+
+	directories := segments[:len(segments)-1]
+	if len(directories) > routes.segments {
+		directories = directories[:routes.segments]
+	}
+	if len(directories) == 0 {
+		return "/**"
+	}
+	return "/" + strings.Join(directories, "/") + "/**"
+}
+
+func matchRoute(pattern []string, segments []string) bool {
+	for i, patternSegment := range pattern {
+		if patternSegment == "**" {
+			return true
+		}
+		if i >= len(segments) || (patternSegment != "*" && patternSegment != segments[i]) {
+			return false
+		}
+	}
+
+	return len(pattern) == len(segments)
+}
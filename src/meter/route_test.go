@@ -0,0 +1,53 @@
+//go:build unit
+
+package meter_test
+
+import (
+	"testing"
+
+	"github.com/inx51/howlite-resources/meter"
+)
+
+func TestRouteShouldUseFirstDirectorySegments(t *testing.T) {
+	if err := meter.SetupRoutes(2, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cases := map[string]string{
+		"/a.txt":             "/**",
+		"/images/a.png":      "/images/**",
+		"/images/2024/a.png": "/images/2024/**",
+		"/images/2024/1/a":   "/images/2024/**",
+	}
+	for identifier, expected := range cases {
+		if route := meter.Route(identifier); route != expected {
+			t.Fatalf("Expected %s for %s, got %s", expected, identifier, route)
+		}
+	}
+}
+
+func TestRouteShouldPreferMatchingPattern(t *testing.T) {
+	if err := meter.SetupRoutes(1, []string{"/tenants/*/images/**", "/static/*"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cases := map[string]string{
+		"/tenants/42/images/a/b.png": "/tenants/*/images/**",
+		"/tenants/42/docs/a.pdf":     "/tenants/**",
+		"/static/app.js":             "/static/*",
+		"/static/js/app.js":          "/static/**",
+	}
+	for identifier, expected := range cases {
+		if route := meter.Route(identifier); route != expected {
+			t.Fatalf("Expected %s for %s, got %s", expected, identifier, route)
+		}
+	}
+}
+
+func TestSetupRoutesShouldRejectInvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"tenants/*", "/**/images"} {
+		if err := meter.SetupRoutes(1, []string{pattern}); err == nil {
+			t.Fatalf("Expected an error for %s", pattern)
+		}
+	}
+}
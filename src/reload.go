@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/http/authz"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/meter"
+	"github.com/inx51/howlite-resources/tracer"
+)
+
+// Reload re-reads and validates the configuration and applies its reloadable
+// parts, see copyReloadable. Nothing is applied if the configuration is invalid
+// or any of the files it references fails to load. Changes to other parts are
+// reported as requiring a restart.
+func (app *Application) Reload(ctx context.Context) error {
+	app.reloadMutex.Lock()
+	defer app.reloadMutex.Unlock()
+
+	configuration.ConfigureEnvFiles()
+	next, err := configuration.LoadConfiguration(app.configFile(), app.arguments.overrides)
+	if err != nil {
+		return err
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	if err := app.container.reload(ctx, next); err != nil {
+		return err
+	}
+
+	applied := *app.configuration
+	copyReloadable(&applied, next)
+	if sections := changedSections(&applied, next); len(sections) > 0 {
+		logger.Warn(ctx, "Configuration changes require a restart to take effect", "sections", sections)
+	}
+	app.configuration = &applied
+	return nil
+}
+
+// copyReloadable copies the parts of the configuration that are applied by a
+// reload from next to running.
+func copyReloadable(running *configuration.Configuration, next *configuration.Configuration) {
+	running.LOGGING.LEVEL = next.LOGGING.LEVEL
+	running.TRACING.LEVEL = next.TRACING.LEVEL
+	running.METRICS.ROUTE_SEGMENTS = next.METRICS.ROUTE_SEGMENTS
+	running.METRICS.ROUTE_PATTERNS = next.METRICS.ROUTE_PATTERNS
+	running.AUTHENTICATION = next.AUTHENTICATION
+	running.AUTHORIZATION = next.AUTHORIZATION
+}
+
+// changedSections returns the names of the top level sections that differ.
+func changedSections(running *configuration.Configuration, next *configuration.Configuration) []string {
+	runningValue := reflect.ValueOf(running).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+
+	var sections []string
+	for i := 0; i < runningValue.NumField(); i++ {
+		if !reflect.DeepEqual(runningValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			sections = append(sections, runningValue.Type().Field(i).Name)
+		}
+	}
+	return sections
+}
+
+// reload loads the authenticators and the authorization policy of the
+// configuration before swapping them in, along with the log and tracing levels
+// and the metric routes, which Validate has already checked. In-flight requests
+// finish with the previous authenticators and policy.
+func (container *Container) reload(ctx context.Context, configuration *configuration.Configuration) error {
+	authenticators, err := buildAuthenticators(ctx, configuration.AUTHENTICATION)
+	if err != nil {
+		return fmt.Errorf("failed to load authenticators: %w", err)
+	}
+	if (container.auth == nil) != (len(authenticators) == 0) {
+		return errors.New("enabling or disabling authentication requires a restart")
+	}
+
+	if (container.authz == nil) != (configuration.AUTHORIZATION.POLICY_PATH == "") {
+		return errors.New("enabling or disabling authorization requires a restart")
+	}
+	var authorizer *authz.Authorizer
+	var reloadInterval time.Duration
+	if container.authz != nil {
+		authorizer, err = authz.NewAuthorizer(configuration.AUTHORIZATION.POLICY_PATH)
+		if err != nil {
+			return fmt.Errorf("failed to load authorization policy: %w", err)
+		}
+		reloadInterval, err = time.ParseDuration(configuration.AUTHORIZATION.RELOAD_INTERVAL)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := logger.SetLevel(configuration.LOGGING.LEVEL); err != nil {
+		return err
+	}
+	tracer.SetLevel(configuration.TRACING.LEVEL)
+	if err := meter.SetupRoutes(configuration.METRICS.ROUTE_SEGMENTS, configuration.METRICS.ROUTE_PATTERNS); err != nil {
+		return err
+	}
+	if container.auth != nil {
+		container.auth.Replace(authenticators...)
+	}
+	if container.authz != nil {
+		container.authz.Replace(authorizer)
+		container.authzWorker.Reset(reloadInterval)
+	}
+
+	logger.Info(ctx, "Configuration reloaded", "logLevel", configuration.LOGGING.LEVEL, "tracingLevel", configuration.TRACING.LEVEL, "authenticators", len(authenticators))
+	return nil
+}
+
+// reloadWorker reloads the configuration whenever the process receives SIGHUP
+// and, if interval is set, whenever one of the configuration files changes.
+type reloadWorker struct {
+	app      *Application
+	interval time.Duration
+	hangups  chan os.Signal
+	files    map[string]fileState
+	done     chan struct{}
+}
+
+type fileState struct {
+	modifiedUtc time.Time
+	size        int64
+}
+
+func newReloadWorker(app *Application, interval time.Duration) *reloadWorker {
+	worker := &reloadWorker{
+		app:      app,
+		interval: interval,
+		hangups:  make(chan os.Signal, 1),
+		files:    make(map[string]fileState),
+		done:     make(chan struct{}),
+	}
+	worker.filesChanged()
+	signal.Notify(worker.hangups, syscall.SIGHUP)
+	return worker
+}
+
+func (worker *reloadWorker) Start(ctx context.Context) {
+	defer close(worker.done)
+
+	var ticks <-chan time.Time
+	if worker.interval > 0 {
+		ticker := time.NewTicker(worker.interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "Configuration reload worker stopped")
+			return
+		case <-worker.hangups:
+			logger.Info(ctx, "Received SIGHUP, reloading configuration")
+			worker.reload(ctx)
+		case <-ticks:
+			if worker.filesChanged() {
+				logger.Info(ctx, "Configuration files changed, reloading configuration")
+				worker.reload(ctx)
+			}
+		}
+	}
+}
+
+func (worker *reloadWorker) reload(ctx context.Context) {
+	if err := worker.app.Reload(ctx); err != nil {
+		logger.Error(ctx, "Failed to reload configuration, keeping the current configuration", "error", err)
+	}
+}
+
+// filesChanged records the state of the configuration files and returns
+// whether any of them changed since the last call.
+func (worker *reloadWorker) filesChanged() bool {
+	changed := false
+	for _, path := range []string{worker.app.configFile(), ".env", ".local.env"} {
+		if path == "" {
+			continue
+		}
+
+		var state fileState
+		if info, err := os.Stat(path); err == nil {
+			state = fileState{modifiedUtc: info.ModTime(), size: info.Size()}
+		}
+		if previous, found := worker.files[path]; found && previous != state {
+			changed = true
+		}
+		worker.files[path] = state
+	}
+	return changed
+}
+
+func (worker *reloadWorker) Stop(ctx context.Context) {
+	signal.Stop(worker.hangups)
+	select {
+	case <-worker.done:
+	case <-ctx.Done():
+		logger.Warn(ctx, "Configuration reload worker did not stop in time")
+	}
+}
@@ -1,13 +1,18 @@
 package resource
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
+	"time"
 )
 
 type Resource struct {
 	Identifier *ResourceIdentifier
 	Headers    *ResourceHeaders
 	Body       *io.ReadCloser
+	Properties *ResourceProperties
 }
 
 func NewResource(identifier *ResourceIdentifier, body *io.ReadCloser) *Resource {
@@ -15,21 +20,47 @@ func NewResource(identifier *ResourceIdentifier, body *io.ReadCloser) *Resource
 		Identifier: identifier,
 		Body:       body,
 		Headers:    NewResourceHeaders(),
+		Properties: NewResourceProperties(identifier),
 	}
 }
 
 func (resource *Resource) Write(writer io.WriteCloser) error {
+	return resource.write(writer, false)
+}
+
+// WriteWithProperties writes the resource like Write, followed by a trailer
+// holding its properties, for storage providers that can't attach the
+// properties to a stored object once its body has been written.
+func (resource *Resource) WriteWithProperties(writer io.WriteCloser) error {
+	return resource.write(writer, true)
+}
+
+func (resource *Resource) write(writer io.WriteCloser, withProperties bool) error {
 	if err := resource.Headers.writeHeaders(writer); err != nil {
 		return err
 	}
 
+	hash := sha256.New()
 	buff := make([]byte, 1024)
-	readCloser := io.NopCloser(*resource.Body)
-	_, err := io.CopyBuffer(writer, readCloser, buff)
+	readCloser := io.NopCloser(io.TeeReader(*resource.Body, hash))
+	contentLength, err := io.CopyBuffer(writer, readCloser, buff)
 	if err != nil {
 		return err
 	}
 
+	// Properties must be set before the writer is closed, storage providers
+	// streaming through a pipe read them as soon as the upload completes.
+	resource.Properties.ContentHash = hex.EncodeToString(hash.Sum(nil))
+	resource.Properties.ContentLength = contentLength
+	resource.Properties.ContentType = resource.Headers.Get("Content-Type")
+	resource.Properties.LastModified = time.Now().UTC()
+
+	if withProperties {
+		if err := resource.Properties.writeTrailer(writer); err != nil {
+			return err
+		}
+	}
+
 	err = writer.Close()
 	if err != nil {
 		return err
@@ -49,7 +80,70 @@ func LoadResource(resourceIdentifier *ResourceIdentifier, reader io.ReadCloser)
 		Identifier: resourceIdentifier,
 		Headers:    resourceHeaders,
 		Body:       &reader,
+		Properties: NewResourceProperties(resourceIdentifier),
 	}
 
 	return resource, err
 }
+
+// ReadAtCloser is a stored resource that can be read from any offset, such as
+// a file.
+type ReadAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// LoadStoredResource loads a resource of the given size, along with the
+// properties from its trailer. Resources stored without a properties trailer
+// are loaded with ErrNoPropertiesTrailer, their body is read to the end.
+func LoadStoredResource(resourceIdentifier *ResourceIdentifier, reader ReadAtCloser, size int64) (*Resource, error) {
+	properties, trailerSize, trailerErr := loadPropertiesTrailerAt(reader, size)
+	if trailerErr != nil && !errors.Is(trailerErr, ErrNoPropertiesTrailer) {
+		return nil, trailerErr
+	}
+
+	section := struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(reader, 0, size-trailerSize), reader}
+	resource, err := LoadResource(resourceIdentifier, section)
+	if err != nil {
+		return nil, err
+	}
+	if properties != nil {
+		resource.Properties = properties
+	}
+
+	return resource, trailerErr
+}
+
+func loadPropertiesTrailerAt(reader io.ReaderAt, size int64) (*ResourceProperties, int64, error) {
+	tail := make([]byte, min(size, PropertiesTrailerReadSize))
+	if err := readAtFull(reader, tail, size-int64(len(tail))); err != nil {
+		return nil, 0, err
+	}
+
+	properties, trailerSize, err := LoadPropertiesTrailer(tail)
+	if err != nil || properties != nil {
+		return properties, trailerSize, err
+	}
+	if trailerSize > size {
+		return nil, 0, ErrNoPropertiesTrailer
+	}
+
+	tail = make([]byte, trailerSize)
+	if err := readAtFull(reader, tail, size-trailerSize); err != nil {
+		return nil, 0, err
+	}
+	return LoadPropertiesTrailer(tail)
+}
+
+// readAtFull reads len(buffer) bytes at offset, ReadAt may return io.EOF
+// along with the last bytes.
+func readAtFull(reader io.ReaderAt, buffer []byte, offset int64) error {
+	read, err := reader.ReadAt(buffer, offset)
+	if read == len(buffer) {
+		return nil
+	}
+	return err
+}
@@ -4,6 +4,7 @@ package resource_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"io"
 	"strings"
@@ -56,6 +57,45 @@ func TestWriteShouldWriteContentToWriter(t *testing.T) {
 	}
 }
 
+func TestWriteShouldSetContentHashAndLastModified(t *testing.T) {
+	identifier := resource.NewResourceIdentifier("test")
+	body := io.NopCloser(strings.NewReader("test content"))
+	res := resource.NewResource(identifier, &body)
+	var buf bytes.Buffer
+	writer := &testWriteCloser{&buf}
+
+	err := res.Write(writer)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedHash := "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+	if res.Properties.ContentHash != expectedHash {
+		t.Fatalf("Expected content hash %s, got %s", expectedHash, res.Properties.ContentHash)
+	}
+	if res.Properties.LastModified.IsZero() {
+		t.Fatal("Expected last modified to be set")
+	}
+}
+
+func TestWriteShouldSetContentType(t *testing.T) {
+	identifier := resource.NewResourceIdentifier("test")
+	body := io.NopCloser(strings.NewReader("test content"))
+	res := resource.NewResource(identifier, &body)
+	res.Headers.Add(context.Background(), "Content-Type", []string{"text/plain"})
+	var buf bytes.Buffer
+	writer := &testWriteCloser{&buf}
+
+	err := res.Write(writer)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if res.Properties.ContentType != "text/plain" {
+		t.Fatalf("Expected content type 'text/plain', got %s", res.Properties.ContentType)
+	}
+}
+
 func TestLoadResourceShouldLoadWithHeaders(t *testing.T) {
 	identifier := resource.NewResourceIdentifier("test")
 	headers := map[string][]string{"Type": {"json"}}
@@ -3,8 +3,8 @@ package resource
 import (
 	"context"
 	"encoding/binary"
-	"fmt"
 	"io"
+	"net/textproto"
 	"slices"
 	"strings"
 
@@ -12,6 +12,10 @@ import (
 	"github.com/vmihailenco/msgpack/v5"
 )
 
+// HeadersLengthPrefixSize is the size of the length prefix preceding the
+// msgpack encoded headers at the start of a stored resource.
+const HeadersLengthPrefixSize = 8
+
 type ResourceHeaders struct {
 	headers map[string][]string
 }
@@ -31,6 +35,17 @@ func (resourceHeaders *ResourceHeaders) Add(ctx context.Context, key string, val
 	resourceHeaders.headers[key] = values
 }
 
+// Get returns the first value of the given header, or an empty string if the
+// header has not been stored.
+func (resourceHeaders *ResourceHeaders) Get(key string) string {
+	values := resourceHeaders.headers[textproto.CanonicalMIMEHeaderKey(key)]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
 func (resourceHeaders *ResourceHeaders) Headers() *map[string][]string {
 	return &resourceHeaders.headers
 }
@@ -45,6 +60,24 @@ func (resourceHeaders *ResourceHeaders) LoadHeaders(reader io.ReadCloser) error
 	return nil
 }
 
+// HeadersSectionLength reads the length prefix at the start of a stored
+// resource and returns the size of the whole headers section, which is the
+// offset at which the resource body starts.
+func HeadersSectionLength(reader io.ReadCloser) (int64, error) {
+	headersLength, err := getHeadersLengthFromStream(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(headersLength) + HeadersLengthPrefixSize, nil
+}
+
+// IsStoredHeader reports whether a request header is stored with the resource
+// when it is added, or filtered as reserved.
+func IsStoredHeader(headerName string) bool {
+	return !isReservedResponseHeader(headerName)
+}
+
 func isReservedResponseHeader(headerName string) bool {
 	var reservedResponseHeaders = []string{
 		"content-length",
@@ -67,6 +100,8 @@ func isReservedResponseHeader(headerName string) bool {
 		"proxy-authenticate",
 		"authorization",
 		"proxy-authorization",
+		"x-api-key",
+		"cookie",
 		"age",
 		"cache-control",
 		"expires",
@@ -117,7 +152,7 @@ func isReservedResponseHeader(headerName string) bool {
 func (resourceHeaders *ResourceHeaders) writeHeaders(writer io.Writer) error {
 	headersLength := len(resourceHeaders.headers)
 	if headersLength == 0 {
-		writer.Write(make([]byte, 8))
+		writer.Write(make([]byte, HeadersLengthPrefixSize))
 	} else {
 		msgPackedHeaders, err := msgpack.Marshal(resourceHeaders.headers)
 		if err != nil {
@@ -142,7 +177,7 @@ func getHeadersFromStream(stream io.ReadCloser) (*map[string][]string, error) {
 	}
 
 	headersBytes := make([]byte, headersLength)
-	_, err = stream.Read(headersBytes)
+	_, err = io.ReadFull(stream, headersBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -156,13 +191,12 @@ func getHeadersFromStream(stream io.ReadCloser) (*map[string][]string, error) {
 }
 
 func getHeadersLengthFromStream(stream io.ReadCloser) (uint64, error) {
-	headerLengthBytes := make([]byte, 8)
-	_, err := stream.Read(headerLengthBytes)
+	headerLengthBytes := make([]byte, HeadersLengthPrefixSize)
+	_, err := io.ReadFull(stream, headerLengthBytes)
 	if err != nil {
 		return 0, err
 	}
 
 	length := binary.LittleEndian.Uint64(headerLengthBytes)
-	fmt.Println(length)
 	return length, nil
 }
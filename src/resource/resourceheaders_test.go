@@ -123,6 +123,8 @@ func TestAddShouldBlockReservedHeaders(t *testing.T) {
 		{"CONTENT-LENGTH uppercase", "CONTENT-LENGTH", []string{"100"}, true},
 		{"Set-Cookie mixed case", "Set-Cookie", []string{"session=abc123"}, true},
 		{"SERVER uppercase", "SERVER", []string{"apache"}, true},
+		{"x-api-key", "x-api-key", []string{"s3cr3t"}, true},
+		{"cookie", "cookie", []string{"session=abc123"}, true},
 	}
 
 	for _, tc := range testCases {
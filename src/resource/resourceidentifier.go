@@ -5,6 +5,10 @@ import (
 	"encoding/base64"
 )
 
+// UniqueFilenameSuffix ends the unique filename of every resource, which tells
+// them apart from other files stored next to them.
+const UniqueFilenameSuffix = ".bin"
+
 type ResourceIdentifier struct {
 	identifier string
 }
@@ -20,6 +24,14 @@ func (resourceIdentifier *ResourceIdentifier) Identifier() string {
 }
 
 func (resourceIdentifier *ResourceIdentifier) ToUniqueFilename() string {
+	return resourceIdentifier.uniqueName() + UniqueFilenameSuffix
+}
+
+func (resourceIdentifier *ResourceIdentifier) ToUniquePropertiesFilename() string {
+	return resourceIdentifier.uniqueName() + ".properties"
+}
+
+func (resourceIdentifier *ResourceIdentifier) uniqueName() string {
 	var encBytes = md5.Sum([]byte(resourceIdentifier.Identifier()))
-	return base64.URLEncoding.EncodeToString(encBytes[:]) + ".bin"
+	return base64.URLEncoding.EncodeToString(encBytes[:])
 }
@@ -0,0 +1,133 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// PropertiesTrailerReadSize is how much of the end of a stored resource is
+// read to load its properties trailer at once, which fits the properties of
+// all but resources with unusually long identifiers.
+const PropertiesTrailerReadSize = 4096
+
+// PropertiesTrailerFooterSize is the size of the length and magic number
+// ending the properties trailer of a stored resource.
+const PropertiesTrailerFooterSize = 16
+
+// propertiesTrailerMagic ends every properties trailer, it tells resources
+// stored with their properties apart from those stored before.
+var propertiesTrailerMagic = []byte("HWLPROPS")
+
+// ErrNoPropertiesTrailer is returned by LoadPropertiesTrailer for resources
+// stored without a properties trailer.
+var ErrNoPropertiesTrailer = errors.New("resource has no properties trailer")
+
+// ResourceProperties holds the server computed metadata of a stored resource.
+// It is stored together with the resource by each storage provider, once the
+// resource body has been fully written.
+type ResourceProperties struct {
+	Identifier    string    `msgpack:"identifier"`
+	ContentHash   string    `msgpack:"content_hash"`
+	ContentLength int64     `msgpack:"content_length"`
+	ContentType   string    `msgpack:"content_type"`
+	LastModified  time.Time `msgpack:"last_modified"`
+	// Revision identifies the stored version of the resource to the storage
+	// provider, such as the ETag of an object, for conditional saves. Unlike
+	// ETag it changes whenever the resource is saved.
+	Revision string `msgpack:"revision,omitempty"`
+}
+
+func NewResourceProperties(identifier *ResourceIdentifier) *ResourceProperties {
+	return &ResourceProperties{
+		Identifier: identifier.Identifier(),
+	}
+}
+
+// ETag returns the strong entity tag for the resource, or an empty string if
+// no content hash has been recorded (e.g. resources stored before properties
+// were introduced).
+func (properties *ResourceProperties) ETag() string {
+	if properties.ContentHash == "" {
+		return ""
+	}
+
+	return "\"" + properties.ContentHash + "\""
+}
+
+func (properties *ResourceProperties) Marshal() ([]byte, error) {
+	return msgpack.Marshal(properties)
+}
+
+func (properties *ResourceProperties) Write(writer io.WriteCloser) error {
+	msgPackedProperties, err := properties.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(msgPackedProperties)
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func LoadResourceProperties(reader io.ReadCloser) (*ResourceProperties, error) {
+	defer reader.Close()
+
+	var properties ResourceProperties
+	err := msgpack.NewDecoder(reader).Decode(&properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return &properties, nil
+}
+
+// writeTrailer appends the properties to a stored resource, followed by their
+// length and a magic number, so that they can be read from its end.
+func (properties *ResourceProperties) writeTrailer(writer io.Writer) error {
+	msgPackedProperties, err := properties.Marshal()
+	if err != nil {
+		return err
+	}
+
+	footer := make([]byte, PropertiesTrailerFooterSize)
+	binary.LittleEndian.PutUint64(footer, uint64(len(msgPackedProperties)))
+	copy(footer[8:], propertiesTrailerMagic)
+
+	_, err = writer.Write(append(msgPackedProperties, footer...))
+	return err
+}
+
+// LoadPropertiesTrailer loads the properties trailer from tail, the last bytes
+// of a stored resource, and returns the size of the whole trailer. If tail is
+// shorter than the trailer the properties are nil, and the returned number of
+// bytes must be read from the end instead.
+func LoadPropertiesTrailer(tail []byte) (*ResourceProperties, int64, error) {
+	if len(tail) < PropertiesTrailerFooterSize || !bytes.Equal(tail[len(tail)-8:], propertiesTrailerMagic) {
+		return nil, 0, ErrNoPropertiesTrailer
+	}
+
+	propertiesLength := binary.LittleEndian.Uint64(tail[len(tail)-PropertiesTrailerFooterSize:])
+	if propertiesLength > 1<<20 {
+		return nil, 0, ErrNoPropertiesTrailer
+	}
+	trailerSize := int64(propertiesLength) + PropertiesTrailerFooterSize
+	if int64(len(tail)) < trailerSize {
+		return nil, trailerSize, nil
+	}
+
+	var properties ResourceProperties
+	start := int64(len(tail)) - trailerSize
+	if err := msgpack.Unmarshal(tail[start:start+int64(propertiesLength)], &properties); err != nil {
+		return nil, 0, err
+	}
+
+	return &properties, trailerSize, nil
+}
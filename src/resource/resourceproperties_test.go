@@ -0,0 +1,46 @@
+//go:build unit
+
+package resource_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/resource"
+)
+
+func TestLoadResourcePropertiesShouldLoadWrittenProperties(t *testing.T) {
+	properties := resource.NewResourceProperties(resource.NewResourceIdentifier("/test"))
+	properties.ContentHash = "abc123"
+	properties.LastModified = time.Date(2025, 12, 9, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	_ = properties.Write(&testWriteCloser{&buf})
+
+	loaded, err := resource.LoadResourceProperties(io.NopCloser(bytes.NewReader(buf.Bytes())))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if loaded.Identifier != "/test" || loaded.ContentHash != "abc123" || !loaded.LastModified.Equal(properties.LastModified) {
+		t.Fatalf("Expected properties to be loaded correctly, got %+v", loaded)
+	}
+}
+
+func TestETagShouldBeQuotedContentHash(t *testing.T) {
+	properties := resource.NewResourceProperties(resource.NewResourceIdentifier("/test"))
+	properties.ContentHash = "abc123"
+
+	if properties.ETag() != "\"abc123\"" {
+		t.Fatalf("Expected ETag \"abc123\", got %s", properties.ETag())
+	}
+}
+
+func TestETagShouldBeEmptyWithoutContentHash(t *testing.T) {
+	properties := resource.NewResourceProperties(resource.NewResourceIdentifier("/test"))
+
+	if properties.ETag() != "" {
+		t.Fatalf("Expected empty ETag, got %s", properties.ETag())
+	}
+}
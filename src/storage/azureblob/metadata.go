@@ -0,0 +1,69 @@
+package azureblob
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inx51/howlite-resources/resource"
+)
+
+// The properties are stored as metadata of the resource blob, so that they are
+// returned with it and written in the same commit. Metadata values are sent as
+// headers, so the identifier and content type are escaped.
+const (
+	metadataIdentifier    = "identifier"
+	metadataContentHash   = "contenthash"
+	metadataContentLength = "contentlength"
+	metadataContentType   = "contenttype"
+	metadataLastModified  = "lastmodified"
+)
+
+// errNoPropertiesMetadata is returned for blobs stored before the properties
+// were kept in the blob metadata.
+var errNoPropertiesMetadata = errors.New("blob has no properties metadata")
+
+func propertiesMetadata(properties *resource.ResourceProperties) map[string]*string {
+	return map[string]*string{
+		metadataIdentifier:    metadataValue(url.QueryEscape(properties.Identifier)),
+		metadataContentHash:   metadataValue(properties.ContentHash),
+		metadataContentLength: metadataValue(strconv.FormatInt(properties.ContentLength, 10)),
+		metadataContentType:   metadataValue(url.QueryEscape(properties.ContentType)),
+		metadataLastModified:  metadataValue(properties.LastModified.UTC().Format(time.RFC3339Nano)),
+	}
+}
+
+func metadataValue(value string) *string {
+	return &value
+}
+
+// propertiesFromMetadata loads the properties from the blob metadata. The
+// metadata keys may come back in a different case than they were stored in.
+func propertiesFromMetadata(resourceIdentifier *resource.ResourceIdentifier, metadata map[string]*string) (*resource.ResourceProperties, error) {
+	values := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		if value != nil {
+			values[strings.ToLower(key)] = *value
+		}
+	}
+	if _, found := values[metadataContentHash]; !found {
+		return nil, errNoPropertiesMetadata
+	}
+
+	properties := resource.NewResourceProperties(resourceIdentifier)
+	properties.ContentHash = values[metadataContentHash]
+
+	var err error
+	if properties.ContentLength, err = strconv.ParseInt(values[metadataContentLength], 10, 64); err != nil {
+		return nil, err
+	}
+	if properties.ContentType, err = url.QueryUnescape(values[metadataContentType]); err != nil {
+		return nil, err
+	}
+	if properties.LastModified, err = time.Parse(time.RFC3339Nano, values[metadataLastModified]); err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
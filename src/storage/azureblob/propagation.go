@@ -0,0 +1,19 @@
+package azureblob
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// tracePropagationPolicy injects the trace context of the operation into the
+// headers of the requests sent to Azure.
+type tracePropagationPolicy struct{}
+
+func (tracePropagationPolicy) Do(request *policy.Request) (*http.Response, error) {
+	raw := request.Raw()
+	otel.GetTextMapPropagator().Inject(raw.Context(), propagation.HeaderCarrier(raw.Header))
+	return request.Next()
+}
@@ -1,10 +1,22 @@
 package azureblob
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
@@ -16,11 +28,24 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 )
 
+const (
+	indexBlobPrefix = "index/"
+	// maxReadAttempts limits how often reading a range is restarted when the
+	// blob is replaced between its ranged downloads.
+	maxReadAttempts = 3
+)
+
+// errBlobChanged is returned when the blob is replaced between its ranged
+// downloads.
+var errBlobChanged = errors.New("blob changed while it was read")
+
 type Storage struct {
 	containerClient *container.Client
 	configuration   configuration.AzureBlobStorageConfiguration
 }
 
+// GetResource downloads the blob, its properties are read from the metadata
+// returned with it.
 func (azureBlobStorage *Storage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
 	blobName := resourceIdentifier.ToUniqueFilename()
 	logger.Debug(ctx, "trying to download blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
@@ -38,23 +63,144 @@ func (azureBlobStorage *Storage) GetResource(ctx context.Context, resourceIdenti
 	if err != nil {
 		if bloberror.HasCode(err, bloberror.BlobNotFound) {
 			logger.Debug(ctx, "blob not found", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
-			return nil, err
+			return nil, storage.ErrResourceNotFound
 		}
 		logger.Error(ctx, "failed to download blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
 		return nil, err
 	}
 
+	properties, err := azureBlobStorage.loadProperties(ctx, resourceIdentifier, blobStream.Metadata, blobStream.ETag)
+	if err != nil {
+		blobStream.Body.Close()
+		return nil, err
+	}
+
 	resource, err := resource.LoadResource(resourceIdentifier, blobStream.Body)
 	if err != nil {
 		blobStream.Body.Close()
 		logger.Error(ctx, "failed to load resource from blob stream", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
 		return nil, err
 	}
+	resource.Properties = properties
 	logger.Debug(ctx, "successfully downloaded blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
 	return resource, err
 }
 
-func (azureBlobStorage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+// GetResourceRange downloads only the headers section and the requested range
+// of the body by issuing ranged blob downloads. All of them are pinned to the
+// ETag named by the precondition, or else to the ETag read first.
+func (azureBlobStorage *Storage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	blobName := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to download blob range", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "range.offset", offset, "range.length", length)
+
+	for attempt := 1; ; attempt++ {
+		resource, err := azureBlobStorage.getResourceRange(ctx, resourceIdentifier, offset, length, precondition)
+		if errors.Is(err, errBlobChanged) && precondition != nil {
+			logger.Debug(ctx, "blob does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+			return nil, storage.ErrPreconditionFailed
+		}
+		if errors.Is(err, errBlobChanged) && attempt < maxReadAttempts {
+			logger.Debug(ctx, "blob changed while it was read, retrying", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug(ctx, "successfully downloaded blob range", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+		return resource, nil
+	}
+}
+
+func (azureBlobStorage *Storage) getResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	blobName := resourceIdentifier.ToUniqueFilename()
+	var etag *azcore.ETag
+	if precondition != nil {
+		etag = to.Ptr(azcore.ETag(precondition.Revision))
+	}
+
+	lengthPrefix, err := azureBlobStorage.downloadRange(ctx, resourceIdentifier, 0, resource.HeadersLengthPrefixSize, etag)
+	if err != nil {
+		return nil, err
+	}
+	etag = lengthPrefix.ETag
+	headersSectionLength, err := resource.HeadersSectionLength(lengthPrefix.Body)
+	lengthPrefix.Body.Close()
+	if err != nil {
+		logger.Error(ctx, "failed to read headers length from blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+		return nil, err
+	}
+
+	headers := resource.NewResourceHeaders()
+	if headersSectionLength > resource.HeadersLengthPrefixSize {
+		headersSection, err := azureBlobStorage.downloadRange(ctx, resourceIdentifier, 0, headersSectionLength, etag)
+		if err != nil {
+			return nil, err
+		}
+		err = headers.LoadHeaders(headersSection.Body)
+		headersSection.Body.Close()
+		if err != nil {
+			logger.Error(ctx, "failed to load headers from blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+			return nil, err
+		}
+	}
+
+	blobStream, err := azureBlobStorage.downloadRange(ctx, resourceIdentifier, headersSectionLength+offset, length, etag)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := resource.NewResource(resourceIdentifier, &blobStream.Body)
+	resource.Headers = headers
+	return resource, nil
+}
+
+// downloadRange downloads a range of the blob, if ifMatch is set only from
+// that version of the blob, and returns errBlobChanged otherwise.
+func (azureBlobStorage *Storage) downloadRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, ifMatch *azcore.ETag) (*blob.DownloadStreamResponse, error) {
+	blobName := resourceIdentifier.ToUniqueFilename()
+	blobClient := azureBlobStorage.containerClient.NewBlobClient(blobName)
+
+	blobClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.download")
+	tracer.SetDebugAttributes(blobClientCtx, span,
+		attribute.String("blob.name", blobName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+		attribute.Int64("azure.blob.range.offset", offset),
+		attribute.Int64("azure.blob.range.count", length),
+	)
+	options := &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{
+			Offset: offset,
+			Count:  length,
+		},
+	}
+	if ifMatch != nil {
+		options.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: ifMatch},
+		}
+	}
+	blobStream, err := blobClient.DownloadStream(blobClientCtx, options)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		if ifMatch != nil && bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobNotFound) {
+			return nil, errBlobChanged
+		}
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			logger.Debug(ctx, "blob not found", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+			return nil, storage.ErrResourceNotFound
+		}
+		logger.Error(ctx, "failed to download blob range", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+		return nil, err
+	}
+
+	return &blobStream, nil
+}
+
+// RemoveResource deletes the resource blob, the precondition is enforced with
+// the same access conditions as when committing it.
+func (azureBlobStorage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
 	blobName := resourceIdentifier.ToUniqueFilename()
 	blobClient := azureBlobStorage.containerClient.NewBlobClient(blobName)
 	logger.Debug(ctx, "trying to delete blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
@@ -64,18 +210,152 @@ func (azureBlobStorage *Storage) RemoveResource(ctx context.Context, resourceIde
 		attribute.String("blob.name", blobName),
 		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
 	)
-	_, err := blobClient.Delete(blobClientCtx, nil)
+	_, err := blobClient.Delete(blobClientCtx, &blob.DeleteOptions{
+		AccessConditions: accessConditions(precondition),
+	})
 	tracer.SafeRecordError(span, err)
 	tracer.SafeEndSpan(span)
 
+	if err != nil && precondition != nil && bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobNotFound) {
+		logger.Debug(ctx, "blob does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+		return storage.ErrPreconditionFailed
+	}
 	if err != nil {
 		logger.Error(ctx, "failed to delete blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
 		return err
 	}
 	logger.Debug(ctx, "successfully deleted blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+	err = azureBlobStorage.removeProperties(ctx, resourceIdentifier)
+	if err != nil {
+		return err
+	}
+
+	return azureBlobStorage.removeIndex(ctx, resourceIdentifier)
+}
+
+func (azureBlobStorage *Storage) removeProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	blobName := resourceIdentifier.ToUniquePropertiesFilename()
+	blobClient := azureBlobStorage.containerClient.NewBlobClient(blobName)
+	logger.Debug(ctx, "trying to delete properties blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+
+	blobClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.delete")
+	tracer.SetDebugAttributes(blobClientCtx, span,
+		attribute.String("blob.name", blobName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := blobClient.Delete(blobClientCtx, nil)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		logger.Error(ctx, "failed to delete properties blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+		return err
+	}
+	logger.Debug(ctx, "successfully deleted properties blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+	return nil
+}
+
+func (azureBlobStorage *Storage) removeIndex(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	blobName := indexBlobName(resourceIdentifier.Identifier())
+	blobClient := azureBlobStorage.containerClient.NewBlobClient(blobName)
+	logger.Debug(ctx, "trying to delete index blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+
+	blobClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.delete")
+	tracer.SetDebugAttributes(blobClientCtx, span,
+		attribute.String("blob.name", blobName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := blobClient.Delete(blobClientCtx, nil)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		logger.Error(ctx, "failed to delete index blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+		return err
+	}
+	logger.Debug(ctx, "successfully deleted index blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
 	return nil
 }
 
+// GetResourceProperties reads the properties from the blob metadata.
+func (azureBlobStorage *Storage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	blobName := resourceIdentifier.ToUniqueFilename()
+	blobClient := azureBlobStorage.containerClient.NewBlobClient(blobName)
+	logger.Debug(ctx, "trying to get blob properties", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+
+	blobClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.get_properties")
+	tracer.SetDebugAttributes(blobClientCtx, span,
+		attribute.String("blob.name", blobName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	blobProperties, err := blobClient.GetProperties(blobClientCtx, nil)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			logger.Debug(ctx, "blob not found", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+			return nil, storage.ErrResourceNotFound
+		}
+		logger.Error(ctx, "failed to get blob properties", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+		return nil, err
+	}
+
+	properties, err := azureBlobStorage.loadProperties(ctx, resourceIdentifier, blobProperties.Metadata, blobProperties.ETag)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug(ctx, "successfully got blob properties", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+	return properties, nil
+}
+
+// loadProperties loads the properties from the blob metadata, or from the
+// properties blob of resources stored before the properties were kept in the
+// metadata. The ETag of the blob is the revision.
+func (azureBlobStorage *Storage) loadProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, metadata map[string]*string, etag *azcore.ETag) (*resource.ResourceProperties, error) {
+	properties, err := propertiesFromMetadata(resourceIdentifier, metadata)
+	if errors.Is(err, errNoPropertiesMetadata) {
+		properties, err = azureBlobStorage.getLegacyProperties(ctx, resourceIdentifier)
+	}
+	if err != nil {
+		logger.Error(ctx, "failed to load properties of blob", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+
+	if etag != nil {
+		properties.Revision = string(*etag)
+	}
+	return properties, nil
+}
+
+// getLegacyProperties reads the properties blob that was written next to
+// resource blobs before the properties were kept in the blob metadata.
+func (azureBlobStorage *Storage) getLegacyProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	blobName := resourceIdentifier.ToUniquePropertiesFilename()
+	logger.Debug(ctx, "trying to download properties blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+	blobClient := azureBlobStorage.containerClient.NewBlobClient(blobName)
+
+	blobClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.download")
+	tracer.SetDebugAttributes(blobClientCtx, span,
+		attribute.String("blob.name", blobName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	blobStream, err := blobClient.DownloadStream(blobClientCtx, nil)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			logger.Debug(ctx, "properties blob not found", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+			return resource.NewResourceProperties(resourceIdentifier), nil
+		}
+		logger.Error(ctx, "failed to download properties blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+		return nil, err
+	}
+
+	return resource.LoadResourceProperties(blobStream.Body)
+}
+
 func (azureBlobStorage *Storage) ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error) {
 	blobName := resourceIdentifier.ToUniqueFilename()
 	blobClient := azureBlobStorage.containerClient.NewBlobClient(blobName)
@@ -104,8 +384,91 @@ func (azureBlobStorage *Storage) ResourceExists(ctx context.Context, resourceIde
 	return true, nil
 }
 
+// ListResources lists the index blobs, which are named after the original
+// identifier, and loads the properties of each listed resource. The cursor is
+// the azure list marker.
+func (azureBlobStorage *Storage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*storage.ResourceList, error) {
+	blobPrefix := indexBlobName(prefix)
+	logger.Debug(ctx, "trying to list index blobs", "blob.prefix", blobPrefix, "list.cursor", cursor)
+
+	maxResults := int32(limit)
+	options := &container.ListBlobsFlatOptions{
+		Prefix:     &blobPrefix,
+		MaxResults: &maxResults,
+	}
+	if cursor != "" {
+		options.Marker = &cursor
+	}
+	pager := azureBlobStorage.containerClient.NewListBlobsFlatPager(options)
+
+	containerClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.list")
+	tracer.SetDebugAttributes(containerClientCtx, span,
+		attribute.String("blob.prefix", blobPrefix),
+		attribute.Int("azure.blob.max_results", limit),
+	)
+	page, err := pager.NextPage(containerClientCtx)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to list index blobs", "blob.prefix", blobPrefix, "error", err)
+		return nil, err
+	}
+
+	list := &storage.ResourceList{Resources: []*resource.ResourceProperties{}}
+	for _, blobItem := range page.Segment.BlobItems {
+		resourceIdentifier := resource.NewResourceIdentifier(identifierFromIndexBlobName(*blobItem.Name))
+		properties, err := azureBlobStorage.GetResourceProperties(ctx, resourceIdentifier)
+		if errors.Is(err, storage.ErrResourceNotFound) {
+			// Removed since it was indexed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list.Resources = append(list.Resources, properties)
+	}
+	if page.NextMarker != nil {
+		list.NextCursor = *page.NextMarker
+	}
+
+	logger.Debug(ctx, "successfully listed index blobs", "blob.prefix", blobPrefix, "list.count", len(list.Resources))
+	return list, nil
+}
+
+// CountResources counts the data blobs page by page, without loading the
+// properties of the resources. The delimiter leaves out the index blobs,
+// which are written before the data blob and may be left without one.
+func (azureBlobStorage *Storage) CountResources(ctx context.Context) (int64, error) {
+	pager := azureBlobStorage.containerClient.NewListBlobsHierarchyPager("/", nil)
+
+	var count int64
+	for pager.More() {
+		containerClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.list")
+		page, err := pager.NextPage(containerClientCtx)
+		tracer.SafeRecordError(span, err)
+		tracer.SafeEndSpan(span)
+
+		if err != nil {
+			logger.Error(ctx, "failed to count blobs", "error", err)
+			return 0, err
+		}
+		for _, blobItem := range page.Segment.BlobItems {
+			if blobItem.Name != nil && strings.HasSuffix(*blobItem.Name, resource.UniqueFilenameSuffix) {
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
 func NewStorage(configuration *configuration.AzureBlobStorageConfiguration) storage.Storage {
-	client, err := azblob.NewClientFromConnectionString(configuration.CONNECTION_STRING, nil)
+	client, err := azblob.NewClientFromConnectionString(configuration.CONNECTION_STRING, &azblob.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			PerCallPolicies: []policy.Policy{tracePropagationPolicy{}},
+		},
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -120,9 +483,30 @@ func (azureBlobStorage *Storage) GetName() string {
 	return "azureblob"
 }
 
-func (azureBlobStorage *Storage) SaveResource(ctx context.Context, resource *resource.Resource) error {
+// CheckHealth checks that the container exists and is accessible with the
+// configured connection string.
+func (azureBlobStorage *Storage) CheckHealth(ctx context.Context) error {
+	containerCtx, span := tracer.StartDebugSpan(ctx, "azure.container.get_properties")
+	defer tracer.SafeEndSpan(span)
+
+	_, err := azureBlobStorage.containerClient.GetProperties(containerCtx, nil)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// SaveResource stages the resource as blocks and commits them together with
+// the properties as metadata, once the resource has been written and its
+// properties are known. The precondition is enforced when committing. The
+// index blob is saved first, listing skips index blobs of missing resources.
+func (azureBlobStorage *Storage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *storage.Precondition) error {
 	blobName := resource.Identifier.ToUniqueFilename()
 	blockBlobClient := azureBlobStorage.containerClient.NewBlockBlobClient(blobName)
+	if err := azureBlobStorage.saveIndex(ctx, resource.Identifier); err != nil {
+		return err
+	}
 
 	reader := azureBlobStorage.createResourceReader(ctx, resource)
 	logger.Debug(ctx, "trying to upload blob", "resource.identifier", resource.Identifier.Identifier(), "blob.name", blobName)
@@ -134,24 +518,144 @@ func (azureBlobStorage *Storage) SaveResource(ctx context.Context, resource *res
 		attribute.Int64("azure.blob.block_size", azureBlobStorage.configuration.BLOCK_SIZE),
 		attribute.Int("azure.blob.concurrency", azureBlobStorage.configuration.UPLOAD_CONCURRENCY),
 	)
-	_, err := blockBlobClient.UploadStream(blobClientCtx, reader, &blockblob.UploadStreamOptions{
-		BlockSize:   azureBlobStorage.configuration.BLOCK_SIZE,
-		Concurrency: azureBlobStorage.configuration.UPLOAD_CONCURRENCY,
-	})
-	if err != nil {
-		tracer.SafeRecordError(span, err)
+	blockIDs, err := azureBlobStorage.stageBlocks(blobClientCtx, blockBlobClient, reader)
+	var response blockblob.CommitBlockListResponse
+	if err == nil {
+		response, err = blockBlobClient.CommitBlockList(blobClientCtx, blockIDs, &blockblob.CommitBlockListOptions{
+			Metadata:         propertiesMetadata(resource.Properties),
+			AccessConditions: accessConditions(precondition),
+		})
 	}
+	tracer.SafeRecordError(span, err)
 	tracer.SafeEndSpan(span)
 
+	if err != nil && precondition != nil && bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists, bloberror.BlobNotFound) {
+		logger.Debug(ctx, "blob does not satisfy the precondition", "resource.identifier", resource.Identifier.Identifier(), "blob.name", blobName)
+		return storage.ErrPreconditionFailed
+	}
 	if err != nil {
 		logger.Error(ctx, "failed to upload blob", "resource.identifier", resource.Identifier.Identifier(), "blob.name", blobName, "error", err)
 		return err
 	}
 
+	if response.ETag != nil {
+		resource.Properties.Revision = string(*response.ETag)
+	}
 	logger.Debug(ctx, "successfully uploaded blob", "resource.identifier", resource.Identifier.Identifier(), "blob.name", blobName)
 	return nil
 }
 
+// stageBlocks stages the blocks read from reader, up to the configured
+// concurrency at once, and returns their IDs in order. The IDs are unique to
+// the upload, so concurrent uploads of the same blob don't overwrite each
+// other's uncommitted blocks.
+func (azureBlobStorage *Storage) stageBlocks(ctx context.Context, blockBlobClient *blockblob.Client, reader io.Reader) ([]string, error) {
+	uploadID := make([]byte, 8)
+	if _, err := rand.Read(uploadID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wait     sync.WaitGroup
+		mutex    sync.Mutex
+		stageErr error
+		blockIDs []string
+	)
+	slots := make(chan struct{}, max(azureBlobStorage.configuration.UPLOAD_CONCURRENCY, 1))
+	for {
+		block := make([]byte, azureBlobStorage.configuration.BLOCK_SIZE)
+		n, err := io.ReadFull(reader, block)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			cancel()
+			wait.Wait()
+			return nil, err
+		}
+
+		blockID := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "%x-%08d", uploadID, len(blockIDs)))
+		blockIDs = append(blockIDs, blockID)
+		slots <- struct{}{}
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			defer func() { <-slots }()
+			_, err := blockBlobClient.StageBlock(ctx, blockID, streaming.NopCloser(bytes.NewReader(block[:n])), nil)
+			if err != nil {
+				mutex.Lock()
+				if stageErr == nil {
+					stageErr = err
+				}
+				mutex.Unlock()
+				cancel()
+			}
+		}()
+
+		if n < len(block) {
+			break
+		}
+	}
+	wait.Wait()
+
+	if stageErr != nil {
+		return nil, stageErr
+	}
+	return blockIDs, nil
+}
+
+// accessConditions makes committing the blob enforce the precondition.
+func accessConditions(precondition *storage.Precondition) *blob.AccessConditions {
+	if precondition == nil {
+		return nil
+	}
+
+	conditions := &blob.ModifiedAccessConditions{}
+	if precondition.NotExists {
+		conditions.IfNoneMatch = to.Ptr(azcore.ETagAny)
+	} else {
+		conditions.IfMatch = to.Ptr(azcore.ETag(precondition.Revision))
+	}
+	return &blob.AccessConditions{ModifiedAccessConditions: conditions}
+}
+
+// saveIndex stores an empty blob named after the original identifier, so that
+// resources can be listed by prefix even though their data blobs are named by
+// a hash of the identifier.
+func (azureBlobStorage *Storage) saveIndex(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	blobName := indexBlobName(resourceIdentifier.Identifier())
+	blockBlobClient := azureBlobStorage.containerClient.NewBlockBlobClient(blobName)
+	logger.Debug(ctx, "trying to upload index blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+
+	blobClientCtx, span := tracer.StartDebugSpan(ctx, "azure.blob.upload")
+	tracer.SetDebugAttributes(blobClientCtx, span,
+		attribute.String("blob.name", blobName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := blockBlobClient.UploadBuffer(blobClientCtx, []byte{}, nil)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to upload index blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully uploaded index blob", "resource.identifier", resourceIdentifier.Identifier(), "blob.name", blobName)
+	return nil
+}
+
+func indexBlobName(identifier string) string {
+	return indexBlobPrefix + strings.TrimPrefix(identifier, "/")
+}
+
+func identifierFromIndexBlobName(blobName string) string {
+	return "/" + strings.TrimPrefix(blobName, indexBlobPrefix)
+}
+
 func (azureBlobStorage *Storage) createResourceReader(ctx context.Context, resource *resource.Resource) io.Reader {
 	pipeReader, pipeWriter := io.Pipe()
 	go func() {
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/resource"
+)
+
+const (
+	diskEntryExtension     = ".bin"
+	diskTemporaryExtension = ".tmp"
+)
+
+// diskTier keeps cache entries as files in a local directory, up to maxSize
+// bytes. Which entries exist is only tracked in memory, so the entries left
+// behind by a previous run are removed when the tier is created; they may be
+// stale since the events invalidating them were missed.
+type diskTier struct {
+	mutex sync.Mutex
+	path  string
+	items *lru
+}
+
+func newDiskTier(path string, maxSize int64) (*diskTier, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		extension := filepath.Ext(file.Name())
+		if file.IsDir() || (extension != diskEntryExtension && extension != diskTemporaryExtension) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(path, file.Name())); err != nil {
+			return nil, err
+		}
+	}
+
+	return &diskTier{
+		path:  path,
+		items: newLru(maxSize),
+	}, nil
+}
+
+func (tier *diskTier) name() string {
+	return "disk"
+}
+
+func (tier *diskTier) filePath(key string) string {
+	return filepath.Join(tier.path, resource.NewResourceIdentifier(key).ToUniqueFilename())
+}
+
+// get reads the entry file without holding the lock. If the entry is removed
+// meanwhile the read fails and the entry is reported as not found, if it is
+// replaced the new entry is read.
+func (tier *diskTier) get(ctx context.Context, key string) ([]byte, time.Time, bool) {
+	tier.mutex.Lock()
+	item, expired := tier.items.get(key, time.Now())
+	if expired != nil {
+		tier.removeFile(ctx, expired.key)
+	}
+	tier.mutex.Unlock()
+
+	if expired != nil {
+		recordEviction(ctx, tier, evictionReasonExpired)
+	}
+	if item == nil {
+		return nil, time.Time{}, false
+	}
+
+	data, err := os.ReadFile(tier.filePath(key))
+	if err != nil {
+		logger.Debug(ctx, "failed to read cache entry from disk", "cache.key", key, "error", err)
+		return nil, time.Time{}, false
+	}
+
+	return data, item.expiresUtc, true
+}
+
+// set writes the entry to a temporary file first, so that readers never see a
+// partially written entry.
+func (tier *diskTier) set(ctx context.Context, key string, data []byte, expiresUtc time.Time) {
+	if tier.items.maxSize > 0 && int64(len(data)) > tier.items.maxSize {
+		return
+	}
+
+	file, err := os.CreateTemp(tier.path, "*"+diskTemporaryExtension)
+	if err != nil {
+		logger.Error(ctx, "failed to create cache entry on disk", "cache.key", key, "error", err)
+		return
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		logger.Error(ctx, "failed to write cache entry to disk", "cache.key", key, "error", err)
+		return
+	}
+
+	tier.mutex.Lock()
+	if err := os.Rename(file.Name(), tier.filePath(key)); err != nil {
+		tier.mutex.Unlock()
+		os.Remove(file.Name())
+		logger.Error(ctx, "failed to write cache entry to disk", "cache.key", key, "error", err)
+		return
+	}
+	_, evicted := tier.items.add(&lruItem{
+		key:        key,
+		size:       int64(len(data)),
+		expiresUtc: expiresUtc,
+	})
+	for _, item := range evicted {
+		tier.removeFile(ctx, item.key)
+	}
+	tier.mutex.Unlock()
+
+	for range evicted {
+		recordEviction(ctx, tier, evictionReasonSize)
+	}
+}
+
+func (tier *diskTier) remove(ctx context.Context, key string) {
+	tier.mutex.Lock()
+	defer tier.mutex.Unlock()
+
+	if tier.items.remove(key) != nil {
+		tier.removeFile(ctx, key)
+	}
+}
+
+func (tier *diskTier) removeFile(ctx context.Context, key string) {
+	err := os.Remove(tier.filePath(key))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Error(ctx, "failed to remove cache entry from disk", "cache.key", key, "error", err)
+	}
+}
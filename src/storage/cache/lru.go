@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+const (
+	evictionReasonSize    = "size"
+	evictionReasonExpired = "expired"
+)
+
+type lruItem struct {
+	key        string
+	size       int64
+	expiresUtc time.Time
+	data       []byte
+}
+
+// lru keeps track of the items of a tier, the total size of the items and the
+// order in which they were used. It is not safe for concurrent use, tiers
+// guard it with their own mutex.
+type lru struct {
+	items   map[string]*list.Element
+	recency *list.List
+	size    int64
+	maxSize int64
+}
+
+func newLru(maxSize int64) *lru {
+	return &lru{
+		items:   make(map[string]*list.Element),
+		recency: list.New(),
+		maxSize: maxSize,
+	}
+}
+
+// get returns the item for key and marks it as the most recently used one. An
+// expired item is removed and returned as not found.
+func (cache *lru) get(key string, now time.Time) (item *lruItem, expired *lruItem) {
+	element, found := cache.items[key]
+	if !found {
+		return nil, nil
+	}
+
+	item = element.Value.(*lruItem)
+	if !now.Before(item.expiresUtc) {
+		cache.removeElement(element)
+		return nil, item
+	}
+
+	cache.recency.MoveToFront(element)
+	return item, nil
+}
+
+// add adds or replaces the item for item.key, evicting the least recently used
+// items until it fits. Items larger than maxSize are not added.
+func (cache *lru) add(item *lruItem) (added bool, evicted []*lruItem) {
+	if cache.maxSize > 0 && item.size > cache.maxSize {
+		return false, nil
+	}
+
+	if element, found := cache.items[item.key]; found {
+		cache.removeElement(element)
+	}
+
+	for cache.maxSize > 0 && cache.size+item.size > cache.maxSize {
+		evicted = append(evicted, cache.removeElement(cache.recency.Back()))
+	}
+
+	cache.items[item.key] = cache.recency.PushFront(item)
+	cache.size += item.size
+	return true, evicted
+}
+
+func (cache *lru) remove(key string) *lruItem {
+	element, found := cache.items[key]
+	if !found {
+		return nil
+	}
+
+	return cache.removeElement(element)
+}
+
+func (cache *lru) removeElement(element *list.Element) *lruItem {
+	item := cache.recency.Remove(element).(*lruItem)
+	delete(cache.items, item.key)
+	cache.size -= item.size
+	return item
+}
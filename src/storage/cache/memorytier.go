@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryTier keeps cache entries in memory, up to maxSize bytes.
+type memoryTier struct {
+	mutex sync.Mutex
+	items *lru
+}
+
+func newMemoryTier(maxSize int64) *memoryTier {
+	return &memoryTier{
+		items: newLru(maxSize),
+	}
+}
+
+func (tier *memoryTier) name() string {
+	return "memory"
+}
+
+func (tier *memoryTier) get(ctx context.Context, key string) ([]byte, time.Time, bool) {
+	tier.mutex.Lock()
+	item, expired := tier.items.get(key, time.Now())
+	tier.mutex.Unlock()
+
+	if expired != nil {
+		recordEviction(ctx, tier, evictionReasonExpired)
+	}
+	if item == nil {
+		return nil, time.Time{}, false
+	}
+
+	return item.data, item.expiresUtc, true
+}
+
+func (tier *memoryTier) set(ctx context.Context, key string, data []byte, expiresUtc time.Time) {
+	tier.mutex.Lock()
+	_, evicted := tier.items.add(&lruItem{
+		key:        key,
+		size:       int64(len(data)),
+		expiresUtc: expiresUtc,
+		data:       data,
+	})
+	tier.mutex.Unlock()
+
+	for range evicted {
+		recordEviction(ctx, tier, evictionReasonSize)
+	}
+}
+
+func (tier *memoryTier) remove(ctx context.Context, key string) {
+	tier.mutex.Lock()
+	defer tier.mutex.Unlock()
+
+	tier.items.remove(key)
+}
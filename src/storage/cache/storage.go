@@ -0,0 +1,343 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/meter"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// tier holds cache entries, keyed by resource identifier. Tiers evict entries
+// on their own once they are full or expired.
+type tier interface {
+	name() string
+	get(ctx context.Context, key string) ([]byte, time.Time, bool)
+	set(ctx context.Context, key string, data []byte, expiresUtc time.Time)
+	remove(ctx context.Context, key string)
+}
+
+// entry is a cached resource, Data holds the headers and body of the resource
+// serialized the same way storage providers store them.
+type entry struct {
+	Properties resource.ResourceProperties `msgpack:"properties"`
+	Data       []byte                      `msgpack:"data"`
+}
+
+// Options selects the tiers of the cache, a tier is used if its max size is
+// set, or for the disk tier if DiskPath is set. A max size of 0 means unlimited
+// for the disk tier.
+type Options struct {
+	MemoryMaxSize   int64
+	DiskPath        string
+	DiskMaxSize     int64
+	MaxResourceSize int64
+	Ttl             time.Duration
+}
+
+// Storage is a read-through cache in front of another storage provider.
+// Resources are cached when they are read, in every tier, and are looked up
+// from the first tier to the last. Saving or removing a resource through the
+// cache, or receiving an event that another instance changed it, invalidates
+// the resource in every tier.
+type Storage struct {
+	storage         storage.Storage
+	tiers           []tier
+	maxResourceSize int64
+	ttl             time.Duration
+	// mutex orders invalidations and cache writes, so that a resource read
+	// before an invalidation is never cached after it.
+	mutex         sync.Mutex
+	invalidations uint64
+}
+
+func NewStorage(ctx context.Context, inner storage.Storage, options Options) (*Storage, error) {
+	var tiers []tier
+	if options.MemoryMaxSize > 0 {
+		tiers = append(tiers, newMemoryTier(options.MemoryMaxSize))
+	}
+	if options.DiskPath != "" {
+		diskTier, err := newDiskTier(options.DiskPath, options.DiskMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, diskTier)
+	}
+	if len(tiers) == 0 {
+		return nil, errors.New("the cache needs a memory or a disk tier")
+	}
+
+	maxResourceSize := options.MaxResourceSize
+	if maxResourceSize <= 0 {
+		maxResourceSize = math.MaxInt64 - 1
+	}
+
+	return &Storage{
+		storage:         inner,
+		tiers:           tiers,
+		maxResourceSize: maxResourceSize,
+		ttl:             options.Ttl,
+	}, nil
+}
+
+// GetName returns the name of the cached storage provider, the cache is
+// transparent to the handlers.
+func (cacheStorage *Storage) GetName() string {
+	return cacheStorage.storage.GetName()
+}
+
+// CheckHealth checks the cached storage provider, a cache that cannot be
+// written to only causes misses.
+func (cacheStorage *Storage) CheckHealth(ctx context.Context) error {
+	return cacheStorage.storage.CheckHealth(ctx)
+}
+
+func (cacheStorage *Storage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	if cached := cacheStorage.lookup(ctx, resourceIdentifier); cached != nil {
+		loaded, err := resource.LoadResource(resourceIdentifier, io.NopCloser(bytes.NewReader(cached.Data)))
+		if err != nil {
+			return nil, err
+		}
+		loaded.Properties = &cached.Properties
+		return loaded, nil
+	}
+
+	invalidations := cacheStorage.currentInvalidations()
+	fetched, err := cacheStorage.storage.GetResource(ctx, resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	body := *fetched.Body
+	var buffered bytes.Buffer
+	read, err := io.CopyN(&buffered, body, cacheStorage.maxResourceSize+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		body.Close()
+		return nil, err
+	}
+
+	if read > cacheStorage.maxResourceSize {
+		logger.Debug(ctx, "resource is too large to be cached", "resource.identifier", resourceIdentifier.Identifier())
+		var readCloser io.ReadCloser = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&buffered, body), body}
+		fetched.Body = &readCloser
+		return fetched, nil
+	}
+	body.Close()
+
+	cacheStorage.store(ctx, resourceIdentifier, fetched.Headers, fetched.Properties, buffered.Bytes(), invalidations)
+
+	readCloser := io.NopCloser(bytes.NewReader(buffered.Bytes()))
+	fetched.Body = &readCloser
+	return fetched, nil
+}
+
+// GetResourceRange reads the range from the cached resource, unless it is not
+// cached or the cached revision does not satisfy the precondition, in which
+// case the underlying storage decides.
+func (cacheStorage *Storage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	cached := cacheStorage.lookup(ctx, resourceIdentifier)
+	if cached == nil || (precondition != nil && (precondition.NotExists || cached.Properties.Revision != precondition.Revision)) {
+		return cacheStorage.storage.GetResourceRange(ctx, resourceIdentifier, offset, length, precondition)
+	}
+
+	reader := bytes.NewReader(cached.Data)
+	resource, err := resource.LoadResource(resourceIdentifier, io.NopCloser(reader))
+	if err != nil {
+		return nil, err
+	}
+
+	// LoadResource leaves the reader positioned at the start of the body.
+	if _, err := reader.Seek(offset, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	body := io.NopCloser(io.LimitReader(reader, length))
+	resource.Body = &body
+	return resource, nil
+}
+
+func (cacheStorage *Storage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	if cached := cacheStorage.lookup(ctx, resourceIdentifier); cached != nil {
+		return &cached.Properties, nil
+	}
+
+	return cacheStorage.storage.GetResourceProperties(ctx, resourceIdentifier)
+}
+
+func (cacheStorage *Storage) ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error) {
+	if cached := cacheStorage.lookup(ctx, resourceIdentifier); cached != nil {
+		return true, nil
+	}
+
+	return cacheStorage.storage.ResourceExists(ctx, resourceIdentifier)
+}
+
+func (cacheStorage *Storage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*storage.ResourceList, error) {
+	return cacheStorage.storage.ListResources(ctx, prefix, cursor, limit)
+}
+
+func (cacheStorage *Storage) CountResources(ctx context.Context) (int64, error) {
+	return storage.CountResources(ctx, cacheStorage.storage)
+}
+
+func (cacheStorage *Storage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *storage.Precondition) error {
+	err := cacheStorage.storage.SaveResource(ctx, resource, precondition)
+	cacheStorage.Invalidate(ctx, resource.Identifier.Identifier())
+	return err
+}
+
+func (cacheStorage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
+	err := cacheStorage.storage.RemoveResource(ctx, resourceIdentifier, precondition)
+	cacheStorage.Invalidate(ctx, resourceIdentifier.Identifier())
+	return err
+}
+
+// Invalidate removes the resource from every tier.
+func (cacheStorage *Storage) Invalidate(ctx context.Context, identifier string) {
+	cacheStorage.mutex.Lock()
+	defer cacheStorage.mutex.Unlock()
+
+	cacheStorage.invalidations++
+	for _, tier := range cacheStorage.tiers {
+		tier.remove(ctx, identifier)
+	}
+	logger.Debug(ctx, "invalidated cached resource", "resource.identifier", identifier)
+}
+
+// HandleEvent invalidates the resource an event received from another instance
+// is about.
+func (cacheStorage *Storage) HandleEvent(ctx context.Context, envelope *event.Envelope) {
+	switch envelope.Type {
+	case types.ResourceCreatedEventType, types.ResourceRepalcedEventType, types.ResourceRemoavedEventType:
+	default:
+		return
+	}
+
+	var data struct {
+		ResourceIdentity string
+	}
+	if err := json.Unmarshal(envelope.Data, &data); err != nil || data.ResourceIdentity == "" {
+		logger.Warn(ctx, "Received resource event without resource identity", "type", envelope.Type)
+		return
+	}
+
+	cacheStorage.Invalidate(ctx, data.ResourceIdentity)
+}
+
+func (cacheStorage *Storage) currentInvalidations() uint64 {
+	cacheStorage.mutex.Lock()
+	defer cacheStorage.mutex.Unlock()
+
+	return cacheStorage.invalidations
+}
+
+// lookup returns the cached entry for the resource from the first tier holding
+// it, and copies the entry to the tiers before that one unless the resource
+// was invalidated meanwhile.
+func (cacheStorage *Storage) lookup(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) *entry {
+	key := resourceIdentifier.Identifier()
+	invalidations := cacheStorage.currentInvalidations()
+	for i, tier := range cacheStorage.tiers {
+		data, expiresUtc, found := tier.get(ctx, key)
+		if !found {
+			continue
+		}
+
+		var cached entry
+		if err := msgpack.Unmarshal(data, &cached); err != nil || cached.Properties.Identifier != key {
+			logger.Warn(ctx, "Removing unreadable cache entry", "resource.identifier", key, "cache.tier", tier.name())
+			tier.remove(ctx, key)
+			continue
+		}
+
+		cacheStorage.mutex.Lock()
+		if cacheStorage.invalidations == invalidations {
+			for _, previous := range cacheStorage.tiers[:i] {
+				previous.set(ctx, key, data, expiresUtc)
+			}
+		}
+		cacheStorage.mutex.Unlock()
+
+		meter.ArithmeticInt64Counter(ctx, "storage_cache_hits_total", 1, metric.WithAttributes(attribute.String("cache_tier", tier.name())))
+		return &cached
+	}
+
+	meter.ArithmeticInt64Counter(ctx, "storage_cache_misses_total", 1)
+	return nil
+}
+
+// store caches a resource read from the cached storage provider, along with the
+// properties read with it, unless it was invalidated since reading it.
+func (cacheStorage *Storage) store(
+	ctx context.Context,
+	resourceIdentifier *resource.ResourceIdentifier,
+	headers *resource.ResourceHeaders,
+	properties *resource.ResourceProperties,
+	body []byte,
+	invalidations uint64) {
+	var serialized bufferCloser
+	bodyReader := io.NopCloser(bytes.NewReader(body))
+	serializedResource := resource.NewResource(resourceIdentifier, &bodyReader)
+	serializedResource.Headers = headers
+	if err := serializedResource.Write(&serialized); err != nil {
+		logger.Warn(ctx, "Failed to serialize resource to cache", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return
+	}
+	if properties.ContentHash == "" {
+		logger.Debug(ctx, "resource stored without properties, not caching it", "resource.identifier", resourceIdentifier.Identifier())
+		return
+	}
+
+	data, err := msgpack.Marshal(&entry{
+		Properties: *properties,
+		Data:       serialized.Bytes(),
+	})
+	if err != nil {
+		logger.Warn(ctx, "Failed to serialize resource to cache", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return
+	}
+
+	cacheStorage.mutex.Lock()
+	defer cacheStorage.mutex.Unlock()
+
+	if cacheStorage.invalidations != invalidations {
+		logger.Debug(ctx, "resource invalidated while it was read, not caching it", "resource.identifier", resourceIdentifier.Identifier())
+		return
+	}
+
+	expiresUtc := time.Now().Add(cacheStorage.ttl)
+	for _, tier := range cacheStorage.tiers {
+		tier.set(ctx, resourceIdentifier.Identifier(), data, expiresUtc)
+	}
+	logger.Debug(ctx, "cached resource", "resource.identifier", resourceIdentifier.Identifier(), "cache.size", len(data))
+}
+
+func recordEviction(ctx context.Context, tier tier, reason string) {
+	meter.ArithmeticInt64Counter(ctx, "storage_cache_evictions_total", 1, metric.WithAttributes(
+		attribute.String("cache_tier", tier.name()),
+		attribute.String("reason", reason),
+	))
+}
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (buffer *bufferCloser) Close() error {
+	return nil
+}
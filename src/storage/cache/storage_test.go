@@ -0,0 +1,252 @@
+//go:build unit
+
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/storage/cache"
+	"github.com/inx51/howlite-resources/storage/memory"
+)
+
+// countingStorage counts the reads reaching the cached storage provider.
+type countingStorage struct {
+	storage.Storage
+	reads atomic.Int64
+}
+
+func (counting *countingStorage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	counting.reads.Add(1)
+	return counting.Storage.GetResource(ctx, resourceIdentifier)
+}
+
+func newCache(t *testing.T, options cache.Options) (*cache.Storage, *countingStorage) {
+	t.Helper()
+	inner := &countingStorage{Storage: memory.NewStorage(&configuration.MemoryConfiguration{})}
+	if options.Ttl == 0 {
+		options.Ttl = time.Hour
+	}
+	cacheStorage, err := cache.NewStorage(context.Background(), inner, options)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return cacheStorage, inner
+}
+
+func save(t *testing.T, target storage.Storage, identifier string, body string) {
+	t.Helper()
+	readCloser := io.NopCloser(strings.NewReader(body))
+	if err := target.SaveResource(context.Background(), resource.NewResource(resource.NewResourceIdentifier(identifier), &readCloser), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func read(t *testing.T, target storage.Storage, identifier string) string {
+	t.Helper()
+	resource, err := target.GetResource(context.Background(), resource.NewResourceIdentifier(identifier))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer (*resource.Body).Close()
+	body, err := io.ReadAll(*resource.Body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return string(body)
+}
+
+func TestGetResourceShouldBeServedFromCacheOnceRead(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 1 << 20})
+	save(t, inner, "/a.txt", "hello world")
+
+	first := read(t, cacheStorage, "/a.txt")
+	second := read(t, cacheStorage, "/a.txt")
+
+	if first != "hello world" || second != "hello world" {
+		t.Fatalf("Expected hello world twice, got %q and %q", first, second)
+	}
+	if reads := inner.reads.Load(); reads != 1 {
+		t.Fatalf("Expected 1 read of the storage provider, got %d", reads)
+	}
+}
+
+func TestGetResourcePropertiesShouldBeServedFromCache(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 1 << 20})
+	save(t, inner, "/a.txt", "hello world")
+	read(t, cacheStorage, "/a.txt")
+	expected, _ := inner.GetResourceProperties(context.Background(), resource.NewResourceIdentifier("/a.txt"))
+	inner.RemoveResource(context.Background(), resource.NewResourceIdentifier("/a.txt"), nil)
+
+	properties, err := cacheStorage.GetResourceProperties(context.Background(), resource.NewResourceIdentifier("/a.txt"))
+	exists, _ := cacheStorage.ResourceExists(context.Background(), resource.NewResourceIdentifier("/a.txt"))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if properties.ContentHash != expected.ContentHash || properties.ContentLength != 11 {
+		t.Fatalf("Expected the cached properties, got %+v", properties)
+	}
+	if !exists {
+		t.Fatalf("Expected the cached resource to exist")
+	}
+}
+
+func TestGetResourceRangeShouldBeServedFromCache(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 1 << 20})
+	save(t, inner, "/a.txt", "hello world")
+	read(t, cacheStorage, "/a.txt")
+
+	resource, err := cacheStorage.GetResourceRange(context.Background(), resource.NewResourceIdentifier("/a.txt"), 6, 3, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	body, _ := io.ReadAll(*resource.Body)
+
+	if string(body) != "wor" {
+		t.Fatalf("Expected wor, got %q", body)
+	}
+	if reads := inner.reads.Load(); reads != 1 {
+		t.Fatalf("Expected 1 read of the storage provider, got %d", reads)
+	}
+}
+
+func TestSaveResourceShouldInvalidateCachedResource(t *testing.T) {
+	cacheStorage, _ := newCache(t, cache.Options{MemoryMaxSize: 1 << 20})
+	save(t, cacheStorage, "/a.txt", "first")
+	read(t, cacheStorage, "/a.txt")
+
+	save(t, cacheStorage, "/a.txt", "second")
+
+	if body := read(t, cacheStorage, "/a.txt"); body != "second" {
+		t.Fatalf("Expected second, got %q", body)
+	}
+}
+
+func TestRemoveResourceShouldInvalidateCachedResource(t *testing.T) {
+	cacheStorage, _ := newCache(t, cache.Options{MemoryMaxSize: 1 << 20})
+	save(t, cacheStorage, "/a.txt", "hello world")
+	read(t, cacheStorage, "/a.txt")
+
+	if err := cacheStorage.RemoveResource(context.Background(), resource.NewResourceIdentifier("/a.txt"), nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	exists, _ := cacheStorage.ResourceExists(context.Background(), resource.NewResourceIdentifier("/a.txt"))
+	if exists {
+		t.Fatalf("Expected the removed resource to not exist")
+	}
+}
+
+func TestHandleEventShouldInvalidateResourceChangedByPeer(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 1 << 20})
+	save(t, inner, "/a.txt", "first")
+	read(t, cacheStorage, "/a.txt")
+	save(t, inner, "/a.txt", "second")
+	data, _ := json.Marshal(types.ResourceReplaced{ReplacedUtc: time.Now(), ResourceIdentity: "/a.txt"})
+
+	cacheStorage.HandleEvent(context.Background(), &event.Envelope{Type: types.ResourceRepalcedEventType, Data: data})
+
+	if body := read(t, cacheStorage, "/a.txt"); body != "second" {
+		t.Fatalf("Expected second, got %q", body)
+	}
+}
+
+func TestGetResourceShouldNotServeExpiredResource(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 1 << 20, Ttl: 10 * time.Millisecond})
+	save(t, inner, "/a.txt", "hello world")
+	read(t, cacheStorage, "/a.txt")
+
+	time.Sleep(20 * time.Millisecond)
+	read(t, cacheStorage, "/a.txt")
+
+	if reads := inner.reads.Load(); reads != 2 {
+		t.Fatalf("Expected 2 reads of the storage provider, got %d", reads)
+	}
+}
+
+func TestGetResourceShouldEvictLeastRecentlyUsedResource(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 2500})
+	for _, identifier := range []string{"/a.txt", "/b.txt", "/c.txt"} {
+		save(t, inner, identifier, strings.Repeat("x", 1000))
+	}
+	read(t, cacheStorage, "/a.txt")
+	read(t, cacheStorage, "/b.txt")
+	read(t, cacheStorage, "/a.txt")
+
+	read(t, cacheStorage, "/c.txt")
+	read(t, cacheStorage, "/a.txt")
+	read(t, cacheStorage, "/b.txt")
+
+	// a is cached throughout, b is evicted when c is cached and read again.
+	if reads := inner.reads.Load(); reads != 4 {
+		t.Fatalf("Expected 4 reads of the storage provider, got %d", reads)
+	}
+}
+
+func TestGetResourceShouldNotCacheResourceLargerThanMaxResourceSize(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 1 << 20, MaxResourceSize: 100})
+	save(t, inner, "/a.txt", strings.Repeat("x", 1000))
+
+	first := read(t, cacheStorage, "/a.txt")
+	read(t, cacheStorage, "/a.txt")
+
+	if len(first) != 1000 {
+		t.Fatalf("Expected the whole resource, got %d bytes", len(first))
+	}
+	if reads := inner.reads.Load(); reads != 2 {
+		t.Fatalf("Expected 2 reads of the storage provider, got %d", reads)
+	}
+}
+
+func TestGetResourceShouldBeServedFromDiskAfterMemoryEviction(t *testing.T) {
+	cacheStorage, inner := newCache(t, cache.Options{MemoryMaxSize: 1500, DiskPath: t.TempDir()})
+	save(t, inner, "/a.txt", strings.Repeat("a", 1000))
+	save(t, inner, "/b.txt", strings.Repeat("b", 1000))
+	read(t, cacheStorage, "/a.txt")
+	read(t, cacheStorage, "/b.txt")
+
+	body := read(t, cacheStorage, "/a.txt")
+
+	if body != strings.Repeat("a", 1000) {
+		t.Fatalf("Expected the cached resource, got %q", body)
+	}
+	if reads := inner.reads.Load(); reads != 2 {
+		t.Fatalf("Expected 2 reads of the storage provider, got %d", reads)
+	}
+}
+
+func TestNewStorageShouldRemoveEntriesLeftOnDisk(t *testing.T) {
+	path := t.TempDir()
+	os.WriteFile(filepath.Join(path, "stale.bin"), []byte("stale"), 0o600)
+	os.WriteFile(filepath.Join(path, "keep.txt"), []byte("keep"), 0o600)
+
+	newCache(t, cache.Options{DiskPath: path})
+
+	if _, err := os.Stat(filepath.Join(path, "stale.bin")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the stale entry to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "keep.txt")); err != nil {
+		t.Fatalf("Expected other files to be kept, got %v", err)
+	}
+}
+
+func TestNewStorageShouldRequireATier(t *testing.T) {
+	_, err := cache.NewStorage(context.Background(), memory.NewStorage(&configuration.MemoryConfiguration{}), cache.Options{Ttl: time.Hour})
+
+	if err == nil {
+		t.Fatalf("Expected an error without tiers")
+	}
+}
@@ -0,0 +1,34 @@
+package storage
+
+import "context"
+
+const countPageSize = 1000
+
+// Counter is implemented by storage providers that can count their stored
+// resources without loading the properties of each resource. Index entries
+// are written before the resource, so they are not counted on their own.
+type Counter interface {
+	CountResources(ctx context.Context) (int64, error)
+}
+
+// CountResources counts all resources of a storage provider, by itself if it
+// is a Counter and otherwise by listing them page by page.
+func CountResources(ctx context.Context, storage Storage) (int64, error) {
+	if counter, ok := storage.(Counter); ok {
+		return counter.CountResources(ctx)
+	}
+
+	var count int64
+	cursor := ""
+	for {
+		list, err := storage.ListResources(ctx, "/", cursor, countPageSize)
+		if err != nil {
+			return 0, err
+		}
+		count += int64(len(list.Resources))
+		if list.NextCursor == "" {
+			return count, nil
+		}
+		cursor = list.NextCursor
+	}
+}
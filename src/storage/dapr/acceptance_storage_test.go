@@ -0,0 +1,522 @@
+package dapr
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/http/handlers"
+	httpserver "github.com/inx51/howlite-resources/http/server"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/stretchr/testify/require"
+)
+
+const testStoreName = "statestore"
+
+func newTestServer(t *testing.T) (*httptest.Server, *http.Client) {
+	t.Helper()
+
+	sidecar := newStubSidecar(t, testStoreName)
+	storageConfig := &configuration.DaprConfiguration{
+		ENDPOINT:   sidecar.URL,
+		STORE_NAME: testStoreName,
+	}
+
+	store := NewStorage(storageConfig)
+	bus := event.NewBus(nil, nil)
+	hs := &[]handlers.Handler{
+		handlers.NewGetHandler(&store),
+		handlers.NewCreateHandler(&store, bus),
+		handlers.NewReplaceHandler(&store, bus),
+		handlers.NewRemoveHandler(&store, bus),
+		handlers.NewExistsHandler(&store),
+	}
+
+	ts := httptest.NewServer(httpserver.NewServeMux(hs))
+	t.Cleanup(ts.Close)
+	return ts, ts.Client()
+}
+
+func TestAcceptance_GetResource_ReturnsResource(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+	require.Equal(t, http.StatusOK, getResp.StatusCode)
+	got, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+func TestAcceptance_GetResource_ReturnsNotFoundWhenResourceDoesNotExist(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	resp, err := client.Get(ts.URL + "/does/not/exist.txt")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAcceptance_ExistsResource_ReturnsNoContentWhenResourceExists(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodHead, ts.URL+"/my/resource.txt", nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestAcceptance_ExistsResource_ReturnsNotFoundWhenResourceDoesNotExist(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodHead, ts.URL+"/my/resource.txt", nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAcceptance_RemoveResource_RemovesResource(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/my/resource.txt", nil)
+	delResp, err := client.Do(req)
+	require.NoError(t, err)
+	delResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, delResp.StatusCode)
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	getResp.Body.Close()
+	require.Equal(t, http.StatusNotFound, getResp.StatusCode)
+}
+
+func TestAcceptance_RemoveResource_ReturnsNotFoundWhenResourceDoesNotExist(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/my/resource.txt", nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAcceptance_ReplaceResource_CreatesResourceWhenResourceDoesNotExist(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version one"))
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get("Location"))
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	body, err := io.ReadAll(getResp.Body)
+	getResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "version one", string(body))
+}
+
+func TestAcceptance_ReplaceResource_ReplacesExistingResource(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("version one"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version two"))
+	req.Header.Set("Content-Type", "text/plain")
+	replaceResp, err := client.Do(req)
+	require.NoError(t, err)
+	replaceResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, replaceResp.StatusCode)
+	require.NotEmpty(t, replaceResp.Header.Get("Location"))
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	body, err := io.ReadAll(getResp.Body)
+	getResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "version two", string(body))
+}
+
+func TestAcceptance_CreateResource(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	body := strings.NewReader("hello world")
+	resp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get("Location"))
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+	require.Equal(t, http.StatusOK, getResp.StatusCode)
+
+	got, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+func TestAcceptance_GetResource_ReturnsETagAndLastModified(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+	require.NotEmpty(t, postResp.Header.Get("ETag"))
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	getResp.Body.Close()
+	require.Equal(t, http.StatusOK, getResp.StatusCode)
+	require.Equal(t, postResp.Header.Get("ETag"), getResp.Header.Get("ETag"))
+	require.NotEmpty(t, getResp.Header.Get("Last-Modified"))
+}
+
+func TestAcceptance_GetResource_ReturnsNotModifiedWhenETagMatches(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("If-None-Match", postResp.Header.Get("ETag"))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestAcceptance_GetResource_ReturnsNotModifiedWhenNotModifiedSince(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("If-Modified-Since", postResp.Header.Get("Last-Modified"))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestAcceptance_ReplaceResource_ReplacesResourceWhenETagMatches(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("version one"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version two"))
+	req.Header.Set("If-Match", postResp.Header.Get("ETag"))
+	replaceResp, err := client.Do(req)
+	require.NoError(t, err)
+	replaceResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, replaceResp.StatusCode)
+	require.NotEqual(t, postResp.Header.Get("ETag"), replaceResp.Header.Get("ETag"))
+}
+
+func TestAcceptance_ReplaceResource_ReturnsPreconditionFailedWhenETagDoesNotMatch(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("version one"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version two"))
+	req.Header.Set("If-Match", "\"does-not-match\"")
+	replaceResp, err := client.Do(req)
+	require.NoError(t, err)
+	replaceResp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, replaceResp.StatusCode)
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	body, err := io.ReadAll(getResp.Body)
+	getResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "version one", string(body))
+}
+
+func TestAcceptance_ReplaceResource_ReturnsPreconditionFailedWhenResourceExistsAndIfNoneMatchIsWildcard(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("version one"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version two"))
+	req.Header.Set("If-None-Match", "*")
+	replaceResp, err := client.Do(req)
+	require.NoError(t, err)
+	replaceResp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, replaceResp.StatusCode)
+}
+
+func TestAcceptance_RemoveResource_ReturnsPreconditionFailedWhenETagDoesNotMatch(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("If-Match", "\"does-not-match\"")
+	delResp, err := client.Do(req)
+	require.NoError(t, err)
+	delResp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, delResp.StatusCode)
+}
+
+func TestAcceptance_GetResource_ReturnsPartialContentForSingleRange(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "bytes 6-10/11", resp.Header.Get("Content-Range"))
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(got))
+}
+
+func TestAcceptance_GetResource_ReturnsMultipartForMultipleRanges(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=0-4,-5")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/byteranges", mediaType)
+
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	expected := []struct{ contentRange, body string }{
+		{"bytes 0-4/11", "hello"},
+		{"bytes 6-10/11", "world"},
+	}
+	for _, e := range expected {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, e.contentRange, part.Header.Get("Content-Range"))
+		require.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+		got, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, e.body, string(got))
+	}
+	_, err = reader.NextPart()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestAcceptance_GetResource_ReturnsRangeNotSatisfiableWhenRangeIsOutOfBounds(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=100-200")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	require.Equal(t, "bytes */11", resp.Header.Get("Content-Range"))
+}
+
+func TestAcceptance_GetResource_ReturnsFullResourceWhenIfRangeDoesNotMatch(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	req.Header.Set("If-Range", "\"does-not-match\"")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+type listResponse struct {
+	Resources []struct {
+		Identifier   string `json:"identifier"`
+		Size         int64  `json:"size"`
+		ContentType  string `json:"contentType"`
+		LastModified string `json:"lastModified"`
+	} `json:"resources"`
+	NextCursor string `json:"nextCursor"`
+}
+
+func listResources(t *testing.T, ts *httptest.Server, client *http.Client, prefix string, query url.Values) listResponse {
+	t.Helper()
+	resp, err := client.Get(ts.URL + prefix + "?list&" + query.Encode())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var list listResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	return list
+}
+
+func TestAcceptance_ListResources_ReturnsResourcesMatchingPrefix(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	for _, path := range []string{"/docs/a.txt", "/docs/b.txt", "/images/c.png"} {
+		postResp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("hello world"))
+		require.NoError(t, err)
+		postResp.Body.Close()
+	}
+
+	list := listResources(t, ts, client, "/docs/", url.Values{})
+	require.Len(t, list.Resources, 2)
+	require.Equal(t, "/docs/a.txt", list.Resources[0].Identifier)
+	require.Equal(t, "/docs/b.txt", list.Resources[1].Identifier)
+	require.Equal(t, int64(11), list.Resources[0].Size)
+	require.Equal(t, "text/plain", list.Resources[0].ContentType)
+	require.NotEmpty(t, list.Resources[0].LastModified)
+	require.Empty(t, list.NextCursor)
+}
+
+func TestAcceptance_ListResources_PaginatesWithCursor(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	for _, path := range []string{"/docs/a.txt", "/docs/b.txt", "/docs/c.txt"} {
+		postResp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("hello world"))
+		require.NoError(t, err)
+		postResp.Body.Close()
+	}
+
+	var identifiers []string
+	query := url.Values{"limit": {"2"}}
+	for {
+		list := listResources(t, ts, client, "/docs/", query)
+		for _, listed := range list.Resources {
+			identifiers = append(identifiers, listed.Identifier)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", list.NextCursor)
+	}
+	require.Equal(t, []string{"/docs/a.txt", "/docs/b.txt", "/docs/c.txt"}, identifiers)
+}
+
+func TestAcceptance_ListResources_DoesNotReturnRemovedResources(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/docs/a.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/docs/a.txt", nil)
+	deleteResp, err := client.Do(req)
+	require.NoError(t, err)
+	deleteResp.Body.Close()
+
+	list := listResources(t, ts, client, "/docs/", url.Values{})
+	require.Empty(t, list.Resources)
+}
+
+func TestAcceptance_ListResources_ReturnsBadRequestWhenLimitIsInvalid(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	resp, err := client.Get(ts.URL + "/docs/?list&limit=0")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAcceptance_CreateResources_Concurrently_AreAllListed(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Post(ts.URL+"/concurrent/"+strconv.Itoa(i)+".txt", "text/plain", strings.NewReader("data"))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	list := listResources(t, ts, client, "/concurrent/", nil)
+	require.Len(t, list.Resources, 10)
+}
+
+func TestAcceptance_CountResources_CountsStoredResources(t *testing.T) {
+	ctx := context.Background()
+	sidecar := newStubSidecar(t, testStoreName)
+	store := NewStorage(&configuration.DaprConfiguration{ENDPOINT: sidecar.URL, STORE_NAME: testStoreName})
+	for _, identifier := range []string{"/docs/a.txt", "/docs/b.txt"} {
+		readCloser := io.NopCloser(strings.NewReader("hello world"))
+		require.NoError(t, store.SaveResource(ctx, resource.NewResource(resource.NewResourceIdentifier(identifier), &readCloser), nil))
+	}
+	// Left behind by a save that failed after indexing the resource.
+	require.NoError(t, store.(*Storage).updateIndex(ctx, resource.NewResourceIdentifier("/orphan.txt"), true))
+
+	count, err := storage.CountResources(ctx, store)
+
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+}
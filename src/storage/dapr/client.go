@@ -0,0 +1,187 @@
+package dapr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errEtagMismatch = errors.New("state etag mismatch")
+
+// stateClient talks to the state store API of a Dapr sidecar over HTTP.
+type stateClient struct {
+	endpoint   string
+	storeName  string
+	apiToken   string
+	httpClient *http.Client
+}
+
+type stateItem struct {
+	Key     string        `json:"key"`
+	Value   any           `json:"value"`
+	Etag    *string       `json:"etag,omitempty"`
+	Options *stateOptions `json:"options,omitempty"`
+}
+
+type stateOptions struct {
+	Concurrency string `json:"concurrency"`
+}
+
+func newStateClient(endpoint string, storeName string, apiToken string) *stateClient {
+	return &stateClient{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		storeName:  storeName,
+		apiToken:   apiToken,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// get returns the value stored under key and its etag, or found false if the
+// key does not exist.
+func (client *stateClient) get(ctx context.Context, key string, value any) (string, bool, error) {
+	resp, err := client.do(ctx, http.MethodGet, client.stateUrl(key), nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return "", false, nil
+	case http.StatusOK:
+	default:
+		return "", false, client.unexpectedStatus(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+		return "", false, err
+	}
+
+	return resp.Header.Get("ETag"), true, nil
+}
+
+type bulkStateItem struct {
+	Key  string          `json:"key"`
+	Data json.RawMessage `json:"data"`
+	Etag string          `json:"etag"`
+}
+
+// getBulk returns the values stored under keys, in a single request. The data
+// of keys that don't exist is empty.
+func (client *stateClient) getBulk(ctx context.Context, keys ...string) (map[string]bulkStateItem, error) {
+	body, err := json.Marshal(map[string][]string{"keys": keys})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.do(ctx, http.MethodPost, client.endpoint+"/v1.0/state/"+url.PathEscape(client.storeName)+"/bulk", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, client.unexpectedStatus(resp)
+	}
+
+	var items []bulkStateItem
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bulkStateItem, len(items))
+	for _, item := range items {
+		if len(item.Data) > 0 && string(item.Data) != "null" {
+			found[item.Key] = item
+		}
+	}
+	return found, nil
+}
+
+func (client *stateClient) save(ctx context.Context, items ...stateItem) error {
+	body, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(ctx, http.MethodPost, client.endpoint+"/v1.0/state/"+url.PathEscape(client.storeName), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return errEtagMismatch
+	default:
+		return client.unexpectedStatus(resp)
+	}
+}
+
+// delete removes the value stored under key, only if its etag still matches
+// when etag is set.
+func (client *stateClient) delete(ctx context.Context, key string, etag string) error {
+	stateUrl := client.stateUrl(key)
+	if etag != "" {
+		stateUrl += "?concurrency=first-write"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, stateUrl, nil)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	resp, err := client.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return errEtagMismatch
+	default:
+		return client.unexpectedStatus(resp)
+	}
+}
+
+func (client *stateClient) stateUrl(key string) string {
+	return client.endpoint + "/v1.0/state/" + url.PathEscape(client.storeName) + "/" + url.PathEscape(key)
+}
+
+func (client *stateClient) do(ctx context.Context, method string, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return client.send(req)
+}
+
+func (client *stateClient) send(req *http.Request) (*http.Response, error) {
+	if client.apiToken != "" {
+		req.Header.Set("dapr-api-token", client.apiToken)
+	}
+
+	return client.httpClient.Do(req)
+}
+
+func (client *stateClient) unexpectedStatus(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("dapr state store %q returned status %d: %s", client.storeName, resp.StatusCode, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,129 @@
+package dapr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// stubSidecar implements the parts of the Dapr state store HTTP API used by
+// the storage provider, keeping state in memory.
+type stubSidecar struct {
+	mutex sync.Mutex
+	state map[string]stubStateEntry
+	etag  int
+}
+
+type stubStateEntry struct {
+	value json.RawMessage
+	etag  string
+}
+
+func newStubSidecar(t *testing.T, storeName string) *httptest.Server {
+	t.Helper()
+	sidecar := &stubSidecar{state: make(map[string]stubStateEntry)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1.0/state/"+storeName+"/{key}", sidecar.get)
+	mux.HandleFunc("DELETE /v1.0/state/"+storeName+"/{key}", sidecar.delete)
+	mux.HandleFunc("POST /v1.0/state/"+storeName, sidecar.save)
+	mux.HandleFunc("POST /v1.0/state/"+storeName+"/bulk", sidecar.getBulk)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (sidecar *stubSidecar) get(resp http.ResponseWriter, req *http.Request) {
+	sidecar.mutex.Lock()
+	defer sidecar.mutex.Unlock()
+
+	entry, found := sidecar.state[req.PathValue("key")]
+	if !found {
+		resp.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp.Header().Set("ETag", entry.etag)
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(entry.value)
+}
+
+func (sidecar *stubSidecar) getBulk(resp http.ResponseWriter, req *http.Request) {
+	var request struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sidecar.mutex.Lock()
+	defer sidecar.mutex.Unlock()
+
+	type bulkItem struct {
+		Key  string          `json:"key"`
+		Data json.RawMessage `json:"data,omitempty"`
+		Etag string          `json:"etag,omitempty"`
+	}
+	items := make([]bulkItem, 0, len(request.Keys))
+	for _, key := range request.Keys {
+		entry := sidecar.state[key]
+		items = append(items, bulkItem{Key: key, Data: entry.value, Etag: entry.etag})
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(items)
+}
+
+func (sidecar *stubSidecar) delete(resp http.ResponseWriter, req *http.Request) {
+	sidecar.mutex.Lock()
+	defer sidecar.mutex.Unlock()
+
+	entry, found := sidecar.state[req.PathValue("key")]
+	if etag := req.Header.Get("If-Match"); etag != "" && (!found || entry.etag != etag) {
+		http.Error(resp, "possible etag mismatch", http.StatusConflict)
+		return
+	}
+	delete(sidecar.state, req.PathValue("key"))
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+func (sidecar *stubSidecar) save(resp http.ResponseWriter, req *http.Request) {
+	var items []struct {
+		Key     string          `json:"key"`
+		Value   json.RawMessage `json:"value"`
+		Etag    *string         `json:"etag"`
+		Options *stateOptions   `json:"options"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&items); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sidecar.mutex.Lock()
+	defer sidecar.mutex.Unlock()
+
+	for _, item := range items {
+		if item.Options == nil || item.Options.Concurrency != "first-write" {
+			continue
+		}
+		entry, found := sidecar.state[item.Key]
+		if (item.Etag == nil && found) || (item.Etag != nil && (!found || entry.etag != *item.Etag)) {
+			http.Error(resp, "possible etag mismatch", http.StatusConflict)
+			return
+		}
+	}
+
+	for _, item := range items {
+		sidecar.etag++
+		sidecar.state[item.Key] = stubStateEntry{
+			value: item.Value,
+			etag:  strconv.Itoa(sidecar.etag),
+		}
+	}
+	resp.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,471 @@
+package dapr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/tracer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// indexKey holds the sorted identifiers of all stored resources, since
+	// state stores can't list their keys.
+	indexKey = "index"
+	// healthKey is read by CheckHealth and never written.
+	healthKey              = "health"
+	maxIndexUpdateAttempts = 10
+	// countBatchSize is the number of properties items read at once when
+	// counting the resources.
+	countBatchSize = 1000
+)
+
+// Storage stores resources in a Dapr state store through the sidecar, so any
+// state store component configured in the cluster can be used. Each resource
+// is kept as two state items, its data and its properties, whose values are
+// base64 encoded by the JSON state API. The etag of the properties item is the
+// revision of the resource.
+type Storage struct {
+	client        *stateClient
+	configuration configuration.DaprConfiguration
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// GetResource gets the data and properties items in a single request.
+func (daprStorage *Storage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	stateKey := resourceIdentifier.ToUniqueFilename()
+	propertiesKey := resourceIdentifier.ToUniquePropertiesFilename()
+	logger.Debug(ctx, "trying to get dapr state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+
+	daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.get_bulk_state")
+	tracer.SetDebugAttributes(daprCtx, span,
+		attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+		attribute.String("dapr.key", stateKey),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	items, err := daprStorage.client.getBulk(daprCtx, stateKey, propertiesKey)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to get dapr state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey, "error", err)
+		return nil, err
+	}
+	dataItem, dataFound := items[stateKey]
+	propertiesItem, propertiesFound := items[propertiesKey]
+	if !dataFound || !propertiesFound {
+		logger.Debug(ctx, "dapr state not found", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+		return nil, storage.ErrResourceNotFound
+	}
+
+	var data, properties []byte
+	if err := json.Unmarshal(dataItem.Data, &data); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(propertiesItem.Data, &properties); err != nil {
+		return nil, err
+	}
+
+	resource, err := resource.LoadResource(resourceIdentifier, io.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		logger.Error(ctx, "failed to load resource from dapr state", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+	resource.Properties, err = daprStorage.loadProperties(properties, propertiesItem.Etag)
+	if err != nil {
+		logger.Error(ctx, "failed to load properties from dapr state", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+
+	logger.Debug(ctx, "successfully got dapr state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+	return resource, nil
+}
+
+func (daprStorage *Storage) loadProperties(properties []byte, etag string) (*resource.ResourceProperties, error) {
+	loadedProperties, err := resource.LoadResourceProperties(io.NopCloser(bytes.NewReader(properties)))
+	if err != nil {
+		return nil, err
+	}
+	loadedProperties.Revision = etag
+	return loadedProperties, nil
+}
+
+// GetResourceRange gets the whole resource, since state stores can't read a
+// part of a value, and returns the requested range of its body. The data and
+// properties items are read together, so the precondition is checked against
+// the revision of the data that is returned.
+func (daprStorage *Storage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	stateKey := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to get dapr state range", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey, "range.offset", offset, "range.length", length)
+
+	resource, err := daprStorage.GetResource(ctx, resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	if precondition != nil && (precondition.NotExists || resource.Properties.Revision != precondition.Revision) {
+		logger.Debug(ctx, "dapr state does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+		return nil, storage.ErrPreconditionFailed
+	}
+
+	// GetResource leaves the body positioned at its start.
+	if _, err := io.CopyN(io.Discard, *resource.Body, offset); err != nil {
+		logger.Error(ctx, "failed to seek dapr state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey, "error", err)
+		return nil, err
+	}
+	body := io.NopCloser(io.LimitReader(*resource.Body, length))
+	resource.Body = &body
+
+	logger.Debug(ctx, "successfully got dapr state range", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+	return resource, nil
+}
+
+// RemoveResource deletes the properties item first, with first-write
+// concurrency on the revision if a precondition is set, so that the data isn't
+// deleted when it fails.
+func (daprStorage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
+	if precondition != nil && precondition.NotExists {
+		// An existing resource never satisfies the precondition, deleting a
+		// missing one changes nothing.
+		exists, err := daprStorage.ResourceExists(ctx, resourceIdentifier)
+		if err == nil && exists {
+			err = storage.ErrPreconditionFailed
+		}
+		return err
+	}
+
+	etag := ""
+	if precondition != nil {
+		etag = precondition.Revision
+	}
+	for _, stateKey := range []string{resourceIdentifier.ToUniquePropertiesFilename(), resourceIdentifier.ToUniqueFilename()} {
+		logger.Debug(ctx, "trying to delete dapr state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+
+		daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.delete_state")
+		tracer.SetDebugAttributes(daprCtx, span,
+			attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+			attribute.String("dapr.key", stateKey),
+			attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+		)
+		err := daprStorage.client.delete(daprCtx, stateKey, etag)
+		tracer.SafeRecordError(span, err)
+		tracer.SafeEndSpan(span)
+
+		if errors.Is(err, errEtagMismatch) {
+			logger.Debug(ctx, "dapr state does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+			return storage.ErrPreconditionFailed
+		}
+		if err != nil {
+			logger.Error(ctx, "failed to delete dapr state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey, "error", err)
+			return err
+		}
+
+		logger.Debug(ctx, "successfully deleted dapr state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+		// The data item has an etag of its own, it follows the properties.
+		etag = ""
+	}
+
+	return daprStorage.updateIndex(ctx, resourceIdentifier, false)
+}
+
+func (daprStorage *Storage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	stateKey := resourceIdentifier.ToUniquePropertiesFilename()
+	logger.Debug(ctx, "trying to get dapr properties state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+
+	daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.get_state")
+	tracer.SetDebugAttributes(daprCtx, span,
+		attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+		attribute.String("dapr.key", stateKey),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	var properties []byte
+	etag, found, err := daprStorage.client.get(daprCtx, stateKey, &properties)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to get dapr properties state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey, "error", err)
+		return nil, err
+	}
+	if !found {
+		logger.Debug(ctx, "dapr properties state not found", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+		return nil, storage.ErrResourceNotFound
+	}
+
+	loadedProperties, err := daprStorage.loadProperties(properties, etag)
+	if err != nil {
+		logger.Error(ctx, "failed to load properties from dapr state", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+
+	logger.Debug(ctx, "successfully got dapr properties state", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+	return loadedProperties, nil
+}
+
+// ResourceExists checks the properties state item, which is saved together
+// with the data, to avoid getting the whole resource.
+func (daprStorage *Storage) ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error) {
+	stateKey := resourceIdentifier.ToUniquePropertiesFilename()
+	logger.Debug(ctx, "checking if dapr state exists", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey)
+
+	daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.get_state")
+	tracer.SetDebugAttributes(daprCtx, span,
+		attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+		attribute.String("dapr.key", stateKey),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	var properties []byte
+	_, found, err := daprStorage.client.get(daprCtx, stateKey, &properties)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to check dapr state existence", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey, "error", err)
+		return false, err
+	}
+
+	logger.Debug(ctx, "checked if dapr state exists", "resource.identifier", resourceIdentifier.Identifier(), "dapr.key", stateKey, "exists", found)
+	return found, nil
+}
+
+// ListResources reads the index, which holds the sorted identifiers of all
+// resources, and loads the properties of each listed resource. The cursor is
+// the last listed identifier.
+func (daprStorage *Storage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*storage.ResourceList, error) {
+	logger.Debug(ctx, "trying to list dapr index", "list.prefix", prefix, "list.cursor", cursor)
+
+	identifiers, _, err := daprStorage.getIndex(ctx)
+	if err != nil {
+		logger.Error(ctx, "failed to list dapr index", "list.prefix", prefix, "error", err)
+		return nil, err
+	}
+
+	start, _ := slices.BinarySearch(identifiers, max(prefix, cursor))
+	list := &storage.ResourceList{Resources: []*resource.ResourceProperties{}}
+	for _, identifier := range identifiers[start:] {
+		if identifier == cursor {
+			continue
+		}
+		if !strings.HasPrefix(identifier, prefix) {
+			break
+		}
+		if len(list.Resources) == limit {
+			list.NextCursor = list.Resources[limit-1].Identifier
+			break
+		}
+
+		properties, err := daprStorage.GetResourceProperties(ctx, resource.NewResourceIdentifier(identifier))
+		if errors.Is(err, storage.ErrResourceNotFound) {
+			// Removed since the index was read.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list.Resources = append(list.Resources, properties)
+	}
+
+	logger.Debug(ctx, "successfully listed dapr index", "list.prefix", prefix, "list.count", len(list.Resources))
+	return list, nil
+}
+
+// CountResources counts the identifiers in the index whose properties item
+// exists. Identifiers are indexed before the resource is saved and may be left
+// without one, the properties items are read in bulk to leave them out.
+func (daprStorage *Storage) CountResources(ctx context.Context) (int64, error) {
+	identifiers, _, err := daprStorage.getIndex(ctx)
+	if err != nil {
+		logger.Error(ctx, "failed to count dapr index", "error", err)
+		return 0, err
+	}
+
+	var count int64
+	for batch := range slices.Chunk(identifiers, countBatchSize) {
+		keys := make([]string, len(batch))
+		for i, identifier := range batch {
+			keys[i] = resource.NewResourceIdentifier(identifier).ToUniquePropertiesFilename()
+		}
+
+		daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.get_bulk_state")
+		tracer.SetDebugAttributes(daprCtx, span,
+			attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+			attribute.Int("dapr.keys", len(keys)),
+		)
+		items, err := daprStorage.client.getBulk(daprCtx, keys...)
+		tracer.SafeRecordError(span, err)
+		tracer.SafeEndSpan(span)
+
+		if err != nil {
+			logger.Error(ctx, "failed to count dapr state", "error", err)
+			return 0, err
+		}
+		count += int64(len(items))
+	}
+
+	return count, nil
+}
+
+func NewStorage(configuration *configuration.DaprConfiguration) storage.Storage {
+	return &Storage{
+		client:        newStateClient(configuration.ENDPOINT, configuration.STORE_NAME, configuration.API_TOKEN),
+		configuration: *configuration,
+	}
+}
+
+func (daprStorage *Storage) GetName() string {
+	return "dapr"
+}
+
+// CheckHealth reads a key that is never written, which checks that both the
+// sidecar and its state store are reachable.
+func (daprStorage *Storage) CheckHealth(ctx context.Context) error {
+	daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.state.health")
+	defer tracer.SafeEndSpan(span)
+
+	var value json.RawMessage
+	_, _, err := daprStorage.client.get(daprCtx, healthKey, &value)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// SaveResource buffers the resource, since state values are sent as a whole,
+// and saves its data and properties in a single request. The precondition is
+// enforced with first-write concurrency on the properties item, which is saved
+// first, so that the data isn't saved when it fails.
+func (daprStorage *Storage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *storage.Precondition) error {
+	stateKey := resource.Identifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to save dapr state", "resource.identifier", resource.Identifier.Identifier(), "dapr.key", stateKey)
+
+	var data bytes.Buffer
+	if err := resource.Write(nopWriteCloser{&data}); err != nil {
+		logger.Error(ctx, "failed to write resource", "resource.identifier", resource.Identifier.Identifier(), "error", err)
+		return err
+	}
+	properties, err := resource.Properties.Marshal()
+	if err != nil {
+		logger.Error(ctx, "failed to marshal resource properties", "resource.identifier", resource.Identifier.Identifier(), "error", err)
+		return err
+	}
+
+	daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.save_state")
+	tracer.SetDebugAttributes(daprCtx, span,
+		attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+		attribute.String("dapr.key", stateKey),
+		attribute.String("resource.identifier", resource.Identifier.Identifier()),
+		attribute.Int("dapr.value_size", data.Len()),
+	)
+	propertiesItem := stateItem{Key: resource.Identifier.ToUniquePropertiesFilename(), Value: properties}
+	if precondition != nil {
+		propertiesItem.Options = &stateOptions{Concurrency: "first-write"}
+		if !precondition.NotExists && precondition.Revision != "" {
+			propertiesItem.Etag = &precondition.Revision
+		}
+	}
+	err = daprStorage.client.save(daprCtx,
+		propertiesItem,
+		stateItem{Key: stateKey, Value: data.Bytes()},
+	)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if errors.Is(err, errEtagMismatch) && precondition != nil {
+		logger.Debug(ctx, "dapr state does not satisfy the precondition", "resource.identifier", resource.Identifier.Identifier(), "dapr.key", stateKey)
+		return storage.ErrPreconditionFailed
+	}
+	if err != nil {
+		logger.Error(ctx, "failed to save dapr state", "resource.identifier", resource.Identifier.Identifier(), "dapr.key", stateKey, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully saved dapr state", "resource.identifier", resource.Identifier.Identifier(), "dapr.key", stateKey)
+	return daprStorage.updateIndex(ctx, resource.Identifier, true)
+}
+
+func (daprStorage *Storage) getIndex(ctx context.Context) ([]string, string, error) {
+	daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.get_state")
+	tracer.SetDebugAttributes(daprCtx, span,
+		attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+		attribute.String("dapr.key", indexKey),
+	)
+	var identifiers []string
+	etag, _, err := daprStorage.client.get(daprCtx, indexKey, &identifiers)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	return identifiers, etag, err
+}
+
+// updateIndex adds or removes the identifier from the index. Concurrent
+// updates are detected with the etag of the index and retried.
+func (daprStorage *Storage) updateIndex(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, add bool) error {
+	identifier := resourceIdentifier.Identifier()
+	logger.Debug(ctx, "trying to update dapr index", "resource.identifier", identifier, "index.add", add)
+
+	for attempt := 1; attempt <= maxIndexUpdateAttempts; attempt++ {
+		identifiers, etag, err := daprStorage.getIndex(ctx)
+		if err != nil {
+			logger.Error(ctx, "failed to get dapr index", "resource.identifier", identifier, "error", err)
+			return err
+		}
+
+		position, found := slices.BinarySearch(identifiers, identifier)
+		if found == add {
+			return nil
+		}
+		if add {
+			identifiers = slices.Insert(identifiers, position, identifier)
+		} else {
+			identifiers = slices.Delete(identifiers, position, position+1)
+		}
+
+		item := stateItem{Key: indexKey, Value: identifiers, Options: &stateOptions{Concurrency: "first-write"}}
+		if etag != "" {
+			item.Etag = &etag
+		}
+
+		daprCtx, span := tracer.StartDebugSpan(ctx, "dapr.save_state")
+		tracer.SetDebugAttributes(daprCtx, span,
+			attribute.String("dapr.store", daprStorage.configuration.STORE_NAME),
+			attribute.String("dapr.key", indexKey),
+			attribute.String("resource.identifier", identifier),
+			attribute.Int("dapr.attempt", attempt),
+		)
+		err = daprStorage.client.save(daprCtx, item)
+		tracer.SafeRecordError(span, err)
+		tracer.SafeEndSpan(span)
+
+		if errors.Is(err, errEtagMismatch) {
+			logger.Debug(ctx, "dapr index changed concurrently, retrying", "resource.identifier", identifier, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			logger.Error(ctx, "failed to update dapr index", "resource.identifier", identifier, "error", err)
+			return err
+		}
+
+		logger.Debug(ctx, "successfully updated dapr index", "resource.identifier", identifier, "index.add", add)
+		return nil
+	}
+
+	logger.Error(ctx, "failed to update dapr index, too many concurrent updates", "resource.identifier", identifier)
+	return errors.New("failed to update dapr index after " + strconv.Itoa(maxIndexUpdateAttempts) + " attempts")
+}
@@ -1,23 +1,39 @@
 package filesystem
 
 import (
+	"context"
+	"encoding/json"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/inx51/howlite-resources/configuration"
 	"github.com/inx51/howlite-resources/event"
+	"github.com/inx51/howlite-resources/event/types"
+	"github.com/inx51/howlite-resources/health"
+	"github.com/inx51/howlite-resources/http/authz"
 	"github.com/inx51/howlite-resources/http/handlers"
 	httpserver "github.com/inx51/howlite-resources/http/server"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
 	"github.com/stretchr/testify/require"
 )
 
 func newTestServer(t *testing.T) (*httptest.Server, *http.Client) {
 	t.Helper()
-	dir := t.TempDir()
+	return newTestServerWithPath(t, t.TempDir())
+}
 
+func newTestServerWithPath(t *testing.T, dir string) (*httptest.Server, *http.Client) {
+	t.Helper()
 	store := NewStorage(&configuration.FilesystemConfiguration{PATH: dir})
 	bus := event.NewBus(nil, nil)
 	hs := &[]handlers.Handler{
@@ -172,3 +188,668 @@ func TestAcceptance_CreateResource(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "hello world", string(got))
 }
+
+func TestAcceptance_GetResource_ReturnsETagAndLastModified(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+	require.NotEmpty(t, postResp.Header.Get("ETag"))
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	getResp.Body.Close()
+	require.Equal(t, http.StatusOK, getResp.StatusCode)
+	require.Equal(t, postResp.Header.Get("ETag"), getResp.Header.Get("ETag"))
+	require.NotEmpty(t, getResp.Header.Get("Last-Modified"))
+}
+
+func TestAcceptance_GetResource_ReturnsNotModifiedWhenETagMatches(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("If-None-Match", postResp.Header.Get("ETag"))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestAcceptance_GetResource_ReturnsNotModifiedWhenNotModifiedSince(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("If-Modified-Since", postResp.Header.Get("Last-Modified"))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestAcceptance_ReplaceResource_ReplacesResourceWhenETagMatches(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("version one"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version two"))
+	req.Header.Set("If-Match", postResp.Header.Get("ETag"))
+	replaceResp, err := client.Do(req)
+	require.NoError(t, err)
+	replaceResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, replaceResp.StatusCode)
+	require.NotEqual(t, postResp.Header.Get("ETag"), replaceResp.Header.Get("ETag"))
+}
+
+func TestAcceptance_ReplaceResource_ReturnsPreconditionFailedWhenETagDoesNotMatch(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("version one"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version two"))
+	req.Header.Set("If-Match", "\"does-not-match\"")
+	replaceResp, err := client.Do(req)
+	require.NoError(t, err)
+	replaceResp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, replaceResp.StatusCode)
+
+	getResp, err := client.Get(ts.URL + "/my/resource.txt")
+	require.NoError(t, err)
+	body, err := io.ReadAll(getResp.Body)
+	getResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "version one", string(body))
+}
+
+func TestAcceptance_ReplaceResource_ReturnsPreconditionFailedWhenResourceExistsAndIfNoneMatchIsWildcard(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("version one"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("version two"))
+	req.Header.Set("If-None-Match", "*")
+	replaceResp, err := client.Do(req)
+	require.NoError(t, err)
+	replaceResp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, replaceResp.StatusCode)
+}
+
+func TestAcceptance_RemoveResource_ReturnsPreconditionFailedWhenETagDoesNotMatch(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("If-Match", "\"does-not-match\"")
+	delResp, err := client.Do(req)
+	require.NoError(t, err)
+	delResp.Body.Close()
+	require.Equal(t, http.StatusPreconditionFailed, delResp.StatusCode)
+}
+
+func TestAcceptance_GetResource_ReturnsPartialContentForSingleRange(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "bytes 6-10/11", resp.Header.Get("Content-Range"))
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(got))
+}
+
+func TestAcceptance_GetResource_ReturnsMultipartForMultipleRanges(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=0-4,-5")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/byteranges", mediaType)
+
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	expected := []struct{ contentRange, body string }{
+		{"bytes 0-4/11", "hello"},
+		{"bytes 6-10/11", "world"},
+	}
+	for _, e := range expected {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, e.contentRange, part.Header.Get("Content-Range"))
+		require.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+		got, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, e.body, string(got))
+	}
+	_, err = reader.NextPart()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestAcceptance_GetResource_ReturnsRangeNotSatisfiableWhenRangeIsOutOfBounds(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=100-200")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	require.Equal(t, "bytes */11", resp.Header.Get("Content-Range"))
+}
+
+func TestAcceptance_GetResource_ReturnsFullResourceWhenIfRangeDoesNotMatch(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/my/resource.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/my/resource.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	req.Header.Set("If-Range", "\"does-not-match\"")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+type listResponse struct {
+	Resources []struct {
+		Identifier   string `json:"identifier"`
+		Size         int64  `json:"size"`
+		ContentType  string `json:"contentType"`
+		LastModified string `json:"lastModified"`
+	} `json:"resources"`
+	NextCursor string `json:"nextCursor"`
+}
+
+func listResources(t *testing.T, ts *httptest.Server, client *http.Client, prefix string, query url.Values) listResponse {
+	t.Helper()
+	resp, err := client.Get(ts.URL + prefix + "?list&" + query.Encode())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var list listResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	return list
+}
+
+func TestAcceptance_ListResources_ReturnsResourcesMatchingPrefix(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	for _, path := range []string{"/docs/a.txt", "/docs/b.txt", "/images/c.png"} {
+		postResp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("hello world"))
+		require.NoError(t, err)
+		postResp.Body.Close()
+	}
+
+	list := listResources(t, ts, client, "/docs/", url.Values{})
+	require.Len(t, list.Resources, 2)
+	require.Equal(t, "/docs/a.txt", list.Resources[0].Identifier)
+	require.Equal(t, "/docs/b.txt", list.Resources[1].Identifier)
+	require.Equal(t, int64(11), list.Resources[0].Size)
+	require.Equal(t, "text/plain", list.Resources[0].ContentType)
+	require.NotEmpty(t, list.Resources[0].LastModified)
+	require.Empty(t, list.NextCursor)
+}
+
+func TestAcceptance_ListResources_PaginatesWithCursor(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	for _, path := range []string{"/docs/a.txt", "/docs/b.txt", "/docs/c.txt"} {
+		postResp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("hello world"))
+		require.NoError(t, err)
+		postResp.Body.Close()
+	}
+
+	var identifiers []string
+	query := url.Values{"limit": {"2"}}
+	for {
+		list := listResources(t, ts, client, "/docs/", query)
+		for _, listed := range list.Resources {
+			identifiers = append(identifiers, listed.Identifier)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", list.NextCursor)
+	}
+	require.Equal(t, []string{"/docs/a.txt", "/docs/b.txt", "/docs/c.txt"}, identifiers)
+}
+
+func TestAcceptance_ListResources_DoesNotReturnRemovedResources(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	postResp, err := client.Post(ts.URL+"/docs/a.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/docs/a.txt", nil)
+	deleteResp, err := client.Do(req)
+	require.NoError(t, err)
+	deleteResp.Body.Close()
+
+	list := listResources(t, ts, client, "/docs/", url.Values{})
+	require.Empty(t, list.Resources)
+}
+
+func TestAcceptance_ListResources_ReturnsResourcesInIdentifierOrder(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	for _, path := range []string{"/docs0", "/docs/b/c.txt", "/docs", "/docs/a b.txt", "/docs/b", "/doc"} {
+		postResp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("hello world"))
+		require.NoError(t, err)
+		postResp.Body.Close()
+	}
+
+	var identifiers []string
+	query := url.Values{"limit": {"1"}}
+	for {
+		list := listResources(t, ts, client, "/docs", query)
+		for _, listed := range list.Resources {
+			identifiers = append(identifiers, listed.Identifier)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", list.NextCursor)
+	}
+	require.Equal(t, []string{"/docs", "/docs/a b.txt", "/docs/b", "/docs/b/c.txt", "/docs0"}, identifiers)
+}
+
+func TestAcceptance_ListResources_ReturnsResourcesWithoutPropertiesFile(t *testing.T) {
+	dir := t.TempDir()
+	ts, client := newTestServerWithPath(t, dir)
+
+	postResp, err := client.Post(ts.URL+"/docs/a.txt", "text/plain", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	postResp.Body.Close()
+	storeLegacyResource(t, dir, "/docs/a.txt", "hello world", false)
+
+	list := listResources(t, ts, client, "/docs/", url.Values{})
+	require.Len(t, list.Resources, 1)
+	require.Equal(t, "/docs/a.txt", list.Resources[0].Identifier)
+	require.Equal(t, int64(11), list.Resources[0].Size)
+	require.Equal(t, "text/plain", list.Resources[0].ContentType)
+	require.NotEmpty(t, list.Resources[0].LastModified)
+}
+
+func TestAcceptance_ListResources_IndexesResourcesStoredBeforeTheIndex(t *testing.T) {
+	dir := t.TempDir()
+	storeLegacyResource(t, dir, "/docs/a.txt", "hello world", true)
+
+	ts, client := newTestServerWithPath(t, dir)
+	list := listResources(t, ts, client, "/docs/", url.Values{})
+	require.Len(t, list.Resources, 1)
+	require.Equal(t, "/docs/a.txt", list.Resources[0].Identifier)
+}
+
+func TestAcceptance_GetResource_ReturnsResourceStoredWithPropertiesFile(t *testing.T) {
+	dir := t.TempDir()
+	storeLegacyResource(t, dir, "/docs/a.txt", "hello world", true)
+	ts, client := newTestServerWithPath(t, dir)
+
+	getResp, err := client.Get(ts.URL + "/docs/a.txt")
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+	require.Equal(t, http.StatusOK, getResp.StatusCode)
+	require.NotEmpty(t, getResp.Header.Get("ETag"))
+	got, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+func TestAcceptance_ReplaceResource_ReplacesResourceStoredWithPropertiesFileWhenETagMatches(t *testing.T) {
+	dir := t.TempDir()
+	storeLegacyResource(t, dir, "/docs/a.txt", "hello world", true)
+	ts, client := newTestServerWithPath(t, dir)
+
+	headResp, err := client.Head(ts.URL + "/docs/a.txt")
+	require.NoError(t, err)
+	headResp.Body.Close()
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/docs/a.txt", strings.NewReader("replaced"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("If-Match", headResp.Header.Get("ETag"))
+	putResp, err := client.Do(req)
+	require.NoError(t, err)
+	putResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, putResp.StatusCode)
+	require.NoFileExists(t, filepath.Join(dir, resource.NewResourceIdentifier("/docs/a.txt").ToUniquePropertiesFilename()))
+}
+
+// storeLegacyResource stores a resource the way it was stored before the
+// properties were kept in the resource file, with or without a properties
+// file and without an index entry.
+func storeLegacyResource(t *testing.T, dir string, identifier string, content string, withPropertiesFile bool) {
+	t.Helper()
+	resourceIdentifier := resource.NewResourceIdentifier(identifier)
+	body := io.NopCloser(strings.NewReader(content))
+	stored := resource.NewResource(resourceIdentifier, &body)
+	stored.Headers.Add(context.Background(), "Content-Type", []string{"text/plain"})
+
+	file, err := os.Create(filepath.Join(dir, resourceIdentifier.ToUniqueFilename()))
+	require.NoError(t, err)
+	require.NoError(t, stored.Write(file))
+	if !withPropertiesFile {
+		return
+	}
+
+	file, err = os.Create(filepath.Join(dir, resourceIdentifier.ToUniquePropertiesFilename()))
+	require.NoError(t, err)
+	require.NoError(t, stored.Properties.Write(file))
+}
+
+func TestAcceptance_ListResources_ReturnsBadRequestWhenLimitIsInvalid(t *testing.T) {
+	ts, client := newTestServer(t)
+
+	resp, err := client.Get(ts.URL + "/docs/?list&limit=0")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAcceptance_CountResources_CountsStoredResources(t *testing.T) {
+	dir := t.TempDir()
+	ts, client := newTestServerWithPath(t, dir)
+
+	for _, path := range []string{"/docs/a.txt", "/docs/b/c.txt", "/d.txt"} {
+		postResp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("hello world"))
+		require.NoError(t, err)
+		postResp.Body.Close()
+	}
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/d.txt", nil)
+	require.NoError(t, err)
+	deleteResp, err := client.Do(req)
+	require.NoError(t, err)
+	deleteResp.Body.Close()
+	// Left behind by a save that failed after indexing the resource.
+	store := NewStorage(&configuration.FilesystemConfiguration{PATH: dir})
+	require.NoError(t, store.(*Storage).saveIndex(context.Background(), "/orphan.txt"))
+
+	count, err := storage.CountResources(context.Background(), store)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+}
+
+func TestAcceptance_ListResources_OnlyReturnsAuthorizedResources(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(policyPath, []byte(`{"rules": [
+		{"principals": ["*"], "methods": ["GET"], "paths": ["/team-a/**"]},
+		{"principals": ["*"], "methods": ["POST"], "paths": ["/**"]}
+	]}`), 0o600))
+	authorizer, err := authz.NewAuthorizer(policyPath)
+	require.NoError(t, err)
+	store := NewStorage(&configuration.FilesystemConfiguration{PATH: t.TempDir()})
+	hs := &[]handlers.Handler{
+		handlers.NewGetHandler(&store),
+		handlers.NewCreateHandler(&store, event.NewBus(nil, nil)),
+	}
+	ts := httptest.NewServer(httpserver.NewServeMux(hs, httpserver.WithAuthorizer(authorizer)))
+	t.Cleanup(ts.Close)
+	client := ts.Client()
+
+	for _, path := range []string{"/team-a/x", "/team-a-secret/x"} {
+		postResp, err := client.Post(ts.URL+path, "text/plain", strings.NewReader("hello world"))
+		require.NoError(t, err)
+		postResp.Body.Close()
+		require.Equal(t, http.StatusCreated, postResp.StatusCode)
+	}
+
+	list := listResources(t, ts, client, "/team-a", url.Values{})
+	require.Len(t, list.Resources, 1)
+	require.Equal(t, "/team-a/x", list.Resources[0].Identifier)
+}
+
+func newHealthTestServer(t *testing.T, path string, cacheTTL time.Duration) (*httptest.Server, *http.Client, *health.Checker) {
+	t.Helper()
+
+	store := NewStorage(&configuration.FilesystemConfiguration{PATH: path})
+	checker := health.NewChecker(time.Second, cacheTTL)
+	checker.Register("storage", store.CheckHealth)
+	hs := &[]handlers.Handler{
+		handlers.NewExistsHandler(&store),
+	}
+
+	ts := httptest.NewServer(httpserver.NewHandler(httpserver.NewServeMux(hs), httpserver.WithHealth(checker)))
+	t.Cleanup(ts.Close)
+	return ts, ts.Client(), checker
+}
+
+func TestAcceptance_Ready_ReturnsOkWhenStorageIsWritable(t *testing.T) {
+	ts, client, _ := newHealthTestServer(t, t.TempDir(), 0)
+
+	resp, err := client.Get(ts.URL + "/$sys/ready")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	require.Equal(t, health.StatusUp, report.Status)
+	require.Equal(t, health.StatusUp, report.Components["storage"].Status)
+}
+
+func TestAcceptance_Ready_ReturnsServiceUnavailableWithoutErrorWhenStorageIsMissing(t *testing.T) {
+	ts, client, _ := newHealthTestServer(t, filepath.Join(t.TempDir(), "missing"), 0)
+
+	resp, err := client.Get(ts.URL + "/$sys/ready")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	require.Equal(t, health.StatusDown, report.Status)
+	require.Equal(t, health.StatusDown, report.Components["storage"].Status)
+	require.Empty(t, report.Components["storage"].Error)
+}
+
+func TestAcceptance_Ready_ReturnsCachedReportWithinCacheTTL(t *testing.T) {
+	path := t.TempDir()
+	ts, client, _ := newHealthTestServer(t, path, time.Hour)
+
+	resp, err := client.Get(ts.URL + "/$sys/ready")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, os.RemoveAll(path))
+	resp, err = client.Get(ts.URL + "/$sys/ready")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAcceptance_Ready_ReturnsServiceUnavailableWhenDraining(t *testing.T) {
+	ts, client, checker := newHealthTestServer(t, t.TempDir(), 0)
+
+	checker.Drain()
+	resp, err := client.Get(ts.URL + "/$sys/ready")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	require.Equal(t, health.StatusDraining, report.Status)
+}
+
+func TestAcceptance_Live_ReturnsOkWhenStorageIsMissing(t *testing.T) {
+	ts, client, _ := newHealthTestServer(t, filepath.Join(t.TempDir(), "missing"), 0)
+
+	resp, err := client.Get(ts.URL + "/$sys/live")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func newEventTestServer(t *testing.T) (*httptest.Server, *http.Client, *event.Outbox) {
+	t.Helper()
+
+	store := NewStorage(&configuration.FilesystemConfiguration{PATH: t.TempDir()})
+	outbox := event.NewOutbox(context.Background(), filepath.Join(t.TempDir(), "outbox.db"))
+	t.Cleanup(func() { outbox.Close(context.Background()) })
+	bus := event.NewBus(nil, &outbox)
+	hs := &[]handlers.Handler{
+		handlers.NewCreateHandler(&store, bus),
+		handlers.NewReplaceHandler(&store, bus),
+		handlers.NewRemoveHandler(&store, bus),
+	}
+
+	ts := httptest.NewServer(httpserver.NewServeMux(hs))
+	t.Cleanup(ts.Close)
+	return ts, ts.Client(), &outbox
+}
+
+func dequeueEvent(t *testing.T, outbox *event.Outbox, eventType string, data any) {
+	t.Helper()
+	message := outbox.Next(context.Background())
+	require.NotNil(t, message)
+	outbox.Acknowledge(context.Background(), message)
+	require.Equal(t, eventType, message.Type)
+
+	envelope, err := event.DecodeEnvelope(message.Payload)
+	require.NoError(t, err)
+	require.Equal(t, eventType, envelope.Type)
+	require.NoError(t, json.Unmarshal(envelope.Data, data))
+}
+
+func TestAcceptance_Events_DescribeStoredAndPreviousResource(t *testing.T) {
+	ts, client, outbox := newEventTestServer(t)
+
+	postReq, _ := http.NewRequest(http.MethodPost, ts.URL+"/my/resource.txt", strings.NewReader("hello"))
+	postReq.Header.Set("Content-Type", "text/plain")
+	postReq.Header.Set("X-Request-Id", "request-1")
+	postResp, err := client.Do(postReq)
+	require.NoError(t, err)
+	postResp.Body.Close()
+	require.Equal(t, http.StatusCreated, postResp.StatusCode)
+
+	var created types.ResourceCreated
+	dequeueEvent(t, outbox, types.ResourceCreatedEventType, &created)
+	require.Equal(t, "/my/resource.txt", created.ResourceIdentity)
+	require.Equal(t, int64(5), created.Resource.ContentLength)
+	require.Equal(t, "text/plain", created.Resource.ContentType)
+	require.Equal(t, postResp.Header.Get("ETag"), created.Resource.ETag)
+	require.Equal(t, []string{"text/plain"}, created.Resource.Headers["Content-Type"])
+	require.Equal(t, "request-1", created.Caller.RequestId)
+
+	putReq, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("hello world"))
+	putResp, err := client.Do(putReq)
+	require.NoError(t, err)
+	putResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, putResp.StatusCode)
+
+	var replaced types.ResourceReplaced
+	dequeueEvent(t, outbox, types.ResourceRepalcedEventType, &replaced)
+	require.Equal(t, int64(11), replaced.Resource.ContentLength)
+	require.NotNil(t, replaced.Previous)
+	require.Equal(t, int64(5), replaced.Previous.ContentLength)
+	require.Equal(t, created.Resource.ContentHash, replaced.Previous.ContentHash)
+
+	deleteReq, _ := http.NewRequest(http.MethodDelete, ts.URL+"/my/resource.txt", nil)
+	deleteResp, err := client.Do(deleteReq)
+	require.NoError(t, err)
+	deleteResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, deleteResp.StatusCode)
+
+	var removed types.ResourceRemoved
+	dequeueEvent(t, outbox, types.ResourceRemoavedEventType, &removed)
+	require.NotNil(t, removed.Previous)
+	require.Equal(t, replaced.Resource.ContentHash, removed.Previous.ContentHash)
+}
+
+func TestAcceptance_Events_PublishCreatedWhenReplaceCreatesResource(t *testing.T) {
+	ts, client, outbox := newEventTestServer(t)
+
+	putReq, _ := http.NewRequest(http.MethodPut, ts.URL+"/my/resource.txt", strings.NewReader("hello"))
+	putResp, err := client.Do(putReq)
+	require.NoError(t, err)
+	putResp.Body.Close()
+	require.Equal(t, http.StatusCreated, putResp.StatusCode)
+
+	var created types.ResourceCreated
+	dequeueEvent(t, outbox, types.ResourceCreatedEventType, &created)
+	require.Equal(t, int64(5), created.Resource.ContentLength)
+}
+
+func TestAcceptance_RemoveResource_KeepsResourceReplacedAfterItWasRead(t *testing.T) {
+	ctx := context.Background()
+	store := NewStorage(&configuration.FilesystemConfiguration{PATH: t.TempDir()})
+	identifier := resource.NewResourceIdentifier("/docs/a.txt")
+	saveResource(t, store, identifier, "first")
+	read, err := store.GetResourceProperties(ctx, identifier)
+	require.NoError(t, err)
+	saveResource(t, store, identifier, "second")
+
+	err = store.RemoveResource(ctx, identifier, storage.PreconditionFor(read))
+
+	require.ErrorIs(t, err, storage.ErrPreconditionFailed)
+	exists, err := store.ResourceExists(ctx, identifier)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestAcceptance_GetResourceRange_ReturnsPreconditionFailedWhenResourceWasReplacedAfterItWasRead(t *testing.T) {
+	ctx := context.Background()
+	store := NewStorage(&configuration.FilesystemConfiguration{PATH: t.TempDir()})
+	identifier := resource.NewResourceIdentifier("/docs/a.txt")
+	saveResource(t, store, identifier, "first")
+	read, err := store.GetResourceProperties(ctx, identifier)
+	require.NoError(t, err)
+	saveResource(t, store, identifier, "second")
+
+	_, err = store.GetResourceRange(ctx, identifier, 0, 3, storage.PreconditionFor(read))
+
+	require.ErrorIs(t, err, storage.ErrPreconditionFailed)
+}
+
+func saveResource(t *testing.T, store storage.Storage, identifier *resource.ResourceIdentifier, body string) {
+	t.Helper()
+	readCloser := io.NopCloser(strings.NewReader(body))
+	require.NoError(t, store.SaveResource(context.Background(), resource.NewResource(identifier, &readCloser), nil))
+}
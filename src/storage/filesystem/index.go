@@ -0,0 +1,262 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/tracer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The resource files are named after a hash of their identifiers, so the
+// index directory mirrors the identifiers as a tree of empty files, e.g.
+// /docs/a.txt is indexed by index/docs%2F/a.txt. Directories carry an escaped
+// trailing slash, which no escaped file name can end with, so /docs and
+// /docs/a.txt can both be indexed. Listing walks the tree in identifier order
+// and stops as soon as a page is full.
+const (
+	indexDirectory       = "index"
+	indexCompleteFile    = "index.complete"
+	indexDirectorySuffix = "%2F"
+	// indexEmptySegment names the entry of an identifier ending with a slash,
+	// a lone percent sign can't be the result of escaping a segment.
+	indexEmptySegment = "%"
+)
+
+func (fileSystem *Storage) indexRoot() string {
+	return fileSystem.StoragePath + "/" + indexDirectory
+}
+
+func (fileSystem *Storage) indexPath(identifier string) string {
+	segments := strings.Split(strings.TrimPrefix(identifier, "/"), "/")
+	path := fileSystem.indexRoot()
+	for _, segment := range segments[:len(segments)-1] {
+		path += "/" + escapeIndexSegment(segment) + indexDirectorySuffix
+	}
+	last := segments[len(segments)-1]
+	if last == "" {
+		return path + "/" + indexEmptySegment
+	}
+	return path + "/" + escapeIndexSegment(last)
+}
+
+func escapeIndexSegment(segment string) string {
+	switch segment {
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+	return url.PathEscape(segment)
+}
+
+// indexKey returns the part of the identifiers that an index entry stands
+// for, directories end with a slash so that they sort like the identifiers
+// beneath them.
+func indexKey(name string, isDir bool) (string, bool) {
+	if isDir {
+		segment, found := strings.CutSuffix(name, indexDirectorySuffix)
+		if !found {
+			return "", false
+		}
+		key, err := url.PathUnescape(segment)
+		return key + "/", err == nil
+	}
+	if name == indexEmptySegment {
+		return "", true
+	}
+	key, err := url.PathUnescape(name)
+	return key, err == nil
+}
+
+func (fileSystem *Storage) saveIndex(ctx context.Context, identifier string) error {
+	path := fileSystem.indexPath(identifier)
+
+	osCreateCtx, span := tracer.StartDebugSpan(ctx, "os.create")
+	tracer.SetDebugAttributes(osCreateCtx, span,
+		attribute.String("file.path", path),
+		attribute.String("resource.identifier", identifier),
+	)
+	defer tracer.SafeEndSpan(span)
+
+	// A concurrent removal may prune the parent directories between creating
+	// them and the index file, so the creation is retried once.
+	var err error
+	for range 2 {
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			break
+		}
+		var file *os.File
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+		if err == nil {
+			file.Close()
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+
+	tracer.SafeRecordError(span, err)
+	logger.Error(ctx, "failed to create index file", "resource.identifier", identifier, "file.path", path, "error", err)
+	return err
+}
+
+func (fileSystem *Storage) removeIndex(ctx context.Context, identifier string) error {
+	path := fileSystem.indexPath(identifier)
+
+	osRemoveCtx, span := tracer.StartDebugSpan(ctx, "os.remove")
+	tracer.SetDebugAttributes(osRemoveCtx, span,
+		attribute.String("file.path", path),
+		attribute.String("resource.identifier", identifier),
+	)
+	defer tracer.SafeEndSpan(span)
+
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "failed to remove index file", "resource.identifier", identifier, "file.path", path, "error", err)
+		return err
+	}
+
+	// Prune the directories left empty, removing a directory that isn't empty
+	// fails and ends the pruning.
+	for dir := filepath.Dir(path); dir != fileSystem.indexRoot(); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// walkIndex visits the indexed identifiers starting with prefix and sorting
+// after cursor, in order, until visit returns false.
+func (fileSystem *Storage) walkIndex(ctx context.Context, prefix string, cursor string, visit func(identifier string) (bool, error)) error {
+	osReadDirCtx, span := tracer.StartDebugSpan(ctx, "os.read_dir")
+	tracer.SetDebugAttributes(osReadDirCtx, span,
+		attribute.String("file.path", fileSystem.indexRoot()),
+		attribute.String("list.prefix", prefix),
+	)
+	defer tracer.SafeEndSpan(span)
+
+	_, err := walkIndexDirectory(fileSystem.indexRoot(), "/", prefix, cursor, visit)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+type indexEntry struct {
+	key   string
+	name  string
+	isDir bool
+}
+
+func walkIndexDirectory(dir string, base string, prefix string, cursor string, visit func(identifier string) (bool, error)) (bool, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && base != "/" {
+			// Pruned by a concurrent removal.
+			return true, nil
+		}
+		return false, err
+	}
+
+	entries := make([]indexEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		key, ok := indexKey(dirEntry.Name(), dirEntry.IsDir())
+		if ok {
+			entries = append(entries, indexEntry{key: base + key, name: dirEntry.Name(), isDir: dirEntry.IsDir()})
+		}
+	}
+	slices.SortFunc(entries, func(a, b indexEntry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.key, prefix) {
+			if entry.key > prefix && !(entry.isDir && strings.HasPrefix(prefix, entry.key)) {
+				// Everything from here on sorts after the prefix.
+				return false, nil
+			}
+			if !entry.isDir || !strings.HasPrefix(prefix, entry.key) {
+				continue
+			}
+		}
+
+		if entry.isDir {
+			if entry.key < cursor && !strings.HasPrefix(cursor, entry.key) {
+				continue
+			}
+			more, err := walkIndexDirectory(dir+"/"+entry.name, entry.key, prefix, cursor, visit)
+			if err != nil || !more {
+				return more, err
+			}
+			continue
+		}
+
+		if entry.key <= cursor {
+			continue
+		}
+		more, err := visit(entry.key)
+		if err != nil || !more {
+			return more, err
+		}
+	}
+	return true, nil
+}
+
+// indexLegacyProperties indexes the resources stored before the index
+// existed, from the identifiers kept in their properties files. Resources
+// without a properties file only have a hash of their identifier on disk and
+// are indexed once they are saved again.
+func (fileSystem *Storage) indexLegacyProperties(ctx context.Context) {
+	completePath := fileSystem.StoragePath + "/" + indexCompleteFile
+	if _, err := os.Stat(completePath); err == nil {
+		return
+	}
+
+	entries, err := os.ReadDir(fileSystem.StoragePath)
+	if err != nil {
+		logger.Error(ctx, "failed to read storage directory", "file.path", fileSystem.StoragePath, "error", err)
+		return
+	}
+
+	indexed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".properties") {
+			continue
+		}
+
+		path := fileSystem.StoragePath + "/" + entry.Name()
+		reader, err := os.Open(path)
+		if err != nil {
+			logger.Warn(ctx, "failed to open properties file", "file.path", path, "error", err)
+			continue
+		}
+		properties, err := resource.LoadResourceProperties(reader)
+		if err != nil {
+			logger.Warn(ctx, "failed to load properties from file", "file.path", path, "error", err)
+			continue
+		}
+		if err := fileSystem.saveIndex(ctx, properties.Identifier); err != nil {
+			return
+		}
+		indexed++
+	}
+
+	if err := os.WriteFile(completePath, nil, fs.FileMode(0o644)); err != nil {
+		logger.Error(ctx, "failed to mark the index as complete", "file.path", completePath, "error", err)
+		return
+	}
+	logger.Info(ctx, "Indexed stored resources", "file.path", fileSystem.StoragePath, "count", indexed)
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package filesystem
+
+import "os"
+
+// lockExclusive is a no-op where advisory file locks aren't available, saves
+// are then only serialized within the instance.
+func lockExclusive(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockExclusive blocks until the file is locked, the lock is released when
+// the file is closed.
+func lockExclusive(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
@@ -2,8 +2,14 @@ package filesystem
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/inx51/howlite-resources/configuration"
 	"github.com/inx51/howlite-resources/logger"
@@ -13,8 +19,27 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 )
 
+const (
+	// lockFile is locked while a resource file is replaced or removed, so that
+	// conditional saves of instances sharing the storage path don't interleave.
+	lockFile = ".lock"
+	// countBatchSize is the number of directory entries read at once when
+	// counting the resources.
+	countBatchSize = 1000
+)
+
+// Storage keeps each resource in a single file, its headers, body and
+// properties, named after a hash of its identifier. Resources are written to
+// a temporary file first and moved in place once complete, so readers never
+// see a partially written resource.
 type Storage struct {
 	StoragePath string
+	mutex       sync.Mutex
+}
+
+type rangeReadCloser struct {
+	io.Reader
+	io.Closer
 }
 
 func (fileSystem *Storage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
@@ -26,7 +51,7 @@ func (fileSystem *Storage) GetResource(ctx context.Context, resourceIdentifier *
 		attribute.String("file.path", path),
 		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
 	)
-	reader, err := os.Open(path)
+	file, err := os.Open(path)
 	if err != nil {
 		tracer.SafeRecordError(span, err)
 	}
@@ -35,31 +60,189 @@ func (fileSystem *Storage) GetResource(ctx context.Context, resourceIdentifier *
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			logger.Debug(ctx, "file not found", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+			return nil, storage.ErrResourceNotFound
+		}
+		logger.Error(ctx, "failed to open file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "error", err)
+		return nil, err
+	}
+
+	resource, err := fileSystem.loadResource(ctx, resourceIdentifier, file)
+	if err != nil {
+		file.Close()
+		logger.Error(ctx, "failed to load resource from file", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+	logger.Debug(ctx, "successfully read file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+	return resource, nil
+}
+
+// loadResource loads the resource and its properties from its trailer, or
+// for resources stored before the properties were kept in the resource file,
+// from the properties file or the resource file itself.
+func (fileSystem *Storage) loadResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, file *os.File) (*resource.Resource, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := resource.LoadStoredResource(resourceIdentifier, file, info.Size())
+	if err == nil {
+		return loaded, nil
+	}
+	if !errors.Is(err, resource.ErrNoPropertiesTrailer) {
+		return nil, err
+	}
+
+	properties, err := fileSystem.loadLegacyProperties(ctx, resourceIdentifier)
+	if err != nil {
+		(*loaded.Body).Close()
+		return nil, err
+	}
+	if properties == nil {
+		headersLength, err := resource.HeadersSectionLength(io.NopCloser(io.NewSectionReader(file, 0, resource.HeadersLengthPrefixSize)))
+		if err != nil {
+			(*loaded.Body).Close()
 			return nil, err
 		}
+		properties = resource.NewResourceProperties(resourceIdentifier)
+		properties.ContentLength = info.Size() - headersLength
+		properties.ContentType = loaded.Headers.Get("Content-Type")
+		properties.LastModified = info.ModTime().UTC()
+	}
+	properties.Revision = legacyRevision(info)
+	loaded.Properties = properties
+	return loaded, nil
+}
+
+// loadLegacyProperties reads the properties file that was written next to
+// resource files before the properties were kept in the resource file, it
+// returns nil properties if there is none.
+func (fileSystem *Storage) loadLegacyProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	path := fileSystem.propertiesPath(resourceIdentifier)
+	logger.Debug(ctx, "trying to read properties file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+
+	reader, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Debug(ctx, "properties file not found", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+			return nil, nil
+		}
+		logger.Error(ctx, "failed to open properties file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "error", err)
+		return nil, err
+	}
+
+	return resource.LoadResourceProperties(reader)
+}
+
+// legacyRevision identifies a version of a resource file without properties
+// trailer, which has no revision of its own.
+func legacyRevision(info os.FileInfo) string {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10) + "-" + strconv.FormatInt(info.Size(), 10)
+}
+
+// GetResourceRange reads the headers and the given range of the body. The
+// precondition is checked against the opened file, which is replaced rather
+// than rewritten, so the range is read from the same revision.
+func (fileSystem *Storage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	path := fileSystem.resourcePath(resourceIdentifier)
+	logger.Debug(ctx, "trying to read file range", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "range.offset", offset, "range.length", length)
+
+	osOpenCtx, span := tracer.StartDebugSpan(ctx, "os.open")
+	tracer.SetDebugAttributes(osOpenCtx, span,
+		attribute.String("file.path", path),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	reader, err := os.Open(path)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+	}
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, storage.ErrResourceNotFound
+		}
 		logger.Error(ctx, "failed to open file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "error", err)
 		return nil, err
 	}
 
+	if precondition != nil {
+		// loadResource reads the file at offsets, it stays positioned at the start.
+		stored, err := fileSystem.loadResource(ctx, resourceIdentifier, reader)
+		if err != nil {
+			reader.Close()
+			logger.Error(ctx, "failed to load resource from file", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+			return nil, err
+		}
+		if precondition.NotExists || stored.Properties.Revision != precondition.Revision {
+			reader.Close()
+			logger.Debug(ctx, "file changed since it was read", "resource.identifier", resourceIdentifier.Identifier())
+			return nil, storage.ErrPreconditionFailed
+		}
+	}
+
 	resource, err := resource.LoadResource(resourceIdentifier, reader)
 	if err != nil {
+		reader.Close()
 		logger.Error(ctx, "failed to load resource from file", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
 		return nil, err
 	}
-	logger.Debug(ctx, "successfully read file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
-	return resource, err
+
+	// LoadResource leaves the file positioned at the start of the body.
+	osSeekCtx, span := tracer.StartDebugSpan(ctx, "os.seek")
+	tracer.SetDebugAttributes(osSeekCtx, span,
+		attribute.String("file.path", path),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+		attribute.Int64("range.offset", offset),
+		attribute.Int64("range.length", length),
+	)
+	_, err = reader.Seek(offset, io.SeekCurrent)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+	}
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		reader.Close()
+		logger.Error(ctx, "failed to seek file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "error", err)
+		return nil, err
+	}
+
+	var body io.ReadCloser = &rangeReadCloser{
+		Reader: io.LimitReader(reader, length),
+		Closer: reader,
+	}
+	resource.Body = &body
+	logger.Debug(ctx, "successfully read file range", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+	return resource, nil
 }
 
-func (fileSystem *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+// RemoveResource checks the precondition and removes the resource file while
+// holding the same lock as commit, so that the file can't be replaced in
+// between.
+func (fileSystem *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
 	path := fileSystem.resourcePath(resourceIdentifier)
 	logger.Debug(ctx, "trying to remove file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
 
+	unlock, err := fileSystem.lock(ctx)
+	if err != nil {
+		return err
+	}
+
+	if precondition != nil {
+		if err := fileSystem.checkRevision(ctx, resourceIdentifier, precondition); err != nil {
+			unlock()
+			return err
+		}
+	}
+
 	osRemoveCtx, span := tracer.StartDebugSpan(ctx, "os.remove")
 	tracer.SetDebugAttributes(osRemoveCtx, span,
 		attribute.String("file.path", path),
 		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
 	)
-	err := os.Remove(path)
+	err = os.Remove(path)
+	unlock()
 	defer tracer.SafeEndSpan(span)
 
 	if err != nil {
@@ -68,6 +251,32 @@ func (fileSystem *Storage) RemoveResource(ctx context.Context, resourceIdentifie
 		return err
 	}
 	logger.Debug(ctx, "successfully removed file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+	if err := fileSystem.removeProperties(ctx, resourceIdentifier); err != nil {
+		return err
+	}
+	return fileSystem.removeIndex(ctx, resourceIdentifier.Identifier())
+}
+
+// removeProperties removes the properties file of a resource stored before
+// the properties were kept in the resource file.
+func (fileSystem *Storage) removeProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	path := fileSystem.propertiesPath(resourceIdentifier)
+	logger.Debug(ctx, "trying to remove properties file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+
+	osRemoveCtx, span := tracer.StartDebugSpan(ctx, "os.remove")
+	tracer.SetDebugAttributes(osRemoveCtx, span,
+		attribute.String("file.path", path),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	err := os.Remove(path)
+	defer tracer.SafeEndSpan(span)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "failed to remove properties file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "error", err)
+		return err
+	}
+	logger.Debug(ctx, "successfully removed properties file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
 	return nil
 }
 
@@ -97,24 +306,134 @@ func (fileSystem *Storage) ResourceExists(ctx context.Context, resourceIdentifie
 	return true, nil
 }
 
+// GetResourceProperties reads the properties from the end of the resource
+// file, the body isn't read.
+func (fileSystem *Storage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	resource, err := fileSystem.GetResource(ctx, resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	(*resource.Body).Close()
+	return resource.Properties, nil
+}
+
+// ListResources walks the index, which mirrors the identifiers of the hashed
+// resource files, in identifier order and stops once the page is full. The
+// cursor is the identifier of the last resource on the previous page.
+func (fileSystem *Storage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*storage.ResourceList, error) {
+	logger.Debug(ctx, "trying to list index files", "file.path", fileSystem.indexRoot(), "list.prefix", prefix, "list.cursor", cursor)
+
+	resources := []*resource.ResourceProperties{}
+	err := fileSystem.walkIndex(ctx, prefix, cursor, func(identifier string) (bool, error) {
+		properties, err := fileSystem.GetResourceProperties(ctx, resource.NewResourceIdentifier(identifier))
+		if errors.Is(err, storage.ErrResourceNotFound) {
+			// Removed since it was indexed.
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		resources = append(resources, properties)
+		return len(resources) <= limit, nil
+	})
+	if err != nil {
+		logger.Error(ctx, "failed to list index files", "file.path", fileSystem.indexRoot(), "error", err)
+		return nil, err
+	}
+
+	list := &storage.ResourceList{Resources: resources}
+	if len(resources) > limit {
+		list.Resources = resources[:limit]
+		list.NextCursor = resources[limit-1].Identifier
+	}
+
+	logger.Debug(ctx, "successfully listed index files", "file.path", fileSystem.indexRoot(), "list.count", len(list.Resources))
+	return list, nil
+}
+
+// CountResources counts the resource files, without reading them. Index files
+// are written before the resource file and may be left without one, so they
+// are not counted.
+func (fileSystem *Storage) CountResources(ctx context.Context) (int64, error) {
+	osReadDirCtx, span := tracer.StartDebugSpan(ctx, "os.read_dir")
+	tracer.SetDebugAttributes(osReadDirCtx, span, attribute.String("file.path", fileSystem.StoragePath))
+	defer tracer.SafeEndSpan(span)
+
+	directory, err := os.Open(fileSystem.StoragePath)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "failed to count resource files", "file.path", fileSystem.StoragePath, "error", err)
+		return 0, err
+	}
+	defer directory.Close()
+
+	var count int64
+	for {
+		entries, err := directory.ReadDir(countBatchSize)
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), resource.UniqueFilenameSuffix) {
+				count++
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			tracer.SafeRecordError(span, err)
+			logger.Error(ctx, "failed to count resource files", "file.path", fileSystem.StoragePath, "error", err)
+			return 0, err
+		}
+	}
+}
+
 func NewStorage(configuration *configuration.FilesystemConfiguration) storage.Storage {
-	return &Storage{StoragePath: configuration.PATH}
+	fileSystem := &Storage{StoragePath: configuration.PATH}
+	fileSystem.indexLegacyProperties(context.Background())
+	return fileSystem
 }
 
 func (fileSystem *Storage) GetName() string {
 	return "filesystem"
 }
 
-func (fileSystem *Storage) SaveResource(ctx context.Context, resource *resource.Resource) error {
+// CheckHealth checks that the storage path is writable by creating and
+// removing a temporary file in it.
+func (fileSystem *Storage) CheckHealth(ctx context.Context) error {
+	osCreateCtx, span := tracer.StartDebugSpan(ctx, "os.create_temp")
+	tracer.SetDebugAttributes(osCreateCtx, span, attribute.String("file.path", fileSystem.StoragePath))
+	defer tracer.SafeEndSpan(span)
+
+	file, err := os.CreateTemp(fileSystem.StoragePath, ".health-*")
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	file.Close()
+
+	if err := os.Remove(file.Name()); err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// SaveResource writes the resource and its properties to a temporary file,
+// which is then moved in place if the precondition is satisfied.
+func (fileSystem *Storage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *storage.Precondition) error {
 	path := fileSystem.resourcePath(resource.Identifier)
 	logger.Debug(ctx, "trying to create file", "resource.identifier", resource.Identifier.Identifier(), "file.path", path)
 
-	osCreateCtx, span := tracer.StartDebugSpan(ctx, "os.create")
+	// Indexed first, listing skips index files of resources that don't exist.
+	if err := fileSystem.saveIndex(ctx, resource.Identifier.Identifier()); err != nil {
+		return err
+	}
+
+	osCreateCtx, span := tracer.StartDebugSpan(ctx, "os.create_temp")
 	tracer.SetDebugAttributes(osCreateCtx, span,
 		attribute.String("file.path", path),
 		attribute.String("resource.identifier", resource.Identifier.Identifier()),
 	)
-	writer, err := os.Create(path)
+	writer, err := os.CreateTemp(fileSystem.StoragePath, ".save-*")
 	if err != nil {
 		tracer.SafeRecordError(span, err)
 	}
@@ -124,13 +443,127 @@ func (fileSystem *Storage) SaveResource(ctx context.Context, resource *resource.
 		logger.Error(ctx, "failed to create file", "resource.identifier", resource.Identifier.Identifier(), "file.path", path, "error", err)
 		return err
 	}
+	defer os.Remove(writer.Name())
 
-	logger.Debug(ctx, "successfully created file", "resource.identifier", resource.Identifier.Identifier(), "file.path", path)
-	resource.Write(writer)
+	logger.Debug(ctx, "successfully created file", "resource.identifier", resource.Identifier.Identifier(), "file.path", writer.Name())
+	resource.Properties.Revision = newRevision()
+	err = resource.WriteWithProperties(writer)
 	writer.Close()
+	if err != nil {
+		logger.Error(ctx, "failed to write resource to file", "resource.identifier", resource.Identifier.Identifier(), "file.path", writer.Name(), "error", err)
+		return err
+	}
+
+	if err := fileSystem.commit(ctx, resource.Identifier, writer.Name(), precondition); err != nil {
+		return err
+	}
+	return fileSystem.removeProperties(ctx, resource.Identifier)
+}
+
+// commit moves a written resource file in place, holding the lock so that
+// the stored revision can't change between checking and replacing it.
+func (fileSystem *Storage) commit(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, writtenPath string, precondition *storage.Precondition) error {
+	path := fileSystem.resourcePath(resourceIdentifier)
+
+	unlock, err := fileSystem.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	osRenameCtx, span := tracer.StartDebugSpan(ctx, "os.rename")
+	tracer.SetDebugAttributes(osRenameCtx, span,
+		attribute.String("file.path", path),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	defer tracer.SafeEndSpan(span)
+
+	switch {
+	case precondition == nil:
+	case precondition.NotExists:
+		// Linking fails if the resource file exists, without replacing it.
+		err = os.Link(writtenPath, path)
+		if errors.Is(err, os.ErrExist) {
+			logger.Debug(ctx, "file already exists", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path)
+			return storage.ErrPreconditionFailed
+		}
+		if err != nil {
+			tracer.SafeRecordError(span, err)
+			logger.Error(ctx, "failed to link file", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "error", err)
+		}
+		return err
+	default:
+		err := fileSystem.checkRevision(ctx, resourceIdentifier, precondition)
+		if err != nil && !errors.Is(err, storage.ErrPreconditionFailed) {
+			tracer.SafeRecordError(span, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(writtenPath, path); err != nil {
+		tracer.SafeRecordError(span, err)
+		logger.Error(ctx, "failed to move file in place", "resource.identifier", resourceIdentifier.Identifier(), "file.path", path, "error", err)
+		return err
+	}
+	return nil
+}
+
+// checkRevision fails with storage.ErrPreconditionFailed unless the stored
+// resource satisfies the precondition, it must be called holding the lock.
+func (fileSystem *Storage) checkRevision(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
+	stored, err := fileSystem.GetResourceProperties(ctx, resourceIdentifier)
+	if errors.Is(err, storage.ErrResourceNotFound) {
+		if precondition.NotExists {
+			return nil
+		}
+		logger.Debug(ctx, "file removed since it was read", "resource.identifier", resourceIdentifier.Identifier())
+		return storage.ErrPreconditionFailed
+	}
+	if err != nil {
+		return err
+	}
+	if precondition.NotExists || stored.Revision != precondition.Revision {
+		logger.Debug(ctx, "file changed since it was read", "resource.identifier", resourceIdentifier.Identifier())
+		return storage.ErrPreconditionFailed
+	}
+
 	return nil
 }
 
+// lock locks the storage path for this and other instances sharing it.
+func (fileSystem *Storage) lock(ctx context.Context) (func(), error) {
+	fileSystem.mutex.Lock()
+	file, err := os.OpenFile(fileSystem.StoragePath+"/"+lockFile, os.O_CREATE|os.O_RDWR, 0o644)
+	if err == nil {
+		err = lockExclusive(file)
+		if err != nil {
+			file.Close()
+		}
+	}
+	if err != nil {
+		fileSystem.mutex.Unlock()
+		logger.Error(ctx, "failed to lock storage path", "file.path", fileSystem.StoragePath, "error", err)
+		return nil, err
+	}
+
+	return func() {
+		file.Close()
+		fileSystem.mutex.Unlock()
+	}, nil
+}
+
+func newRevision() string {
+	revision := make([]byte, 16)
+	rand.Read(revision)
+	return hex.EncodeToString(revision)
+}
+
 func (fileSystem *Storage) resourcePath(resourceIdentifier *resource.ResourceIdentifier) string {
 	return fileSystem.StoragePath + "/" + resourceIdentifier.ToUniqueFilename()
 }
+
+func (fileSystem *Storage) propertiesPath(resourceIdentifier *resource.ResourceIdentifier) string {
+	return fileSystem.StoragePath + "/" + resourceIdentifier.ToUniquePropertiesFilename()
+}
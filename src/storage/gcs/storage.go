@@ -0,0 +1,639 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gcsstorage "cloud.google.com/go/storage"
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/tracer"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+const (
+	indexObjectPrefix = "index/"
+	// maxReadAttempts limits how often reading a resource is restarted when
+	// it's replaced while it's read.
+	maxReadAttempts = 3
+)
+
+// errObjectChanged is returned when the object is replaced between reading
+// its properties and its body.
+var errObjectChanged = errors.New("gcs object changed while it was read")
+
+type Storage struct {
+	bucket        *gcsstorage.BucketHandle
+	configuration configuration.GcsConfiguration
+}
+
+// GetResource reads the end of the object first, which holds the properties,
+// and then its headers and body from the same generation of the object.
+// Resources small enough to be read with the properties take a single request.
+func (gcsStorage *Storage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	objectName := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to download gcs object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+
+	for attempt := 1; ; attempt++ {
+		resource, err := gcsStorage.getResource(ctx, resourceIdentifier)
+		if errors.Is(err, errObjectChanged) && attempt < maxReadAttempts {
+			logger.Debug(ctx, "gcs object changed while it was read, retrying", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug(ctx, "successfully downloaded gcs object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+		return resource, nil
+	}
+}
+
+func (gcsStorage *Storage) getResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	tail, err := gcsStorage.getObjectTail(ctx, resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	if int64(len(tail.data)) == tail.size {
+		body = io.NopCloser(bytes.NewReader(tail.data[:tail.size-tail.trailerSize]))
+	} else {
+		body, err = gcsStorage.readObject(ctx, resourceIdentifier, 0, tail.size-tail.trailerSize, tail.generation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resource, err := resource.LoadResource(resourceIdentifier, body)
+	if err != nil {
+		body.Close()
+		logger.Error(ctx, "failed to load resource from gcs object", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+	resource.Properties = tail.properties
+	return resource, nil
+}
+
+// objectTail is the end of a stored object and the properties read from it.
+type objectTail struct {
+	data        []byte
+	size        int64
+	generation  int64
+	properties  *resource.ResourceProperties
+	trailerSize int64
+}
+
+// getObjectTail reads the properties trailer from the end of the object, or
+// the properties object of resources stored before the properties were kept
+// in the resource object. The generation of the object is the revision.
+func (gcsStorage *Storage) getObjectTail(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*objectTail, error) {
+	tail, err := gcsStorage.readObjectTail(ctx, resourceIdentifier, resource.PropertiesTrailerReadSize, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, trailerSize, err := resource.LoadPropertiesTrailer(tail.data)
+	if err == nil && properties == nil {
+		tail, err = gcsStorage.readObjectTail(ctx, resourceIdentifier, trailerSize, tail.generation)
+		if err != nil {
+			return nil, err
+		}
+		properties, trailerSize, err = resource.LoadPropertiesTrailer(tail.data)
+	}
+	if errors.Is(err, resource.ErrNoPropertiesTrailer) {
+		properties, err = gcsStorage.getLegacyProperties(ctx, resourceIdentifier)
+		trailerSize = 0
+	}
+	if err != nil {
+		logger.Error(ctx, "failed to load properties from gcs object", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+
+	properties.Revision = strconv.FormatInt(tail.generation, 10)
+	tail.properties = properties
+	tail.trailerSize = trailerSize
+	return tail, nil
+}
+
+func (gcsStorage *Storage) readObjectTail(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, length int64, generation int64) (*objectTail, error) {
+	reader, err := gcsStorage.readObject(ctx, resourceIdentifier, -length, -1, generation)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectTail{data: data, size: reader.Attrs.Size, generation: reader.Attrs.Generation}, nil
+}
+
+// getLegacyProperties reads the properties object that was written next to
+// resource objects before the properties were kept in the resource object.
+func (gcsStorage *Storage) getLegacyProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	objectName := resourceIdentifier.ToUniquePropertiesFilename()
+	logger.Debug(ctx, "trying to download gcs properties object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.get_object")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.object", objectName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	reader, err := gcsStorage.bucket.Object(objectName).NewReader(gcsCtx)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		if errors.Is(err, gcsstorage.ErrObjectNotExist) {
+			logger.Debug(ctx, "gcs properties object not found", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+			return resource.NewResourceProperties(resourceIdentifier), nil
+		}
+		logger.Error(ctx, "failed to download gcs properties object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+		return nil, err
+	}
+
+	return resource.LoadResourceProperties(reader)
+}
+
+// GetResourceRange downloads only the headers section and the requested range
+// of the body by issuing ranged object reads. All of them are pinned to the
+// generation named by the precondition, or else to the generation read first.
+func (gcsStorage *Storage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	objectName := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to download gcs object range", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "range.offset", offset, "range.length", length)
+
+	var generation int64
+	if precondition != nil {
+		var err error
+		if generation, err = strconv.ParseInt(precondition.Revision, 10, 64); err != nil {
+			return nil, storage.ErrPreconditionFailed
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		resource, err := gcsStorage.getResourceRange(ctx, resourceIdentifier, offset, length, generation)
+		if errors.Is(err, errObjectChanged) && precondition != nil {
+			logger.Debug(ctx, "gcs object does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+			return nil, storage.ErrPreconditionFailed
+		}
+		if errors.Is(err, errObjectChanged) && attempt < maxReadAttempts {
+			logger.Debug(ctx, "gcs object changed while it was read, retrying", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug(ctx, "successfully downloaded gcs object range", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+		return resource, nil
+	}
+}
+
+func (gcsStorage *Storage) getResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, generation int64) (*resource.Resource, error) {
+	objectName := resourceIdentifier.ToUniqueFilename()
+
+	lengthPrefix, err := gcsStorage.readObject(ctx, resourceIdentifier, 0, resource.HeadersLengthPrefixSize, generation)
+	if err != nil {
+		return nil, err
+	}
+	generation = lengthPrefix.Attrs.Generation
+	headersSectionLength, err := resource.HeadersSectionLength(lengthPrefix)
+	lengthPrefix.Close()
+	if err != nil {
+		logger.Error(ctx, "failed to read headers length from gcs object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+		return nil, err
+	}
+
+	headers := resource.NewResourceHeaders()
+	if headersSectionLength > resource.HeadersLengthPrefixSize {
+		headersSection, err := gcsStorage.readObject(ctx, resourceIdentifier, 0, headersSectionLength, generation)
+		if err != nil {
+			return nil, err
+		}
+		err = headers.LoadHeaders(headersSection)
+		headersSection.Close()
+		if err != nil {
+			logger.Error(ctx, "failed to load headers from gcs object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+			return nil, err
+		}
+	}
+
+	reader, err := gcsStorage.readObject(ctx, resourceIdentifier, headersSectionLength+offset, length, generation)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser = reader
+	resource := resource.NewResource(resourceIdentifier, &body)
+	resource.Headers = headers
+	return resource, nil
+}
+
+// readObject reads a range of the object, if generation is set only from that
+// generation of the object, and returns errObjectChanged otherwise.
+func (gcsStorage *Storage) readObject(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, generation int64) (*gcsstorage.Reader, error) {
+	objectName := resourceIdentifier.ToUniqueFilename()
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.get_object")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.object", objectName),
+		attribute.Int64("gcs.range.offset", offset),
+		attribute.Int64("gcs.range.length", length),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	object := gcsStorage.bucket.Object(objectName)
+	if generation != 0 {
+		object = object.Generation(generation)
+	}
+	reader, err := object.NewRangeReader(gcsCtx, offset, length)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		if errors.Is(err, gcsstorage.ErrObjectNotExist) {
+			logger.Debug(ctx, "gcs object not found", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+			if generation != 0 {
+				return nil, errObjectChanged
+			}
+			return nil, storage.ErrResourceNotFound
+		}
+		logger.Error(ctx, "failed to download gcs object range", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// RemoveResource deletes the resource object, the precondition is enforced
+// with generation preconditions.
+func (gcsStorage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
+	objectName := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to delete gcs object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+
+	object, err := conditionalObject(gcsStorage.bucket.Object(objectName), precondition)
+	if err != nil {
+		return err
+	}
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.delete_object")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.object", objectName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	err = object.Delete(gcsCtx)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil && precondition != nil && (isPreconditionFailed(err) || errors.Is(err, gcsstorage.ErrObjectNotExist)) {
+		logger.Debug(ctx, "gcs object does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+		return storage.ErrPreconditionFailed
+	}
+	if err != nil {
+		logger.Error(ctx, "failed to delete gcs object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully deleted gcs object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+	err = gcsStorage.removeObject(ctx, resourceIdentifier, resourceIdentifier.ToUniquePropertiesFilename(), "properties")
+	if err != nil {
+		return err
+	}
+
+	return gcsStorage.removeObject(ctx, resourceIdentifier, indexObjectName(resourceIdentifier.Identifier()), "index")
+}
+
+// removeObject deletes a properties or index object. Both may be missing for
+// resources stored before they were introduced, which is not an error.
+func (gcsStorage *Storage) removeObject(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, objectName string, kind string) error {
+	logger.Debug(ctx, "trying to delete gcs "+kind+" object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.delete_object")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.object", objectName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	err := gcsStorage.bucket.Object(objectName).Delete(gcsCtx)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil && !errors.Is(err, gcsstorage.ErrObjectNotExist) {
+		logger.Error(ctx, "failed to delete gcs "+kind+" object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully deleted gcs "+kind+" object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+	return nil
+}
+
+// GetResourceProperties reads the properties from the end of the object.
+func (gcsStorage *Storage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	objectName := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to download gcs object properties", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+
+	for attempt := 1; ; attempt++ {
+		tail, err := gcsStorage.getObjectTail(ctx, resourceIdentifier)
+		if errors.Is(err, errObjectChanged) && attempt < maxReadAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug(ctx, "successfully downloaded gcs object properties", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+		return tail.properties, nil
+	}
+}
+
+func (gcsStorage *Storage) ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error) {
+	objectName := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "checking if gcs object exists", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.get_object_attrs")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.object", objectName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := gcsStorage.bucket.Object(objectName).Attrs(gcsCtx)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		if errors.Is(err, gcsstorage.ErrObjectNotExist) {
+			logger.Debug(ctx, "gcs object not found", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+			return false, nil
+		}
+		logger.Error(ctx, "failed to check gcs object existence", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+		return false, err
+	}
+
+	logger.Debug(ctx, "gcs object exists", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+	return true, nil
+}
+
+// ListResources lists the index objects, which are named after the original
+// identifier, and loads the properties of each listed resource. The cursor is
+// the gcs page token.
+func (gcsStorage *Storage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*storage.ResourceList, error) {
+	objectPrefix := indexObjectName(prefix)
+	logger.Debug(ctx, "trying to list gcs index objects", "gcs.prefix", objectPrefix, "list.cursor", cursor)
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.list_objects")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.prefix", objectPrefix),
+		attribute.Int("gcs.page_size", limit),
+	)
+	query := &gcsstorage.Query{Prefix: objectPrefix}
+	if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+		tracer.SafeEndSpan(span)
+		return nil, err
+	}
+	var objects []*gcsstorage.ObjectAttrs
+	nextCursor, err := iterator.NewPager(gcsStorage.bucket.Objects(gcsCtx, query), limit, cursor).NextPage(&objects)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to list gcs index objects", "gcs.prefix", objectPrefix, "error", err)
+		return nil, err
+	}
+
+	list := &storage.ResourceList{Resources: []*resource.ResourceProperties{}}
+	for _, object := range objects {
+		resourceIdentifier := resource.NewResourceIdentifier(identifierFromIndexObjectName(object.Name))
+		properties, err := gcsStorage.GetResourceProperties(ctx, resourceIdentifier)
+		if errors.Is(err, storage.ErrResourceNotFound) {
+			// Removed since it was indexed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list.Resources = append(list.Resources, properties)
+	}
+	list.NextCursor = nextCursor
+
+	logger.Debug(ctx, "successfully listed gcs index objects", "gcs.prefix", objectPrefix, "list.count", len(list.Resources))
+	return list, nil
+}
+
+// CountResources counts the data objects, only fetching their names. The
+// delimiter leaves out the index objects, which are written before the data
+// object and may be left without one.
+func (gcsStorage *Storage) CountResources(ctx context.Context) (int64, error) {
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.list_objects")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+	)
+	defer tracer.SafeEndSpan(span)
+
+	query := &gcsstorage.Query{Delimiter: "/"}
+	if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	objects := gcsStorage.bucket.Objects(gcsCtx, query)
+	for {
+		object, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			return count, nil
+		}
+		if err != nil {
+			tracer.SafeRecordError(span, err)
+			logger.Error(ctx, "failed to count gcs objects", "gcs.bucket", gcsStorage.configuration.BUCKET, "error", err)
+			return 0, err
+		}
+		// Prefixes, such as the one of the index, are returned as objects with
+		// only a prefix, and properties objects of earlier versions are skipped.
+		if strings.HasSuffix(object.Name, resource.UniqueFilenameSuffix) {
+			count++
+		}
+	}
+}
+
+// NewStorage creates a client authenticated with the service account key file
+// at CREDENTIALS_PATH, or with Application Default Credentials if it is not
+// set. An ENDPOINT without credentials, such as an emulator, is used without
+// authentication.
+func NewStorage(ctx context.Context, configuration *configuration.GcsConfiguration) storage.Storage {
+	client, err := gcsstorage.NewClient(ctx, buildClientOptions(configuration)...)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Storage{
+		bucket:        client.Bucket(configuration.BUCKET),
+		configuration: *configuration,
+	}
+}
+
+func buildClientOptions(configuration *configuration.GcsConfiguration) []option.ClientOption {
+	var options []option.ClientOption
+	if configuration.ENDPOINT != "" {
+		options = append(options, option.WithEndpoint(configuration.ENDPOINT))
+	}
+
+	switch {
+	case configuration.CREDENTIALS_PATH != "":
+		options = append(options, option.WithAuthCredentialsFile(option.ServiceAccount, configuration.CREDENTIALS_PATH))
+	case configuration.ENDPOINT != "":
+		options = append(options, option.WithoutAuthentication())
+	}
+
+	return options
+}
+
+func (gcsStorage *Storage) GetName() string {
+	return "gcs"
+}
+
+// CheckHealth checks that the bucket exists and is accessible with the
+// configured credentials.
+func (gcsStorage *Storage) CheckHealth(ctx context.Context) error {
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.get_bucket_attrs")
+	defer tracer.SafeEndSpan(span)
+
+	_, err := gcsStorage.bucket.Attrs(gcsCtx)
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// SaveResource streams the resource followed by its properties into a
+// resumable upload, which sends it in CHUNK_SIZE chunks and retries failed
+// chunks. A CHUNK_SIZE of 0 uploads the resource in a single request instead.
+// The precondition is enforced with generation preconditions. The index
+// object is saved first, listing skips index objects of missing resources.
+func (gcsStorage *Storage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *storage.Precondition) error {
+	objectName := resource.Identifier.ToUniqueFilename()
+	if err := gcsStorage.saveIndex(ctx, resource.Identifier); err != nil {
+		return err
+	}
+
+	object, err := conditionalObject(gcsStorage.bucket.Object(objectName), precondition)
+	if err != nil {
+		return err
+	}
+	logger.Debug(ctx, "trying to upload gcs object", "resource.identifier", resource.Identifier.Identifier(), "gcs.object", objectName)
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.put_object")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.object", objectName),
+		attribute.String("resource.identifier", resource.Identifier.Identifier()),
+		attribute.Int("gcs.chunk_size", gcsStorage.configuration.CHUNK_SIZE),
+	)
+	attrs, err := gcsStorage.writeObject(gcsCtx, object, gcsStorage.configuration.CHUNK_SIZE, resource.WriteWithProperties)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil && precondition != nil && isPreconditionFailed(err) {
+		logger.Debug(ctx, "gcs object does not satisfy the precondition", "resource.identifier", resource.Identifier.Identifier(), "gcs.object", objectName)
+		return storage.ErrPreconditionFailed
+	}
+	if err != nil {
+		logger.Error(ctx, "failed to upload gcs object", "resource.identifier", resource.Identifier.Identifier(), "gcs.object", objectName, "error", err)
+		return err
+	}
+
+	resource.Properties.Revision = strconv.FormatInt(attrs.Generation, 10)
+	logger.Debug(ctx, "successfully uploaded gcs object", "resource.identifier", resource.Identifier.Identifier(), "gcs.object", objectName)
+	return nil
+}
+
+// conditionalObject makes the writes and deletes of object enforce the
+// precondition with generation preconditions.
+func conditionalObject(object *gcsstorage.ObjectHandle, precondition *storage.Precondition) (*gcsstorage.ObjectHandle, error) {
+	switch {
+	case precondition == nil:
+		return object, nil
+	case precondition.NotExists:
+		return object.If(gcsstorage.Conditions{DoesNotExist: true}), nil
+	default:
+		generation, err := strconv.ParseInt(precondition.Revision, 10, 64)
+		if err != nil {
+			return nil, storage.ErrPreconditionFailed
+		}
+		return object.If(gcsstorage.Conditions{GenerationMatch: generation}), nil
+	}
+}
+
+// isPreconditionFailed reports whether a write or delete failed its generation
+// precondition.
+func isPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
+
+// saveIndex stores an empty object named after the original identifier, so
+// that resources can be listed by prefix even though their data objects are
+// named by a hash of the identifier.
+func (gcsStorage *Storage) saveIndex(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	objectName := indexObjectName(resourceIdentifier.Identifier())
+	logger.Debug(ctx, "trying to upload gcs index object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+
+	gcsCtx, span := tracer.StartDebugSpan(ctx, "gcs.put_object")
+	tracer.SetDebugAttributes(gcsCtx, span,
+		attribute.String("gcs.bucket", gcsStorage.configuration.BUCKET),
+		attribute.String("gcs.object", objectName),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := gcsStorage.writeObject(gcsCtx, gcsStorage.bucket.Object(objectName), 0, func(writer io.WriteCloser) error {
+		return writer.Close()
+	})
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to upload gcs index object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully uploaded gcs index object", "resource.identifier", resourceIdentifier.Identifier(), "gcs.object", objectName)
+	return nil
+}
+
+// writeObject writes an object with the given write function, which must
+// close the writer to complete the upload, and returns the attributes of the
+// written object. The upload is cancelled if write fails, so no partial object
+// is left behind.
+func (gcsStorage *Storage) writeObject(ctx context.Context, object *gcsstorage.ObjectHandle, chunkSize int, write func(writer io.WriteCloser) error) (*gcsstorage.ObjectAttrs, error) {
+	writerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	writer := object.NewWriter(writerCtx)
+	writer.ChunkSize = chunkSize
+	if err := write(writer); err != nil {
+		return nil, err
+	}
+	return writer.Attrs(), nil
+}
+
+func indexObjectName(identifier string) string {
+	return indexObjectPrefix + strings.TrimPrefix(identifier, "/")
+}
+
+func identifierFromIndexObjectName(objectName string) string {
+	return "/" + strings.TrimPrefix(objectName, indexObjectPrefix)
+}
@@ -0,0 +1,348 @@
+package memory
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/inx51/howlite-resources/configuration"
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/inx51/howlite-resources/resource"
+	"github.com/inx51/howlite-resources/storage"
+	"github.com/inx51/howlite-resources/tracer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Storage keeps resources in memory, serialized the same way the other
+// storage providers store them. Stored data is never modified, a replaced
+// resource gets new data, so readers can use it without holding the lock.
+//
+// The least recently read or saved resources are evicted to make room for new
+// ones if LRU_EVICTION is enabled, otherwise saving fails once MAX_SIZE is
+// reached.
+type Storage struct {
+	mutex         sync.Mutex
+	entries       map[string]*list.Element
+	recency       *list.List
+	size          int64
+	revision      uint64
+	configuration configuration.MemoryConfiguration
+}
+
+type entry struct {
+	identifier string
+	data       []byte
+	properties resource.ResourceProperties
+}
+
+// limitedBuffer fails writes beyond its limit, so that resources larger than
+// MAX_RESOURCE_SIZE are rejected without buffering them completely. The
+// buffer is not embedded, its ReadFrom would bypass the limit.
+type limitedBuffer struct {
+	buffer bytes.Buffer
+	limit  int64
+}
+
+func (buffer *limitedBuffer) Write(data []byte) (int, error) {
+	if buffer.limit > 0 && int64(buffer.buffer.Len()+len(data)) > buffer.limit {
+		return 0, storage.ErrResourceTooLarge
+	}
+
+	return buffer.buffer.Write(data)
+}
+
+func (buffer *limitedBuffer) Close() error {
+	return nil
+}
+
+func (memoryStorage *Storage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	logger.Debug(ctx, "trying to read resource from memory", "resource.identifier", resourceIdentifier.Identifier())
+
+	stored, err := memoryStorage.getEntry(ctx, resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := resource.LoadResource(resourceIdentifier, io.NopCloser(bytes.NewReader(stored.data)))
+	if err != nil {
+		logger.Error(ctx, "failed to load resource from memory", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+	properties := stored.properties
+	resource.Properties = &properties
+
+	logger.Debug(ctx, "successfully read resource from memory", "resource.identifier", resourceIdentifier.Identifier())
+	return resource, nil
+}
+
+func (memoryStorage *Storage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	logger.Debug(ctx, "trying to read resource range from memory", "resource.identifier", resourceIdentifier.Identifier(), "range.offset", offset, "range.length", length)
+
+	stored, err := memoryStorage.getEntry(ctx, resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	if precondition != nil && (precondition.NotExists || stored.properties.Revision != precondition.Revision) {
+		logger.Debug(ctx, "resource in memory does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier())
+		return nil, storage.ErrPreconditionFailed
+	}
+
+	reader := bytes.NewReader(stored.data)
+	resource, err := resource.LoadResource(resourceIdentifier, io.NopCloser(reader))
+	if err != nil {
+		logger.Error(ctx, "failed to load resource from memory", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+
+	// LoadResource leaves the reader positioned at the start of the body.
+	if _, err := reader.Seek(offset, io.SeekCurrent); err != nil {
+		logger.Error(ctx, "failed to seek resource in memory", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+	body := io.NopCloser(io.LimitReader(reader, length))
+	resource.Body = &body
+
+	logger.Debug(ctx, "successfully read resource range from memory", "resource.identifier", resourceIdentifier.Identifier())
+	return resource, nil
+}
+
+func (memoryStorage *Storage) getEntry(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*entry, error) {
+	_, span := tracer.StartDebugSpan(ctx, "memory.get")
+	tracer.SetDebugAttributes(ctx, span,
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	defer tracer.SafeEndSpan(span)
+
+	memoryStorage.mutex.Lock()
+	defer memoryStorage.mutex.Unlock()
+
+	element, found := memoryStorage.entries[resourceIdentifier.Identifier()]
+	if !found {
+		logger.Debug(ctx, "resource not found in memory", "resource.identifier", resourceIdentifier.Identifier())
+		return nil, storage.ErrResourceNotFound
+	}
+	memoryStorage.recency.MoveToFront(element)
+
+	return element.Value.(*entry), nil
+}
+
+// RemoveResource checks the precondition and removes the resource under the
+// same lock, so that it can't be replaced in between.
+func (memoryStorage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
+	logger.Debug(ctx, "trying to remove resource from memory", "resource.identifier", resourceIdentifier.Identifier())
+
+	_, span := tracer.StartDebugSpan(ctx, "memory.remove")
+	tracer.SetDebugAttributes(ctx, span,
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	memoryStorage.mutex.Lock()
+	element, found := memoryStorage.entries[resourceIdentifier.Identifier()]
+	if !satisfiesPrecondition(element, found, precondition) {
+		memoryStorage.mutex.Unlock()
+		tracer.SafeEndSpan(span)
+		logger.Debug(ctx, "resource in memory does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier())
+		return storage.ErrPreconditionFailed
+	}
+	if found {
+		memoryStorage.removeElement(element)
+	}
+	memoryStorage.mutex.Unlock()
+	tracer.SafeEndSpan(span)
+
+	logger.Debug(ctx, "successfully removed resource from memory", "resource.identifier", resourceIdentifier.Identifier())
+	return nil
+}
+
+func (memoryStorage *Storage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	memoryStorage.mutex.Lock()
+	defer memoryStorage.mutex.Unlock()
+
+	element, found := memoryStorage.entries[resourceIdentifier.Identifier()]
+	if !found {
+		logger.Debug(ctx, "resource properties not found in memory", "resource.identifier", resourceIdentifier.Identifier())
+		return nil, storage.ErrResourceNotFound
+	}
+
+	properties := element.Value.(*entry).properties
+	return &properties, nil
+}
+
+func (memoryStorage *Storage) ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error) {
+	memoryStorage.mutex.Lock()
+	defer memoryStorage.mutex.Unlock()
+
+	_, found := memoryStorage.entries[resourceIdentifier.Identifier()]
+	logger.Debug(ctx, "checked if resource exists in memory", "resource.identifier", resourceIdentifier.Identifier(), "exists", found)
+	return found, nil
+}
+
+func (memoryStorage *Storage) CountResources(ctx context.Context) (int64, error) {
+	memoryStorage.mutex.Lock()
+	defer memoryStorage.mutex.Unlock()
+
+	return int64(len(memoryStorage.entries)), nil
+}
+
+// ListResources sorts the identifiers of all resources in memory, the cursor
+// is the last listed identifier.
+func (memoryStorage *Storage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*storage.ResourceList, error) {
+	logger.Debug(ctx, "trying to list resources in memory", "list.prefix", prefix, "list.cursor", cursor)
+
+	memoryStorage.mutex.Lock()
+	identifiers := make([]string, 0)
+	for identifier := range memoryStorage.entries {
+		if strings.HasPrefix(identifier, prefix) && identifier > cursor {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	slices.Sort(identifiers)
+
+	list := &storage.ResourceList{Resources: []*resource.ResourceProperties{}}
+	for _, identifier := range identifiers {
+		if len(list.Resources) == limit {
+			list.NextCursor = list.Resources[limit-1].Identifier
+			break
+		}
+		properties := memoryStorage.entries[identifier].Value.(*entry).properties
+		list.Resources = append(list.Resources, &properties)
+	}
+	memoryStorage.mutex.Unlock()
+
+	logger.Debug(ctx, "successfully listed resources in memory", "list.prefix", prefix, "list.count", len(list.Resources))
+	return list, nil
+}
+
+func NewStorage(configuration *configuration.MemoryConfiguration) storage.Storage {
+	return &Storage{
+		entries:       make(map[string]*list.Element),
+		recency:       list.New(),
+		configuration: *configuration,
+	}
+}
+
+func (memoryStorage *Storage) GetName() string {
+	return "memory"
+}
+
+// CheckHealth always succeeds, the memory storage has no dependencies.
+func (memoryStorage *Storage) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// SaveResource checks the precondition and replaces the resource under the
+// same lock, every saved resource gets a new revision.
+func (memoryStorage *Storage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *storage.Precondition) error {
+	identifier := resource.Identifier.Identifier()
+	logger.Debug(ctx, "trying to save resource to memory", "resource.identifier", identifier)
+
+	data := &limitedBuffer{limit: memoryStorage.configuration.MAX_RESOURCE_SIZE}
+	if err := resource.Write(data); err != nil {
+		if errors.Is(err, storage.ErrResourceTooLarge) {
+			logger.Debug(ctx, "resource exceeds max resource size", "resource.identifier", identifier, "memory.max_resource_size", memoryStorage.configuration.MAX_RESOURCE_SIZE)
+			return err
+		}
+		logger.Error(ctx, "failed to write resource to memory", "resource.identifier", identifier, "error", err)
+		return err
+	}
+
+	_, span := tracer.StartDebugSpan(ctx, "memory.save")
+	tracer.SetDebugAttributes(ctx, span,
+		attribute.String("resource.identifier", identifier),
+		attribute.Int("memory.size", data.buffer.Len()),
+	)
+	defer tracer.SafeEndSpan(span)
+
+	memoryStorage.mutex.Lock()
+	defer memoryStorage.mutex.Unlock()
+
+	element, found := memoryStorage.entries[identifier]
+	if !satisfiesPrecondition(element, found, precondition) {
+		logger.Debug(ctx, "resource in memory does not satisfy the precondition", "resource.identifier", identifier)
+		return storage.ErrPreconditionFailed
+	}
+
+	err := memoryStorage.makeRoom(ctx, identifier, int64(data.buffer.Len()))
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+
+	if element, found := memoryStorage.entries[identifier]; found {
+		memoryStorage.removeElement(element)
+	}
+	memoryStorage.revision++
+	resource.Properties.Revision = strconv.FormatUint(memoryStorage.revision, 10)
+	memoryStorage.entries[identifier] = memoryStorage.recency.PushFront(&entry{
+		identifier: identifier,
+		data:       data.buffer.Bytes(),
+		properties: *resource.Properties,
+	})
+	memoryStorage.size += int64(data.buffer.Len())
+
+	logger.Debug(ctx, "successfully saved resource to memory", "resource.identifier", identifier, "memory.total_size", memoryStorage.size)
+	return nil
+}
+
+// makeRoom makes sure a resource of the given size fits within MAX_SIZE once
+// the resource it replaces is removed, by evicting the least recently used
+// resources if LRU_EVICTION is enabled.
+func (memoryStorage *Storage) makeRoom(ctx context.Context, identifier string, size int64) error {
+	maxSize := memoryStorage.configuration.MAX_SIZE
+	if maxSize <= 0 {
+		return nil
+	}
+	if size > maxSize {
+		logger.Debug(ctx, "resource exceeds max size", "resource.identifier", identifier, "memory.max_size", maxSize)
+		return storage.ErrResourceTooLarge
+	}
+
+	required := memoryStorage.size + size
+	if element, found := memoryStorage.entries[identifier]; found {
+		required -= int64(len(element.Value.(*entry).data))
+	}
+	if required <= maxSize {
+		return nil
+	}
+	if !memoryStorage.configuration.LRU_EVICTION {
+		logger.Warn(ctx, "memory storage is full", "resource.identifier", identifier, "memory.total_size", memoryStorage.size, "memory.max_size", maxSize)
+		return storage.ErrStorageFull
+	}
+
+	for element := memoryStorage.recency.Back(); element != nil && required > maxSize; {
+		previous := element.Prev()
+		evicted := element.Value.(*entry)
+		if evicted.identifier != identifier {
+			required -= int64(len(evicted.data))
+			memoryStorage.removeElement(element)
+			logger.Debug(ctx, "evicted resource from memory", "resource.identifier", evicted.identifier)
+		}
+		element = previous
+	}
+
+	return nil
+}
+
+func (memoryStorage *Storage) removeElement(element *list.Element) {
+	removed := memoryStorage.recency.Remove(element).(*entry)
+	delete(memoryStorage.entries, removed.identifier)
+	memoryStorage.size -= int64(len(removed.data))
+}
+
+// satisfiesPrecondition reports whether the stored element, if found,
+// satisfies the precondition, which is always the case without one.
+func satisfiesPrecondition(element *list.Element, found bool, precondition *storage.Precondition) bool {
+	switch {
+	case precondition == nil:
+		return true
+	case precondition.NotExists:
+		return !found
+	default:
+		return found && element.Value.(*entry).properties.Revision == precondition.Revision
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/inx51/howlite-resources/meter"
+	"github.com/inx51/howlite-resources/resource"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var operationDuration = meter.Instrument{
+	Name:        "storage.operation.duration",
+	Unit:        "s",
+	Description: "Duration of storage provider operations.",
+	Buckets:     []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10},
+}
+
+// meteredStorage records the duration of every operation of a storage
+// provider. Reads are measured until the resource is returned, not until its
+// body has been read.
+type meteredStorage struct {
+	storage Storage
+}
+
+func NewMeteredStorage(storage Storage) Storage {
+	return &meteredStorage{
+		storage: storage,
+	}
+}
+
+func (meteredStorage *meteredStorage) record(ctx context.Context, operation string, start time.Time, err error) {
+	attributes := []attribute.KeyValue{
+		attribute.String("storage.provider", meteredStorage.storage.GetName()),
+		attribute.String("storage.operation", operation),
+	}
+	// Missing resources and failed preconditions are answers, not errors.
+	if err != nil && !errors.Is(err, ErrResourceNotFound) && !errors.Is(err, ErrPreconditionFailed) {
+		attributes = append(attributes, attribute.String("error.type", "_OTHER"))
+	}
+
+	meter.RecordFloat64Histogram(ctx, operationDuration, time.Since(start).Seconds(), metric.WithAttributes(attributes...))
+}
+
+func (meteredStorage *meteredStorage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *Precondition) error {
+	start := time.Now()
+	err := meteredStorage.storage.RemoveResource(ctx, resourceIdentifier, precondition)
+	meteredStorage.record(ctx, "remove_resource", start, err)
+	return err
+}
+
+func (meteredStorage *meteredStorage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *Precondition) error {
+	start := time.Now()
+	err := meteredStorage.storage.SaveResource(ctx, resource, precondition)
+	meteredStorage.record(ctx, "save_resource", start, err)
+	return err
+}
+
+func (meteredStorage *meteredStorage) ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error) {
+	start := time.Now()
+	exists, err := meteredStorage.storage.ResourceExists(ctx, resourceIdentifier)
+	meteredStorage.record(ctx, "resource_exists", start, err)
+	return exists, err
+}
+
+func (meteredStorage *meteredStorage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	start := time.Now()
+	resource, err := meteredStorage.storage.GetResource(ctx, resourceIdentifier)
+	meteredStorage.record(ctx, "get_resource", start, err)
+	return resource, err
+}
+
+func (meteredStorage *meteredStorage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *Precondition) (*resource.Resource, error) {
+	start := time.Now()
+	resource, err := meteredStorage.storage.GetResourceRange(ctx, resourceIdentifier, offset, length, precondition)
+	meteredStorage.record(ctx, "get_resource_range", start, err)
+	return resource, err
+}
+
+func (meteredStorage *meteredStorage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	start := time.Now()
+	properties, err := meteredStorage.storage.GetResourceProperties(ctx, resourceIdentifier)
+	meteredStorage.record(ctx, "get_resource_properties", start, err)
+	return properties, err
+}
+
+func (meteredStorage *meteredStorage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*ResourceList, error) {
+	start := time.Now()
+	list, err := meteredStorage.storage.ListResources(ctx, prefix, cursor, limit)
+	meteredStorage.record(ctx, "list_resources", start, err)
+	return list, err
+}
+
+func (meteredStorage *meteredStorage) CountResources(ctx context.Context) (int64, error) {
+	start := time.Now()
+	count, err := CountResources(ctx, meteredStorage.storage)
+	meteredStorage.record(ctx, "count_resources", start, err)
+	return count, err
+}
+
+func (meteredStorage *meteredStorage) GetName() string {
+	return meteredStorage.storage.GetName()
+}
+
+func (meteredStorage *meteredStorage) CheckHealth(ctx context.Context) error {
+	return meteredStorage.storage.CheckHealth(ctx)
+}
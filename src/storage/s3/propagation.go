@@ -0,0 +1,24 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// addTracePropagation injects the trace context of the operation into the
+// headers of the requests sent to S3.
+func addTracePropagation(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("TracePropagation", func(
+		ctx context.Context,
+		in middleware.BuildInput,
+		next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+		if request, ok := in.Request.(*smithyhttp.Request); ok {
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+		}
+		return next.HandleBuild(ctx, in)
+	}), middleware.After)
+}
@@ -1,9 +1,13 @@
 package s3
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -11,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/inx51/howlite-resources/configuration"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/resource"
@@ -19,6 +24,17 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 )
 
+const (
+	indexKeyPrefix = "index/"
+	// maxReadAttempts limits how often reading a resource is restarted when
+	// it's replaced while it's read.
+	maxReadAttempts = 3
+)
+
+// errObjectChanged is returned when the object is replaced between reading
+// its properties and its body.
+var errObjectChanged = errors.New("s3 object changed while it was read")
+
 type Storage struct {
 	client        *s3.Client
 	uploader      *manager.Uploader
@@ -26,10 +42,125 @@ type Storage struct {
 	configuration configuration.S3Configuration
 }
 
+// GetResource reads the end of the object first, which holds the properties,
+// and then its headers and body pinned to the same version of the object.
+// Resources small enough to be read with the properties take a single request.
 func (s3Storage *Storage) GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
 	objectKey := resourceIdentifier.ToUniqueFilename()
 	logger.Debug(ctx, "trying to download s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
 
+	for attempt := 1; ; attempt++ {
+		resource, err := s3Storage.getResource(ctx, resourceIdentifier)
+		if errors.Is(err, errObjectChanged) && attempt < maxReadAttempts {
+			logger.Debug(ctx, "s3 object changed while it was read, retrying", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug(ctx, "successfully downloaded s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+		return resource, nil
+	}
+}
+
+func (s3Storage *Storage) getResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error) {
+	tail, err := s3Storage.getObjectTail(ctx, resourceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	if int64(len(tail.data)) == tail.size {
+		body = io.NopCloser(bytes.NewReader(tail.data[:tail.size-tail.trailerSize]))
+	} else {
+		result, err := s3Storage.getObject(ctx, resourceIdentifier, fmt.Sprintf("bytes=0-%d", tail.size-tail.trailerSize-1), tail.etag)
+		if err != nil {
+			return nil, err
+		}
+		body = result.Body
+	}
+
+	resource, err := resource.LoadResource(resourceIdentifier, body)
+	if err != nil {
+		body.Close()
+		logger.Error(ctx, "failed to load resource from s3 object", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+	resource.Properties = tail.properties
+	return resource, nil
+}
+
+// objectTail is the end of a stored object and the properties read from it.
+type objectTail struct {
+	data        []byte
+	size        int64
+	etag        string
+	properties  *resource.ResourceProperties
+	trailerSize int64
+}
+
+// getObjectTail reads the properties trailer from the end of the object, or
+// the properties object of resources stored before the properties were kept
+// in the resource object. The ETag of the object is the revision.
+func (s3Storage *Storage) getObjectTail(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*objectTail, error) {
+	tail, err := s3Storage.readObjectTail(ctx, resourceIdentifier, resource.PropertiesTrailerReadSize, "")
+	if err != nil {
+		return nil, err
+	}
+
+	properties, trailerSize, err := resource.LoadPropertiesTrailer(tail.data)
+	if err == nil && properties == nil {
+		tail, err = s3Storage.readObjectTail(ctx, resourceIdentifier, trailerSize, tail.etag)
+		if err != nil {
+			return nil, err
+		}
+		properties, trailerSize, err = resource.LoadPropertiesTrailer(tail.data)
+	}
+	if errors.Is(err, resource.ErrNoPropertiesTrailer) {
+		properties, err = s3Storage.getLegacyProperties(ctx, resourceIdentifier)
+		trailerSize = 0
+	}
+	if err != nil {
+		logger.Error(ctx, "failed to load properties from s3 object", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
+		return nil, err
+	}
+
+	properties.Revision = tail.etag
+	tail.properties = properties
+	tail.trailerSize = trailerSize
+	return tail, nil
+}
+
+func (s3Storage *Storage) readObjectTail(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, length int64, ifMatch string) (*objectTail, error) {
+	result, err := s3Storage.getObject(ctx, resourceIdentifier, fmt.Sprintf("bytes=-%d", length), ifMatch)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(data))
+	if contentRange := aws.ToString(result.ContentRange); contentRange != "" {
+		_, total, found := strings.Cut(contentRange, "/")
+		if size, err = strconv.ParseInt(total, 10, 64); !found || err != nil {
+			return nil, fmt.Errorf("unexpected content range %q of s3 object", contentRange)
+		}
+	}
+
+	return &objectTail{data: data, size: size, etag: aws.ToString(result.ETag)}, nil
+}
+
+// getLegacyProperties reads the properties object that was written next to
+// resource objects before the properties were kept in the resource object.
+func (s3Storage *Storage) getLegacyProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	objectKey := resourceIdentifier.ToUniquePropertiesFilename()
+	logger.Debug(ctx, "trying to download s3 properties object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+
 	s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.get_object")
 	tracer.SetDebugAttributes(s3Ctx, span,
 		attribute.String("s3.bucket", s3Storage.configuration.BUCKET),
@@ -46,25 +177,163 @@ func (s3Storage *Storage) GetResource(ctx context.Context, resourceIdentifier *r
 	if err != nil {
 		var notFound *types.NoSuchKey
 		if errors.As(err, &notFound) {
-			logger.Debug(ctx, "s3 object not found", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+			logger.Debug(ctx, "s3 properties object not found", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+			return resource.NewResourceProperties(resourceIdentifier), nil
+		}
+		logger.Error(ctx, "failed to download s3 properties object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
+		return nil, err
+	}
+
+	return resource.LoadResourceProperties(result.Body)
+}
+
+// GetResourceRange downloads only the headers section and the requested range
+// of the body by issuing ranged GetObject requests. All of them are pinned to
+// the version of the object named by the precondition, or else to the version
+// read first.
+func (s3Storage *Storage) GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	objectKey := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to download s3 object range", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "range.offset", offset, "range.length", length)
+
+	for attempt := 1; ; attempt++ {
+		resource, err := s3Storage.getResourceRange(ctx, resourceIdentifier, offset, length, precondition)
+		if errors.Is(err, errObjectChanged) && precondition != nil {
+			logger.Debug(ctx, "s3 object does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+			return nil, storage.ErrPreconditionFailed
+		}
+		if errors.Is(err, errObjectChanged) && attempt < maxReadAttempts {
+			logger.Debug(ctx, "s3 object changed while it was read, retrying", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "attempt", attempt)
+			continue
+		}
+		if err != nil {
 			return nil, err
 		}
-		logger.Error(ctx, "failed to download s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
+
+		logger.Debug(ctx, "successfully downloaded s3 object range", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+		return resource, nil
+	}
+}
+
+func (s3Storage *Storage) getResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *storage.Precondition) (*resource.Resource, error) {
+	objectKey := resourceIdentifier.ToUniqueFilename()
+	etag := ""
+	if precondition != nil {
+		etag = precondition.Revision
+	}
+
+	lengthPrefix, err := s3Storage.getObject(ctx, resourceIdentifier, byteRange(0, resource.HeadersLengthPrefixSize), etag)
+	if err != nil {
+		return nil, err
+	}
+	etag = aws.ToString(lengthPrefix.ETag)
+	headersSectionLength, err := resource.HeadersSectionLength(lengthPrefix.Body)
+	lengthPrefix.Body.Close()
+	if err != nil {
+		logger.Error(ctx, "failed to read headers length from s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
 		return nil, err
 	}
 
-	resource, err := resource.LoadResource(resourceIdentifier, result.Body)
+	headers := resource.NewResourceHeaders()
+	if headersSectionLength > resource.HeadersLengthPrefixSize {
+		headersSection, err := s3Storage.getObject(ctx, resourceIdentifier, byteRange(0, headersSectionLength), etag)
+		if err != nil {
+			return nil, err
+		}
+		err = headers.LoadHeaders(headersSection.Body)
+		headersSection.Body.Close()
+		if err != nil {
+			logger.Error(ctx, "failed to load headers from s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
+			return nil, err
+		}
+	}
+
+	result, err := s3Storage.getObject(ctx, resourceIdentifier, byteRange(headersSectionLength+offset, length), etag)
 	if err != nil {
-		result.Body.Close()
-		logger.Error(ctx, "failed to load resource from s3 object", "resource.identifier", resourceIdentifier.Identifier(), "error", err)
 		return nil, err
 	}
 
-	logger.Debug(ctx, "successfully downloaded s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
-	return resource, err
+	resource := resource.NewResource(resourceIdentifier, &result.Body)
+	resource.Headers = headers
+	return resource, nil
+}
+
+func byteRange(offset int64, length int64) string {
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
 }
 
-func (s3Storage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+// getObject reads a range of the object, if ifMatch is set only from that
+// version of the object, and returns errObjectChanged otherwise.
+func (s3Storage *Storage) getObject(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, byteRange string, ifMatch string) (*s3.GetObjectOutput, error) {
+	objectKey := resourceIdentifier.ToUniqueFilename()
+
+	s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.get_object")
+	tracer.SetDebugAttributes(s3Ctx, span,
+		attribute.String("s3.bucket", s3Storage.configuration.BUCKET),
+		attribute.String("s3.key", objectKey),
+		attribute.String("s3.range", byteRange),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s3Storage.configuration.BUCKET),
+		Key:    aws.String(objectKey),
+		Range:  aws.String(byteRange),
+	}
+	if ifMatch != "" {
+		input.IfMatch = aws.String(ifMatch)
+	}
+	result, err := s3Storage.client.GetObject(s3Ctx, input)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			logger.Debug(ctx, "s3 object not found", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+			if ifMatch != "" {
+				return nil, errObjectChanged
+			}
+			return nil, storage.ErrResourceNotFound
+		}
+		if ifMatch != "" && isPreconditionFailed(err) {
+			return nil, errObjectChanged
+		}
+		logger.Error(ctx, "failed to download s3 object range", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "s3.range", byteRange, "error", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// isPreconditionFailed reports whether a conditional request failed because
+// the object changed, or because another conditional write raced it.
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	default:
+		return false
+	}
+}
+
+// isObjectMissing reports whether a request failed because the object doesn't
+// exist, which a conditional delete reports as such.
+func isObjectMissing(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.ErrorCode() == "NoSuchKey" || apiErr.ErrorCode() == "NotFound"
+}
+
+// RemoveResource deletes the resource object, the precondition is enforced
+// with a conditional delete on its ETag, which is the revision.
+func (s3Storage *Storage) RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *storage.Precondition) error {
 	objectKey := resourceIdentifier.ToUniqueFilename()
 	logger.Debug(ctx, "trying to delete s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
 
@@ -74,22 +343,116 @@ func (s3Storage *Storage) RemoveResource(ctx context.Context, resourceIdentifier
 		attribute.String("s3.key", objectKey),
 		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
 	)
-	_, err := s3Storage.client.DeleteObject(s3Ctx, &s3.DeleteObjectInput{
+	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(s3Storage.configuration.BUCKET),
 		Key:    aws.String(objectKey),
-	})
+	}
+	if precondition != nil && precondition.NotExists {
+		// An existing object never satisfies the precondition, deleting a
+		// missing one changes nothing.
+		exists, err := s3Storage.ResourceExists(s3Ctx, resourceIdentifier)
+		if err == nil && exists {
+			err = storage.ErrPreconditionFailed
+		}
+		tracer.SafeEndSpan(span)
+		return err
+	}
+	if precondition != nil {
+		input.IfMatch = aws.String(precondition.Revision)
+	}
+	_, err := s3Storage.client.DeleteObject(s3Ctx, input)
 	tracer.SafeRecordError(span, err)
 	tracer.SafeEndSpan(span)
 
+	if err != nil && precondition != nil && (isPreconditionFailed(err) || isObjectMissing(err)) {
+		logger.Debug(ctx, "s3 object does not satisfy the precondition", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+		return storage.ErrPreconditionFailed
+	}
 	if err != nil {
 		logger.Error(ctx, "failed to delete s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
 		return err
 	}
 
 	logger.Debug(ctx, "successfully deleted s3 object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+	err = s3Storage.removeProperties(ctx, resourceIdentifier)
+	if err != nil {
+		return err
+	}
+
+	return s3Storage.removeIndex(ctx, resourceIdentifier)
+}
+
+func (s3Storage *Storage) removeProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	objectKey := resourceIdentifier.ToUniquePropertiesFilename()
+	logger.Debug(ctx, "trying to delete s3 properties object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+
+	s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.delete_object")
+	tracer.SetDebugAttributes(s3Ctx, span,
+		attribute.String("s3.bucket", s3Storage.configuration.BUCKET),
+		attribute.String("s3.key", objectKey),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := s3Storage.client.DeleteObject(s3Ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s3Storage.configuration.BUCKET),
+		Key:    aws.String(objectKey),
+	})
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to delete s3 properties object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully deleted s3 properties object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+	return nil
+}
+
+func (s3Storage *Storage) removeIndex(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	objectKey := indexKey(resourceIdentifier.Identifier())
+	logger.Debug(ctx, "trying to delete s3 index object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+
+	s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.delete_object")
+	tracer.SetDebugAttributes(s3Ctx, span,
+		attribute.String("s3.bucket", s3Storage.configuration.BUCKET),
+		attribute.String("s3.key", objectKey),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := s3Storage.client.DeleteObject(s3Ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s3Storage.configuration.BUCKET),
+		Key:    aws.String(objectKey),
+	})
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to delete s3 index object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully deleted s3 index object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
 	return nil
 }
 
+// GetResourceProperties reads the properties from the end of the object.
+func (s3Storage *Storage) GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error) {
+	objectKey := resourceIdentifier.ToUniqueFilename()
+	logger.Debug(ctx, "trying to download s3 object properties", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+
+	for attempt := 1; ; attempt++ {
+		tail, err := s3Storage.getObjectTail(ctx, resourceIdentifier)
+		if errors.Is(err, errObjectChanged) && attempt < maxReadAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug(ctx, "successfully downloaded s3 object properties", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+		return tail.properties, nil
+	}
+}
+
 func (s3Storage *Storage) ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error) {
 	objectKey := resourceIdentifier.ToUniqueFilename()
 	logger.Debug(ctx, "checking if s3 object exists", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
@@ -121,6 +484,92 @@ func (s3Storage *Storage) ResourceExists(ctx context.Context, resourceIdentifier
 	return true, nil
 }
 
+// ListResources lists the index objects, which are keyed by the original
+// identifier, and loads the properties of each listed resource. The cursor is
+// the s3 continuation token.
+func (s3Storage *Storage) ListResources(ctx context.Context, prefix string, cursor string, limit int) (*storage.ResourceList, error) {
+	keyPrefix := indexKey(prefix)
+	logger.Debug(ctx, "trying to list s3 index objects", "s3.prefix", keyPrefix, "list.cursor", cursor)
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s3Storage.configuration.BUCKET),
+		Prefix:  aws.String(keyPrefix),
+		MaxKeys: aws.Int32(int32(limit)),
+	}
+	if cursor != "" {
+		input.ContinuationToken = aws.String(cursor)
+	}
+
+	s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.list_objects")
+	tracer.SetDebugAttributes(s3Ctx, span,
+		attribute.String("s3.bucket", s3Storage.configuration.BUCKET),
+		attribute.String("s3.prefix", keyPrefix),
+		attribute.Int("s3.max_keys", limit),
+	)
+	result, err := s3Storage.client.ListObjectsV2(s3Ctx, input)
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to list s3 index objects", "s3.prefix", keyPrefix, "error", err)
+		return nil, err
+	}
+
+	list := &storage.ResourceList{Resources: []*resource.ResourceProperties{}}
+	for _, object := range result.Contents {
+		resourceIdentifier := resource.NewResourceIdentifier(identifierFromIndexKey(aws.ToString(object.Key)))
+		properties, err := s3Storage.GetResourceProperties(ctx, resourceIdentifier)
+		if errors.Is(err, storage.ErrResourceNotFound) {
+			// Removed since it was indexed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list.Resources = append(list.Resources, properties)
+	}
+	if aws.ToBool(result.IsTruncated) {
+		list.NextCursor = aws.ToString(result.NextContinuationToken)
+	}
+
+	logger.Debug(ctx, "successfully listed s3 index objects", "s3.prefix", keyPrefix, "list.count", len(list.Resources))
+	return list, nil
+}
+
+// CountResources counts the data objects page by page, without loading the
+// properties of the resources. The delimiter leaves out the index objects,
+// which are written before the data object and may be left without one.
+func (s3Storage *Storage) CountResources(ctx context.Context) (int64, error) {
+	paginator := s3.NewListObjectsV2Paginator(s3Storage.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s3Storage.configuration.BUCKET),
+		Delimiter: aws.String("/"),
+	})
+
+	var count int64
+	for paginator.HasMorePages() {
+		s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.list_objects")
+		tracer.SetDebugAttributes(s3Ctx, span,
+			attribute.String("s3.bucket", s3Storage.configuration.BUCKET),
+		)
+		page, err := paginator.NextPage(s3Ctx)
+		tracer.SafeRecordError(span, err)
+		tracer.SafeEndSpan(span)
+
+		if err != nil {
+			logger.Error(ctx, "failed to count s3 objects", "s3.bucket", s3Storage.configuration.BUCKET, "error", err)
+			return 0, err
+		}
+		// Properties objects of earlier versions are stored next to the data.
+		for _, object := range page.Contents {
+			if strings.HasSuffix(aws.ToString(object.Key), resource.UniqueFilenameSuffix) {
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
 func NewStorage(ctx context.Context, configuration *configuration.S3Configuration) storage.Storage {
 	cfg, err := buildConfig(ctx, configuration)
 	if err != nil {
@@ -149,6 +598,9 @@ func NewStorage(ctx context.Context, configuration *configuration.S3Configuratio
 func buildS3Options(configuration *configuration.S3Configuration) []func(*s3.Options) {
 	var options []func(*s3.Options)
 	options = applyUsePathStyle(configuration, options)
+	options = append(options, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, addTracePropagation)
+	})
 	return options
 }
 
@@ -197,8 +649,32 @@ func (s3Storage *Storage) GetName() string {
 	return "s3"
 }
 
-func (s3Storage *Storage) SaveResource(ctx context.Context, resource *resource.Resource) error {
+// CheckHealth checks that the bucket exists and is accessible with the
+// configured credentials.
+func (s3Storage *Storage) CheckHealth(ctx context.Context) error {
+	s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.head_bucket")
+	tracer.SetDebugAttributes(s3Ctx, span, attribute.String("s3.bucket", s3Storage.configuration.BUCKET))
+	defer tracer.SafeEndSpan(span)
+
+	_, err := s3Storage.client.HeadBucket(s3Ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s3Storage.configuration.BUCKET),
+	})
+	if err != nil {
+		tracer.SafeRecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// SaveResource uploads the resource followed by its properties as a single
+// object, the precondition is enforced with a conditional write. The index
+// object is saved first, listing skips index objects of missing resources.
+func (s3Storage *Storage) SaveResource(ctx context.Context, resource *resource.Resource, precondition *storage.Precondition) error {
 	objectKey := resource.Identifier.ToUniqueFilename()
+	if err := s3Storage.saveIndex(ctx, resource.Identifier); err != nil {
+		return err
+	}
+
 	reader := s3Storage.createResourceReader(ctx, resource)
 	logger.Debug(ctx, "trying to upload s3 object", "resource.identifier", resource.Identifier.Identifier(), "s3.key", objectKey)
 
@@ -210,28 +686,77 @@ func (s3Storage *Storage) SaveResource(ctx context.Context, resource *resource.R
 		attribute.Int64("s3.part_size", s3Storage.configuration.PART_UPLOAD_SIZE),
 		attribute.Int("s3.concurrency", s3Storage.configuration.UPLOAD_CONCURRENCY),
 	)
-	_, err := s3Storage.uploader.Upload(s3Ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(s3Storage.configuration.BUCKET),
 		Key:    aws.String(objectKey),
 		Body:   reader,
-	})
+	}
+	if precondition != nil && precondition.NotExists {
+		input.IfNoneMatch = aws.String("*")
+	} else if precondition != nil {
+		input.IfMatch = aws.String(precondition.Revision)
+	}
+	result, err := s3Storage.uploader.Upload(s3Ctx, input)
 	tracer.SafeRecordError(span, err)
 	tracer.SafeEndSpan(span)
 
+	if err != nil && precondition != nil && isPreconditionFailed(err) {
+		logger.Debug(ctx, "s3 object does not satisfy the precondition", "resource.identifier", resource.Identifier.Identifier(), "s3.key", objectKey)
+		return storage.ErrPreconditionFailed
+	}
 	if err != nil {
 		logger.Error(ctx, "failed to upload s3 object", "resource.identifier", resource.Identifier.Identifier(), "s3.key", objectKey, "error", err)
 		return err
 	}
 
+	resource.Properties.Revision = aws.ToString(result.ETag)
 	logger.Debug(ctx, "successfully uploaded s3 object", "resource.identifier", resource.Identifier.Identifier(), "s3.key", objectKey)
 	return nil
 }
 
+// saveIndex stores an empty object keyed by the original identifier, so that
+// resources can be listed by prefix even though their data objects are keyed
+// by a hash of the identifier.
+func (s3Storage *Storage) saveIndex(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error {
+	objectKey := indexKey(resourceIdentifier.Identifier())
+	logger.Debug(ctx, "trying to upload s3 index object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+
+	s3Ctx, span := tracer.StartDebugSpan(ctx, "s3.put_object")
+	tracer.SetDebugAttributes(s3Ctx, span,
+		attribute.String("s3.bucket", s3Storage.configuration.BUCKET),
+		attribute.String("s3.key", objectKey),
+		attribute.String("resource.identifier", resourceIdentifier.Identifier()),
+	)
+	_, err := s3Storage.client.PutObject(s3Ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s3Storage.configuration.BUCKET),
+		Key:    aws.String(objectKey),
+		Body:   bytes.NewReader(nil),
+	})
+	tracer.SafeRecordError(span, err)
+	tracer.SafeEndSpan(span)
+
+	if err != nil {
+		logger.Error(ctx, "failed to upload s3 index object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully uploaded s3 index object", "resource.identifier", resourceIdentifier.Identifier(), "s3.key", objectKey)
+	return nil
+}
+
+func indexKey(identifier string) string {
+	return indexKeyPrefix + strings.TrimPrefix(identifier, "/")
+}
+
+func identifierFromIndexKey(objectKey string) string {
+	return "/" + strings.TrimPrefix(objectKey, indexKeyPrefix)
+}
+
 func (s3Storage *Storage) createResourceReader(ctx context.Context, resource *resource.Resource) io.Reader {
 	pipeReader, pipeWriter := io.Pipe()
 	go func() {
 		defer pipeWriter.Close()
-		err := resource.Write(pipeWriter)
+		err := resource.WriteWithProperties(pipeWriter)
 		if err != nil {
 			logger.Error(ctx, "failed to write resource to pipe", "error", err)
 			pipeWriter.CloseWithError(err)
@@ -2,14 +2,73 @@ package storage
 
 import (
 	"context"
+	"errors"
 
 	"github.com/inx51/howlite-resources/resource"
 )
 
+var (
+	// ErrResourceTooLarge is returned by SaveResource when the resource exceeds
+	// the size limit of the storage provider.
+	ErrResourceTooLarge = errors.New("resource too large")
+	// ErrStorageFull is returned by SaveResource when the storage provider has
+	// no room left for the resource.
+	ErrStorageFull = errors.New("storage full")
+	// ErrPreconditionFailed is returned by SaveResource and RemoveResource when
+	// the stored resource doesn't satisfy the precondition of the save or
+	// removal.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrResourceNotFound is returned by GetResource and GetResourceProperties
+	// when the resource doesn't exist.
+	ErrResourceNotFound = errors.New("resource not found")
+)
+
 type Storage interface {
-	RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) error
-	SaveResource(ctx context.Context, resource *resource.Resource) error
+	// RemoveResource removes the resource, if precondition is set only when the
+	// stored resource satisfies it, which the storage provider enforces with a
+	// conditional delete.
+	RemoveResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, precondition *Precondition) error
+	// SaveResource saves the resource, if precondition is set only when the
+	// stored resource satisfies it, which the storage provider enforces with
+	// a conditional write.
+	SaveResource(ctx context.Context, resource *resource.Resource, precondition *Precondition) error
 	ResourceExists(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (bool, error)
+	// GetResource returns the resource along with its properties.
 	GetResource(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.Resource, error)
+	// GetResourceRange returns the headers and the given range of the body. If
+	// precondition is set, ErrPreconditionFailed is returned when the stored
+	// resource no longer has the given revision.
+	GetResourceRange(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier, offset int64, length int64, precondition *Precondition) (*resource.Resource, error)
+	GetResourceProperties(ctx context.Context, resourceIdentifier *resource.ResourceIdentifier) (*resource.ResourceProperties, error)
+	ListResources(ctx context.Context, prefix string, cursor string, limit int) (*ResourceList, error)
 	GetName() string
+	// CheckHealth returns an error if the storage provider cannot serve
+	// requests, such as when its bucket is unreachable or its path is not
+	// writable.
+	CheckHealth(ctx context.Context) error
+}
+
+// ResourceList is a single page of resources whose identifiers share a prefix.
+// NextCursor is opaque to callers, it is empty when there are no more pages.
+type ResourceList struct {
+	Resources  []*resource.ResourceProperties
+	NextCursor string
+}
+
+// Precondition makes a save or removal conditional on the stored resource,
+// either that it doesn't exist yet or that it's still the revision that was
+// read.
+type Precondition struct {
+	NotExists bool
+	Revision  string
+}
+
+// PreconditionFor returns the precondition that the stored resource is still
+// the one described by properties, or still doesn't exist if they are nil.
+func PreconditionFor(properties *resource.ResourceProperties) *Precondition {
+	if properties == nil {
+		return &Precondition{NotExists: true}
+	}
+
+	return &Precondition{Revision: properties.Revision}
 }
@@ -2,38 +2,69 @@ package telemetry
 
 import (
 	"context"
+	"net/http"
 
+	"github.com/inx51/howlite-resources/configuration"
 	"github.com/inx51/howlite-resources/logger"
 	"github.com/inx51/howlite-resources/meter"
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/exporters/autoexport"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/sdk/metric"
 )
 
 var meterProvider *metric.MeterProvider
+var metricsHandler http.Handler
 
-func newMeterProvider(ctx context.Context) (*metric.MeterProvider, error) {
-	otlpMetricReader, err := autoexport.NewMetricReader(ctx)
-	if err != nil {
-		logger.Warn(ctx, "Failed to create metrics exporter for OpenTelemetry, skipping OpenTelemetry metrics", "error", err)
-		return nil, err
+// newMeterProvider adds an OTLP reader if OpenTelemetry is enabled and a
+// Prometheus reader if the Prometheus endpoint is enabled, a reader that fails
+// to be created is skipped.
+func newMeterProvider(ctx context.Context, otlpEnabled bool, configuration *configuration.Metrics) *metric.MeterProvider {
+	var readers []metric.Option
+
+	if otlpEnabled {
+		otlpMetricReader, err := autoexport.NewMetricReader(ctx)
+		if err != nil {
+			logger.Warn(ctx, "Failed to create metrics exporter for OpenTelemetry, skipping OpenTelemetry metrics", "error", err)
+		} else {
+			readers = append(readers, metric.WithReader(otlpMetricReader))
+		}
+	}
+
+	if configuration.PROMETHEUS_ENABLED {
+		registry := prometheusclient.NewRegistry()
+		prometheusReader, err := prometheus.New(prometheus.WithRegisterer(registry))
+		if err != nil {
+			logger.Warn(ctx, "Failed to create Prometheus exporter, skipping Prometheus metrics", "error", err)
+		} else {
+			readers = append(readers, metric.WithReader(prometheusReader))
+			metricsHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+		}
+	}
+
+	if len(readers) == 0 {
+		return nil
 	}
 
-	provider := metric.NewMeterProvider(
-		metric.WithReader(otlpMetricReader),
-	)
-	return provider, nil
+	return metric.NewMeterProvider(readers...)
 }
 
-func SetupMetric(ctx context.Context) {
-	var err error
-	meterProvider, err = newMeterProvider(ctx)
-	if err != nil {
+func SetupMetric(ctx context.Context, otlpEnabled bool, configuration *configuration.Metrics) {
+	meterProvider = newMeterProvider(ctx, otlpEnabled, configuration)
+	if meterProvider == nil {
 		return
 	}
 
 	otel.SetMeterProvider(meterProvider)
-	meter.SetupMeter(meterProvider != nil)
+	meter.SetupMeter(true)
+}
+
+// MetricsHandler returns the handler serving the metrics in the Prometheus
+// text format, or nil if the Prometheus endpoint is disabled.
+func MetricsHandler() http.Handler {
+	return metricsHandler
 }
 
 func ShutdownMetrics(ctx context.Context) {
@@ -3,6 +3,7 @@ package tracer
 import (
 	"context"
 	"strings"
+	"sync/atomic"
 
 	"github.com/inx51/howlite-resources/configuration"
 	"go.opentelemetry.io/otel"
@@ -11,7 +12,9 @@ import (
 )
 
 var tracer trace.Tracer
-var level int = -1
+
+// level is changed by SetLevel while spans are started, 0 is debug and 1 info.
+var level atomic.Int32
 var enabled bool = false
 
 func SetupTracer(configuration *configuration.Tracing, enable bool) {
@@ -22,7 +25,7 @@ func SetupTracer(configuration *configuration.Tracing, enable bool) {
 	}
 
 	tracer = otel.Tracer("howlite-resources")
-	setLevel(configuration.LEVEL)
+	SetLevel(configuration.LEVEL)
 }
 
 func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
@@ -38,7 +41,7 @@ func StartInfoSpan(ctx context.Context, name string, opts ...trace.SpanStartOpti
 		return ctx, nil
 	}
 
-	if level <= 1 {
+	if level.Load() <= 1 {
 		return StartSpan(ctx, name, opts...)
 	}
 	return ctx, nil
@@ -49,7 +52,7 @@ func StartDebugSpan(ctx context.Context, name string, opts ...trace.SpanStartOpt
 		return ctx, nil
 	}
 
-	if level == 0 {
+	if level.Load() == 0 {
 		return StartSpan(ctx, name, opts...)
 	}
 	return ctx, nil
@@ -75,23 +78,15 @@ func SafeRecordError(span trace.Span, err error) {
 	}
 }
 
-func setLevel(traceLevel string) int {
-	if traceLevel == "" {
-		traceLevel = "info"
-	} else {
-		traceLevel = strings.ToLower(traceLevel)
-	}
-
-	switch traceLevel {
+// SetLevel changes the level of the spans that are started, debug or info.
+// Unrecognized levels fall back to info.
+func SetLevel(traceLevel string) {
+	switch strings.ToLower(traceLevel) {
 	case "debug":
-		level = 0
-	case "info":
-		level = 1
+		level.Store(0)
 	default:
-		level = 1
+		level.Store(1)
 	}
-
-	return level
 }
 
 func SetDebugAttributes(ctx context.Context, span trace.Span, kv ...attribute.KeyValue) {
@@ -99,7 +94,7 @@ func SetDebugAttributes(ctx context.Context, span trace.Span, kv ...attribute.Ke
 		return
 	}
 
-	if level == 0 {
+	if level.Load() == 0 {
 		span.SetAttributes(kv...)
 	}
 }
@@ -109,7 +104,7 @@ func SetInfoAttributes(ctx context.Context, span trace.Span, kv ...attribute.Key
 		return
 	}
 
-	if level <= 1 {
+	if level.Load() <= 1 {
 		span.SetAttributes(kv...)
 	}
 }
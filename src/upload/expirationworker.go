@@ -0,0 +1,43 @@
+package upload
+
+import (
+	"context"
+	"time"
+
+	"github.com/inx51/howlite-resources/logger"
+)
+
+type ExpirationWorker struct {
+	store  *Store
+	ticker *time.Ticker
+}
+
+func NewExpirationWorker(ctx context.Context, store *Store, interval time.Duration) ExpirationWorker {
+	return ExpirationWorker{
+		store:  store,
+		ticker: time.NewTicker(interval),
+	}
+}
+
+func (worker *ExpirationWorker) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(ctx, "Upload expiration worker stopped")
+			return
+		case <-worker.ticker.C:
+			removed, err := worker.store.RemoveExpired(ctx)
+			if err != nil {
+				logger.Error(ctx, "Failed to remove expired uploads", "error", err)
+				continue
+			}
+			if removed > 0 {
+				logger.Info(ctx, "Removed expired uploads", "count", removed)
+			}
+		}
+	}
+}
+
+func (worker *ExpirationWorker) Stop(ctx context.Context) {
+	worker.ticker.Stop()
+}
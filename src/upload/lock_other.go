@@ -0,0 +1,11 @@
+//go:build !unix
+
+package upload
+
+import "os"
+
+// tryLockExclusive is a no-op where advisory file locks aren't available, the
+// staging path is then not guarded against other instances.
+func tryLockExclusive(file *os.File) error {
+	return nil
+}
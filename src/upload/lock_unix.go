@@ -0,0 +1,20 @@
+//go:build unix
+
+package upload
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockExclusive locks the file without waiting, it fails with
+// ErrStagingPathInUse if another process holds the lock. The lock is released
+// when the file is closed.
+func tryLockExclusive(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrStagingPathInUse
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package upload
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+)
+
+// ParseMetadata parses a tus Upload-Metadata header, which is a comma
+// separated list of keys and optional base64 encoded values.
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encodedValue, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, ErrInvalidMetadata
+		}
+		if _, exists := metadata[key]; exists {
+			return nil, ErrInvalidMetadata
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedValue))
+		if err != nil {
+			return nil, ErrInvalidMetadata
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+func FormatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if metadata[key] == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,71 @@
+//go:build unit
+
+package upload_test
+
+import (
+	"testing"
+
+	"github.com/inx51/howlite-resources/upload"
+)
+
+func TestParseMetadataShouldDecodeValues(t *testing.T) {
+	metadata, err := upload.ParseMetadata("identifier L2RvY3MvYS50eHQ=,contentType dGV4dC9wbGFpbg==,empty")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if metadata["identifier"] != "/docs/a.txt" {
+		t.Fatalf("Expected identifier '/docs/a.txt', got %s", metadata["identifier"])
+	}
+	if metadata["contentType"] != "text/plain" {
+		t.Fatalf("Expected contentType 'text/plain', got %s", metadata["contentType"])
+	}
+	if value, exists := metadata["empty"]; !exists || value != "" {
+		t.Fatal("Expected key without value to be present and empty")
+	}
+}
+
+func TestParseMetadataShouldReturnEmptyForEmptyHeader(t *testing.T) {
+	metadata, err := upload.ParseMetadata("")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(metadata) != 0 {
+		t.Fatalf("Expected no metadata, got %v", metadata)
+	}
+}
+
+func TestParseMetadataShouldRejectInvalidValues(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+	}{
+		{"invalid base64", "identifier not-base64!"},
+		{"duplicate key", "a YQ==,a Yg=="},
+		{"empty key", ",a YQ=="},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := upload.ParseMetadata(tc.header)
+
+			if err != upload.ErrInvalidMetadata {
+				t.Fatalf("Expected ErrInvalidMetadata, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFormatMetadataShouldRoundTrip(t *testing.T) {
+	metadata := map[string]string{"identifier": "/docs/a.txt", "empty": ""}
+
+	parsed, err := upload.ParseMetadata(upload.FormatMetadata(metadata))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if parsed["identifier"] != "/docs/a.txt" || parsed["empty"] != "" || len(parsed) != 2 {
+		t.Fatalf("Expected metadata to round trip, got %v", parsed)
+	}
+}
@@ -0,0 +1,412 @@
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inx51/howlite-resources/logger"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Store stages the bytes of resumable uploads on the local filesystem. Each
+// upload is kept as a data file and an info file holding its Upload.
+//
+// Uploads can only be resumed by the instance that created them. The store
+// locks its staging path so that no other instance can share it, and the ids
+// of its uploads start with the id of the instance, so that requests routed to
+// another instance fail with ErrUploadMisdirected instead of ErrUploadNotFound.
+type Store struct {
+	path       string
+	expiration time.Duration
+	instance   string
+	lockFile   *os.File
+	mutex      sync.Mutex
+	locked     map[string]struct{}
+}
+
+type uploadReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// chunkReader keeps the error of reading the chunk of a request, to tell it
+// apart from failing to write the staged bytes.
+type chunkReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (reader *chunkReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if err != nil && err != io.EOF {
+		reader.err = err
+	}
+	return n, err
+}
+
+// NewStore creates the staging directory at path if it doesn't exist yet and
+// locks it, it fails with ErrStagingPathInUse if another instance holds it.
+func NewStore(path string, expiration time.Duration) (*Store, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := os.OpenFile(filepath.Join(path, ".lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err = tryLockExclusive(lockFile); err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+
+	instance, err := loadInstanceId(path)
+	if err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+
+	return &Store{
+		path:       path,
+		expiration: expiration,
+		instance:   instance,
+		lockFile:   lockFile,
+		locked:     make(map[string]struct{}),
+	}, nil
+}
+
+// Close releases the lock of the staging path.
+func (store *Store) Close() error {
+	return store.lockFile.Close()
+}
+
+func (store *Store) Create(ctx context.Context, identifier string, length int64, metadata map[string]string) (*Upload, error) {
+	id, err := store.newUploadId()
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &Upload{
+		ID:         id,
+		Identifier: identifier,
+		Length:     length,
+		Metadata:   metadata,
+		ExpiresUtc: time.Now().UTC().Add(store.expiration),
+	}
+
+	file, err := os.Create(store.dataPath(id))
+	if err != nil {
+		logger.Error(ctx, "failed to create upload data file", "upload.id", id, "error", err)
+		return nil, err
+	}
+	file.Close()
+
+	err = store.saveInfo(upload)
+	if err != nil {
+		logger.Error(ctx, "failed to save upload info", "upload.id", id, "error", err)
+		os.Remove(store.dataPath(id))
+		return nil, err
+	}
+
+	logger.Debug(ctx, "successfully created upload", "upload.id", id, "resource.identifier", identifier, "upload.length", length)
+	return upload, nil
+}
+
+func (store *Store) Get(ctx context.Context, id string) (*Upload, error) {
+	if err := store.checkUploadId(id); err != nil {
+		return nil, err
+	}
+
+	upload, err := store.loadInfo(id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Debug(ctx, "upload not found", "upload.id", id)
+			return nil, ErrUploadNotFound
+		}
+		logger.Error(ctx, "failed to load upload info", "upload.id", id, "error", err)
+		return nil, err
+	}
+
+	if upload.IsExpired(time.Now().UTC()) {
+		logger.Debug(ctx, "upload expired", "upload.id", id, "upload.expires_utc", upload.ExpiresUtc)
+		return nil, ErrUploadExpired
+	}
+
+	return upload, nil
+}
+
+// Append writes the bytes read from reader to the upload, starting at offset,
+// until the reader is exhausted or the upload is complete. The offset is
+// advanced by the bytes written even if reading fails part way through, so
+// that the client can resume from there, and ErrUploadInterrupted is returned.
+func (store *Store) Append(ctx context.Context, id string, offset int64, reader io.Reader) (*Upload, error) {
+	upload, err := store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.Offset != offset {
+		logger.Debug(ctx, "upload offset mismatch", "upload.id", id, "upload.offset", upload.Offset, "request.offset", offset)
+		return upload, ErrOffsetMismatch
+	}
+
+	file, err := os.OpenFile(store.dataPath(id), os.O_WRONLY, 0)
+	if err != nil {
+		logger.Error(ctx, "failed to open upload data file", "upload.id", id, "error", err)
+		return nil, err
+	}
+
+	// Bytes beyond the recorded offset were written by a request that failed
+	// before the offset could be saved, they are dropped.
+	err = file.Truncate(offset)
+	if err == nil {
+		_, err = file.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		logger.Error(ctx, "failed to prepare upload data file", "upload.id", id, "error", err)
+		return nil, err
+	}
+
+	chunk := &chunkReader{reader: io.LimitReader(reader, upload.Length-upload.Offset)}
+	written, copyErr := io.Copy(file, chunk)
+	closeErr := file.Close()
+
+	upload.Offset += written
+	upload.ExpiresUtc = time.Now().UTC().Add(store.expiration)
+	err = store.saveInfo(upload)
+	if err != nil {
+		logger.Error(ctx, "failed to save upload info", "upload.id", id, "error", err)
+		return nil, err
+	}
+
+	if chunk.err != nil && closeErr == nil {
+		logger.Debug(ctx, "upload chunk interrupted", "upload.id", id, "upload.offset", upload.Offset, "error", chunk.err)
+		return upload, errors.Join(ErrUploadInterrupted, chunk.err)
+	}
+	if err = errors.Join(copyErr, closeErr); err != nil {
+		logger.Error(ctx, "failed to write upload data", "upload.id", id, "upload.offset", upload.Offset, "error", err)
+		return upload, err
+	}
+
+	logger.Debug(ctx, "successfully appended to upload", "upload.id", id, "upload.offset", upload.Offset, "upload.length", upload.Length)
+	return upload, nil
+}
+
+// Rewind moves the offset of the upload back to offset, dropping the bytes
+// appended since, such as those of a chunk that turned out to be invalid.
+func (store *Store) Rewind(ctx context.Context, id string, offset int64) (*Upload, error) {
+	upload, err := store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	upload.Offset = min(upload.Offset, offset)
+	if err := store.saveInfo(upload); err != nil {
+		logger.Error(ctx, "failed to save upload info", "upload.id", id, "error", err)
+		return nil, err
+	}
+
+	logger.Debug(ctx, "successfully rewound upload", "upload.id", id, "upload.offset", upload.Offset)
+	return upload, nil
+}
+
+// Open returns a reader over the staged bytes of the upload.
+func (store *Store) Open(ctx context.Context, upload *Upload) (io.ReadCloser, error) {
+	file, err := os.Open(store.dataPath(upload.ID))
+	if err != nil {
+		logger.Error(ctx, "failed to open upload data file", "upload.id", upload.ID, "error", err)
+		return nil, err
+	}
+
+	return &uploadReadCloser{
+		Reader: io.LimitReader(file, upload.Offset),
+		Closer: file,
+	}, nil
+}
+
+func (store *Store) Remove(ctx context.Context, id string) error {
+	if err := store.checkUploadId(id); err != nil {
+		return err
+	}
+
+	err := os.Remove(store.infoPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrUploadNotFound
+		}
+		logger.Error(ctx, "failed to remove upload info file", "upload.id", id, "error", err)
+		return err
+	}
+
+	err = os.Remove(store.dataPath(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error(ctx, "failed to remove upload data file", "upload.id", id, "error", err)
+		return err
+	}
+
+	logger.Debug(ctx, "successfully removed upload", "upload.id", id)
+	return nil
+}
+
+// RemoveExpired removes all expired uploads that are not currently locked and
+// returns how many were removed.
+func (store *Store) RemoveExpired(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(store.path)
+	if err != nil {
+		logger.Error(ctx, "failed to read upload staging directory", "upload.path", store.path, "error", err)
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	removed := 0
+	for _, entry := range entries {
+		id, isInfo := strings.CutSuffix(entry.Name(), ".info")
+		if entry.IsDir() || !isInfo || !isValidUploadId(id) {
+			continue
+		}
+
+		upload, err := store.loadInfo(id)
+		if err != nil || !upload.IsExpired(now) {
+			continue
+		}
+
+		unlock, err := store.Lock(id)
+		if err != nil {
+			continue
+		}
+		err = store.Remove(ctx, id)
+		unlock()
+		if err == nil {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// Lock marks the upload as being in use by a request, it fails with
+// ErrUploadLocked if another request is already using it.
+func (store *Store) Lock(id string) (func(), error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, locked := store.locked[id]; locked {
+		return nil, ErrUploadLocked
+	}
+	store.locked[id] = struct{}{}
+
+	return func() {
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		delete(store.locked, id)
+	}, nil
+}
+
+func (store *Store) saveInfo(upload *Upload) error {
+	info, err := msgpack.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	temporaryPath := store.infoPath(upload.ID) + ".tmp"
+	err = os.WriteFile(temporaryPath, info, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, store.infoPath(upload.ID))
+}
+
+func (store *Store) loadInfo(id string) (*Upload, error) {
+	info, err := os.ReadFile(store.infoPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var upload Upload
+	err = msgpack.Unmarshal(info, &upload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+func (store *Store) dataPath(id string) string {
+	return filepath.Join(store.path, id+".bin")
+}
+
+func (store *Store) infoPath(id string) string {
+	return filepath.Join(store.path, id+".info")
+}
+
+// newUploadId returns the id of the instance followed by 8 random bytes.
+func (store *Store) newUploadId() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return store.instance + hex.EncodeToString(id), nil
+}
+
+func (store *Store) checkUploadId(id string) error {
+	if !isValidUploadId(id) {
+		return ErrUploadNotFound
+	}
+	if !strings.HasPrefix(id, store.instance) {
+		return ErrUploadMisdirected
+	}
+
+	return nil
+}
+
+// loadInstanceId reads the id of the instance from the staging path, it is
+// created on first use so that uploads can still be resumed after a restart.
+func loadInstanceId(path string) (string, error) {
+	instancePath := filepath.Join(path, "instance")
+	instance, err := os.ReadFile(instancePath)
+	if err == nil && isValidInstanceId(string(instance)) {
+		return string(instance), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(instancePath, []byte(hex.EncodeToString(id)), 0o644); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+func isValidInstanceId(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func isValidUploadId(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
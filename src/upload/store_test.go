@@ -0,0 +1,205 @@
+//go:build unit
+
+package upload_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inx51/howlite-resources/upload"
+)
+
+func TestAppendShouldAdvanceOffset(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, time.Hour)
+	created, _ := store.Create(ctx, "/docs/a.txt", 11, nil)
+
+	appended, err := store.Append(ctx, created.ID, 0, strings.NewReader("hello "))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if appended.Offset != 6 {
+		t.Fatalf("Expected offset 6, got %d", appended.Offset)
+	}
+	if appended.IsComplete() {
+		t.Fatal("Expected upload to be incomplete")
+	}
+}
+
+func TestAppendShouldCompleteUpload(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, time.Hour)
+	created, _ := store.Create(ctx, "/docs/a.txt", 11, nil)
+	store.Append(ctx, created.ID, 0, strings.NewReader("hello "))
+
+	appended, err := store.Append(ctx, created.ID, 6, strings.NewReader("world and more"))
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !appended.IsComplete() {
+		t.Fatalf("Expected upload to be complete, offset is %d", appended.Offset)
+	}
+	reader, _ := store.Open(ctx, appended)
+	defer reader.Close()
+	content, _ := io.ReadAll(reader)
+	if string(content) != "hello world" {
+		t.Fatalf("Expected 'hello world', got %s", content)
+	}
+}
+
+func TestAppendShouldRejectOffsetMismatch(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, time.Hour)
+	created, _ := store.Create(ctx, "/docs/a.txt", 11, nil)
+
+	_, err := store.Append(ctx, created.ID, 3, strings.NewReader("hello"))
+
+	if !errors.Is(err, upload.ErrOffsetMismatch) {
+		t.Fatalf("Expected ErrOffsetMismatch, got %v", err)
+	}
+}
+
+func TestAppendShouldKeepBytesReceivedBeforeFailure(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, time.Hour)
+	created, _ := store.Create(ctx, "/docs/a.txt", 11, nil)
+	reader := io.MultiReader(strings.NewReader("hello"), &failingReader{})
+
+	appended, err := store.Append(ctx, created.ID, 0, reader)
+
+	if !errors.Is(err, upload.ErrUploadInterrupted) {
+		t.Fatalf("Expected ErrUploadInterrupted, got %v", err)
+	}
+	if appended.Offset != 5 {
+		t.Fatalf("Expected offset 5, got %d", appended.Offset)
+	}
+}
+
+func TestGetShouldReturnNotFoundForUnknownUpload(t *testing.T) {
+	store := newStore(t, time.Hour)
+
+	_, err := store.Get(context.Background(), "../../etc/passwd")
+
+	if !errors.Is(err, upload.ErrUploadNotFound) {
+		t.Fatalf("Expected ErrUploadNotFound, got %v", err)
+	}
+}
+
+func TestGetShouldReturnExpiredForExpiredUpload(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, -time.Second)
+	created, _ := store.Create(ctx, "/docs/a.txt", 11, nil)
+
+	_, err := store.Get(ctx, created.ID)
+
+	if !errors.Is(err, upload.ErrUploadExpired) {
+		t.Fatalf("Expected ErrUploadExpired, got %v", err)
+	}
+}
+
+func TestRemoveExpiredShouldRemoveExpiredUploads(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, -time.Second)
+	created, _ := store.Create(ctx, "/docs/a.txt", 11, nil)
+
+	removed, err := store.RemoveExpired(ctx)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Expected 1 removed upload, got %d", removed)
+	}
+	if err := store.Remove(ctx, created.ID); !errors.Is(err, upload.ErrUploadNotFound) {
+		t.Fatalf("Expected upload to be removed, got %v", err)
+	}
+}
+
+func TestLockShouldRejectConcurrentLock(t *testing.T) {
+	store := newStore(t, time.Hour)
+	unlock, _ := store.Lock("upload")
+
+	_, err := store.Lock("upload")
+	unlock()
+	_, errAfterUnlock := store.Lock("upload")
+
+	if !errors.Is(err, upload.ErrUploadLocked) {
+		t.Fatalf("Expected ErrUploadLocked, got %v", err)
+	}
+	if errAfterUnlock != nil {
+		t.Fatalf("Expected lock to succeed after unlock, got %v", errAfterUnlock)
+	}
+}
+
+func TestNewStoreShouldReturnErrorWhenPathIsAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(path, nil, 0o644)
+
+	_, err := upload.NewStore(path, time.Hour)
+
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+}
+
+func TestNewStoreShouldRejectStagingPathOfAnotherStore(t *testing.T) {
+	path := t.TempDir()
+	store, _ := upload.NewStore(path, time.Hour)
+	defer store.Close()
+
+	_, err := upload.NewStore(path, time.Hour)
+
+	if !errors.Is(err, upload.ErrStagingPathInUse) {
+		t.Fatalf("Expected ErrStagingPathInUse, got %v", err)
+	}
+}
+
+func TestNewStoreShouldKeepUploadsAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir()
+	store, _ := upload.NewStore(path, time.Hour)
+	created, _ := store.Create(ctx, "/docs/a.txt", 11, nil)
+	store.Close()
+	restarted, _ := upload.NewStore(path, time.Hour)
+	defer restarted.Close()
+
+	_, err := restarted.Get(ctx, created.ID)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestGetShouldReturnMisdirectedForUploadOfAnotherStore(t *testing.T) {
+	ctx := context.Background()
+	created, _ := newStore(t, time.Hour).Create(ctx, "/docs/a.txt", 11, nil)
+
+	_, err := newStore(t, time.Hour).Get(ctx, created.ID)
+
+	if !errors.Is(err, upload.ErrUploadMisdirected) {
+		t.Fatalf("Expected ErrUploadMisdirected, got %v", err)
+	}
+}
+
+func newStore(t *testing.T, expiration time.Duration) *upload.Store {
+	t.Helper()
+	store, err := upload.NewStore(t.TempDir(), expiration)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return store
+}
+
+type failingReader struct{}
+
+func (reader *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
@@ -0,0 +1,41 @@
+package upload
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUploadNotFound  = errors.New("upload not found")
+	ErrUploadExpired   = errors.New("upload expired")
+	ErrUploadLocked    = errors.New("upload is locked by another request")
+	ErrOffsetMismatch  = errors.New("upload offset mismatch")
+	ErrInvalidMetadata = errors.New("invalid upload metadata")
+	// ErrUploadInterrupted is returned when the chunk of a request could not be
+	// read to the end, such as when the client disconnected. The bytes read
+	// before are kept.
+	ErrUploadInterrupted = errors.New("upload chunk interrupted")
+	// ErrUploadMisdirected is returned for uploads created by another instance,
+	// only that instance holds their staged bytes.
+	ErrUploadMisdirected = errors.New("upload was created by another instance")
+	ErrStagingPathInUse  = errors.New("upload staging path is used by another instance")
+)
+
+// Upload describes a resumable upload that is staged until all of its bytes
+// have been received, after which it is finalized into the storage provider.
+type Upload struct {
+	ID         string            `msgpack:"id"`
+	Identifier string            `msgpack:"identifier"`
+	Length     int64             `msgpack:"length"`
+	Offset     int64             `msgpack:"offset"`
+	Metadata   map[string]string `msgpack:"metadata"`
+	ExpiresUtc time.Time         `msgpack:"expires_utc"`
+}
+
+func (upload *Upload) IsComplete() bool {
+	return upload.Offset == upload.Length
+}
+
+func (upload *Upload) IsExpired(now time.Time) bool {
+	return !upload.ExpiresUtc.IsZero() && now.After(upload.ExpiresUtc)
+}